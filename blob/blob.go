@@ -0,0 +1,82 @@
+// Package blob хранит содержимое загруженных файлов (вложения сообщений,
+// аватары чатов) вне процесса сервиса: в локальном каталоге (LocalStore)
+// или в S3-совместимом хранилище (S3Store). Несколько реплик сервиса
+// с общим S3-хранилищем видят одни и те же файлы.
+package blob
+
+import (
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"path"
+	"strings"
+	"time"
+)
+
+// Максимальная длина ключа объекта (в байтах), как у S3
+const maxKeyLength = 1024
+
+// Тип содержимого объекта, если он не указан и не определяется по расширению
+const defaultContentType = "application/octet-stream"
+
+var (
+	ErrNotFound      = errors.New("объект не найден")
+	ErrInvalidKey    = errors.New("некорректный ключ объекта")
+	ErrInvalidOffset = errors.New("позиция чтения за пределами объекта")
+)
+
+// Info - сведения о сохранённом объекте
+type Info struct {
+	Key         string
+	Size        int64
+	ContentType string
+	ETag        string // непрозрачный идентификатор версии содержимого в кавычках
+	ModTime     time.Time
+}
+
+// Store - хранилище содержимого файлов. Ключ объекта - путь из сегментов,
+// разделённых «/», например "files/65f1c2.png".
+type Store interface {
+	// Put сохраняет объект под ключом, заменяя существующий. size - размер
+	// содержимого в байтах или -1, если он заранее неизвестен.
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Get открывает объект для чтения с позиции offset (0 <= offset <= размер объекта).
+	// Вызывающий закрывает возвращённый поток.
+	Get(ctx context.Context, key string, offset int64) (io.ReadCloser, *Info, error)
+	// Stat возвращает сведения об объекте без его содержимого
+	Stat(ctx context.Context, key string) (*Info, error)
+	// Delete удаляет объект. Удаление отсутствующего объекта не является ошибкой.
+	Delete(ctx context.Context, key string) error
+}
+
+// ValidateKey проверяет ключ объекта: непустые сегменты без «.» в начале,
+// без обратной косой черты и управляющих символов
+func ValidateKey(key string) error {
+	if key == "" || len(key) > maxKeyLength {
+		return ErrInvalidKey
+	}
+	for _, segment := range strings.Split(key, "/") {
+		if segment == "" || strings.HasPrefix(segment, ".") {
+			return ErrInvalidKey
+		}
+	}
+	for _, r := range key {
+		if r < ' ' || r == 0x7f || r == '\\' {
+			return ErrInvalidKey
+		}
+	}
+	return nil
+}
+
+// contentTypeOf возвращает тип содержимого объекта: указанный при сохранении,
+// иначе определённый по расширению ключа
+func contentTypeOf(key string, contentType string) string {
+	if contentType != "" {
+		return contentType
+	}
+	if byExt := mime.TypeByExtension(path.Ext(key)); byExt != "" {
+		return byExt
+	}
+	return defaultContentType
+}
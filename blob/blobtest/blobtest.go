@@ -0,0 +1,311 @@
+// Package blobtest содержит набор тестов, проверяющий, что реализация
+// blob.Store ведёт себя так же, как LocalStore.
+//
+// Проверка S3Store на локальном MinIO
+// (docker run -p 9000:9000 minio/minio server /data):
+//
+//	func TestS3Store(t *testing.T) {
+//		blobtest.Run(t, func(t *testing.T) blob.Store {
+//			store, err := blob.NewS3Store(blob.S3Config{
+//				Endpoint:  "http://localhost:9000",
+//				Bucket:    "chat-test",
+//				AccessKey: "minioadmin",
+//				SecretKey: "minioadmin",
+//				PathStyle: true,
+//			})
+//			if err != nil {
+//				t.Fatal(err)
+//			}
+//			if err := store.EnsureBucket(context.Background()); err != nil {
+//				t.Skipf("MinIO недоступен: %v", err)
+//			}
+//			return store
+//		})
+//	}
+//
+// Тесты используют ключи с уникальным префиксом, поэтому хранилище
+// может быть общим для всех тестов.
+package blobtest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"chat-service/blob"
+)
+
+// Factory создаёт хранилище для очередного теста. Освобождение ресурсов
+// можно зарегистрировать через t.Cleanup.
+type Factory func(t *testing.T) blob.Store
+
+// Run запускает все проверки контракта blob.Store
+func Run(t *testing.T, newStore Factory) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, s blob.Store, prefix string)
+	}{
+		{"PutGet", testPutGet},
+		{"UnknownSize", testUnknownSize},
+		{"EmptyObject", testEmptyObject},
+		{"Overwrite", testOverwrite},
+		{"Stat", testStat},
+		{"Offset", testOffset},
+		{"Reader", testReader},
+		{"Delete", testDelete},
+		{"NotFound", testNotFound},
+		{"InvalidKeys", testInvalidKeys},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prefix := "blobtest/" + strconv.FormatInt(time.Now().UnixNano(), 36) + "/" + tt.name
+			tt.fn(t, newStore(t), prefix)
+		})
+	}
+}
+
+func testPutGet(t *testing.T, s blob.Store, prefix string) {
+	key := prefix + "/files/привет мир.txt"
+	put(t, s, key, "содержимое файла")
+
+	if got := get(t, s, key); got != "содержимое файла" {
+		t.Fatalf("Get(%s) = %q, ожидалось %q", key, got, "содержимое файла")
+	}
+}
+
+func testUnknownSize(t *testing.T, s blob.Store, prefix string) {
+	key := prefix + "/unknown.bin"
+	content := strings.Repeat("0123456789", 1000)
+
+	// Поток без известной длины
+	r := io.MultiReader(strings.NewReader(content[:5000]), strings.NewReader(content[5000:]))
+	if err := s.Put(context.Background(), key, r, -1, "application/octet-stream"); err != nil {
+		t.Fatalf("Put(%s, -1): %v", key, err)
+	}
+
+	if got := get(t, s, key); got != content {
+		t.Fatalf("Get(%s): получено %d байт, ожидалось %d", key, len(got), len(content))
+	}
+	if info := stat(t, s, key); info.Size != int64(len(content)) {
+		t.Fatalf("Stat(%s).Size = %d, ожидалось %d", key, info.Size, len(content))
+	}
+}
+
+func testEmptyObject(t *testing.T, s blob.Store, prefix string) {
+	key := prefix + "/empty.txt"
+	put(t, s, key, "")
+
+	if got := get(t, s, key); got != "" {
+		t.Fatalf("Get(%s) = %q, ожидался пустой объект", key, got)
+	}
+	if info := stat(t, s, key); info.Size != 0 {
+		t.Fatalf("Stat(%s).Size = %d, ожидалось 0", key, info.Size)
+	}
+}
+
+func testOverwrite(t *testing.T, s blob.Store, prefix string) {
+	key := prefix + "/avatar.png"
+	put(t, s, key, "первая версия")
+	first := stat(t, s, key)
+
+	put(t, s, key, "вторая, более длинная версия")
+
+	if got := get(t, s, key); got != "вторая, более длинная версия" {
+		t.Fatalf("Get(%s) после перезаписи = %q", key, got)
+	}
+	if second := stat(t, s, key); second.ETag == first.ETag {
+		t.Fatalf("ETag не изменился после перезаписи: %s", second.ETag)
+	}
+}
+
+func testStat(t *testing.T, s blob.Store, prefix string) {
+	key := prefix + "/notes.txt"
+	before := time.Now().Add(-time.Minute)
+	put(t, s, key, "12345")
+
+	info := stat(t, s, key)
+	if info.Key != key || info.Size != 5 {
+		t.Fatalf("Stat(%s) = {Key: %s, Size: %d}, ожидалось {Key: %s, Size: 5}", key, info.Key, info.Size, key)
+	}
+	if !strings.HasPrefix(info.ContentType, "text/plain") {
+		t.Fatalf("Stat(%s).ContentType = %q, ожидался text/plain", key, info.ContentType)
+	}
+	if info.ETag == "" {
+		t.Fatalf("Stat(%s).ETag пуст", key)
+	}
+	if info.ModTime.Before(before) {
+		t.Fatalf("Stat(%s).ModTime = %v, ожидалось не раньше %v", key, info.ModTime, before)
+	}
+
+	// Get возвращает те же сведения
+	rc, getInfo, err := s.Get(context.Background(), key, 0)
+	if err != nil {
+		t.Fatalf("Get(%s): %v", key, err)
+	}
+	rc.Close()
+	if getInfo.Size != info.Size || getInfo.ETag != info.ETag || getInfo.ContentType != info.ContentType {
+		t.Fatalf("Get(%s) вернул сведения %+v, Stat - %+v", key, getInfo, info)
+	}
+}
+
+func testOffset(t *testing.T, s blob.Store, prefix string) {
+	key := prefix + "/digits.txt"
+	put(t, s, key, "0123456789")
+
+	for _, c := range []struct {
+		offset int64
+		want   string
+	}{
+		{0, "0123456789"},
+		{4, "456789"},
+		{9, "9"},
+		{10, ""},
+	} {
+		rc, info, err := s.Get(context.Background(), key, c.offset)
+		if err != nil {
+			t.Fatalf("Get(%s, %d): %v", key, c.offset, err)
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil || string(data) != c.want {
+			t.Fatalf("Get(%s, %d) = %q, %v, ожидалось %q", key, c.offset, data, err, c.want)
+		}
+		// Размер - всего объекта, а не прочитанной части
+		if info.Size != 10 {
+			t.Fatalf("Get(%s, %d): Size = %d, ожидалось 10", key, c.offset, info.Size)
+		}
+	}
+
+	for _, offset := range []int64{-1, 11} {
+		if _, _, err := s.Get(context.Background(), key, offset); !errors.Is(err, blob.ErrInvalidOffset) {
+			t.Fatalf("Get(%s, %d): ожидалась ErrInvalidOffset, получено %v", key, offset, err)
+		}
+	}
+}
+
+func testReader(t *testing.T, s blob.Store, prefix string) {
+	key := prefix + "/reader.txt"
+	put(t, s, key, "abcdefghij")
+
+	r := blob.NewReader(context.Background(), s, stat(t, s, key))
+	defer r.Close()
+
+	read := func(n int) string {
+		t.Helper()
+		buf := make([]byte, n)
+		n, err := io.ReadFull(r, buf)
+		if err != nil && err != io.ErrUnexpectedEOF {
+			t.Fatalf("Read: %v", err)
+		}
+		return string(buf[:n])
+	}
+	seek := func(offset int64, whence int, want int64) {
+		t.Helper()
+		if got, err := r.Seek(offset, whence); err != nil || got != want {
+			t.Fatalf("Seek(%d, %d) = %d, %v, ожидалось %d", offset, whence, got, err, want)
+		}
+	}
+
+	if got := read(3); got != "abc" {
+		t.Fatalf("чтение с начала: %q", got)
+	}
+	seek(0, io.SeekEnd, 10)
+	seek(-4, io.SeekCurrent, 6)
+	if got := read(2); got != "gh" {
+		t.Fatalf("чтение с позиции 6: %q", got)
+	}
+	seek(1, io.SeekStart, 1)
+	if got := read(20); got != "bcdefghij" {
+		t.Fatalf("чтение с позиции 1 до конца: %q", got)
+	}
+	if n, err := r.Read(make([]byte, 1)); n != 0 || err != io.EOF {
+		t.Fatalf("чтение в конце объекта: %d, %v, ожидался io.EOF", n, err)
+	}
+}
+
+func testDelete(t *testing.T, s blob.Store, prefix string) {
+	key := prefix + "/trash.txt"
+	put(t, s, key, "удалить")
+
+	if err := s.Delete(context.Background(), key); err != nil {
+		t.Fatalf("Delete(%s): %v", key, err)
+	}
+	if _, err := s.Stat(context.Background(), key); !errors.Is(err, blob.ErrNotFound) {
+		t.Fatalf("Stat(%s) после удаления: ожидалась ErrNotFound, получено %v", key, err)
+	}
+
+	// Повторное удаление не является ошибкой
+	if err := s.Delete(context.Background(), key); err != nil {
+		t.Fatalf("повторный Delete(%s): %v", key, err)
+	}
+}
+
+func testNotFound(t *testing.T, s blob.Store, prefix string) {
+	key := prefix + "/missing.txt"
+	if _, _, err := s.Get(context.Background(), key, 0); !errors.Is(err, blob.ErrNotFound) {
+		t.Fatalf("Get(%s): ожидалась ErrNotFound, получено %v", key, err)
+	}
+	if _, err := s.Stat(context.Background(), key); !errors.Is(err, blob.ErrNotFound) {
+		t.Fatalf("Stat(%s): ожидалась ErrNotFound, получено %v", key, err)
+	}
+
+	// Каталог из ключей с общим префиксом - не объект
+	put(t, s, prefix+"/dir/file.txt", "x")
+	if _, err := s.Stat(context.Background(), prefix+"/dir"); !errors.Is(err, blob.ErrNotFound) {
+		t.Fatalf("Stat(%s/dir): ожидалась ErrNotFound, получено %v", prefix, err)
+	}
+}
+
+func testInvalidKeys(t *testing.T, s blob.Store, prefix string) {
+	for _, key := range []string{"", "/absolute", "a//b", "../escape", prefix + "/../escape", prefix + "/.hidden", "trailing/", "back\\slash", "line\nbreak"} {
+		if err := s.Put(context.Background(), key, strings.NewReader("x"), 1, ""); !errors.Is(err, blob.ErrInvalidKey) {
+			t.Errorf("Put(%q): ожидалась ErrInvalidKey, получено %v", key, err)
+		}
+		if _, _, err := s.Get(context.Background(), key, 0); !errors.Is(err, blob.ErrInvalidKey) {
+			t.Errorf("Get(%q): ожидалась ErrInvalidKey, получено %v", key, err)
+		}
+		if _, err := s.Stat(context.Background(), key); !errors.Is(err, blob.ErrInvalidKey) {
+			t.Errorf("Stat(%q): ожидалась ErrInvalidKey, получено %v", key, err)
+		}
+		if err := s.Delete(context.Background(), key); !errors.Is(err, blob.ErrInvalidKey) {
+			t.Errorf("Delete(%q): ожидалась ErrInvalidKey, получено %v", key, err)
+		}
+	}
+}
+
+func put(t *testing.T, s blob.Store, key string, content string) {
+	t.Helper()
+	if err := s.Put(context.Background(), key, bytes.NewReader([]byte(content)), int64(len(content)), ""); err != nil {
+		t.Fatalf("Put(%s): %v", key, err)
+	}
+}
+
+func get(t *testing.T, s blob.Store, key string) string {
+	t.Helper()
+	rc, _, err := s.Get(context.Background(), key, 0)
+	if err != nil {
+		t.Fatalf("Get(%s): %v", key, err)
+	}
+	defer rc.Close()
+
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatalf("Get(%s): чтение: %v", key, err)
+	}
+	return string(data)
+}
+
+func stat(t *testing.T, s blob.Store, key string) *blob.Info {
+	t.Helper()
+	info, err := s.Stat(context.Background(), key)
+	if err != nil {
+		t.Fatalf("Stat(%s): %v", key, err)
+	}
+	return info
+}
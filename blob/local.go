@@ -0,0 +1,153 @@
+package blob
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Префикс временных файлов, в которые пишется содержимое до переименования.
+// Ключи не могут начинаться с «.», поэтому временные файлы не пересекаются с объектами.
+const localTempPattern = ".upload-*"
+
+// LocalStore хранит объекты файлами в локальном каталоге. Подходит для
+// разработки и единственной реплики сервиса с постоянным томом.
+// Тип содержимого не сохраняется и определяется по расширению ключа.
+type LocalStore struct {
+	root string
+}
+
+// NewLocalStore создаёт хранилище в каталоге root, создавая каталог при необходимости
+func NewLocalStore(root string) (*LocalStore, error) {
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, fmt.Errorf("ошибка создания каталога %s: %w", root, err)
+	}
+	return &LocalStore{root: root}, nil
+}
+
+// Put записывает содержимое во временный файл и переименовывает его,
+// поэтому читатели никогда не видят объект записанным частично
+func (s *LocalStore) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	name, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(name)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, localTempPattern)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	written, err := io.Copy(tmp, r)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if size >= 0 && written != size {
+		return fmt.Errorf("записано %d байт вместо %d", written, size)
+	}
+	return os.Rename(tmp.Name(), name)
+}
+
+// Get открывает файл объекта
+func (s *LocalStore) Get(ctx context.Context, key string, offset int64) (io.ReadCloser, *Info, error) {
+	name, err := s.path(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	if offset < 0 {
+		return nil, nil, ErrInvalidOffset
+	}
+
+	f, err := os.Open(name)
+	if os.IsNotExist(err) {
+		return nil, nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	if fi.IsDir() {
+		f.Close()
+		return nil, nil, ErrNotFound
+	}
+	if offset > fi.Size() {
+		f.Close()
+		return nil, nil, ErrInvalidOffset
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	return f, localInfo(key, fi), nil
+}
+
+// Stat возвращает сведения о файле объекта
+func (s *LocalStore) Stat(ctx context.Context, key string) (*Info, error) {
+	name, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	fi, err := os.Stat(name)
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if fi.IsDir() {
+		return nil, ErrNotFound
+	}
+	return localInfo(key, fi), nil
+}
+
+// Delete удаляет файл объекта
+func (s *LocalStore) Delete(ctx context.Context, key string) error {
+	name, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(name); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// path возвращает путь к файлу объекта внутри корневого каталога
+func (s *LocalStore) path(key string) (string, error) {
+	if err := ValidateKey(key); err != nil {
+		return "", err
+	}
+	return filepath.Join(s.root, filepath.FromSlash(key)), nil
+}
+
+// localInfo собирает сведения об объекте по файлу. ETag меняется при каждой
+// перезаписи: файл заменяется целиком, и время изменения обновляется.
+func localInfo(key string, fi os.FileInfo) *Info {
+	return &Info{
+		Key:         key,
+		Size:        fi.Size(),
+		ContentType: contentTypeOf(key, ""),
+		ETag:        fmt.Sprintf(`"%x-%x"`, fi.ModTime().UnixNano(), fi.Size()),
+		ModTime:     fi.ModTime(),
+	}
+}
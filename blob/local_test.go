@@ -0,0 +1,18 @@
+package blob_test
+
+import (
+	"testing"
+
+	"chat-service/blob"
+	"chat-service/blob/blobtest"
+)
+
+func TestLocalStore(t *testing.T) {
+	blobtest.Run(t, func(t *testing.T) blob.Store {
+		store, err := blob.NewLocalStore(t.TempDir())
+		if err != nil {
+			t.Fatalf("NewLocalStore: %v", err)
+		}
+		return store
+	})
+}
@@ -0,0 +1,76 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+// Reader читает объект хранилища с произвольной позиции, открывая поток
+// только при первом чтении после перемещения. Подходит для http.ServeContent:
+// запрос диапазона скачивает только нужную часть объекта.
+type Reader struct {
+	ctx    context.Context
+	store  Store
+	info   *Info
+	offset int64
+	body   io.ReadCloser
+}
+
+// NewReader создаёт Reader для объекта, сведения о котором получены через Stat
+func NewReader(ctx context.Context, store Store, info *Info) *Reader {
+	return &Reader{ctx: ctx, store: store, info: info}
+}
+
+// Info возвращает сведения о читаемом объекте
+func (r *Reader) Info() *Info {
+	return r.info
+}
+
+func (r *Reader) Read(p []byte) (int, error) {
+	if r.offset >= r.info.Size {
+		return 0, io.EOF
+	}
+	if r.body == nil {
+		body, _, err := r.store.Get(r.ctx, r.info.Key, r.offset)
+		if err != nil {
+			return 0, err
+		}
+		r.body = body
+	}
+
+	n, err := r.body.Read(p)
+	r.offset += int64(n)
+	return n, err
+}
+
+func (r *Reader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += r.info.Size
+	}
+	if offset < 0 {
+		return 0, errors.New("отрицательная позиция чтения")
+	}
+
+	if offset != r.offset {
+		r.closeBody()
+		r.offset = offset
+	}
+	return offset, nil
+}
+
+func (r *Reader) Close() error {
+	return r.closeBody()
+}
+
+func (r *Reader) closeBody() error {
+	if r.body == nil {
+		return nil
+	}
+	err := r.body.Close()
+	r.body = nil
+	return err
+}
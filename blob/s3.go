@@ -0,0 +1,381 @@
+package blob
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// S3Store работает с S3-совместимым хранилищем (AWS S3, MinIO и др.) через
+// REST API с подписью запросов AWS Signature Version 4. Тела запросов
+// не подписываются (UNSIGNED-PAYLOAD): целостность обеспечивает TLS.
+
+// Регион по умолчанию: MinIO принимает его, если регион не настроен
+const defaultS3Region = "us-east-1"
+
+// Хеш пустого тела запроса
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+const (
+	amzDateFormat   = "20060102T150405Z"
+	unsignedPayload = "UNSIGNED-PAYLOAD"
+)
+
+// S3Config - параметры подключения к S3-совместимому хранилищу
+type S3Config struct {
+	Endpoint  string // адрес API со схемой, например "http://minio:9000" или "https://s3.eu-central-1.amazonaws.com"
+	Region    string // по умолчанию us-east-1
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	// PathStyle - бакет в пути запроса (http://minio:9000/bucket/key), как требует
+	// MinIO. Иначе бакет указывается в имени хоста (http://bucket.s3.amazonaws.com/key).
+	PathStyle bool
+}
+
+// S3Store - хранилище объектов в бакете S3-совместимого сервиса
+type S3Store struct {
+	cfg      S3Config
+	endpoint *url.URL
+	client   *http.Client
+}
+
+// NewS3Store проверяет параметры подключения и создаёт хранилище.
+// Запросы к сервису не выполняются: доступность бакета проверяет EnsureBucket.
+func NewS3Store(cfg S3Config) (*S3Store, error) {
+	if cfg.Bucket == "" {
+		return nil, errors.New("не указан бакет S3")
+	}
+	if cfg.AccessKey == "" || cfg.SecretKey == "" {
+		return nil, errors.New("не указаны ключи доступа S3")
+	}
+	if cfg.Region == "" {
+		cfg.Region = defaultS3Region
+	}
+
+	endpoint, err := url.Parse(cfg.Endpoint)
+	if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+		return nil, fmt.Errorf("некорректный адрес S3: %q", cfg.Endpoint)
+	}
+	return &S3Store{cfg: cfg, endpoint: endpoint, client: &http.Client{}}, nil
+}
+
+// EnsureBucket создаёт бакет, если его ещё нет
+func (s *S3Store) EnsureBucket(ctx context.Context) error {
+	resp, err := s.do(ctx, http.MethodHead, s.bucketURL(), nil, 0, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		return nil
+	case resp.StatusCode != http.StatusNotFound:
+		return fmt.Errorf("бакет %s недоступен: %s", s.cfg.Bucket, resp.Status)
+	}
+
+	// В us-east-1 регион бакета не указывается
+	var body []byte
+	if s.cfg.Region != defaultS3Region {
+		body = []byte("<CreateBucketConfiguration><LocationConstraint>" + s.cfg.Region + "</LocationConstraint></CreateBucketConfiguration>")
+	}
+	resp, err = s.do(ctx, http.MethodPut, s.bucketURL(), bytes.NewReader(body), int64(len(body)), nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return s3Error(resp, "ошибка создания бакета "+s.cfg.Bucket)
+	}
+	return nil
+}
+
+// Put загружает объект одним запросом. Если размер неизвестен, содержимое
+// сначала записывается во временный файл: S3 требует размер тела заранее.
+func (s *S3Store) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	if err := ValidateKey(key); err != nil {
+		return err
+	}
+
+	if size < 0 {
+		spooled, err := spool(r)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			spooled.Close()
+			os.Remove(spooled.Name())
+		}()
+		fi, err := spooled.Stat()
+		if err != nil {
+			return err
+		}
+		r, size = spooled, fi.Size()
+	}
+
+	header := http.Header{"Content-Type": {contentTypeOf(key, contentType)}}
+	resp, err := s.do(ctx, http.MethodPut, s.objectURL(key), r, size, header)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return s3Error(resp, "ошибка загрузки объекта "+key)
+	}
+	return nil
+}
+
+// Get скачивает объект, начиная с позиции offset
+func (s *S3Store) Get(ctx context.Context, key string, offset int64) (io.ReadCloser, *Info, error) {
+	if err := ValidateKey(key); err != nil {
+		return nil, nil, err
+	}
+	if offset < 0 {
+		return nil, nil, ErrInvalidOffset
+	}
+
+	header := http.Header{}
+	if offset > 0 {
+		header.Set("Range", "bytes="+strconv.FormatInt(offset, 10)+"-")
+	}
+	resp, err := s.do(ctx, http.MethodGet, s.objectURL(key), nil, 0, header)
+	if err != nil {
+		return nil, nil, err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusPartialContent:
+		return resp.Body, s3Info(key, resp), nil
+	case http.StatusNotFound:
+		resp.Body.Close()
+		return nil, nil, ErrNotFound
+	case http.StatusRequestedRangeNotSatisfiable:
+		resp.Body.Close()
+		// S3 не отдаёт пустой диапазон в конце объекта
+		info, err := s.Stat(ctx, key)
+		if err != nil {
+			return nil, nil, err
+		}
+		if offset != info.Size {
+			return nil, nil, ErrInvalidOffset
+		}
+		return io.NopCloser(strings.NewReader("")), info, nil
+	}
+	defer resp.Body.Close()
+	return nil, nil, s3Error(resp, "ошибка чтения объекта "+key)
+}
+
+// Stat запрашивает заголовки объекта
+func (s *S3Store) Stat(ctx context.Context, key string) (*Info, error) {
+	if err := ValidateKey(key); err != nil {
+		return nil, err
+	}
+
+	resp, err := s.do(ctx, http.MethodHead, s.objectURL(key), nil, 0, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return s3Info(key, resp), nil
+	case http.StatusNotFound:
+		return nil, ErrNotFound
+	}
+	return nil, s3Error(resp, "ошибка чтения объекта "+key)
+}
+
+// Delete удаляет объект. S3 отвечает успехом и для отсутствующего объекта.
+func (s *S3Store) Delete(ctx context.Context, key string) error {
+	if err := ValidateKey(key); err != nil {
+		return err
+	}
+
+	resp, err := s.do(ctx, http.MethodDelete, s.objectURL(key), nil, 0, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		return s3Error(resp, "ошибка удаления объекта "+key)
+	}
+	return nil
+}
+
+// bucketURL возвращает адрес бакета
+func (s *S3Store) bucketURL() *url.URL {
+	return s.objectURL("")
+}
+
+// objectURL возвращает адрес объекта с учётом способа адресации бакета
+func (s *S3Store) objectURL(key string) *url.URL {
+	u := *s.endpoint
+	base := strings.TrimSuffix(u.Path, "/")
+	if s.cfg.PathStyle {
+		base += "/" + s.cfg.Bucket
+	} else {
+		u.Host = s.cfg.Bucket + "." + u.Host
+	}
+
+	u.Path = base + "/" + key
+	u.RawPath = s3Escape(base) + "/" + s3Escape(key)
+	u.RawQuery = ""
+	return &u
+}
+
+// do подписывает и выполняет запрос с телом размера size и дополнительными заголовками
+func (s *S3Store) do(ctx context.Context, method string, u *url.URL, body io.Reader, size int64, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.URL = u
+	for name, values := range header {
+		req.Header[name] = values
+	}
+
+	payloadHash := emptyPayloadHash
+	if body != nil && size > 0 {
+		req.Body = io.NopCloser(body)
+		req.ContentLength = size
+		payloadHash = unsignedPayload
+	}
+
+	s.sign(req, payloadHash, time.Now().UTC())
+	return s.client.Do(req)
+}
+
+// sign добавляет к запросу подпись AWS Signature Version 4
+func (s *S3Store) sign(req *http.Request, payloadHash string, now time.Time) {
+	amzDate := now.Format(amzDateFormat)
+	scope := now.Format("20060102") + "/" + s.cfg.Region + "/s3/aws4_request"
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, hashHex([]byte(canonicalRequest))}, "\n")
+
+	key := []byte("AWS4" + s.cfg.SecretKey)
+	for _, part := range strings.Split(scope, "/") {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.cfg.AccessKey, scope, signedHeaders, signature))
+}
+
+// s3Info собирает сведения об объекте по заголовкам ответа
+func s3Info(key string, resp *http.Response) *Info {
+	info := &Info{
+		Key:         key,
+		Size:        resp.ContentLength,
+		ContentType: resp.Header.Get("Content-Type"),
+		ETag:        resp.Header.Get("ETag"),
+	}
+	if size, err := strconv.ParseInt(resp.Header.Get("Content-Length"), 10, 64); err == nil {
+		info.Size = size
+	}
+	// У части объекта размер всего объекта указан в Content-Range: bytes 100-199/1000
+	if contentRange := resp.Header.Get("Content-Range"); contentRange != "" {
+		if i := strings.LastIndexByte(contentRange, '/'); i >= 0 {
+			if size, err := strconv.ParseInt(contentRange[i+1:], 10, 64); err == nil {
+				info.Size = size
+			}
+		}
+	}
+	if modTime, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		info.ModTime = modTime
+	}
+	return info
+}
+
+// s3Error возвращает ошибку с кодом из XML-ответа S3
+func s3Error(resp *http.Response, message string) error {
+	var body struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	if data, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10)); err == nil && xml.Unmarshal(data, &body) == nil && body.Code != "" {
+		return fmt.Errorf("%s: %s (%s: %s)", message, resp.Status, body.Code, body.Message)
+	}
+	return fmt.Errorf("%s: %s", message, resp.Status)
+}
+
+// spool записывает поток во временный файл и возвращает файл, готовый к чтению с начала
+func spool(r io.Reader) (*os.File, error) {
+	f, err := os.CreateTemp("", "blob-*")
+	if err != nil {
+		return nil, err
+	}
+	if _, err = io.Copy(f, r); err == nil {
+		if _, err = f.Seek(0, io.SeekStart); err == nil {
+			return f, nil
+		}
+	}
+	f.Close()
+	os.Remove(f.Name())
+	return nil, err
+}
+
+// s3Escape кодирует путь по правилам Signature Version 4: все символы,
+// кроме латинских букв, цифр, «-», «_», «.», «~» и «/», заменяются на %XX
+func s3Escape(p string) string {
+	var b strings.Builder
+	for i := 0; i < len(p); i++ {
+		c := p[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || strings.IndexByte("-_.~/", c) >= 0 {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
+
+func hashHex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
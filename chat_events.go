@@ -0,0 +1,135 @@
+package main
+
+import (
+	"log"
+	"strconv"
+
+	"chat-service/hub"
+	chatpb "chat-service/proto/chat-service/proto"
+	"chat-service/storage"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Соответствие типов событий хаба типам событий gRPC API
+var protoEventTypes = map[string]chatpb.ChatEventType{
+	hub.EventMessageCreated:      chatpb.ChatEventType_MESSAGE_CREATED,
+	hub.EventMessageEdited:       chatpb.ChatEventType_MESSAGE_EDITED,
+	hub.EventMessageDeleted:      chatpb.ChatEventType_MESSAGE_DELETED,
+	hub.EventReactionAdded:       chatpb.ChatEventType_REACTION_ADDED,
+	hub.EventReactionRemoved:     chatpb.ChatEventType_REACTION_REMOVED,
+	hub.EventParticipantAdded:    chatpb.ChatEventType_PARTICIPANT_ADDED,
+	hub.EventParticipantRemoved:  chatpb.ChatEventType_PARTICIPANT_REMOVED,
+	hub.EventChatUpdated:         chatpb.ChatEventType_CHAT_UPDATED,
+	hub.EventMessageStatus:       chatpb.ChatEventType_MESSAGE_STATUS,
+	hub.EventMemberRoleChanged:   chatpb.ChatEventType_MEMBER_ROLE_CHANGED,
+	hub.EventJoinRequestCreated:  chatpb.ChatEventType_JOIN_REQUEST_CREATED,
+	hub.EventJoinRequestApproved: chatpb.ChatEventType_JOIN_REQUEST_APPROVED,
+	hub.EventJoinRequestRejected: chatpb.ChatEventType_JOIN_REQUEST_REJECTED,
+	hub.EventChatDeleted:         chatpb.ChatEventType_CHAT_DELETED,
+	hub.EventChatRestored:        chatpb.ChatEventType_CHAT_RESTORED,
+}
+
+// SubscribeChatEvents передаёт в поток события всех чатов, в которых состоит пользователь
+func (s *ChatService) SubscribeChatEvents(req *chatpb.SubscribeChatEventsRequest, stream grpc.ServerStreamingServer[chatpb.ChatEvent]) error {
+	ctx := stream.Context()
+
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	if s.Service.Hub() == nil {
+		return status.Error(codes.Unavailable, "доставка событий недоступна")
+	}
+
+	// Необязательный фильтр по чатам: подписаться можно только на свои чаты
+	filter := make(map[string]bool, len(req.ChatIds))
+	for _, chatID := range req.ChatIds {
+		if _, err := s.Service.GetChat(ctx, userID, chatID); err != nil {
+			return serviceErrorToStatus(err)
+		}
+		filter[chatID] = true
+	}
+
+	sub := s.Service.Hub().Subscribe(userID)
+	defer sub.Close()
+	log.Printf("gRPC-подписка на события открыта для userID: %d", userID)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-sub.Events():
+			if !ok {
+				// Хаб закрывает подписку, если клиент не успевает читать события
+				return status.Error(codes.ResourceExhausted, "клиент не успевает получать события")
+			}
+			if len(filter) > 0 && !filter[event.ChatID] {
+				continue
+			}
+
+			pb := toProtoEvent(event)
+			if pb == nil {
+				continue
+			}
+			if err := stream.Send(pb); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// toProtoEvent преобразует событие хаба в событие gRPC API.
+// Для событий, не входящих в gRPC API, возвращает nil.
+func toProtoEvent(event hub.Event) *chatpb.ChatEvent {
+	eventType, ok := protoEventTypes[event.Type]
+	if !ok {
+		return nil
+	}
+
+	pb := &chatpb.ChatEvent{
+		Type:      eventType,
+		ChatId:    event.ChatID,
+		CreatedAt: timestamppb.New(event.At),
+	}
+
+	switch payload := event.Payload.(type) {
+	case *storage.Message:
+		pb.Payload = &chatpb.ChatEvent_Message{Message: toProtoMessage(payload)}
+	case *storage.Chat:
+		pb.Payload = &chatpb.ChatEvent_Chat{Chat: toProtoChat(payload)}
+	case *storage.JoinRequest:
+		pb.Payload = &chatpb.ChatEvent_JoinRequest{JoinRequest: toProtoJoinRequest(payload)}
+	case hub.MessageDeletedPayload:
+		pb.Payload = &chatpb.ChatEvent_MessageDeleted{MessageDeleted: &chatpb.MessageDeletedEvent{
+			MessageId: payload.MessageID,
+		}}
+	case hub.ReactionPayload:
+		pb.Payload = &chatpb.ChatEvent_Reaction{Reaction: &chatpb.ReactionChangedEvent{
+			MessageId: payload.MessageID,
+			UserId:    strconv.Itoa(int(payload.UserID)),
+			Reaction:  payload.Reaction,
+		}}
+	case hub.StatusPayload:
+		pb.Payload = &chatpb.ChatEvent_Status{Status: &chatpb.MessageStatusEvent{
+			MessageId: payload.MessageID,
+			UserId:    strconv.Itoa(int(payload.UserID)),
+			Status:    payload.Status,
+		}}
+	case hub.ParticipantPayload:
+		pb.Payload = &chatpb.ChatEvent_Participant{Participant: &chatpb.ParticipantChangedEvent{
+			UserId: strconv.Itoa(int(payload.UserID)),
+		}}
+	case hub.RolePayload:
+		pb.Payload = &chatpb.ChatEvent_Participant{Participant: &chatpb.ParticipantChangedEvent{
+			UserId: strconv.Itoa(int(payload.UserID)),
+			Role:   payload.Role,
+		}}
+	}
+
+	return pb
+}
@@ -0,0 +1,907 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	chatpb "chat-service/proto/chat-service/proto"
+	"chat-service/service"
+	"chat-service/storage"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Структура ChatService - gRPC-адаптер над сервисным слоем
+type ChatService struct {
+	chatpb.UnimplementedChatServiceServer
+	Service *service.Service
+}
+
+// CreateChat создаёт чат от имени текущего пользователя
+func (s *ChatService) CreateChat(ctx context.Context, req *chatpb.CreateChatRequest) (*chatpb.ChatResponse, error) {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	memberIDs, err := parseUserIDs(req.Participants)
+	if err != nil {
+		return nil, err
+	}
+
+	var chat *storage.Chat
+	if req.IsChannel {
+		chat, err = s.Service.CreateChannel(ctx, userID, req.Name, req.Description, memberIDs)
+	} else {
+		chat, err = s.Service.CreateChat(ctx, userID, req.Name, req.Description, memberIDs, req.IsGroup)
+	}
+	if err != nil {
+		return nil, serviceErrorToStatus(err)
+	}
+	return &chatpb.ChatResponse{Chat: toProtoChat(chat)}, nil
+}
+
+// GetChat возвращает информацию о чате, если пользователь в нём состоит
+func (s *ChatService) GetChat(ctx context.Context, req *chatpb.GetChatRequest) (*chatpb.ChatResponse, error) {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	chat, err := s.Service.GetChat(ctx, userID, req.ChatId)
+	if err != nil {
+		return nil, serviceErrorToStatus(err)
+	}
+	return &chatpb.ChatResponse{Chat: toProtoChat(chat)}, nil
+}
+
+// UpdateChat обновляет название, описание и аватар чата
+func (s *ChatService) UpdateChat(ctx context.Context, req *chatpb.UpdateChatRequest) (*chatpb.ChatResponse, error) {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Name == "" && req.Description == "" && req.AvatarUrl == "" {
+		return nil, status.Error(codes.InvalidArgument, "должно быть указано хотя бы одно поле для обновления")
+	}
+
+	var chat *storage.Chat
+	if req.Name != "" || req.Description != "" {
+		if chat, err = s.Service.UpdateChat(ctx, userID, req.ChatId, req.Name, req.Description); err != nil {
+			return nil, serviceErrorToStatus(err)
+		}
+	}
+	if req.AvatarUrl != "" {
+		if chat, err = s.Service.SetChatAvatar(ctx, userID, req.ChatId, req.AvatarUrl); err != nil {
+			return nil, serviceErrorToStatus(err)
+		}
+	}
+	return &chatpb.ChatResponse{Chat: toProtoChat(chat)}, nil
+}
+
+// DeleteChat удаляет чат вместе со всеми сообщениями
+func (s *ChatService) DeleteChat(ctx context.Context, req *chatpb.DeleteChatRequest) (*emptypb.Empty, error) {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.Service.DeleteChat(ctx, userID, req.ChatId); err != nil {
+		return nil, serviceErrorToStatus(err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// RestoreChat возвращает удалённый чат из корзины
+func (s *ChatService) RestoreChat(ctx context.Context, req *chatpb.RestoreChatRequest) (*chatpb.ChatResponse, error) {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	chat, err := s.Service.RestoreChat(ctx, userID, req.ChatId)
+	if err != nil {
+		return nil, serviceErrorToStatus(err)
+	}
+	return &chatpb.ChatResponse{Chat: toProtoChat(chat)}, nil
+}
+
+// ListDeletedChats возвращает удалённые чаты, которые текущий пользователь может восстановить
+func (s *ChatService) ListDeletedChats(ctx context.Context, req *chatpb.ListDeletedChatsRequest) (*chatpb.ListChatsResponse, error) {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	chats, err := s.Service.ListDeletedChats(ctx, userID)
+	if err != nil {
+		return nil, serviceErrorToStatus(err)
+	}
+
+	resp := &chatpb.ListChatsResponse{Chats: make([]*chatpb.Chat, 0, len(chats))}
+	for _, chat := range chats {
+		resp.Chats = append(resp.Chats, toProtoChat(chat))
+	}
+	return resp, nil
+}
+
+// ListUserChats возвращает чаты текущего пользователя
+func (s *ChatService) ListUserChats(ctx context.Context, req *chatpb.ListUserChatsRequest) (*chatpb.ListChatsResponse, error) {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Запрашивать можно только собственный список чатов
+	if err := checkSameUser(req.UserId, userID); err != nil {
+		return nil, err
+	}
+
+	chats, err := s.Service.ListUserChats(ctx, userID)
+	if err != nil {
+		return nil, serviceErrorToStatus(err)
+	}
+
+	return &chatpb.ListChatsResponse{Chats: toProtoSummaries(chats)}, nil
+}
+
+// Sync возвращает изменения в чатах текущего пользователя после предыдущей синхронизации
+func (s *ChatService) Sync(ctx context.Context, req *chatpb.SyncRequest) (*chatpb.SyncResponse, error) {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := s.Service.Sync(ctx, userID, req.Token, int64(req.Limit))
+	if err != nil {
+		return nil, serviceErrorToStatus(err)
+	}
+
+	resp := &chatpb.SyncResponse{
+		Chats:          toProtoSummaries(result.Chats),
+		RemovedChatIds: result.RemovedChats,
+		Messages:       make([]*chatpb.Message, 0, len(result.Messages)),
+		Token:          result.Token,
+		HasMore:        result.HasMore,
+	}
+	for _, message := range result.Messages {
+		resp.Messages = append(resp.Messages, toProtoMessage(message))
+	}
+	return resp, nil
+}
+
+// SendMessage отправляет сообщение в чат от имени текущего пользователя
+func (s *ChatService) SendMessage(ctx context.Context, req *chatpb.SendMessageRequest) (*chatpb.MessageResponse, error) {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkSameUser(req.SenderId, userID); err != nil {
+		return nil, err
+	}
+
+	// Файлы отправляются только через загрузку: ссылка на произвольный URL
+	// не стала бы вложением, доступным для скачивания
+	if len(req.FileUrls) > 0 {
+		return nil, serviceErrorToStatus(service.ErrFileMessage)
+	}
+
+	message, err := s.Service.SendMessage(ctx, userID, storage.NewMessage{
+		ChatID:          req.ChatId,
+		Content:         req.Content,
+		Type:            "text",
+		ReplyToID:       req.ReplyToId,
+		ClientMessageID: req.ClientMessageId,
+	})
+	if err != nil {
+		return nil, serviceErrorToStatus(err)
+	}
+
+	// Ключ идемпотентности возвращается только отправителю в ответе на отправку
+	pb := toProtoMessage(message)
+	pb.ClientMessageId = message.ClientMessageID
+	return &chatpb.MessageResponse{Message: pb}, nil
+}
+
+// EditMessage изменяет текст сообщения. Редактировать можно только свои сообщения.
+func (s *ChatService) EditMessage(ctx context.Context, req *chatpb.EditMessageRequest) (*chatpb.MessageResponse, error) {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.ExpectedRevision == nil {
+		return nil, status.Error(codes.InvalidArgument, "не указана ожидаемая ревизия сообщения (expected_revision)")
+	}
+
+	message, err := s.Service.EditMessage(ctx, userID, req.MessageId, req.Content, *req.ExpectedRevision)
+	if err != nil {
+		return nil, serviceErrorToStatus(err)
+	}
+	return &chatpb.MessageResponse{Message: toProtoMessage(message)}, nil
+}
+
+// GetMessageRevisions возвращает историю правок сообщения от первой версии к текущей
+func (s *ChatService) GetMessageRevisions(ctx context.Context, req *chatpb.GetMessageRevisionsRequest) (*chatpb.MessageRevisionsResponse, error) {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	revisions, err := s.Service.GetMessageRevisions(ctx, userID, req.MessageId)
+	if err != nil {
+		return nil, serviceErrorToStatus(err)
+	}
+
+	pbRevisions := make([]*chatpb.MessageRevision, len(revisions))
+	for i, revision := range revisions {
+		pbRevisions[i] = &chatpb.MessageRevision{
+			Revision:  revision.Revision,
+			Content:   revision.Content,
+			CreatedAt: timestamppb.New(revision.CreatedAt),
+		}
+		if !revision.ReplacedAt.IsZero() {
+			pbRevisions[i].ReplacedAt = timestamppb.New(revision.ReplacedAt)
+		}
+	}
+	return &chatpb.MessageRevisionsResponse{Revisions: pbRevisions}, nil
+}
+
+// GetFileURL выдаёт подписанную ссылку на файл сообщения
+func (s *ChatService) GetFileURL(ctx context.Context, req *chatpb.GetFileURLRequest) (*chatpb.FileURLResponse, error) {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if req.TtlSeconds < 0 || req.TtlSeconds > int64(service.MaxSignedURLTTL/time.Second) {
+		return nil, status.Error(codes.InvalidArgument, "некорректный срок действия ссылки")
+	}
+
+	signed, err := s.Service.SignFileURL(ctx, userID, req.MessageId, time.Duration(req.TtlSeconds)*time.Second)
+	if err != nil {
+		return nil, serviceErrorToStatus(err)
+	}
+	return &chatpb.FileURLResponse{Url: signed.URL, ExpiresAt: timestamppb.New(signed.ExpiresAt)}, nil
+}
+
+// DeleteMessage удаляет сообщение. Удалять можно только свои сообщения.
+func (s *ChatService) DeleteMessage(ctx context.Context, req *chatpb.DeleteMessageRequest) (*emptypb.Empty, error) {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Для себя можно удалить любое сообщение чата, для всех - только своё
+	deleteMessage := s.Service.DeleteMessage
+	if req.ForMe {
+		deleteMessage = s.Service.HideMessage
+	}
+	if err := deleteMessage(ctx, userID, req.MessageId); err != nil {
+		return nil, serviceErrorToStatus(err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// GetMessages возвращает страницу истории сообщений чата от новых к старым
+func (s *ChatService) GetMessages(ctx context.Context, req *chatpb.GetMessagesRequest) (*chatpb.ListMessagesResponse, error) {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	page, err := s.Service.GetMessages(ctx, userID, req.ChatId, storage.MessagePageQuery{
+		Before:    req.Before,
+		After:     req.After,
+		BeforeSeq: req.BeforeSeq,
+		AfterSeq:  req.AfterSeq,
+		Limit:     int64(req.Limit),
+	})
+	if err != nil {
+		return nil, serviceErrorToStatus(err)
+	}
+
+	resp := &chatpb.ListMessagesResponse{
+		Messages:   make([]*chatpb.Message, 0, len(page.Messages)),
+		NextCursor: page.NextCursor,
+		PrevCursor: page.PrevCursor,
+	}
+	for _, message := range page.Messages {
+		resp.Messages = append(resp.Messages, toProtoMessage(message))
+	}
+	return resp, nil
+}
+
+// GetThread возвращает корневое сообщение и страницу ответов в его ветке
+func (s *ChatService) GetThread(ctx context.Context, req *chatpb.GetThreadRequest) (*chatpb.ThreadResponse, error) {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	root, page, err := s.Service.GetThread(ctx, userID, req.MessageId, storage.MessagePageQuery{
+		Before:    req.Before,
+		After:     req.After,
+		BeforeSeq: req.BeforeSeq,
+		AfterSeq:  req.AfterSeq,
+		Limit:     int64(req.Limit),
+	})
+	if err != nil {
+		return nil, serviceErrorToStatus(err)
+	}
+
+	resp := &chatpb.ThreadResponse{
+		Root:       toProtoMessage(root),
+		Replies:    make([]*chatpb.Message, 0, len(page.Messages)),
+		NextCursor: page.NextCursor,
+		PrevCursor: page.PrevCursor,
+	}
+	for _, message := range page.Messages {
+		resp.Replies = append(resp.Replies, toProtoMessage(message))
+	}
+	return resp, nil
+}
+
+// SearchMessages ищет сообщения по тексту в чатах пользователя
+func (s *ChatService) SearchMessages(ctx context.Context, req *chatpb.SearchMessagesRequest) (*chatpb.SearchMessagesResponse, error) {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := storage.SearchQuery{
+		Text:   req.Query,
+		Type:   req.Type,
+		Cursor: req.Cursor,
+		Limit:  req.Limit,
+	}
+	if req.SenderId != "" {
+		if query.SenderID, err = parseUserID(req.SenderId); err != nil {
+			return nil, err
+		}
+	}
+	if req.From != nil {
+		query.Since = req.From.AsTime()
+	}
+	if req.To != nil {
+		query.Until = req.To.AsTime()
+	}
+
+	page, err := s.Service.SearchMessages(ctx, userID, req.ChatId, query)
+	if err != nil {
+		return nil, serviceErrorToStatus(err)
+	}
+
+	resp := &chatpb.SearchMessagesResponse{
+		Results:    make([]*chatpb.SearchResult, 0, len(page.Results)),
+		NextCursor: page.NextCursor,
+	}
+	for _, result := range page.Results {
+		pb := &chatpb.SearchResult{
+			Message: toProtoMessage(result.Message),
+			Score:   result.Score,
+			Snippet: result.Snippet,
+		}
+		for _, h := range result.Highlights {
+			pb.Highlights = append(pb.Highlights, &chatpb.Highlight{Start: int32(h.Start), End: int32(h.End)})
+		}
+		resp.Results = append(resp.Results, pb)
+	}
+	return resp, nil
+}
+
+// AddParticipant добавляет участника в групповой чат
+func (s *ChatService) AddParticipant(ctx context.Context, req *chatpb.AddParticipantRequest) (*chatpb.ChatResponse, error) {
+	return s.changeParticipants(ctx, req.ChatId, req.UserId, s.Service.AddParticipant)
+}
+
+// RemoveParticipant удаляет участника из группового чата
+func (s *ChatService) RemoveParticipant(ctx context.Context, req *chatpb.RemoveParticipantRequest) (*chatpb.ChatResponse, error) {
+	return s.changeParticipants(ctx, req.ChatId, req.UserId, s.Service.RemoveParticipant)
+}
+
+// PromoteMember назначает участника группового чата администратором
+func (s *ChatService) PromoteMember(ctx context.Context, req *chatpb.ChangeMemberRoleRequest) (*chatpb.ChatResponse, error) {
+	return s.changeParticipants(ctx, req.ChatId, req.UserId, s.Service.PromoteMember)
+}
+
+// DemoteMember снимает с участника группового чата права администратора
+func (s *ChatService) DemoteMember(ctx context.Context, req *chatpb.ChangeMemberRoleRequest) (*chatpb.ChatResponse, error) {
+	return s.changeParticipants(ctx, req.ChatId, req.UserId, s.Service.DemoteMember)
+}
+
+// TransferOwnership передаёт владение групповым чатом другому участнику
+func (s *ChatService) TransferOwnership(ctx context.Context, req *chatpb.ChangeMemberRoleRequest) (*chatpb.ChatResponse, error) {
+	return s.changeParticipants(ctx, req.ChatId, req.UserId, s.Service.TransferOwnership)
+}
+
+// PinMessage закрепляет сообщение чата
+func (s *ChatService) PinMessage(ctx context.Context, req *chatpb.PinMessageRequest) (*chatpb.ChatResponse, error) {
+	return s.changePins(ctx, req, s.Service.PinMessage)
+}
+
+// UnpinMessage открепляет сообщение чата
+func (s *ChatService) UnpinMessage(ctx context.Context, req *chatpb.PinMessageRequest) (*chatpb.ChatResponse, error) {
+	return s.changePins(ctx, req, s.Service.UnpinMessage)
+}
+
+func (s *ChatService) changePins(ctx context.Context, req *chatpb.PinMessageRequest, change func(context.Context, int32, string, string) (*storage.Chat, error)) (*chatpb.ChatResponse, error) {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	chat, err := change(ctx, userID, req.ChatId, req.MessageId)
+	if err != nil {
+		return nil, serviceErrorToStatus(err)
+	}
+	return &chatpb.ChatResponse{Chat: toProtoChat(chat)}, nil
+}
+
+// CreateInvite создаёт пригласительную ссылку в групповой чат
+func (s *ChatService) CreateInvite(ctx context.Context, req *chatpb.CreateInviteRequest) (*chatpb.InviteResponse, error) {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	invite, err := s.Service.CreateInvite(ctx, userID, req.ChatId, time.Duration(req.ExpiresInSeconds)*time.Second, req.MaxUses)
+	if err != nil {
+		return nil, serviceErrorToStatus(err)
+	}
+	return &chatpb.InviteResponse{Invite: toProtoInvite(invite)}, nil
+}
+
+// ListInvites возвращает действующие приглашения чата
+func (s *ChatService) ListInvites(ctx context.Context, req *chatpb.ListInvitesRequest) (*chatpb.ListInvitesResponse, error) {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	invites, err := s.Service.ListInvites(ctx, userID, req.ChatId)
+	if err != nil {
+		return nil, serviceErrorToStatus(err)
+	}
+
+	resp := &chatpb.ListInvitesResponse{Invites: make([]*chatpb.Invite, 0, len(invites))}
+	for _, invite := range invites {
+		resp.Invites = append(resp.Invites, toProtoInvite(invite))
+	}
+	return resp, nil
+}
+
+// RevokeInvite отзывает приглашение
+func (s *ChatService) RevokeInvite(ctx context.Context, req *chatpb.RevokeInviteRequest) (*emptypb.Empty, error) {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.Service.RevokeInvite(ctx, userID, req.Token); err != nil {
+		return nil, serviceErrorToStatus(err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// JoinChatByInvite добавляет текущего пользователя в чат по приглашению
+func (s *ChatService) JoinChatByInvite(ctx context.Context, req *chatpb.JoinChatByInviteRequest) (*chatpb.ChatResponse, error) {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	chat, err := s.Service.JoinByInvite(ctx, userID, req.Token)
+	if err != nil {
+		return nil, serviceErrorToStatus(err)
+	}
+	return &chatpb.ChatResponse{Chat: toProtoChat(chat)}, nil
+}
+
+// SetJoinApproval включает или выключает одобрение заявок на вступление
+func (s *ChatService) SetJoinApproval(ctx context.Context, req *chatpb.SetJoinApprovalRequest) (*chatpb.ChatResponse, error) {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	chat, err := s.Service.SetApprovalRequired(ctx, userID, req.ChatId, req.ApprovalRequired)
+	if err != nil {
+		return nil, serviceErrorToStatus(err)
+	}
+	return &chatpb.ChatResponse{Chat: toProtoChat(chat)}, nil
+}
+
+// RequestToJoinChat подаёт заявку текущего пользователя на вступление в чат
+func (s *ChatService) RequestToJoinChat(ctx context.Context, req *chatpb.RequestToJoinChatRequest) (*chatpb.JoinRequestResponse, error) {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	request, err := s.Service.RequestToJoin(ctx, userID, req.ChatId, req.Message)
+	if err != nil {
+		return nil, serviceErrorToStatus(err)
+	}
+	return &chatpb.JoinRequestResponse{Request: toProtoJoinRequest(request)}, nil
+}
+
+// ListJoinRequests возвращает заявки на вступление в чат
+func (s *ChatService) ListJoinRequests(ctx context.Context, req *chatpb.ListJoinRequestsRequest) (*chatpb.ListJoinRequestsResponse, error) {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	requests, err := s.Service.ListJoinRequests(ctx, userID, req.ChatId, req.Status)
+	if err != nil {
+		return nil, serviceErrorToStatus(err)
+	}
+
+	resp := &chatpb.ListJoinRequestsResponse{Requests: make([]*chatpb.JoinRequest, 0, len(requests))}
+	for _, request := range requests {
+		resp.Requests = append(resp.Requests, toProtoJoinRequest(request))
+	}
+	return resp, nil
+}
+
+// ApproveJoinRequest одобряет заявку и добавляет заявителя в чат
+func (s *ChatService) ApproveJoinRequest(ctx context.Context, req *chatpb.DecideJoinRequestRequest) (*chatpb.JoinRequestResponse, error) {
+	return s.decideJoinRequest(ctx, req.RequestId, s.Service.ApproveJoinRequest)
+}
+
+// RejectJoinRequest отклоняет заявку на вступление
+func (s *ChatService) RejectJoinRequest(ctx context.Context, req *chatpb.DecideJoinRequestRequest) (*chatpb.JoinRequestResponse, error) {
+	return s.decideJoinRequest(ctx, req.RequestId, s.Service.RejectJoinRequest)
+}
+
+// ListChatParticipants возвращает страницу участников чата (подписчиков канала)
+func (s *ChatService) ListChatParticipants(ctx context.Context, req *chatpb.ListChatParticipantsRequest) (*chatpb.ListParticipantsResponse, error) {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	page, err := s.Service.ListParticipants(ctx, userID, req.ChatId, storage.ParticipantPageQuery{
+		Cursor: req.Cursor,
+		Limit:  int64(req.Limit),
+	})
+	if err != nil {
+		return nil, serviceErrorToStatus(err)
+	}
+	return &chatpb.ListParticipantsResponse{
+		Participants: formatUserIDs(page.UserIDs),
+		Total:        page.Total,
+		NextCursor:   page.NextCursor,
+	}, nil
+}
+
+// SetMessageReaction устанавливает реакцию пользователя, заменяя предыдущую
+func (s *ChatService) SetMessageReaction(ctx context.Context, req *chatpb.SetMessageReactionRequest) (*chatpb.MessageResponse, error) {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkSameUser(req.UserId, userID); err != nil {
+		return nil, err
+	}
+
+	message, err := s.Service.SetReaction(ctx, userID, req.MessageId, req.Reaction)
+	if err != nil {
+		return nil, serviceErrorToStatus(err)
+	}
+	return &chatpb.MessageResponse{Message: toProtoMessage(message)}, nil
+}
+
+// RemoveMessageReaction удаляет реакцию пользователя с сообщения
+func (s *ChatService) RemoveMessageReaction(ctx context.Context, req *chatpb.RemoveMessageReactionRequest) (*chatpb.MessageResponse, error) {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkSameUser(req.UserId, userID); err != nil {
+		return nil, err
+	}
+
+	message, err := s.Service.ClearReactions(ctx, userID, req.MessageId)
+	if err != nil {
+		return nil, serviceErrorToStatus(err)
+	}
+	return &chatpb.MessageResponse{Message: toProtoMessage(message)}, nil
+}
+
+// MarkMessageAsRead помечает прочитанными сообщения чата до указанного включительно
+func (s *ChatService) MarkMessageAsRead(ctx context.Context, req *chatpb.MarkMessageAsReadRequest) (*emptypb.Empty, error) {
+	return s.markMessages(ctx, req.MessageId, req.UserId, s.Service.MarkRead)
+}
+
+// MarkMessageAsDelivered помечает доставленными сообщения чата до указанного включительно
+func (s *ChatService) MarkMessageAsDelivered(ctx context.Context, req *chatpb.MarkMessageAsDeliveredRequest) (*emptypb.Empty, error) {
+	return s.markMessages(ctx, req.MessageId, req.UserId, s.Service.MarkDelivered)
+}
+
+// markMessages сдвигает отметку доставки или прочтения текущего пользователя
+func (s *ChatService) markMessages(ctx context.Context, messageID string, rawUserID string, mark func(context.Context, int32, string) error) (*emptypb.Empty, error) {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkSameUser(rawUserID, userID); err != nil {
+		return nil, err
+	}
+
+	if err := mark(ctx, userID, messageID); err != nil {
+		return nil, serviceErrorToStatus(err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// changeParticipants изменяет состав группового чата от имени текущего пользователя
+func (s *ChatService) changeParticipants(ctx context.Context, chatID string, rawUserID string, change func(context.Context, int32, string, int32) (*storage.Chat, error)) (*chatpb.ChatResponse, error) {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	targetID, err := parseUserID(rawUserID)
+	if err != nil {
+		return nil, err
+	}
+
+	chat, err := change(ctx, userID, chatID, targetID)
+	if err != nil {
+		return nil, serviceErrorToStatus(err)
+	}
+	return &chatpb.ChatResponse{Chat: toProtoChat(chat)}, nil
+}
+
+// decideJoinRequest применяет решение по заявке от имени текущего пользователя
+func (s *ChatService) decideJoinRequest(ctx context.Context, requestID string, decide func(context.Context, int32, string) (*storage.JoinRequest, error)) (*chatpb.JoinRequestResponse, error) {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	request, err := decide(ctx, userID, requestID)
+	if err != nil {
+		return nil, serviceErrorToStatus(err)
+	}
+	return &chatpb.JoinRequestResponse{Request: toProtoJoinRequest(request)}, nil
+}
+
+// userIDFromContext извлекает userID, добавленный tokenAuthInterceptor
+func userIDFromContext(ctx context.Context) (int32, error) {
+	userID, ok := ctx.Value(userIDKey).(int32)
+	if !ok || userID == 0 {
+		return 0, status.Error(codes.Unauthenticated, "не удалось определить пользователя")
+	}
+	return userID, nil
+}
+
+// checkSameUser проверяет, что переданный в запросе ID пользователя (если есть)
+// совпадает с аутентифицированным пользователем
+func checkSameUser(rawUserID string, userID int32) error {
+	if rawUserID == "" {
+		return nil
+	}
+	requested, err := parseUserID(rawUserID)
+	if err != nil {
+		return err
+	}
+	if requested != userID {
+		return status.Error(codes.PermissionDenied, "нельзя выполнять действия от имени другого пользователя")
+	}
+	return nil
+}
+
+func parseUserID(raw string) (int32, error) {
+	id, err := strconv.ParseInt(raw, 10, 32)
+	if err != nil || id <= 0 {
+		return 0, status.Errorf(codes.InvalidArgument, "некорректный ID пользователя: %q", raw)
+	}
+	return int32(id), nil
+}
+
+func parseUserIDs(raw []string) ([]int32, error) {
+	ids := make([]int32, 0, len(raw))
+	for _, r := range raw {
+		id, err := parseUserID(r)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func formatUserIDs(ids []int32) []string {
+	out := make([]string, 0, len(ids))
+	for _, id := range ids {
+		out = append(out, strconv.Itoa(int(id)))
+	}
+	return out
+}
+
+func toProtoChat(chat *storage.Chat) *chatpb.Chat {
+	pb := &chatpb.Chat{
+		Id:               chat.ID.Hex(),
+		Name:             chat.Name,
+		Description:      chat.Description,
+		AvatarUrl:        chat.Avatar,
+		Participants:     formatUserIDs(chat.MemberIDs),
+		CreatorId:        strconv.Itoa(int(chat.CreatorID)),
+		IsGroup:          chat.IsGroup,
+		CreatedAt:        timestamppb.New(chat.CreatedAt),
+		LastActivityAt:   timestamppb.New(chat.LastActivityAt),
+		ApprovalRequired: chat.ApprovalRequired,
+		IsChannel:        chat.IsChannel,
+		MemberCount:      int32(chat.MemberCount),
+		LastSeq:          chat.LastSeq,
+	}
+
+	if chat.DeletedAt != nil {
+		pb.DeletedAt = timestamppb.New(*chat.DeletedAt)
+	}
+
+	for _, pin := range chat.PinnedMessages {
+		pb.PinnedMessages = append(pb.PinnedMessages, &chatpb.PinnedMessage{
+			MessageId: pin.MessageID.Hex(),
+			PinnedBy:  strconv.Itoa(int(pin.PinnedBy)),
+			PinnedAt:  timestamppb.New(pin.PinnedAt),
+		})
+	}
+
+	if chat.IsGroup {
+		pb.Roles = make(map[string]string)
+		for _, memberID := range chat.MemberIDs {
+			if role := chat.RoleOf(memberID); role != storage.RoleMember {
+				pb.Roles[strconv.Itoa(int(memberID))] = role
+			}
+		}
+	}
+
+	if chat.LastMessage != nil {
+		pb.LastMessage = &chatpb.LastMessagePreview{
+			MessageId: chat.LastMessage.MessageID.Hex(),
+			SenderId:  strconv.Itoa(int(chat.LastMessage.SenderID)),
+			Type:      chat.LastMessage.Type,
+			Snippet:   chat.LastMessage.Snippet,
+			CreatedAt: timestamppb.New(chat.LastMessage.CreatedAt),
+		}
+	}
+	return pb
+}
+
+func toProtoInvite(invite *storage.Invite) *chatpb.Invite {
+	pb := &chatpb.Invite{
+		Token:     invite.Token,
+		ChatId:    invite.ChatID.Hex(),
+		CreatorId: strconv.Itoa(int(invite.CreatorID)),
+		CreatedAt: timestamppb.New(invite.CreatedAt),
+		MaxUses:   invite.MaxUses,
+		Uses:      invite.Uses,
+	}
+	if invite.ExpiresAt != nil {
+		pb.ExpiresAt = timestamppb.New(*invite.ExpiresAt)
+	}
+	return pb
+}
+
+func toProtoJoinRequest(request *storage.JoinRequest) *chatpb.JoinRequest {
+	pb := &chatpb.JoinRequest{
+		Id:        request.ID.Hex(),
+		ChatId:    request.ChatID.Hex(),
+		UserId:    strconv.Itoa(int(request.UserID)),
+		Message:   request.Message,
+		Status:    request.Status,
+		CreatedAt: timestamppb.New(request.CreatedAt),
+	}
+	if request.DecidedAt != nil {
+		pb.DecidedBy = strconv.Itoa(int(request.DecidedBy))
+		pb.DecidedAt = timestamppb.New(*request.DecidedAt)
+	}
+	return pb
+}
+
+// toProtoSummaries преобразует сводки чатов, дополняя чаты счётчиками непрочитанного
+func toProtoSummaries(summaries []*service.ChatSummary) []*chatpb.Chat {
+	chats := make([]*chatpb.Chat, 0, len(summaries))
+	for _, summary := range summaries {
+		pb := toProtoChat(summary.Chat)
+		pb.UnreadCount = summary.UnreadCount
+		pb.UnreadMentions = summary.UnreadMentions
+		chats = append(chats, pb)
+	}
+	return chats
+}
+
+func toProtoMessage(message *storage.Message) *chatpb.Message {
+	pb := &chatpb.Message{
+		Id:          message.ID.Hex(),
+		ChatId:      message.ChatID.Hex(),
+		SenderId:    strconv.Itoa(int(message.SenderID)),
+		CreatedAt:   timestamppb.New(message.CreatedAt),
+		Reactions:   make(map[string]string, len(message.Reactions)),
+		ReplyCount:  message.ReplyCount,
+		Status:      message.Status,
+		ReadBy:      formatUserIDs(message.ReadBy),
+		DeliveredTo: formatUserIDs(message.DeliveredTo),
+		Mentions:    formatUserIDs(message.Mentions),
+		Views:       message.Views,
+		Seq:         message.Seq,
+		Revision:    message.Revision,
+	}
+
+	if message.EditedAt != nil {
+		pb.UpdatedAt = timestamppb.New(*message.EditedAt)
+	}
+	if message.Notice != nil {
+		pb.Notice = &chatpb.SystemNotice{Action: message.Notice.Action}
+		if !message.Notice.MessageID.IsZero() {
+			pb.Notice.MessageId = message.Notice.MessageID.Hex()
+		}
+		if message.Notice.UserID != 0 {
+			pb.Notice.UserId = strconv.Itoa(int(message.Notice.UserID))
+		}
+	}
+	if message.ReplyTo != nil {
+		pb.ReplyTo = &chatpb.ReplyPreview{
+			MessageId: message.ReplyTo.MessageID.Hex(),
+			ThreadId:  message.ReplyTo.ThreadID.Hex(),
+			SenderId:  strconv.Itoa(int(message.ReplyTo.SenderID)),
+			Snippet:   message.ReplyTo.Snippet,
+		}
+	}
+
+	switch {
+	case message.Deleted():
+		// От удалённого сообщения остаётся только надгробие без содержимого
+		pb.DeletedAt = timestamppb.New(*message.DeletedAt)
+	case message.Type == "file":
+		pb.FileUrls = []string{message.Content}
+		if message.Attachment != nil {
+			pb.Attachment = &chatpb.Attachment{
+				Url:         message.Content,
+				Filename:    message.Attachment.Filename,
+				ContentType: message.Attachment.ContentType,
+				Size:        message.Attachment.Size,
+			}
+		}
+	default:
+		pb.Content = message.Content
+	}
+
+	// Ключ - ID пользователя, значение - его реакция
+	for _, reaction := range message.Reactions {
+		pb.Reactions[strconv.Itoa(int(reaction.UserID))] = reaction.Reaction
+	}
+
+	return pb
+}
+
+// serviceErrorToStatus преобразует доменные ошибки сервиса в gRPC-статусы
+func serviceErrorToStatus(err error) error {
+	switch service.CodeOf(err) {
+	case service.CodeUnauthenticated:
+		return status.Error(codes.Unauthenticated, err.Error())
+	case service.CodeInvalidArgument:
+		return status.Error(codes.InvalidArgument, err.Error())
+	case service.CodeNotFound:
+		return status.Error(codes.NotFound, err.Error())
+	case service.CodeForbidden:
+		return status.Error(codes.PermissionDenied, err.Error())
+	case service.CodeConflict:
+		return status.Error(codes.AlreadyExists, err.Error())
+	case service.CodeFailedPrecondition:
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+	return status.Error(codes.Internal, "внутренняя ошибка сервера")
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"chat-service/blob"
+	"chat-service/hub"
+	chatpb "chat-service/proto/chat-service/proto"
+	"chat-service/service"
+	"chat-service/storage"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestServiceErrorToStatus(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		code codes.Code
+	}{
+		{"без пользователя", service.ErrUnauthenticated, codes.Unauthenticated},
+		{"некорректный аргумент", service.ErrInvalidChatID, codes.InvalidArgument},
+		{"не найдено", service.ErrChatNotFound, codes.NotFound},
+		{"нет прав", service.ErrNotChatMember, codes.PermissionDenied},
+		{"повторное создание", service.ErrReactionExists, codes.AlreadyExists},
+		{"устаревшая ревизия", service.ErrEditConflict, codes.FailedPrecondition},
+		{"сдвиг загрузки", service.ErrUploadOffsetMismatch, codes.FailedPrecondition},
+		{"не доменная", errors.New("сбой базы"), codes.Internal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code := status.Code(serviceErrorToStatus(tt.err)); code != tt.code {
+				t.Errorf("код = %v, ожидался %v", code, tt.code)
+			}
+		})
+	}
+}
+
+func TestSendMessageRejectsFileURLs(t *testing.T) {
+	blobs, err := blob.NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocalStore: %v", err)
+	}
+	svc := &ChatService{Service: service.New(storage.NewMemoryStorage(), blobs, nil, hub.New())}
+	ctx := context.WithValue(context.Background(), userIDKey, int32(1))
+
+	chat, err := svc.CreateChat(ctx, &chatpb.CreateChatRequest{Name: "группа", IsGroup: true, Participants: []string{"2"}})
+	if err != nil {
+		t.Fatalf("CreateChat: %v", err)
+	}
+
+	// Файл нельзя отправить ссылкой: он должен быть загружен в хранилище файлов
+	_, err = svc.SendMessage(ctx, &chatpb.SendMessageRequest{ChatId: chat.Chat.Id, FileUrls: []string{"https://example.com/file.exe"}})
+	if code := status.Code(err); code != codes.InvalidArgument {
+		t.Errorf("SendMessage со ссылкой на файл: код %v, ожидался InvalidArgument", code)
+	}
+
+	resp, err := svc.SendMessage(ctx, &chatpb.SendMessageRequest{ChatId: chat.Chat.Id, Content: "привет"})
+	if err != nil {
+		t.Fatalf("SendMessage: %v", err)
+	}
+	if resp.Message.Content != "привет" || len(resp.Message.FileUrls) != 0 {
+		t.Errorf("отправлено сообщение %+v", resp.Message)
+	}
+}
@@ -2,31 +2,15 @@ package handler
 
 import (
 	"chat-service/middleware"
-	"chat-service/storage"
+	"chat-service/service"
 	"encoding/json"
 	"log"
 	"net/http"
 	"strings"
 )
 
-// isUserAllowedToManageParticipants проверяет, имеет ли пользователь права на управление участниками чата
-func isUserAllowedToManageParticipants(chat *storage.Chat, userID int32) bool {
-    // Для личных чатов: Невозможно добавить новых участников
-    if !chat.IsGroup {
-        return false
-    }
-
-    // Для групповых чатов: Проверяем, является ли пользователь участником чата
-    for _, memberID := range chat.MemberIDs {
-        if memberID == userID {
-            return true
-        }
-    }
-    return false
-}
-
 // AddParticipantHandler обрабатывает добавление участника в групповой чат
-func AddParticipantHandler(storage storage.Storage) http.HandlerFunc {
+func AddParticipantHandler(svc *service.Service) http.HandlerFunc {
     return func(w http.ResponseWriter, r *http.Request) {
         // Проверяем метод запроса
         if r.Method != http.MethodPost {
@@ -51,27 +35,6 @@ func AddParticipantHandler(storage storage.Storage) http.HandlerFunc {
             return
         }
 
-        // Получаем информацию о чате
-        chat, err := storage.GetChatByID(r.Context(), chatID)
-        if err != nil {
-            switch err.Error() {
-            case "некорректный идентификатор чата":
-                http.Error(w, "Некорректный chatID", http.StatusBadRequest)
-            case "чат не найден":
-                http.Error(w, "Чат не найден", http.StatusNotFound)
-            default:
-                log.Printf("Ошибка получения чата: %v", err)
-                http.Error(w, "Не удалось получить информацию о чате", http.StatusInternalServerError)
-            }
-            return
-        }
-
-        // Проверяем права пользователя на управление участниками чата
-        if !isUserAllowedToManageParticipants(chat, userID) {
-            http.Error(w, "У вас нет прав на управление участниками этого чата", http.StatusForbidden)
-            return
-        }
-
         // Читаем тело запроса
         var req struct {
             UserID int32 `json:"user_id"` // ID пользователя для добавления
@@ -87,20 +50,9 @@ func AddParticipantHandler(storage storage.Storage) http.HandlerFunc {
             return
         }
 
-        // Вызываем метод хранилища для добавления участника
-        err = storage.AddParticipant(r.Context(), chatID, req.UserID)
-        if err != nil {
-            switch err.Error() {
-            case "некорректный chatID":
-                http.Error(w, "Некорректный chatID", http.StatusBadRequest)
-            case "некорректный userID":
-                http.Error(w, "Некорректный ID пользователя", http.StatusBadRequest)
-            case "чат не найден или не является групповым":
-                http.Error(w, "Чат не найден или не является групповым", http.StatusNotFound)
-            default:
-                log.Printf("Ошибка при добавлении участника: %v", err)
-                http.Error(w, "Не удалось добавить участника", http.StatusInternalServerError)
-            }
+        // Добавляем участника (права проверяет сервис)
+        if _, err := svc.AddParticipant(r.Context(), userID, chatID, req.UserID); err != nil {
+            writeServiceError(w, err)
             return
         }
 
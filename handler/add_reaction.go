@@ -5,13 +5,13 @@ import (
 	"net/http"
 
 	"chat-service/middleware"
-	"chat-service/storage"
+	"chat-service/service"
 
 	"github.com/gorilla/mux"
 )
 
 // AddReactionHandler обрабатывает запросы на добавление реакции к сообщению.
-func AddReactionHandler(store storage.Storage) http.HandlerFunc {
+func AddReactionHandler(svc *service.Service) http.HandlerFunc {
     return func(w http.ResponseWriter, r *http.Request) {
         ctx := r.Context()
 
@@ -32,8 +32,8 @@ func AddReactionHandler(store storage.Storage) http.HandlerFunc {
             return
         }
 
-        if err := store.AddReaction(ctx, messageID, req.Reaction, userID); err != nil {
-            http.Error(w, err.Error(), http.StatusBadRequest)
+        if _, err := svc.AddReaction(ctx, userID, messageID, req.Reaction); err != nil {
+            writeServiceError(w, err)
             return
         }
 
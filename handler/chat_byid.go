@@ -2,14 +2,14 @@ package handler
 
 import (
 	"chat-service/middleware"
-	"chat-service/storage"
+	"chat-service/service"
 	"encoding/json"
 	"log"
 	"net/http"
 )
 
 // GetChatByIDHandler обрабатывает запрос на получение информации о чате
-func GetChatByIDHandler(storage storage.Storage) http.HandlerFunc {
+func GetChatByIDHandler(svc *service.Service) http.HandlerFunc {
     return func(w http.ResponseWriter, r *http.Request) {
         // Проверяем метод запроса
         if r.Method != http.MethodGet {
@@ -32,24 +32,10 @@ func GetChatByIDHandler(storage storage.Storage) http.HandlerFunc {
             return
         }
 
-        // Получаем информацию о чате
-        chat, err := storage.GetChatByID(r.Context(), chatID)
+        // Получаем информацию о чате (доступна только участникам)
+        chat, err := svc.GetChat(r.Context(), userID, chatID)
         if err != nil {
-            switch err.Error() {
-            case "некорректный идентификатор чата":
-                http.Error(w, "Некорректный chatID", http.StatusBadRequest)
-            case "чат не найден":
-                http.Error(w, "Чат не найден", http.StatusNotFound)
-            default:
-                log.Printf("Ошибка получения чата: %v", err)
-                http.Error(w, "Не удалось получить информацию о чате", http.StatusInternalServerError)
-            }
-            return
-        }
-
-        // Проверяем права доступа к чату
-        if !isUserAllowedToViewChat(chat, userID) {
-            http.Error(w, "У вас нет прав на просмотр этого чата", http.StatusForbidden)
+            writeServiceError(w, err)
             return
         }
 
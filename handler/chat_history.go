@@ -3,19 +3,25 @@ package handler
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
 
 	"chat-service/middleware"
+	"chat-service/service"
 	"chat-service/storage"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 // GetChatHistoryHandler handles requests to retrieve chat history.
-func GetChatHistoryHandler(store storage.Storage) http.HandlerFunc {
+// Параметры: before/after - курсоры (ID сообщений), limit - размер страницы.
+// Вместо ID можно указать порядковые номера сообщений: before_seq/after_seq,
+// тогда next_cursor/prev_cursor - тоже номера.
+func GetChatHistoryHandler(svc *service.Service) http.HandlerFunc {
     return func(w http.ResponseWriter, r *http.Request) {
         ctx := r.Context()
 
@@ -40,42 +46,28 @@ func GetChatHistoryHandler(store storage.Storage) http.HandlerFunc {
             return
         }
 
-        // Получаем информацию о чате
-        chat, err := store.GetChatByID(ctx, chatID)
-        if err != nil {
-            handleChatError(w, err)
-            return
-        }
-
-        // Проверяем права пользователя на просмотр чата
-        if !isUserAllowedToViewChat(chat, userID) {
-            http.Error(w, "У вас нет прав на просмотр этого чата", http.StatusForbidden)
-            return
-        }
-
         // Получаем параметры пагинации
-        limit, skip, err := parsePaginationParams(r.URL.Query())
+        query, err := parsePaginationParams(r.URL.Query())
         if err != nil {
             http.Error(w, err.Error(), http.StatusBadRequest)
             return
         }
 
-        // Получаем историю сообщений
-        var messages []*storage.Message
-        if limit > 0 || skip > 0 {
-            messages, err = store.GetMessagesWithPagination(ctx, chatID, limit, skip)
-        } else {
-            messages, err = store.GetMessages(ctx, chatID)
-        }
+        // Получаем страницу истории сообщений (доступна только участникам чата)
+        page, err := svc.GetMessages(ctx, userID, chatID, query)
         if err != nil {
-            handleMessageError(w, err)
+            writeServiceError(w, err)
             return
         }
 
-        // Возвращаем успешный ответ с историей сообщений
+        // Возвращаем успешный ответ: сообщения от новых к старым и курсоры соседних страниц
         w.Header().Set("Content-Type", "application/json")
         w.WriteHeader(http.StatusOK)
-        if err := json.NewEncoder(w).Encode(messages); err != nil {
+        if err := json.NewEncoder(w).Encode(map[string]interface{}{
+            "messages":    page.Messages,
+            "next_cursor": page.NextCursor,
+            "prev_cursor": page.PrevCursor,
+        }); err != nil {
             log.Printf("Ошибка при кодировании JSON: %v", err)
             http.Error(w, "Не удалось отправить ответ", http.StatusInternalServerError)
         }
@@ -91,57 +83,37 @@ func isValidChatID(chatID string) bool {
     return primitive.IsValidObjectID(chatID)
 }
 
-func parsePaginationParams(queryParams map[string][]string) (int64, int64, error) {
-    var limit, skip int64
-
-    if values, ok := queryParams["limit"]; ok && len(values) > 0 {
-        var err error
-        limit, err = strconv.ParseInt(values[0], 10, 64)
-        if err != nil || limit < 0 {
-            return 0, 0, errors.New("некорректный параметр 'limit'")
-        }
+// parsePaginationParams разбирает параметры before, after, before_seq, after_seq и limit.
+// Курсоры before/after - ID сообщений, их проверяет хранилище.
+func parsePaginationParams(queryParams url.Values) (storage.MessagePageQuery, error) {
+    query := storage.MessagePageQuery{
+        Before: queryParams.Get("before"),
+        After:  queryParams.Get("after"),
     }
 
-    if values, ok := queryParams["skip"]; ok && len(values) > 0 {
-        var err error
-        skip, err = strconv.ParseInt(values[0], 10, 64)
-        if err != nil || skip < 0 {
-            return 0, 0, errors.New("некорректный параметр 'skip'")
+    // Курсоры по порядковым номерам сообщений
+    for _, param := range []struct {
+        name string
+        seq  *int64
+    }{{"before_seq", &query.BeforeSeq}, {"after_seq", &query.AfterSeq}} {
+        value := queryParams.Get(param.name)
+        if value == "" {
+            continue
         }
-    }
-
-    return limit, skip, nil
-}
-
-func isUserAllowedToViewChat(chat *storage.Chat, userID int32) bool {
-    for _, memberID := range chat.MemberIDs {
-        if memberID == userID {
-            return true
+        seq, err := strconv.ParseInt(value, 10, 64)
+        if err != nil || seq <= 0 {
+            return query, fmt.Errorf("некорректный параметр '%s'", param.name)
         }
+        *param.seq = seq
     }
-    return false
-}
 
-func handleChatError(w http.ResponseWriter, err error) {
-    switch {
-    case errors.Is(err, storage.ErrInvalidChatID):
-        http.Error(w, "Некорректный chatID", http.StatusBadRequest)
-    case errors.Is(err, storage.ErrChatNotFound):
-        http.Error(w, "Чат не найден", http.StatusNotFound)
-    default:
-        log.Printf("Ошибка получения чата: %v", err)
-        http.Error(w, "Не удалось получить информацию о чате", http.StatusInternalServerError)
+    if value := queryParams.Get("limit"); value != "" {
+        limit, err := strconv.ParseInt(value, 10, 64)
+        if err != nil || limit < 0 {
+            return query, errors.New("некорректный параметр 'limit'")
+        }
+        query.Limit = limit
     }
-}
 
-func handleMessageError(w http.ResponseWriter, err error) {
-    switch {
-    case errors.Is(err, storage.ErrInvalidChatID):
-        http.Error(w, "Некорректный chatID", http.StatusBadRequest)
-    case errors.Is(err, storage.ErrMessageNotFound):
-        http.Error(w, "Сообщения не найдены", http.StatusNotFound)
-    default:
-        log.Printf("Ошибка получения сообщений: %v", err)
-        http.Error(w, "Неизвестная ошибка при получении сообщений", http.StatusInternalServerError)
-    }
+    return query, nil
 }
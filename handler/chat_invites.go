@@ -0,0 +1,137 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"chat-service/middleware"
+	"chat-service/service"
+
+	"github.com/gorilla/mux"
+)
+
+// CreateInviteHandler создаёт пригласительную ссылку в групповой чат.
+// Тело запроса необязательно: {"expires_in": секунды, "max_uses": число}.
+func CreateInviteHandler(svc *service.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Извлекаем chatID из URL
+		chatID := mux.Vars(r)["chatID"]
+
+		// Извлекаем userID из контекста
+		userID, ok := r.Context().Value(middleware.UserIDKey).(int32)
+		if !ok {
+			log.Printf("Не удалось извлечь userID из контекста")
+			http.Error(w, "Не удалось извлечь userID из токена", http.StatusInternalServerError)
+			return
+		}
+
+		var req struct {
+			ExpiresIn int64 `json:"expires_in"` // срок действия в секундах, 0 - бессрочно
+			MaxUses   int32 `json:"max_uses"`   // лимит использований, 0 - без ограничения
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+			http.Error(w, "Неверный формат данных", http.StatusBadRequest)
+			return
+		}
+
+		invite, err := svc.CreateInvite(r.Context(), userID, chatID, time.Duration(req.ExpiresIn)*time.Second, req.MaxUses)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		if err := json.NewEncoder(w).Encode(invite); err != nil {
+			log.Printf("Ошибка при кодировании JSON: %v", err)
+		}
+	}
+}
+
+// GetChatInvitesHandler возвращает действующие приглашения чата
+func GetChatInvitesHandler(svc *service.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Извлекаем chatID из URL
+		chatID := mux.Vars(r)["chatID"]
+
+		// Извлекаем userID из контекста
+		userID, ok := r.Context().Value(middleware.UserIDKey).(int32)
+		if !ok {
+			log.Printf("Не удалось извлечь userID из контекста")
+			http.Error(w, "Не удалось извлечь userID из токена", http.StatusInternalServerError)
+			return
+		}
+
+		invites, err := svc.ListInvites(r.Context(), userID, chatID)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		if err := json.NewEncoder(w).Encode(invites); err != nil {
+			log.Printf("Ошибка при кодировании JSON: %v", err)
+		}
+	}
+}
+
+// RevokeInviteHandler отзывает приглашение
+func RevokeInviteHandler(svc *service.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Извлекаем токен приглашения из URL
+		token := mux.Vars(r)["token"]
+
+		// Извлекаем userID из контекста
+		userID, ok := r.Context().Value(middleware.UserIDKey).(int32)
+		if !ok {
+			log.Printf("Не удалось извлечь userID из контекста")
+			http.Error(w, "Не удалось извлечь userID из токена", http.StatusInternalServerError)
+			return
+		}
+
+		if err := svc.RevokeInvite(r.Context(), userID, token); err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		if err := json.NewEncoder(w).Encode(map[string]string{"status": "success"}); err != nil {
+			log.Printf("Ошибка при кодировании JSON: %v", err)
+		}
+	}
+}
+
+// JoinByInviteHandler добавляет текущего пользователя в чат по приглашению
+func JoinByInviteHandler(svc *service.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Извлекаем токен приглашения из URL
+		token := mux.Vars(r)["token"]
+
+		// Извлекаем userID из контекста
+		userID, ok := r.Context().Value(middleware.UserIDKey).(int32)
+		if !ok {
+			log.Printf("Не удалось извлечь userID из контекста")
+			http.Error(w, "Не удалось извлечь userID из токена", http.StatusInternalServerError)
+			return
+		}
+
+		chat, err := svc.JoinByInvite(r.Context(), userID, token)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		// Возвращаем чат, в который вступил пользователь
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		if err := json.NewEncoder(w).Encode(chat); err != nil {
+			log.Printf("Ошибка при кодировании JSON: %v", err)
+		}
+	}
+}
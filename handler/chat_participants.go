@@ -2,17 +2,20 @@ package handler
 
 import (
 	"encoding/json"
-	"errors"
 	"log"
 	"net/http"
+	"strconv"
 
+	"chat-service/middleware"
+	"chat-service/service"
 	"chat-service/storage"
 
 	"github.com/gorilla/mux"
 )
 
-// GetChatParticipantsHandler обрабатывает запрос на получение списка участников чата
-func GetChatParticipantsHandler(store storage.Storage) http.HandlerFunc {
+// GetChatParticipantsHandler обрабатывает запрос на получение списка участников чата.
+// Параметры: cursor - курсор из next_cursor предыдущей страницы, limit - размер страницы.
+func GetChatParticipantsHandler(svc *service.Service) http.HandlerFunc {
     return func(w http.ResponseWriter, r *http.Request) {
         ctx := r.Context()
 
@@ -20,24 +23,39 @@ func GetChatParticipantsHandler(store storage.Storage) http.HandlerFunc {
         vars := mux.Vars(r)
         chatID := vars["chatID"]
 
-        // Получаем список участников чата
-        participants, err := store.GetChatParticipants(ctx, chatID)
-        if err != nil {
-            switch {
-            case errors.Is(err, storage.ErrInvalidChatID):
-                http.Error(w, "Некорректный chatID", http.StatusBadRequest)
-            case errors.Is(err, storage.ErrChatNotFound):
-                http.Error(w, "Чат не найден", http.StatusNotFound)
-            default:
-                log.Printf("Ошибка получения участников чата: %v", err)
-                http.Error(w, "Ошибка получения участников чата", http.StatusInternalServerError)
+        // Получаем userID из контекста
+        userID, ok := ctx.Value(middleware.UserIDKey).(int32)
+        if !ok {
+            log.Printf("Не удалось извлечь userID из контекста")
+            http.Error(w, "Не удалось извлечь userID", http.StatusInternalServerError)
+            return
+        }
+
+        // Получаем параметры пагинации
+        query := storage.ParticipantPageQuery{Cursor: r.URL.Query().Get("cursor")}
+        if value := r.URL.Query().Get("limit"); value != "" {
+            limit, err := strconv.ParseInt(value, 10, 64)
+            if err != nil || limit < 0 {
+                http.Error(w, "некорректный параметр 'limit'", http.StatusBadRequest)
+                return
             }
+            query.Limit = limit
+        }
+
+        // Получаем страницу участников чата (доступна только участникам)
+        page, err := svc.ListParticipants(ctx, userID, chatID, query)
+        if err != nil {
+            writeServiceError(w, err)
             return
         }
 
-        // Возвращаем успешный ответ
+        // Возвращаем успешный ответ: участники, их общее число и курсор следующей страницы
         w.Header().Set("Content-Type", "application/json")
         w.WriteHeader(http.StatusOK)
-        json.NewEncoder(w).Encode(participants)
+        json.NewEncoder(w).Encode(map[string]interface{}{
+            "participants": page.UserIDs,
+            "total":        page.Total,
+            "next_cursor":  page.NextCursor,
+        })
     }
 }
\ No newline at end of file
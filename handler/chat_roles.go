@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"chat-service/middleware"
+	"chat-service/service"
+	"chat-service/storage"
+
+	"github.com/gorilla/mux"
+)
+
+// PromoteMemberHandler назначает участника группового чата администратором
+func PromoteMemberHandler(svc *service.Service) http.HandlerFunc {
+	return changeRoleHandler(svc.PromoteMember)
+}
+
+// DemoteMemberHandler снимает с участника группового чата права администратора
+func DemoteMemberHandler(svc *service.Service) http.HandlerFunc {
+	return changeRoleHandler(svc.DemoteMember)
+}
+
+// TransferOwnershipHandler передаёт владение групповым чатом другому участнику
+func TransferOwnershipHandler(svc *service.Service) http.HandlerFunc {
+	return changeRoleHandler(svc.TransferOwnership)
+}
+
+// changeRoleHandler принимает {"user_id": ...} и применяет изменение роли к этому участнику.
+// Права проверяет сервис: роли меняет только владелец чата.
+func changeRoleHandler(change func(context.Context, int32, string, int32) (*storage.Chat, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Извлекаем chatID из URL
+		chatID := mux.Vars(r)["chatID"]
+
+		// Извлекаем userID из контекста
+		userID, ok := r.Context().Value(middleware.UserIDKey).(int32)
+		if !ok {
+			log.Printf("Не удалось извлечь userID из контекста")
+			http.Error(w, "Не удалось извлечь userID из токена", http.StatusInternalServerError)
+			return
+		}
+
+		var req struct {
+			UserID int32 `json:"user_id"` // ID участника, роль которого меняется
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Некорректный запрос", http.StatusBadRequest)
+			return
+		}
+
+		if req.UserID == 0 {
+			http.Error(w, "Не указан ID пользователя", http.StatusBadRequest)
+			return
+		}
+
+		chat, err := change(r.Context(), userID, chatID, req.UserID)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		// Возвращаем чат с обновлёнными ролями
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		if err := json.NewEncoder(w).Encode(chat); err != nil {
+			log.Printf("Ошибка при кодировании JSON: %v", err)
+		}
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"strconv"
 
 	"chat-service/middleware"
+	"chat-service/service"
 	"chat-service/storage"
 )
 
@@ -15,6 +16,7 @@ type CreateChatRequest struct {
 	Name         string   `json:"name"`         // Название чата
 	Participants []string `json:"participants"` // Участники чата (ID в строковом виде)
 	IsGroup      bool     `json:"is_group"`     // Групповой ли чат
+	IsChannel    bool     `json:"is_channel"`   // Канал: публикуют только администраторы
 	Description  string   `json:"description,omitempty"` // Описание чата (необязательное)
 }
 
@@ -24,7 +26,7 @@ type CreateChatResponse struct {
 }
 
 // CreateChatHandler обрабатывает запросы на создание чатов
-func CreateChatHandler(storage storage.Storage) http.HandlerFunc {
+func CreateChatHandler(svc *service.Service) http.HandlerFunc {
     return func(w http.ResponseWriter, r *http.Request) {
         // Проверяем метод запроса
         if r.Method != http.MethodPost {
@@ -50,12 +52,6 @@ func CreateChatHandler(storage storage.Storage) http.HandlerFunc {
             return
         }
 
-        // Проверяем обязательные поля
-        if req.Name == "" || len(req.Participants) == 0 {
-            http.Error(w, "Отсутствуют обязательные поля", http.StatusBadRequest)
-            return
-        }
-
         // Конвертация Participants из []string в []int32
         var memberIDs []int32
         for _, p := range req.Participants {
@@ -67,24 +63,21 @@ func CreateChatHandler(storage storage.Storage) http.HandlerFunc {
             memberIDs = append(memberIDs, int32(id))
         }
 
-        // Для личных чатов (is_group: false) проверяем, что участников ровно два
-        if !req.IsGroup && len(memberIDs) != 1 {
-            http.Error(w, "Личный чат должен содержать ровно одного другого участника", http.StatusBadRequest)
-            return
+        // Создаём чат или канал (обязательные поля и состав личного чата проверяет сервис)
+        var chat *storage.Chat
+        var err error
+        if req.IsChannel {
+            chat, err = svc.CreateChannel(r.Context(), userID, req.Name, req.Description, memberIDs)
+        } else {
+            chat, err = svc.CreateChat(r.Context(), userID, req.Name, req.Description, memberIDs, req.IsGroup)
         }
-
-        // Добавляем userID создателя в список участников
-        memberIDs = append(memberIDs, userID)
-
-        // Создаём чат в хранилище
-        chatID, err := storage.CreateChat(r.Context(), req.Name, memberIDs, req.IsGroup, req.Description, userID)
         if err != nil {
-            http.Error(w, "Не удалось создать чат: "+err.Error(), http.StatusInternalServerError)
+            writeServiceError(w, err)
             return
         }
 
         // Возвращаем успешный ответ
-        resp := CreateChatResponse{ChatID: chatID}
+        resp := CreateChatResponse{ChatID: chat.ID.Hex()}
         w.Header().Set("Content-Type", "application/json")
         w.WriteHeader(http.StatusCreated)
         if err := json.NewEncoder(w).Encode(resp); err != nil {
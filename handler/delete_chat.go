@@ -2,30 +2,14 @@ package handler
 
 import (
 	"chat-service/middleware"
-	"chat-service/storage"
+	"chat-service/service"
 	"encoding/json"
 	"log"
 	"net/http"
 )
 
-// isUserAllowedToDeleteChat проверяет, имеет ли пользователь права на удаление чата
-func isUserAllowedToDeleteChat(chat *storage.Chat, userID int32) bool {
-    // Если это групповой чат, проверяем, является ли пользователь создателем
-    if chat.IsGroup {
-        return chat.CreatorID == userID
-    }
-
-    // Для личных чатов проверяем, является ли пользователь участником
-    for _, memberID := range chat.MemberIDs {
-        if memberID == userID {
-            return true
-        }
-    }
-
-    return false
-}
-
-func DeleteChatHandler(storage storage.Storage) http.HandlerFunc {
+// DeleteChatHandler обрабатывает запросы на удаление чата
+func DeleteChatHandler(svc *service.Service) http.HandlerFunc {
     return func(w http.ResponseWriter, r *http.Request) {
         // Проверяем метод запроса
         if r.Method != http.MethodDelete {
@@ -48,39 +32,9 @@ func DeleteChatHandler(storage storage.Storage) http.HandlerFunc {
             return
         }
 
-        // Получаем информацию о чате
-        chat, err := storage.GetChatByID(r.Context(), chatID)
-        if err != nil {
-            switch err.Error() {
-            case "некорректный идентификатор чата":
-                http.Error(w, "Некорректный chatID", http.StatusBadRequest)
-            case "чат не найден":
-                http.Error(w, "Чат не найден", http.StatusNotFound)
-            default:
-                log.Printf("Ошибка получения чата: %v", err)
-                http.Error(w, "Не удалось получить информацию о чате", http.StatusInternalServerError)
-            }
-            return
-        }
-
-        // Проверяем права пользователя на удаление чата
-        if !isUserAllowedToDeleteChat(chat, userID) {
-            http.Error(w, "У вас нет прав на удаление этого чата", http.StatusForbidden)
-            return
-        }
-
-        // Вызываем метод хранилища для удаления чата
-        err = storage.DeleteChat(r.Context(), chatID)
-        if err != nil {
-            switch err.Error() {
-            case "некорректный идентификатор чата":
-                http.Error(w, "Некорректный chatID", http.StatusBadRequest)
-            case "чат не найден":
-                http.Error(w, "Чат не найден", http.StatusNotFound)
-            default:
-                log.Printf("Ошибка при удалении чата: %v", err)
-                http.Error(w, "Не удалось удалить чат", http.StatusInternalServerError)
-            }
+        // Удаляем чат (права проверяет сервис)
+        if err := svc.DeleteChat(r.Context(), userID, chatID); err != nil {
+            writeServiceError(w, err)
             return
         }
 
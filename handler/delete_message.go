@@ -2,17 +2,17 @@ package handler
 
 import (
 	"chat-service/middleware"
-	"chat-service/storage"
+	"chat-service/service"
 	"encoding/json"
-	"errors"
 	"log"
 	"net/http"
 
 	"github.com/gorilla/mux"
 )
 
-// DeleteMessageHandler обрабатывает запросы на удаление сообщения
-func DeleteMessageHandler(store storage.Storage) http.HandlerFunc {
+// DeleteMessageHandler обрабатывает запросы на удаление сообщения.
+// Параметр for: everyone (по умолчанию) - удалить для всех, me - только для себя.
+func DeleteMessageHandler(svc *service.Service) http.HandlerFunc {
     return func(w http.ResponseWriter, r *http.Request) {
         ctx := r.Context()
 
@@ -28,18 +28,19 @@ func DeleteMessageHandler(store storage.Storage) http.HandlerFunc {
             return
         }
 
-        // Вызов метода для удаления сообщения
-        err := store.DeleteMessage(ctx, messageID, userID)
+        // Для всех удаляются только свои сообщения, для себя - любые сообщения чата
+        var err error
+        switch r.URL.Query().Get("for") {
+        case "", "everyone":
+            err = svc.DeleteMessage(ctx, userID, messageID)
+        case "me":
+            err = svc.HideMessage(ctx, userID, messageID)
+        default:
+            http.Error(w, "некорректный параметр 'for': ожидается everyone или me", http.StatusBadRequest)
+            return
+        }
         if err != nil {
-            switch {
-            case errors.Is(err, storage.ErrInvalidMessageID):
-                http.Error(w, "Некорректный messageID", http.StatusBadRequest)
-            case errors.Is(err, storage.ErrMessageNotFound):
-                http.Error(w, "Сообщение не найдено", http.StatusNotFound)
-            default:
-                log.Printf("Ошибка при удалении сообщения: %v", err)
-                http.Error(w, "Ошибка при удалении сообщения", http.StatusInternalServerError)
-            }
+            writeServiceError(w, err)
             return
         }
 
@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"chat-service/middleware"
+	"chat-service/service"
+
+	"github.com/gorilla/mux"
+)
+
+// GetDeletedChatsHandler возвращает удалённые чаты, которые пользователь может восстановить
+func GetDeletedChatsHandler(svc *service.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Извлекаем userID из контекста
+		userID, ok := r.Context().Value(middleware.UserIDKey).(int32)
+		if !ok {
+			log.Printf("Не удалось извлечь userID из контекста")
+			http.Error(w, "Не удалось извлечь userID из токена", http.StatusInternalServerError)
+			return
+		}
+
+		chats, err := svc.ListDeletedChats(r.Context(), userID)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		if err := json.NewEncoder(w).Encode(chats); err != nil {
+			log.Printf("Ошибка при кодировании JSON: %v", err)
+		}
+	}
+}
+
+// RestoreChatHandler возвращает удалённый чат из корзины вместе с сообщениями
+func RestoreChatHandler(svc *service.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Извлекаем chatID из URL
+		chatID := mux.Vars(r)["chatID"]
+
+		// Извлекаем userID из контекста
+		userID, ok := r.Context().Value(middleware.UserIDKey).(int32)
+		if !ok {
+			log.Printf("Не удалось извлечь userID из контекста")
+			http.Error(w, "Не удалось извлечь userID из токена", http.StatusInternalServerError)
+			return
+		}
+
+		chat, err := svc.RestoreChat(r.Context(), userID, chatID)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		if err := json.NewEncoder(w).Encode(chat); err != nil {
+			log.Printf("Ошибка при кодировании JSON: %v", err)
+		}
+	}
+}
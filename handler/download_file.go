@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"log"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+
+	"chat-service/blob"
+	"chat-service/middleware"
+	"chat-service/service"
+	"chat-service/storage"
+
+	"github.com/gorilla/mux"
+)
+
+// DownloadFileHandler отдаёт файл сообщения по URL /uploads/<ключ> участникам чата.
+// Поддерживает запросы диапазонов (Range) и проверку кэша по ETag: разбор
+// заголовков выполняет http.ServeContent.
+func DownloadFileHandler(svc *service.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Извлекаем userID из контекста
+		userID, ok := r.Context().Value(middleware.UserIDKey).(int32)
+		if !ok {
+			log.Printf("Не удалось извлечь userID из контекста")
+			http.Error(w, "Не удалось извлечь userID из токена", http.StatusInternalServerError)
+			return
+		}
+
+		// Файл доступен только участникам чата, в котором он отправлен
+		attachment, content, err := svc.OpenFile(r.Context(), userID, mux.Vars(r)["key"])
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+		defer content.Close()
+
+		// Доступ зависит от участия в чате, поэтому ответ кэшируется только
+		// клиентом и перепроверяется по ETag при каждом запросе
+		serveAttachment(w, r, attachment, content, "private, no-cache")
+	}
+}
+
+// SignedDownloadHandler отдаёт файл сообщения по подписанной ссылке
+// /signed/uploads/<ключ>?expires=...&kid=...&sig=... без токена аутентификации
+func SignedDownloadHandler(svc *service.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		attachment, content, err := svc.OpenSignedFile(r.Context(), mux.Vars(r)["key"], r.URL.Query())
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+		defer content.Close()
+
+		// Ссылка действует ограниченное время, поэтому общие кэши её не хранят
+		serveAttachment(w, r, attachment, content, "private, max-age=300")
+	}
+}
+
+// serveAttachment отдаёт содержимое файла с его типом, именем и ETag
+func serveAttachment(w http.ResponseWriter, r *http.Request, attachment *storage.Attachment, content *blob.Reader, cacheControl string) {
+	filename := attachment.Filename
+	if filename == "" {
+		filename = path.Base(attachment.Key)
+	}
+
+	info := content.Info()
+	w.Header().Set("Content-Type", attachment.ContentType)
+	w.Header().Set("Content-Disposition", contentDisposition(attachment.ContentType, filename))
+	w.Header().Set("ETag", info.ETag)
+	w.Header().Set("Cache-Control", cacheControl)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+
+	http.ServeContent(w, r, filename, info.ModTime, content)
+}
+
+// contentDisposition показывает в браузере изображения, аудио и видео, а остальные
+// файлы предлагает сохранить. SVG сохраняется: он может содержать скрипты.
+func contentDisposition(contentType string, filename string) string {
+	disposition := "attachment"
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case mediaType == "image/svg+xml":
+	case strings.HasPrefix(mediaType, "image/"), strings.HasPrefix(mediaType, "audio/"), strings.HasPrefix(mediaType, "video/"):
+		disposition = "inline"
+	}
+
+	// Имя не в ASCII передаётся в параметре filename* (RFC 6266)
+	if value := mime.FormatMediaType(disposition, map[string]string{"filename": filename}); value != "" {
+		return value
+	}
+	return disposition
+}
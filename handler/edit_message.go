@@ -2,23 +2,24 @@ package handler
 
 import (
 	"encoding/json"
-	"errors"
 	"log"
 	"net/http"
 	"strings"
 
 	"chat-service/middleware"
-	"chat-service/storage"
+	"chat-service/service"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type EditMessageRequest struct {
 	NewContent string `json:"new_content"`
+	// Ревизия сообщения, которую видел клиент: защищает от одновременных правок
+	ExpectedRevision *int64 `json:"expected_revision"`
 }
 
 // EditMessageHandler handles requests to edit a message.
-func EditMessageHandler(store storage.Storage) http.HandlerFunc {
+func EditMessageHandler(svc *service.Service) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
 
@@ -55,19 +56,25 @@ func EditMessageHandler(store storage.Storage) http.HandlerFunc {
 			http.Error(w, "Новое содержимое не должно быть пустым", http.StatusBadRequest)
 			return
 		}
+		if req.ExpectedRevision == nil {
+			http.Error(w, "Не указана ожидаемая ревизия сообщения (expected_revision)", http.StatusBadRequest)
+			return
+		}
 
-		// Вызываем метод редактирования сообщения в storage
-		err := store.EditMessage(ctx, messageID, userID, req.NewContent)
+		// Редактируем сообщение (автора, участие в чате и ревизию проверяет сервис)
+		message, err := svc.EditMessage(ctx, userID, messageID, req.NewContent, *req.ExpectedRevision)
 		if err != nil {
-			handleEditMessageError(w, err)
+			writeServiceError(w, err)
 			return
 		}
 
 		// Возвращаем успешный ответ
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
-		if err := json.NewEncoder(w).Encode(map[string]string{
-			"message": "Сообщение успешно отредактировано",
+		if err := json.NewEncoder(w).Encode(map[string]interface{}{
+			"message":   "Сообщение успешно отредактировано",
+			"revision":  message.Revision,
+			"edited_at": message.EditedAt,
 		}); err != nil {
 			log.Printf("Ошибка при кодировании JSON: %v", err)
 			http.Error(w, "Не удалось отправить ответ", http.StatusInternalServerError)
@@ -84,15 +91,3 @@ func extractMessageIDFromURL(path string) string {
 func isValidMessageID(messageID string) bool {
 	return primitive.IsValidObjectID(messageID)
 }
-
-func handleEditMessageError(w http.ResponseWriter, err error) {
-	switch {
-	case errors.Is(err, storage.ErrMessageNotFound):
-		http.Error(w, "Сообщение не найдено", http.StatusNotFound)
-	case errors.Is(err, storage.ErrForbidden):
-		http.Error(w, "Вы не можете редактировать чужое сообщение", http.StatusForbidden)
-	default:
-		log.Printf("Ошибка редактирования сообщения: %v", err)
-		http.Error(w, "Не удалось отредактировать сообщение", http.StatusInternalServerError)
-	}
-}
@@ -0,0 +1,46 @@
+package handler
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"unicode"
+	"unicode/utf8"
+
+	"chat-service/service"
+)
+
+// writeServiceError преобразует доменную ошибку сервиса в HTTP-ответ
+func writeServiceError(w http.ResponseWriter, err error) {
+	var e *service.Error
+	if !errors.As(err, &e) {
+		log.Printf("Необработанная ошибка сервиса: %v", err)
+		http.Error(w, "Внутренняя ошибка сервера", http.StatusInternalServerError)
+		return
+	}
+
+	status := http.StatusInternalServerError
+	switch e.Code {
+	case service.CodeUnauthenticated:
+		status = http.StatusUnauthorized
+	case service.CodeInvalidArgument:
+		status = http.StatusBadRequest
+	case service.CodeNotFound:
+		status = http.StatusNotFound
+	case service.CodeForbidden:
+		status = http.StatusForbidden
+	case service.CodeConflict, service.CodeFailedPrecondition:
+		status = http.StatusConflict
+	}
+
+	http.Error(w, capitalize(e.Message), status)
+}
+
+// capitalize делает первую букву сообщения заглавной
+func capitalize(s string) string {
+	r, size := utf8.DecodeRuneInString(s)
+	if r == utf8.RuneError {
+		return s
+	}
+	return string(unicode.ToUpper(r)) + s[size:]
+}
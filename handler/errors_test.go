@@ -0,0 +1,45 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"chat-service/service"
+)
+
+func TestWriteServiceError(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		status  int
+		message string
+	}{
+		{"без пользователя", service.ErrUnauthenticated, http.StatusUnauthorized, "Не удалось определить пользователя"},
+		{"некорректный аргумент", service.ErrInvalidChatID, http.StatusBadRequest, "Некорректный chatID"},
+		{"не найдено", service.ErrChatNotFound, http.StatusNotFound, "Чат не найден"},
+		{"нет прав", service.ErrNotChatMember, http.StatusForbidden, "Вы не являетесь участником этого чата"},
+		{"конфликт", service.ErrReactionExists, http.StatusConflict, "Реакция уже добавлена этим пользователем"},
+		{"устаревшая ревизия", service.ErrEditConflict, http.StatusConflict, "Сообщение уже изменено: обновите его и повторите правку"},
+		{"сдвиг загрузки", service.ErrUploadOffsetMismatch, http.StatusConflict, "Позиция части не совпадает с числом принятых байт: запросите текущую позицию"},
+		{"внутренняя", &service.Error{Code: service.CodeInternal, Message: "внутренняя ошибка"}, http.StatusInternalServerError, "Внутренняя ошибка"},
+		{"обёрнутая", errors.Join(errors.New("контекст"), service.ErrMessageNotFound), http.StatusNotFound, "Сообщение не найдено"},
+		{"не доменная", errors.New("сбой базы"), http.StatusInternalServerError, "Внутренняя ошибка сервера"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			writeServiceError(rec, tt.err)
+
+			if rec.Code != tt.status {
+				t.Errorf("статус = %d, ожидался %d", rec.Code, tt.status)
+			}
+			if body := strings.TrimSpace(rec.Body.String()); body != tt.message {
+				t.Errorf("тело ответа = %q, ожидалось %q", body, tt.message)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package handler
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"chat-service/middleware"
+	"chat-service/service"
+
+	"github.com/gorilla/mux"
+)
+
+// SignFileURLHandler выдаёт подписанную ссылку на файл сообщения. Параметр
+// ttl (необязательно) - срок действия ссылки в секундах.
+func SignFileURLHandler(svc *service.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Извлекаем messageID из URL
+		messageID := mux.Vars(r)["messageID"]
+		if !isValidMessageID(messageID) {
+			http.Error(w, "Некорректный messageID", http.StatusBadRequest)
+			return
+		}
+
+		// Извлекаем userID из контекста
+		userID, ok := r.Context().Value(middleware.UserIDKey).(int32)
+		if !ok {
+			log.Printf("Не удалось извлечь userID из контекста")
+			http.Error(w, "Не удалось извлечь userID из токена", http.StatusInternalServerError)
+			return
+		}
+
+		var ttl time.Duration
+		if value := r.URL.Query().Get("ttl"); value != "" {
+			seconds, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || seconds <= 0 || seconds > int64(service.MaxSignedURLTTL/time.Second) {
+				http.Error(w, "Некорректный параметр ttl", http.StatusBadRequest)
+				return
+			}
+			ttl = time.Duration(seconds) * time.Second
+		}
+
+		// Ссылку получают только участники чата
+		signed, err := svc.SignFileURL(r.Context(), userID, messageID, ttl)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		if err := json.NewEncoder(w).Encode(signed); err != nil {
+			log.Printf("Ошибка при кодировании JSON: %v", err)
+			http.Error(w, "Не удалось отправить ответ", http.StatusInternalServerError)
+		}
+	}
+}
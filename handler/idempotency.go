@@ -0,0 +1,22 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+)
+
+// idempotencyKeyHeader - заголовок с ключом идемпотентности запроса
+const idempotencyKeyHeader = "Idempotency-Key"
+
+// clientMessageID возвращает ключ идемпотентности отправки сообщения: из заголовка
+// Idempotency-Key или из поля client_message_id запроса. Если указаны оба, они должны совпадать.
+func clientMessageID(r *http.Request, fromRequest string) (string, error) {
+	header := r.Header.Get(idempotencyKeyHeader)
+	switch {
+	case header == "":
+		return fromRequest, nil
+	case fromRequest == "" || fromRequest == header:
+		return header, nil
+	}
+	return "", errors.New("заголовок Idempotency-Key не совпадает с client_message_id")
+}
@@ -0,0 +1,163 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+
+	"chat-service/middleware"
+	"chat-service/service"
+	"chat-service/storage"
+
+	"github.com/gorilla/mux"
+)
+
+// SetApprovalRequiredHandler включает или выключает одобрение заявок на вступление.
+// Тело запроса: {"approval_required": true}.
+func SetApprovalRequiredHandler(svc *service.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Извлекаем chatID из URL
+		chatID := mux.Vars(r)["chatID"]
+
+		// Извлекаем userID из контекста
+		userID, ok := r.Context().Value(middleware.UserIDKey).(int32)
+		if !ok {
+			log.Printf("Не удалось извлечь userID из контекста")
+			http.Error(w, "Не удалось извлечь userID из токена", http.StatusInternalServerError)
+			return
+		}
+
+		var req struct {
+			ApprovalRequired *bool `json:"approval_required"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Некорректный запрос", http.StatusBadRequest)
+			return
+		}
+
+		if req.ApprovalRequired == nil {
+			http.Error(w, "Не указано значение approval_required", http.StatusBadRequest)
+			return
+		}
+
+		chat, err := svc.SetApprovalRequired(r.Context(), userID, chatID, *req.ApprovalRequired)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		if err := json.NewEncoder(w).Encode(chat); err != nil {
+			log.Printf("Ошибка при кодировании JSON: %v", err)
+		}
+	}
+}
+
+// RequestToJoinHandler подаёт заявку текущего пользователя на вступление в чат.
+// Тело запроса необязательно: {"message": "сопроводительный текст"}.
+func RequestToJoinHandler(svc *service.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Извлекаем chatID из URL
+		chatID := mux.Vars(r)["chatID"]
+
+		// Извлекаем userID из контекста
+		userID, ok := r.Context().Value(middleware.UserIDKey).(int32)
+		if !ok {
+			log.Printf("Не удалось извлечь userID из контекста")
+			http.Error(w, "Не удалось извлечь userID из токена", http.StatusInternalServerError)
+			return
+		}
+
+		var req struct {
+			Message string `json:"message"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+			http.Error(w, "Неверный формат данных", http.StatusBadRequest)
+			return
+		}
+
+		request, err := svc.RequestToJoin(r.Context(), userID, chatID, req.Message)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		if err := json.NewEncoder(w).Encode(request); err != nil {
+			log.Printf("Ошибка при кодировании JSON: %v", err)
+		}
+	}
+}
+
+// GetJoinRequestsHandler возвращает заявки на вступление в чат.
+// Параметр status (pending, approved, rejected) по умолчанию равен pending.
+func GetJoinRequestsHandler(svc *service.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Извлекаем chatID из URL
+		chatID := mux.Vars(r)["chatID"]
+
+		// Извлекаем userID из контекста
+		userID, ok := r.Context().Value(middleware.UserIDKey).(int32)
+		if !ok {
+			log.Printf("Не удалось извлечь userID из контекста")
+			http.Error(w, "Не удалось извлечь userID из токена", http.StatusInternalServerError)
+			return
+		}
+
+		requests, err := svc.ListJoinRequests(r.Context(), userID, chatID, r.URL.Query().Get("status"))
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		if err := json.NewEncoder(w).Encode(requests); err != nil {
+			log.Printf("Ошибка при кодировании JSON: %v", err)
+		}
+	}
+}
+
+// ApproveJoinRequestHandler одобряет заявку и добавляет заявителя в чат
+func ApproveJoinRequestHandler(svc *service.Service) http.HandlerFunc {
+	return decideJoinRequestHandler(svc.ApproveJoinRequest)
+}
+
+// RejectJoinRequestHandler отклоняет заявку на вступление
+func RejectJoinRequestHandler(svc *service.Service) http.HandlerFunc {
+	return decideJoinRequestHandler(svc.RejectJoinRequest)
+}
+
+// decideJoinRequestHandler применяет решение к заявке из URL и возвращает её.
+// Права проверяет сервис: решения принимают администраторы и владелец чата.
+func decideJoinRequestHandler(decide func(context.Context, int32, string) (*storage.JoinRequest, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Извлекаем ID заявки из URL
+		requestID := mux.Vars(r)["requestID"]
+
+		// Извлекаем userID из контекста
+		userID, ok := r.Context().Value(middleware.UserIDKey).(int32)
+		if !ok {
+			log.Printf("Не удалось извлечь userID из контекста")
+			http.Error(w, "Не удалось извлечь userID из токена", http.StatusInternalServerError)
+			return
+		}
+
+		request, err := decide(r.Context(), userID, requestID)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		if err := json.NewEncoder(w).Encode(request); err != nil {
+			log.Printf("Ошибка при кодировании JSON: %v", err)
+		}
+	}
+}
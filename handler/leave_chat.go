@@ -2,8 +2,7 @@ package handler
 
 import (
 	"chat-service/middleware"
-	"chat-service/storage"
-	"errors"
+	"chat-service/service"
 	"log"
 	"net/http"
 
@@ -11,7 +10,7 @@ import (
 )
 
 // LeaveChatHandler обрабатывает запросы на выход пользователя из чата.
-func LeaveChatHandler(store storage.Storage) http.HandlerFunc {
+func LeaveChatHandler(svc *service.Service) http.HandlerFunc {
     return func(w http.ResponseWriter, r *http.Request) {
         ctx := r.Context()
 
@@ -27,18 +26,9 @@ func LeaveChatHandler(store storage.Storage) http.HandlerFunc {
             return
         }
 
-        // Вызываем метод для выхода из чата
-        err := store.LeaveChat(ctx, chatID, userID)
-        if err != nil {
-            switch {
-            case errors.Is(err, storage.ErrInvalidChatID):
-                http.Error(w, "Некорректный chatID", http.StatusBadRequest)
-            case errors.Is(err, storage.ErrChatNotFound):
-                http.Error(w, "Чат не найден", http.StatusNotFound)
-            default:
-                log.Printf("Ошибка выхода из чата: %v", err)
-                http.Error(w, "Ошибка выхода из чата", http.StatusInternalServerError)
-            }
+        // Выходим из чата
+        if err := svc.LeaveChat(ctx, userID, chatID); err != nil {
+            writeServiceError(w, err)
             return
         }
 
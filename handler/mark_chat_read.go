@@ -0,0 +1,57 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+
+	"chat-service/middleware"
+	"chat-service/service"
+
+	"github.com/gorilla/mux"
+)
+
+// MarkChatReadHandler отмечает прочитанными все сообщения чата до message_id включительно.
+// Если тело запроса пустое или message_id не указан, отмечаются все сообщения чата.
+func MarkChatReadHandler(svc *service.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Извлекаем chatID из URL
+		chatID := mux.Vars(r)["chatID"]
+
+		// Извлекаем userID из контекста
+		userID, ok := r.Context().Value(middleware.UserIDKey).(int32)
+		if !ok {
+			log.Printf("Не удалось извлечь userID из контекста")
+			http.Error(w, "Не удалось извлечь userID из токена", http.StatusInternalServerError)
+			return
+		}
+
+		// Декодируем необязательное тело запроса
+		var req struct {
+			MessageID string `json:"message_id"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+			http.Error(w, "Неверный формат данных", http.StatusBadRequest)
+			return
+		}
+
+		if req.MessageID != "" && !isValidMessageID(req.MessageID) {
+			http.Error(w, "Некорректный message_id", http.StatusBadRequest)
+			return
+		}
+
+		// Сдвигаем отметку прочтения (доступно только участникам чата)
+		if err := svc.MarkChatRead(r.Context(), userID, chatID, req.MessageID); err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		if err := json.NewEncoder(w).Encode(map[string]string{"status": "success"}); err != nil {
+			log.Printf("Ошибка при кодировании JSON: %v", err)
+		}
+	}
+}
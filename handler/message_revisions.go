@@ -0,0 +1,49 @@
+package handler
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"chat-service/middleware"
+	"chat-service/service"
+
+	"github.com/gorilla/mux"
+)
+
+// GetMessageRevisionsHandler возвращает историю правок сообщения от первой версии
+// к текущей
+func GetMessageRevisionsHandler(svc *service.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Извлекаем messageID из URL
+		messageID := mux.Vars(r)["messageID"]
+		if !isValidMessageID(messageID) {
+			http.Error(w, "Некорректный messageID", http.StatusBadRequest)
+			return
+		}
+
+		// Извлекаем userID из контекста
+		userID, ok := r.Context().Value(middleware.UserIDKey).(int32)
+		if !ok {
+			log.Printf("Не удалось извлечь userID из контекста")
+			http.Error(w, "Не удалось извлечь userID из токена", http.StatusInternalServerError)
+			return
+		}
+
+		// Историю правок видят только участники чата
+		revisions, err := svc.GetMessageRevisions(r.Context(), userID, messageID)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		if err := json.NewEncoder(w).Encode(map[string]interface{}{
+			"revisions": revisions,
+		}); err != nil {
+			log.Printf("Ошибка при кодировании JSON: %v", err)
+			http.Error(w, "Не удалось отправить ответ", http.StatusInternalServerError)
+		}
+	}
+}
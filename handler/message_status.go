@@ -1,7 +1,8 @@
 package handler
 
 import (
-	"chat-service/storage"
+	"chat-service/middleware"
+	"chat-service/service"
 	"encoding/json"
 	"log"
 	"net/http"
@@ -9,7 +10,7 @@ import (
 	"github.com/gorilla/mux"
 )
 
-func UpdateMessageStatusHandler(store storage.Storage) http.HandlerFunc {
+func UpdateMessageStatusHandler(svc *service.Service) http.HandlerFunc {
     return func(w http.ResponseWriter, r *http.Request) {
         ctx := r.Context()
 
@@ -18,6 +19,14 @@ func UpdateMessageStatusHandler(store storage.Storage) http.HandlerFunc {
         messageID := vars["messageID"]
         log.Printf("Получен запрос на обновление статуса для messageID: %s", messageID)
 
+        // Получаем userID из контекста
+        userID, ok := ctx.Value(middleware.UserIDKey).(int32)
+        if !ok {
+            log.Printf("Не удалось извлечь userID из контекста")
+            http.Error(w, "Не удалось извлечь userID", http.StatusInternalServerError)
+            return
+        }
+
         // Декодируем тело запроса
         var req struct {
             Status string `json:"status"`
@@ -30,10 +39,10 @@ func UpdateMessageStatusHandler(store storage.Storage) http.HandlerFunc {
 
         log.Printf("Получен статус: %s", req.Status)
 
-        // Обновляем статус сообщения
-        if err := store.UpdateMessageStatus(ctx, messageID, req.Status); err != nil {
+        // Отмечаем сообщения чата до messageID доставленными или прочитанными (доступно только участникам чата)
+        if err := svc.UpdateMessageStatus(ctx, userID, messageID, req.Status); err != nil {
             log.Printf("Ошибка обновления статуса: %v", err)
-            http.Error(w, err.Error(), http.StatusBadRequest)
+            writeServiceError(w, err)
             return
         }
 
@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"chat-service/middleware"
+	"chat-service/service"
+
+	"github.com/gorilla/mux"
+)
+
+// GetMessageThreadHandler возвращает корневое сообщение ветки и ответы на него.
+// Параметры пагинации те же, что и у истории чата: before, after, before_seq, after_seq, limit.
+func GetMessageThreadHandler(svc *service.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Извлекаем messageID из URL
+		messageID := mux.Vars(r)["messageID"]
+		if !isValidMessageID(messageID) {
+			http.Error(w, "Некорректный messageID", http.StatusBadRequest)
+			return
+		}
+
+		// Извлекаем userID из контекста
+		userID, ok := r.Context().Value(middleware.UserIDKey).(int32)
+		if !ok {
+			log.Printf("Не удалось извлечь userID из контекста")
+			http.Error(w, "Не удалось извлечь userID из токена", http.StatusInternalServerError)
+			return
+		}
+
+		// Получаем параметры пагинации
+		query, err := parsePaginationParams(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// Получаем ветку (доступна только участникам чата)
+		root, page, err := svc.GetThread(r.Context(), userID, messageID, query)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		// Возвращаем корневое сообщение с числом ответов и страницу ответов от новых к старым
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		if err := json.NewEncoder(w).Encode(map[string]interface{}{
+			"root":        root,
+			"messages":    page.Messages,
+			"next_cursor": page.NextCursor,
+			"prev_cursor": page.PrevCursor,
+		}); err != nil {
+			log.Printf("Ошибка при кодировании JSON: %v", err)
+			http.Error(w, "Не удалось отправить ответ", http.StatusInternalServerError)
+		}
+	}
+}
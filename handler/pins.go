@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"chat-service/middleware"
+	"chat-service/service"
+	"chat-service/storage"
+
+	"github.com/gorilla/mux"
+)
+
+// PinMessageHandler закрепляет сообщение чата: принимает {"message_id": ...}.
+// Права проверяет сервис: в группах закрепляют администраторы и владелец.
+func PinMessageHandler(svc *service.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Извлекаем chatID из URL
+		chatID := mux.Vars(r)["chatID"]
+
+		// Извлекаем userID из контекста
+		userID, ok := r.Context().Value(middleware.UserIDKey).(int32)
+		if !ok {
+			log.Printf("Не удалось извлечь userID из контекста")
+			http.Error(w, "Не удалось извлечь userID из токена", http.StatusInternalServerError)
+			return
+		}
+
+		var req struct {
+			MessageID string `json:"message_id"` // ID закрепляемого сообщения
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Некорректный запрос", http.StatusBadRequest)
+			return
+		}
+
+		if !isValidMessageID(req.MessageID) {
+			http.Error(w, "Некорректный messageID", http.StatusBadRequest)
+			return
+		}
+
+		chat, err := svc.PinMessage(r.Context(), userID, chatID, req.MessageID)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		writePinnedChat(w, chat)
+	}
+}
+
+// UnpinMessageHandler открепляет сообщение чата
+func UnpinMessageHandler(svc *service.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Извлекаем chatID и messageID из URL
+		vars := mux.Vars(r)
+		chatID, messageID := vars["chatID"], vars["messageID"]
+		if !isValidMessageID(messageID) {
+			http.Error(w, "Некорректный messageID", http.StatusBadRequest)
+			return
+		}
+
+		// Извлекаем userID из контекста
+		userID, ok := r.Context().Value(middleware.UserIDKey).(int32)
+		if !ok {
+			log.Printf("Не удалось извлечь userID из контекста")
+			http.Error(w, "Не удалось извлечь userID из токена", http.StatusInternalServerError)
+			return
+		}
+
+		chat, err := svc.UnpinMessage(r.Context(), userID, chatID, messageID)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		writePinnedChat(w, chat)
+	}
+}
+
+// writePinnedChat возвращает чат с обновлённым списком закреплённых сообщений
+func writePinnedChat(w http.ResponseWriter, chat *storage.Chat) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(chat); err != nil {
+		log.Printf("Ошибка при кодировании JSON: %v", err)
+	}
+}
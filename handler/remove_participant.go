@@ -2,7 +2,7 @@ package handler
 
 import (
 	"chat-service/middleware"
-	"chat-service/storage"
+	"chat-service/service"
 	"encoding/json"
 	"log"
 	"net/http"
@@ -10,7 +10,7 @@ import (
 )
 
 // RemoveParticipantHandler обрабатывает удаление участника из группового чата
-func RemoveParticipantHandler(storage storage.Storage) http.HandlerFunc {
+func RemoveParticipantHandler(svc *service.Service) http.HandlerFunc {
     return func(w http.ResponseWriter, r *http.Request) {
         // Проверяем метод запроса
         if r.Method != http.MethodDelete {
@@ -34,27 +34,6 @@ func RemoveParticipantHandler(storage storage.Storage) http.HandlerFunc {
             return
         }
 
-        // Получаем информацию о чате
-        chat, err := storage.GetChatByID(r.Context(), chatID)
-        if err != nil {
-            switch err.Error() {
-            case "некорректный идентификатор чата":
-                http.Error(w, "Некорректный chatID", http.StatusBadRequest)
-            case "чат не найден":
-                http.Error(w, "Чат не найден", http.StatusNotFound)
-            default:
-                log.Printf("Ошибка получения чата: %v", err)
-                http.Error(w, "Не удалось получить информацию о чате", http.StatusInternalServerError)
-            }
-            return
-        }
-
-        // Проверяем права пользователя на управление участниками чата
-        if !isUserAllowedToManageParticipants(chat, userID) {
-            http.Error(w, "У вас нет прав на управление участниками этого чата", http.StatusForbidden)
-            return
-        }
-
         // Читаем тело запроса
         var req struct {
             UserID int32 `json:"user_id"` // ID удаляемого пользователя
@@ -70,20 +49,9 @@ func RemoveParticipantHandler(storage storage.Storage) http.HandlerFunc {
             return
         }
 
-        // Вызываем метод хранилища для удаления участника
-        err = storage.RemoveParticipant(r.Context(), chatID, req.UserID)
-        if err != nil {
-            switch err.Error() {
-            case "некорректный chatID":
-                http.Error(w, "Некорректный chatID", http.StatusBadRequest)
-            case "некорректный userID":
-                http.Error(w, "Некорректный ID пользователя", http.StatusBadRequest)
-            case "чат не найден или не является групповым":
-                http.Error(w, "Чат не найден или не является групповым", http.StatusNotFound)
-            default:
-                log.Printf("Ошибка при удалении участника: %v", err)
-                http.Error(w, "Не удалось удалить участника", http.StatusInternalServerError)
-            }
+        // Удаляем участника (права проверяет сервис)
+        if _, err := svc.RemoveParticipant(r.Context(), userID, chatID, req.UserID); err != nil {
+            writeServiceError(w, err)
             return
         }
 
@@ -5,13 +5,13 @@ import (
 	"net/http"
 
 	"chat-service/middleware"
-	"chat-service/storage"
+	"chat-service/service"
 
 	"github.com/gorilla/mux"
 )
 
 // RemoveReactionHandler обрабатывает запросы на удаление реакции с сообщения.
-func RemoveReactionHandler(store storage.Storage) http.HandlerFunc {
+func RemoveReactionHandler(svc *service.Service) http.HandlerFunc {
     return func(w http.ResponseWriter, r *http.Request) {
         ctx := r.Context()
 
@@ -32,8 +32,8 @@ func RemoveReactionHandler(store storage.Storage) http.HandlerFunc {
             return
         }
 
-        if err := store.RemoveReaction(ctx, messageID, req.Reaction, userID); err != nil {
-            http.Error(w, err.Error(), http.StatusBadRequest)
+        if _, err := svc.RemoveReaction(ctx, userID, messageID, req.Reaction); err != nil {
+            writeServiceError(w, err)
             return
         }
 
@@ -0,0 +1,236 @@
+package handler
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"chat-service/middleware"
+	"chat-service/service"
+	"chat-service/storage"
+
+	"github.com/gorilla/mux"
+)
+
+// Докачиваемая загрузка файлов по протоколу tus 1.0.0 (https://tus.io) с расширениями
+// creation, expiration и termination:
+//
+//	POST   /api/uploads        - открыть сеанс: Upload-Length и Upload-Metadata
+//	                             (filename, chat_id, client_message_id в base64)
+//	HEAD   /api/uploads/{id}   - узнать число принятых байт (Upload-Offset)
+//	PATCH  /api/uploads/{id}   - дописать часть с позиции Upload-Offset
+//	DELETE /api/uploads/{id}   - отменить загрузку
+//
+// Когда файл принят целиком, PATCH отправляет его в чат и вместо 204 отвечает 201
+// с сообщением, как UploadFileHandler. Если отправка не удалась, её повторяет
+// PATCH без тела с позицией, равной размеру файла.
+
+const (
+	tusVersion    = "1.0.0"
+	tusExtensions = "creation,expiration,termination"
+
+	// Тип тела запроса PATCH по протоколу tus
+	offsetContentType = "application/offset+octet-stream"
+)
+
+// uploadsPath - путь сеансов загрузки, к нему добавляется ID сеанса
+const uploadsPath = "/api/uploads/"
+
+// UploadOptionsHandler сообщает клиенту tus поддерживаемую версию и расширения протокола
+func UploadOptionsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Tus-Resumable", tusVersion)
+		w.Header().Set("Tus-Version", tusVersion)
+		w.Header().Set("Tus-Extension", tusExtensions)
+		w.Header().Set("Tus-Max-Size", strconv.FormatInt(service.MaxUploadSize, 10))
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// CreateUploadHandler открывает сеанс докачиваемой загрузки файла в чат
+func CreateUploadHandler(svc *service.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := tusRequest(w, r)
+		if !ok {
+			return
+		}
+
+		size, err := strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+		if err != nil {
+			http.Error(w, "Некорректный заголовок Upload-Length", http.StatusBadRequest)
+			return
+		}
+
+		metadata, err := parseUploadMetadata(r.Header.Get("Upload-Metadata"))
+		if err != nil {
+			http.Error(w, "Некорректный заголовок Upload-Metadata", http.StatusBadRequest)
+			return
+		}
+		if metadata["chat_id"] == "" {
+			http.Error(w, "Не указан chatID", http.StatusBadRequest)
+			return
+		}
+
+		key, err := clientMessageID(r, metadata["client_message_id"])
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// Права на отправку файла в чат проверяет сервис
+		session, err := svc.CreateUpload(r.Context(), userID, service.NewUpload{
+			ChatID:          metadata["chat_id"],
+			Filename:        metadata["filename"],
+			Size:            size,
+			ClientMessageID: key,
+		})
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		w.Header().Set("Location", uploadsPath+session.ID.Hex())
+		writeUploadState(w, session)
+		w.WriteHeader(http.StatusCreated)
+	}
+}
+
+// UploadStatusHandler сообщает, сколько байт файла принято: с этой позиции
+// клиент продолжает прерванную загрузку
+func UploadStatusHandler(svc *service.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := tusRequest(w, r)
+		if !ok {
+			return
+		}
+
+		session, err := svc.GetUpload(r.Context(), userID, mux.Vars(r)["uploadID"])
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		w.Header().Set("Upload-Length", strconv.FormatInt(session.Size, 10))
+		w.Header().Set("Cache-Control", "no-store")
+		writeUploadState(w, session)
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// AppendUploadHandler дописывает часть файла, а после последней части отправляет файл в чат
+func AppendUploadHandler(svc *service.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := tusRequest(w, r)
+		if !ok {
+			return
+		}
+
+		if r.Header.Get("Content-Type") != offsetContentType {
+			http.Error(w, "Часть файла должна передаваться с типом "+offsetContentType, http.StatusUnsupportedMediaType)
+			return
+		}
+		offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+		if err != nil || offset < 0 {
+			http.Error(w, "Некорректный заголовок Upload-Offset", http.StatusBadRequest)
+			return
+		}
+
+		uploadID := mux.Vars(r)["uploadID"]
+		session, err := svc.AppendUpload(r.Context(), userID, uploadID, offset, r.Body, r.ContentLength)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+		writeUploadState(w, session)
+
+		if !session.Complete() {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		// Файл принят целиком: собираем его и отправляем в чат
+		message, err := svc.FinalizeUpload(r.Context(), userID, uploadID)
+		if err != nil {
+			log.Printf("Ошибка отправки загруженного файла: %v", err)
+			writeServiceError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		if err := json.NewEncoder(w).Encode(map[string]string{
+			"message_id": message.ID.Hex(),
+			"file_url":   message.Content,
+			"status":     "success",
+		}); err != nil {
+			log.Printf("Ошибка при кодировании JSON: %v", err)
+		}
+	}
+}
+
+// CancelUploadHandler отменяет загрузку и удаляет принятые части файла
+func CancelUploadHandler(svc *service.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := tusRequest(w, r)
+		if !ok {
+			return
+		}
+
+		if err := svc.CancelUpload(r.Context(), userID, mux.Vars(r)["uploadID"]); err != nil {
+			writeServiceError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// tusRequest проверяет версию протокола и возвращает пользователя из контекста.
+// Если запрос обработать нельзя, ответ уже записан.
+func tusRequest(w http.ResponseWriter, r *http.Request) (int32, bool) {
+	w.Header().Set("Tus-Resumable", tusVersion)
+
+	// Клиенты без поддержки tus заголовок не передают
+	if version := r.Header.Get("Tus-Resumable"); version != "" && version != tusVersion {
+		w.Header().Set("Tus-Version", tusVersion)
+		http.Error(w, "Неподдерживаемая версия протокола tus", http.StatusPreconditionFailed)
+		return 0, false
+	}
+
+	// Извлекаем userID из контекста
+	userID, ok := r.Context().Value(middleware.UserIDKey).(int32)
+	if !ok {
+		log.Printf("Не удалось извлечь userID из контекста")
+		http.Error(w, "Не удалось извлечь userID из токена", http.StatusInternalServerError)
+		return 0, false
+	}
+	return userID, true
+}
+
+// writeUploadState передаёт в заголовках позицию и срок действия сеанса загрузки
+func writeUploadState(w http.ResponseWriter, session *storage.UploadSession) {
+	w.Header().Set("Upload-Offset", strconv.FormatInt(session.Offset, 10))
+	w.Header().Set("Upload-Expires", session.ExpiresAt.UTC().Format(http.TimeFormat))
+}
+
+// parseUploadMetadata разбирает заголовок Upload-Metadata: пары "ключ значение"
+// через запятую, значение закодировано в base64 и может отсутствовать
+func parseUploadMetadata(header string) (map[string]string, error) {
+	metadata := make(map[string]string)
+	for _, pair := range strings.Split(header, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		key, encoded, _ := strings.Cut(pair, " ")
+		value, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil {
+			return nil, err
+		}
+		metadata[key] = string(value)
+	}
+	return metadata, nil
+}
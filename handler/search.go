@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"chat-service/middleware"
+	"chat-service/service"
+	"chat-service/storage"
+)
+
+// SearchMessagesHandler ищет сообщения по тексту во всех чатах пользователя.
+// Параметры: q - текст запроса; необязательные фильтры chat_id, sender_id,
+// type, from и to (время в формате RFC 3339, to не включается); cursor и limit -
+// пагинация. Результаты упорядочены по релевантности, найденные слова
+// подсвечены позициями в фрагменте сообщения.
+func SearchMessagesHandler(svc *service.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Извлекаем userID из контекста
+		userID, ok := r.Context().Value(middleware.UserIDKey).(int32)
+		if !ok {
+			log.Printf("Не удалось извлечь userID из контекста")
+			http.Error(w, "Не удалось извлечь userID из токена", http.StatusInternalServerError)
+			return
+		}
+
+		params := r.URL.Query()
+		chatID := params.Get("chat_id")
+		if chatID != "" && !isValidChatID(chatID) {
+			http.Error(w, "Некорректный chat_id", http.StatusBadRequest)
+			return
+		}
+
+		query, err := parseSearchParams(params)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// Ищем только в чатах, участником которых является пользователь
+		page, err := svc.SearchMessages(r.Context(), userID, chatID, query)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		if err := json.NewEncoder(w).Encode(page); err != nil {
+			log.Printf("Ошибка при кодировании JSON: %v", err)
+		}
+	}
+}
+
+// parseSearchParams разбирает текст запроса, фильтры и параметры пагинации поиска
+func parseSearchParams(params url.Values) (storage.SearchQuery, error) {
+	query := storage.SearchQuery{
+		Text:   params.Get("q"),
+		Type:   params.Get("type"),
+		Cursor: params.Get("cursor"),
+	}
+
+	if value := params.Get("sender_id"); value != "" {
+		senderID, err := strconv.ParseInt(value, 10, 32)
+		if err != nil || senderID <= 0 {
+			return query, errors.New("некорректный параметр 'sender_id'")
+		}
+		query.SenderID = int32(senderID)
+	}
+
+	// Период поиска по времени отправки
+	for _, param := range []struct {
+		name string
+		time *time.Time
+	}{{"from", &query.Since}, {"to", &query.Until}} {
+		value := params.Get(param.name)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return query, fmt.Errorf("некорректный параметр '%s': ожидается время в формате RFC 3339", param.name)
+		}
+		*param.time = t
+	}
+
+	if value := params.Get("limit"); value != "" {
+		limit, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || limit <= 0 {
+			return query, errors.New("некорректный параметр 'limit'")
+		}
+		query.Limit = limit
+	}
+	return query, nil
+}
@@ -2,14 +2,17 @@ package handler
 
 import (
 	"chat-service/middleware"
+	"chat-service/service"
 	"chat-service/storage"
 	"encoding/json"
 	"log"
 	"net/http"
 )
 
-// SendMessageHandler обрабатывает отправку сообщения в чат
-func SendMessageHandler(storage storage.Storage) http.HandlerFunc {
+// SendMessageHandler обрабатывает отправку сообщения в чат.
+// Повтор запроса с тем же ключом идемпотентности (заголовок Idempotency-Key
+// или поле client_message_id) возвращает ID исходного сообщения.
+func SendMessageHandler(svc *service.Service) http.HandlerFunc {
     return func(w http.ResponseWriter, r *http.Request) {
         log.Printf("Обработка запроса на отправку сообщения")
 
@@ -32,6 +35,8 @@ func SendMessageHandler(storage storage.Storage) http.HandlerFunc {
             ChatID     string `json:"chat_id"`
             Content    string `json:"content"`
             MessageType string `json:"type"`
+            ReplyToID  string `json:"reply_to_id,omitempty"` // ID сообщения, на которое дан ответ (опционально)
+            ClientMessageID string `json:"client_message_id,omitempty"` // Ключ идемпотентности (опционально)
         }
         if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
             http.Error(w, "Некорректный запрос", http.StatusBadRequest)
@@ -44,56 +49,30 @@ func SendMessageHandler(storage storage.Storage) http.HandlerFunc {
             return
         }
 
-        // Получаем информацию о чате
-        chat, err := storage.GetChatByID(r.Context(), req.ChatID)
+        key, err := clientMessageID(r, req.ClientMessageID)
         if err != nil {
-            switch err.Error() {
-            case "некорректный идентификатор чата":
-                http.Error(w, "Некорректный chatID", http.StatusBadRequest)
-            case "чат не найден":
-                http.Error(w, "Чат не найден", http.StatusNotFound)
-            default:
-                log.Printf("Ошибка получения чата: %v", err)
-                http.Error(w, "Не удалось получить информацию о чате", http.StatusInternalServerError)
-            }
+            http.Error(w, err.Error(), http.StatusBadRequest)
             return
         }
 
-        // Проверяем права пользователя на отправку сообщения
-        if !isUserAllowedToSendMessage(chat, senderID) {
-            http.Error(w, "У вас нет прав на отправку сообщений в этот чат", http.StatusForbidden)
-            return
-        }
-
-        // Сохраняем новое сообщение
-        messageID, err := storage.SaveMessage(r.Context(), req.ChatID, senderID, req.Content, req.MessageType)
+        // Сохраняем сообщение (права на отправку проверяет сервис)
+        message, err := svc.SendMessage(r.Context(), senderID, storage.NewMessage{
+            ChatID:          req.ChatID,
+            Content:         req.Content,
+            Type:            req.MessageType,
+            ReplyToID:       req.ReplyToID,
+            ClientMessageID: key,
+        })
         if err != nil {
-            switch err.Error() {
-            case "некорректный идентификатор чата":
-                http.Error(w, "Некорректный chatID", http.StatusBadRequest)
-            default:
-                log.Printf("Ошибка при сохранении сообщения: %v", err)
-                http.Error(w, "Не удалось отправить сообщение", http.StatusInternalServerError)
-            }
+            writeServiceError(w, err)
             return
         }
 
         // Возвращаем успешный ответ с ID созданного сообщения
         w.Header().Set("Content-Type", "application/json")
         w.WriteHeader(http.StatusCreated)
-        if err := json.NewEncoder(w).Encode(map[string]string{"message_id": messageID}); err != nil {
+        if err := json.NewEncoder(w).Encode(map[string]string{"message_id": message.ID.Hex()}); err != nil {
             http.Error(w, "Не удалось отправить ответ", http.StatusInternalServerError)
         }
     }
-}
-
-// isUserAllowedToSendMessage проверяет, имеет ли пользователь права на отправку сообщений в чат
-func isUserAllowedToSendMessage(chat *storage.Chat, userID int32) bool {
-    // Проверяем, является ли пользователь участником чата
-    for _, memberID := range chat.MemberIDs {
-        if memberID == userID {
-            return true
-        }
-    }
-    return false
 }
\ No newline at end of file
@@ -1,23 +1,25 @@
 package handler
 
 import (
-	"chat-service/storage"
+	"chat-service/middleware"
+	"chat-service/service"
 	"encoding/json"
-	"fmt"
-	"io"
 	"log"
 	"net/http"
-	"os"
-	"path/filepath"
-	"time"
 
 	"github.com/gorilla/mux"
 )
 
 // SetChatAvatarHandler обрабатывает запросы на установку или обновление аватара чата.
-func SetChatAvatarHandler(store storage.Storage) http.HandlerFunc {
+func SetChatAvatarHandler(svc *service.Service) http.HandlerFunc {
     return func(w http.ResponseWriter, r *http.Request) {
-        ctx := r.Context()
+        // Извлекаем userID из контекста
+        userID, ok := r.Context().Value(middleware.UserIDKey).(int32)
+        if !ok {
+            log.Printf("Не удалось извлечь userID из контекста")
+            http.Error(w, "Не удалось извлечь userID", http.StatusInternalServerError)
+            return
+        }
 
         // Извлекаем chatID из URL
         vars := mux.Vars(r)
@@ -46,42 +48,11 @@ func SetChatAvatarHandler(store storage.Storage) http.HandlerFunc {
             return
         }
 
-        // Создаем уникальное имя для файла
-        ext := filepath.Ext(handler.Filename)
-        fileName := fmt.Sprintf("%d%s", time.Now().UnixNano(), ext)
-        filePath := filepath.Join("uploads", fileName)
-
-        // Создаем директорию для загрузки файлов, если она не существует
-        if err := os.MkdirAll("uploads", os.ModePerm); err != nil {
-            log.Printf("Ошибка создания директории для загрузки файлов: %v", err)
-            http.Error(w, "Ошибка сервера", http.StatusInternalServerError)
-            return
-        }
-
-        // Сохраняем файл на диск
-        dst, err := os.Create(filePath)
-        if err != nil {
-            log.Printf("Ошибка создания файла: %v", err)
-            http.Error(w, "Ошибка сервера", http.StatusInternalServerError)
-            return
-        }
-        defer dst.Close()
-
-        _, err = io.Copy(dst, file)
-        if err != nil {
-            log.Printf("Ошибка записи файла: %v", err)
-            http.Error(w, "Ошибка сервера", http.StatusInternalServerError)
-            return
-        }
-
-        // Генерируем URL файла
-        fileURL := fmt.Sprintf("/uploads/%s", fileName)
-
-        // Обновляем аватар чата в базе данных
-        err = store.SetChatAvatar(ctx, chatID, fileURL)
+        // Сохраняем файл в хранилище файлов и обновляем аватар чата (права проверяет сервис)
+        chat, err := svc.SetChatAvatarFile(r.Context(), userID, chatID, handler.Filename, file, handler.Size)
         if err != nil {
             log.Printf("Ошибка обновления аватара чата: %v", err)
-            http.Error(w, "Ошибка обновления аватара чата", http.StatusInternalServerError)
+            writeServiceError(w, err)
             return
         }
 
@@ -89,7 +60,7 @@ func SetChatAvatarHandler(store storage.Storage) http.HandlerFunc {
         w.Header().Set("Content-Type", "application/json")
         w.WriteHeader(http.StatusOK)
         json.NewEncoder(w).Encode(map[string]string{
-            "avatar_url": fileURL,
+            "avatar_url": chat.Avatar,
             "status":     "success",
         })
     }
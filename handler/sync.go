@@ -0,0 +1,54 @@
+package handler
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"chat-service/middleware"
+	"chat-service/service"
+)
+
+// SyncHandler возвращает изменения в чатах пользователя после предыдущей синхронизации.
+// Параметры: token - токен из предыдущего ответа (пусто при первой синхронизации),
+// limit - максимальное число сообщений в ответе. Если has_more равно true,
+// запрос нужно повторить с новым токеном.
+func SyncHandler(svc *service.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Извлекаем userID из контекста
+		userID, ok := r.Context().Value(middleware.UserIDKey).(int32)
+		if !ok {
+			log.Printf("Не удалось извлечь userID из контекста")
+			http.Error(w, "Не удалось извлечь userID из токена", http.StatusInternalServerError)
+			return
+		}
+
+		var limit int64
+		if value := r.URL.Query().Get("limit"); value != "" {
+			var err error
+			if limit, err = strconv.ParseInt(value, 10, 64); err != nil || limit < 0 {
+				http.Error(w, "некорректный параметр 'limit'", http.StatusBadRequest)
+				return
+			}
+		}
+
+		result, err := svc.Sync(r.Context(), userID, r.URL.Query().Get("token"), limit)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		if err := json.NewEncoder(w).Encode(map[string]interface{}{
+			"chats":         result.Chats,
+			"removed_chats": result.RemovedChats,
+			"messages":      result.Messages,
+			"token":         result.Token,
+			"has_more":      result.HasMore,
+		}); err != nil {
+			log.Printf("Ошибка при кодировании JSON: %v", err)
+		}
+	}
+}
@@ -6,7 +6,7 @@ import (
     "net/http"
 
     "chat-service/middleware"
-    "chat-service/storage" // Импортируем пакет storage
+    "chat-service/service"
 )
 
 // UpdateChatRequest представляет данные запроса для обновления чата
@@ -15,24 +15,8 @@ type UpdateChatRequest struct {
     Description string `json:"description,omitempty"` // Новое описание чата (опционально)
 }
 
-// isUserAllowedToUpdateChat проверяет, имеет ли пользователь права на обновление чата
-func isUserAllowedToUpdateChat(chat *storage.Chat, userID int32) bool {
-    // Если это групповой чат, проверяем, является ли пользователь создателем
-    if chat.IsGroup {
-        return chat.CreatorID == userID
-    }
-
-    // Для личных чатов проверяем, является ли пользователь участником
-    for _, memberID := range chat.MemberIDs {
-        if memberID == userID {
-            return true
-        }
-    }
-
-    return false
-}
-
-func UpdateChatHandler(storage storage.Storage) http.HandlerFunc {
+// UpdateChatHandler обрабатывает запросы на обновление информации о чате
+func UpdateChatHandler(svc *service.Service) http.HandlerFunc {
     return func(w http.ResponseWriter, r *http.Request) {
         // Проверяем метод запроса
         if r.Method != http.MethodPut {
@@ -55,27 +39,6 @@ func UpdateChatHandler(storage storage.Storage) http.HandlerFunc {
             return
         }
 
-        // Получаем информацию о чате
-        chat, err := storage.GetChatByID(r.Context(), chatID)
-        if err != nil {
-            switch err.Error() {
-            case "некорректный идентификатор чата":
-                http.Error(w, "Некорректный chatID", http.StatusBadRequest)
-            case "чат не найден":
-                http.Error(w, "Чат не найден", http.StatusNotFound)
-            default:
-                log.Printf("Ошибка получения чата: %v", err)
-                http.Error(w, "Не удалось получить информацию о чате", http.StatusInternalServerError)
-            }
-            return
-        }
-
-        // Проверяем права пользователя на обновление чата
-        if !isUserAllowedToUpdateChat(chat, userID) {
-            http.Error(w, "У вас нет прав на обновление этого чата", http.StatusForbidden)
-            return
-        }
-
         // Читаем тело запроса
         var req UpdateChatRequest
         if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -89,20 +52,9 @@ func UpdateChatHandler(storage storage.Storage) http.HandlerFunc {
             return
         }
 
-        // Вызываем метод хранилища для обновления чата
-        err = storage.UpdateChatInfo(r.Context(), chatID, req.Name, req.Description)
-        if err != nil {
-            switch err.Error() {
-            case "некорректный chatID":
-                http.Error(w, "Некорректный chatID", http.StatusBadRequest)
-            case "чат не найден или не является групповым":
-                http.Error(w, "Чат не найден или не является групповым", http.StatusNotFound)
-            case "не переданы данные для обновления":
-                http.Error(w, "Не переданы данные для обновления", http.StatusBadRequest)
-            default:
-                log.Printf("Ошибка при обновлении чата: %v", err)
-                http.Error(w, "Не удалось обновить чат", http.StatusInternalServerError)
-            }
+        // Обновляем чат (права проверяет сервис)
+        if _, err := svc.UpdateChat(r.Context(), userID, chatID, req.Name, req.Description); err != nil {
+            writeServiceError(w, err)
             return
         }
 
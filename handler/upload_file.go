@@ -2,22 +2,18 @@ package handler
 
 import (
 	"chat-service/middleware"
-	"chat-service/storage"
+	"chat-service/service"
 	"encoding/json"
-	"fmt"
-	"io"
 	"log"
 	"net/http"
-	"os"
-	"path/filepath"
-	"time"
 )
 
 // UploadFileHandler обрабатывает запросы на загрузку файла.
-func UploadFileHandler(store storage.Storage) http.HandlerFunc {
+// Повтор запроса с тем же ключом идемпотентности (заголовок Idempotency-Key
+// или поле формы client_message_id) возвращает исходное сообщение.
+// Большие файлы загружаются частями с докачкой, см. CreateUploadHandler.
+func UploadFileHandler(svc *service.Service) http.HandlerFunc {
     return func(w http.ResponseWriter, r *http.Request) {
-        ctx := r.Context()
-
         // Парсим multipart/form-data
         err := r.ParseMultipartForm(10 << 20) // Ограничение размера файла до 10 МБ
         if err != nil {
@@ -55,42 +51,17 @@ func UploadFileHandler(store storage.Storage) http.HandlerFunc {
             return
         }
 
-        // Создаем уникальное имя для файла
-        ext := filepath.Ext(handler.Filename)
-        fileName := fmt.Sprintf("%s%s", generateUniqueFileName(), ext)
-        filePath := filepath.Join("uploads", fileName)
-
-        // Создаем директорию для загрузки файлов, если она не существует
-        if err := os.MkdirAll("uploads", os.ModePerm); err != nil {
-            log.Printf("Ошибка создания директории для загрузки файлов: %v", err)
-            http.Error(w, "Ошибка сервера", http.StatusInternalServerError)
-            return
-        }
-
-        // Сохраняем файл на диск
-        dst, err := os.Create(filePath)
+        key, err := clientMessageID(r, r.FormValue("client_message_id"))
         if err != nil {
-            log.Printf("Ошибка создания файла: %v", err)
-            http.Error(w, "Ошибка сервера", http.StatusInternalServerError)
+            http.Error(w, err.Error(), http.StatusBadRequest)
             return
         }
-        defer dst.Close()
 
-        _, err = io.Copy(dst, file)
-        if err != nil {
-            log.Printf("Ошибка записи файла: %v", err)
-            http.Error(w, "Ошибка сервера", http.StatusInternalServerError)
-            return
-        }
-
-        // Генерируем URL файла
-        fileURL := fmt.Sprintf("/uploads/%s", fileName)
-
-        // Создаем сообщение с типом "file"
-        messageID, err := store.SaveMessage(ctx, chatID, userID, fileURL, "file")
+        // Сохраняем файл в хранилище файлов и создаем сообщение с типом "file" (права проверяет сервис)
+        message, err := svc.UploadFile(r.Context(), userID, chatID, handler.Filename, file, handler.Size, key)
         if err != nil {
             log.Printf("Ошибка сохранения сообщения: %v", err)
-            http.Error(w, "Ошибка сервера", http.StatusInternalServerError)
+            writeServiceError(w, err)
             return
         }
 
@@ -98,14 +69,9 @@ func UploadFileHandler(store storage.Storage) http.HandlerFunc {
         w.Header().Set("Content-Type", "application/json")
         w.WriteHeader(http.StatusCreated)
         json.NewEncoder(w).Encode(map[string]string{
-            "message_id": messageID,
-            "file_url":   fileURL,
+            "message_id": message.ID.Hex(),
+            "file_url":   message.Content,
             "status":     "success",
         })
     }
-}
-
-// Helper function to generate a unique file name
-func generateUniqueFileName() string {
-    return fmt.Sprintf("%d", time.Now().UnixNano())
 }
\ No newline at end of file
@@ -2,14 +2,14 @@ package handler
 
 import (
 	"chat-service/middleware"
-	"chat-service/storage"
+	"chat-service/service"
 	"encoding/json"
 	"log"
 	"net/http"
 )
 
 // GetUserChatsHandler возвращает обработчик для получения списка чатов пользователя
-func GetUserChatsHandler(storage storage.Storage) http.HandlerFunc {
+func GetUserChatsHandler(svc *service.Service) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Проверяем метод запроса
 		if r.Method != http.MethodGet {
@@ -28,11 +28,11 @@ func GetUserChatsHandler(storage storage.Storage) http.HandlerFunc {
 		// Логируем userID для отладки
 		log.Printf("Получен запрос на список чатов для userID: %d", userID)
 
-		// Вызываем метод хранилища для получения списка чатов
-		chats, err := storage.GetUserChats(r.Context(), userID)
+		// Получаем список чатов пользователя с превью последнего сообщения и счётчиками непрочитанного
+		chats, err := svc.ListUserChats(r.Context(), userID)
 		if err != nil {
 			log.Printf("Ошибка при получении списка чатов: %v", err)
-			http.Error(w, "Не удалось получить список чатов: "+err.Error(), http.StatusInternalServerError)
+			writeServiceError(w, err)
 			return
 		}
 
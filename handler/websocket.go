@@ -0,0 +1,110 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"chat-service/hub"
+	"chat-service/middleware"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// Максимальное время записи одного кадра клиенту
+	wsWriteWait = 10 * time.Second
+	// Время ожидания pong от клиента
+	wsPongWait = 60 * time.Second
+	// Период отправки ping, должен быть меньше wsPongWait
+	wsPingPeriod = (wsPongWait * 9) / 10
+	// Максимальный размер входящего сообщения от клиента
+	wsMaxMessageSize = 4096
+)
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	// Клиенты аутентифицируются токеном, поэтому Origin не проверяем
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// WebSocketHandler открывает WebSocket-соединение и доставляет пользователю
+// события всех чатов, в которых он состоит
+func WebSocketHandler(h *hub.Hub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Извлекаем userID из контекста (добавленного AuthMiddleware)
+		userID, ok := r.Context().Value(middleware.UserIDKey).(int32)
+		if !ok {
+			log.Printf("Не удалось извлечь userID из контекста")
+			http.Error(w, "Не удалось извлечь userID из токена", http.StatusInternalServerError)
+			return
+		}
+
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			// Upgrade сам отправляет клиенту ответ с ошибкой
+			log.Printf("Ошибка установки WebSocket-соединения: %v", err)
+			return
+		}
+
+		sub := h.Subscribe(userID)
+		log.Printf("WebSocket-соединение установлено для userID: %d", userID)
+
+		go wsWritePump(conn, sub)
+		wsReadPump(conn, sub)
+	}
+}
+
+// wsReadPump читает входящие кадры, чтобы обрабатывать pong и закрытие соединения.
+// Клиент не отправляет команды через WebSocket: все изменения идут через HTTP API.
+func wsReadPump(conn *websocket.Conn, sub *hub.Subscription) {
+	defer sub.Close()
+
+	conn.SetReadLimit(wsMaxMessageSize)
+	conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
+				log.Printf("WebSocket-соединение userID %d закрыто с ошибкой: %v", sub.UserID, err)
+			}
+			return
+		}
+	}
+}
+
+// wsWritePump отправляет клиенту события из подписки и периодический ping
+func wsWritePump(conn *websocket.Conn, sub *hub.Subscription) {
+	ticker := time.NewTicker(wsPingPeriod)
+	defer func() {
+		ticker.Stop()
+		conn.Close()
+	}()
+
+	for {
+		select {
+		case event, ok := <-sub.Events():
+			conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if !ok {
+				// Подписка закрыта хабом или читающей стороной
+				conn.WriteMessage(websocket.CloseMessage, []byte{})
+				return
+			}
+			if err := conn.WriteJSON(event); err != nil {
+				log.Printf("Ошибка отправки события userID %d: %v", sub.UserID, err)
+				sub.Close()
+				return
+			}
+		case <-ticker.C:
+			conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				sub.Close()
+				return
+			}
+		}
+	}
+}
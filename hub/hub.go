@@ -0,0 +1,165 @@
+package hub
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// Типы событий, доставляемых клиентам в реальном времени
+const (
+	EventMessageCreated  = "message.created"
+	EventMessageEdited   = "message.edited"
+	EventMessageDeleted  = "message.deleted"
+	EventReactionAdded   = "reaction.added"
+	EventReactionRemoved = "reaction.removed"
+	EventMessageStatus   = "message.status"
+
+	EventParticipantAdded    = "participant.added"
+	EventParticipantRemoved  = "participant.removed"
+	EventMemberRoleChanged   = "member.role_changed"
+	EventJoinRequestCreated  = "join_request.created"
+	EventJoinRequestApproved = "join_request.approved"
+	EventJoinRequestRejected = "join_request.rejected"
+	EventChatUpdated         = "chat.updated"
+	EventChatDeleted         = "chat.deleted"
+	EventChatRestored        = "chat.restored"
+)
+
+// Размер буфера событий одной подписки. Если клиент не успевает
+// вычитывать события, подписка закрывается и клиент должен переподключиться.
+const subscriptionBuffer = 64
+
+// Event описывает событие чата, отправляемое подписчикам
+type Event struct {
+	Type    string      `json:"type"`
+	ChatID  string      `json:"chat_id"`
+	Payload interface{} `json:"payload,omitempty"`
+	At      time.Time   `json:"at"`
+}
+
+// MessageDeletedPayload - данные события об удалении сообщения
+type MessageDeletedPayload struct {
+	MessageID string `json:"message_id"`
+}
+
+// ReactionPayload - данные события о добавлении или удалении реакции
+type ReactionPayload struct {
+	MessageID string `json:"message_id"`
+	Reaction  string `json:"reaction"`
+	UserID    int32  `json:"user_id"`
+}
+
+// StatusPayload - данные события об отметке доставки или прочтения.
+// Отметка относится ко всем сообщениям чата до MessageID включительно.
+type StatusPayload struct {
+	MessageID string `json:"message_id"`
+	UserID    int32  `json:"user_id"`
+	Status    string `json:"status"`
+}
+
+// ParticipantPayload - данные события об изменении состава чата
+type ParticipantPayload struct {
+	UserID int32 `json:"user_id"`
+}
+
+// RolePayload - данные события об изменении роли участника
+type RolePayload struct {
+	UserID int32  `json:"user_id"`
+	Role   string `json:"role"`
+}
+
+// Subscription - подписка одного соединения пользователя на события
+type Subscription struct {
+	UserID int32
+
+	hub    *Hub
+	events chan Event
+	once   sync.Once
+}
+
+// Events возвращает канал событий подписки. Канал закрывается при отписке.
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Close отменяет подписку. Повторный вызов безопасен.
+func (s *Subscription) Close() {
+	s.once.Do(func() {
+		s.hub.remove(s)
+	})
+}
+
+// Hub хранит реестр подключений пользователей и рассылает им события
+type Hub struct {
+	mu          sync.RWMutex
+	subscribers map[int32]map[*Subscription]struct{}
+}
+
+// New создаёт пустой хаб
+func New() *Hub {
+	return &Hub{subscribers: make(map[int32]map[*Subscription]struct{})}
+}
+
+// Subscribe регистрирует новое подключение пользователя.
+// У одного пользователя может быть несколько одновременных подключений.
+func (h *Hub) Subscribe(userID int32) *Subscription {
+	sub := &Subscription{
+		UserID: userID,
+		hub:    h,
+		events: make(chan Event, subscriptionBuffer),
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.subscribers[userID] == nil {
+		h.subscribers[userID] = make(map[*Subscription]struct{})
+	}
+	h.subscribers[userID][sub] = struct{}{}
+	return sub
+}
+
+// Publish рассылает событие всем подключениям перечисленных пользователей.
+// Метод не блокируется: отстающие подписки закрываются.
+func (h *Hub) Publish(userIDs []int32, event Event) {
+	if event.At.IsZero() {
+		event.At = time.Now()
+	}
+
+	var slow []*Subscription
+	h.mu.RLock()
+	for _, userID := range userIDs {
+		for sub := range h.subscribers[userID] {
+			select {
+			case sub.events <- event:
+			default:
+				slow = append(slow, sub)
+			}
+		}
+	}
+	h.mu.RUnlock()
+
+	for _, sub := range slow {
+		log.Printf("Подписка пользователя %d не успевает получать события, отключаем", sub.UserID)
+		sub.Close()
+	}
+}
+
+// IsOnline сообщает, есть ли у пользователя активные подключения
+func (h *Hub) IsOnline(userID int32) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.subscribers[userID]) > 0
+}
+
+func (h *Hub) remove(sub *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if subs, ok := h.subscribers[sub.UserID]; ok {
+		delete(subs, sub)
+		if len(subs) == 0 {
+			delete(h.subscribers, sub.UserID)
+		}
+	}
+	close(sub.events)
+}
@@ -0,0 +1,143 @@
+package hub
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// receive читает событие из подписки, не дожидаясь его дольше секунды
+func receive(t *testing.T, sub *Subscription) (Event, bool) {
+	t.Helper()
+	select {
+	case event, ok := <-sub.Events():
+		return event, ok
+	case <-time.After(time.Second):
+		t.Fatalf("подписка пользователя %d не получила событие", sub.UserID)
+		return Event{}, false
+	}
+}
+
+// assertEmpty проверяет, что в подписке нет непрочитанных событий
+func assertEmpty(t *testing.T, sub *Subscription) {
+	t.Helper()
+	select {
+	case event, ok := <-sub.Events():
+		t.Errorf("подписка пользователя %d получила лишнее событие %+v (открыта: %v)", sub.UserID, event, ok)
+	default:
+	}
+}
+
+func TestPublish(t *testing.T) {
+	h := New()
+	first, second := h.Subscribe(1), h.Subscribe(1)
+	other := h.Subscribe(2)
+	defer first.Close()
+	defer second.Close()
+	defer other.Close()
+
+	// Событие получают все подключения адресатов, пользователи без подключений пропускаются
+	h.Publish([]int32{1, 3}, Event{Type: EventMessageCreated, ChatID: "chat"})
+
+	for _, sub := range []*Subscription{first, second} {
+		event, ok := receive(t, sub)
+		if !ok || event.Type != EventMessageCreated || event.ChatID != "chat" {
+			t.Errorf("получено %+v (открыта: %v)", event, ok)
+		}
+		if event.At.IsZero() {
+			t.Error("время события не заполнено")
+		}
+	}
+	assertEmpty(t, other)
+
+	// Заданное время события сохраняется
+	at := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	h.Publish([]int32{2}, Event{Type: EventChatUpdated, At: at})
+	if event, _ := receive(t, other); !event.At.Equal(at) {
+		t.Errorf("время события = %v, ожидалось %v", event.At, at)
+	}
+	assertEmpty(t, first)
+}
+
+func TestSlowSubscriptionClosed(t *testing.T) {
+	h := New()
+	slow, fast := h.Subscribe(1), h.Subscribe(1)
+	defer fast.Close()
+
+	// Буфер отстающей подписки переполняется, и она закрывается, а соседняя
+	// подписка того же пользователя, вычитывающая события, продолжает работать
+	for i := 0; i < subscriptionBuffer+1; i++ {
+		h.Publish([]int32{1}, Event{Type: EventMessageCreated})
+		if _, ok := receive(t, fast); !ok {
+			t.Fatalf("вычитывающая подписка закрыта после %d событий", i+1)
+		}
+	}
+
+	received := 0
+	for range slow.Events() {
+		received++
+	}
+	if received != subscriptionBuffer {
+		t.Errorf("отстающая подписка получила %d событий, ожидалось %d", received, subscriptionBuffer)
+	}
+	if !h.IsOnline(1) {
+		t.Error("пользователь с действующей подпиской считается отключённым")
+	}
+
+	// Закрытая хабом подписка безопасно закрывается клиентом
+	slow.Close()
+	h.Publish([]int32{1}, Event{Type: EventMessageCreated})
+	if _, ok := receive(t, fast); !ok {
+		t.Error("вычитывающая подписка закрыта")
+	}
+}
+
+func TestCloseTwice(t *testing.T) {
+	h := New()
+	sub := h.Subscribe(1)
+	if !h.IsOnline(1) {
+		t.Fatal("пользователь с подпиской считается отключённым")
+	}
+
+	sub.Close()
+	sub.Close()
+
+	if _, ok := <-sub.Events(); ok {
+		t.Error("канал событий закрытой подписки не закрыт")
+	}
+	if h.IsOnline(1) {
+		t.Error("пользователь без подписок считается подключённым")
+	}
+
+	// Публикация после отписки не паникует и никому не доставляется
+	h.Publish([]int32{1}, Event{Type: EventMessageCreated})
+}
+
+func TestConcurrentPublishAndClose(t *testing.T) {
+	h := New()
+	var wg sync.WaitGroup
+
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				h.Publish([]int32{1, 2}, Event{Type: EventMessageCreated})
+			}
+		}()
+		go func(userID int32) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				sub := h.Subscribe(userID)
+				// Отписка параллельно с закрытием отстающей подписки хабом
+				go sub.Close()
+				sub.Close()
+			}
+		}(int32(i%2 + 1))
+	}
+	wg.Wait()
+
+	if h.IsOnline(1) || h.IsOnline(2) {
+		t.Error("после отписки всех подключений пользователь считается подключённым")
+	}
+}
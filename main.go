@@ -1,12 +1,19 @@
 package main
 
 import (
+	"chat-service/blob"
+	"chat-service/hub"
 	"chat-service/middleware"
 	"chat-service/router"
+	"chat-service/service"
+	"chat-service/signedurl"
 	"chat-service/storage"
 	chatpb "chat-service/proto/chat-service/proto"
 	authpb "chat-service/proto/auth-service/proto"
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
 	"log"
 	"net"
 	"net/http"
@@ -21,12 +28,6 @@ import (
 	"google.golang.org/grpc/status"
 )
 
-// Структура ChatService
-type ChatService struct {
-	chatpb.UnimplementedChatServiceServer
-	MongoStorage *storage.MongoStorage
-}
-
 type contextKey string
 
 const userIDKey contextKey = "user_id"
@@ -38,31 +39,66 @@ func tokenAuthInterceptor(authClient authpb.AuthServiceClient) grpc.UnaryServerI
 		info *grpc.UnaryServerInfo,
 		handler grpc.UnaryHandler,
 	) (interface{}, error) {
-		md, ok := metadata.FromIncomingContext(ctx)
-		if !ok {
-			return nil, status.Error(codes.Unauthenticated, "метаданные не найдены")
+		ctx, err := authenticate(ctx, authClient)
+		if err != nil {
+			return nil, err
 		}
 
-		authHeader, exists := md["authorization"]
-		if !exists || len(authHeader) == 0 {
-			return nil, status.Error(codes.Unauthenticated, "токен отсутствует")
-		}
+		return handler(ctx, req)
+	}
+}
 
-		// Проверяем токен через AuthService
-		resp, err := authClient.ValidateToken(ctx, &authpb.ValidateTokenRequest{Token: authHeader[0]})
+// tokenStreamAuthInterceptor проверяет токен для потоковых RPC
+func tokenStreamAuthInterceptor(authClient authpb.AuthServiceClient) grpc.StreamServerInterceptor {
+	return func(
+		srv interface{},
+		ss grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		ctx, err := authenticate(ss.Context(), authClient)
 		if err != nil {
-			return nil, status.Error(codes.Unauthenticated, "ошибка проверки токена")
+			return err
 		}
 
-		if !resp.Valid {
-			return nil, status.Error(codes.Unauthenticated, "токен недействителен")
-		}
+		return handler(srv, &authenticatedStream{ServerStream: ss, ctx: ctx})
+	}
+}
 
-		// Добавляем user_id в контекст
-		ctx = context.WithValue(ctx, userIDKey, resp.UserId)
+// authenticatedStream подменяет контекст потока контекстом с user_id
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
 
-		return handler(ctx, req)
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
+}
+
+// authenticate проверяет токен из метаданных через AuthService и добавляет user_id в контекст
+func authenticate(ctx context.Context, authClient authpb.AuthServiceClient) (context.Context, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "метаданные не найдены")
+	}
+
+	authHeader, exists := md["authorization"]
+	if !exists || len(authHeader) == 0 {
+		return nil, status.Error(codes.Unauthenticated, "токен отсутствует")
 	}
+
+	// Проверяем токен через AuthService
+	resp, err := authClient.ValidateToken(ctx, &authpb.ValidateTokenRequest{Token: authHeader[0]})
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, "ошибка проверки токена")
+	}
+
+	if !resp.Valid {
+		return nil, status.Error(codes.Unauthenticated, "токен недействителен")
+	}
+
+	// Добавляем user_id в контекст
+	return context.WithValue(ctx, userIDKey, resp.UserId), nil
 }
 
 // Функция для подключения к gRPC-сервису с повторными попытками
@@ -93,6 +129,94 @@ func connectWithRetry(ctx context.Context, target string, retries int, delay tim
 	return nil, err
 }
 
+// openStorage создаёт хранилище выбранного типа: "mongo" (по умолчанию) или "memory"
+func openStorage(backend string, mongoURI string, dbName string) (storage.Storage, error) {
+	switch backend {
+	case "", "mongo":
+	case "memory":
+		log.Printf("Используется хранилище в памяти: данные не сохраняются между перезапусками")
+		return storage.NewMemoryStorage(), nil
+	default:
+		return nil, fmt.Errorf("неизвестный тип хранилища: %q", backend)
+	}
+
+	// Подключение к MongoDB
+	mongoStorage, err := storage.NewMongoStorage(mongoURI, dbName)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка подключения к MongoDB: %w", err)
+	}
+
+	// Проверяем доступность MongoDB
+	if err := mongoStorage.Ping(context.Background()); err != nil {
+		mongoStorage.Close(context.Background())
+		return nil, fmt.Errorf("MongoDB недоступна: %w", err)
+	}
+
+	if err := mongoStorage.EnsureIndexes(context.Background()); err != nil {
+		mongoStorage.Close(context.Background())
+		return nil, fmt.Errorf("ошибка создания индексов MongoDB: %w", err)
+	}
+	return mongoStorage, nil
+}
+
+// openBlobStore создаёт хранилище файлов выбранного типа: "local" (по умолчанию) -
+// каталог BLOB_DIR, или "s3" - бакет S3-совместимого хранилища из переменных S3_*
+func openBlobStore(backend string) (blob.Store, error) {
+	switch backend {
+	case "", "local":
+		dir := os.Getenv("BLOB_DIR")
+		if dir == "" {
+			dir = "uploads"
+		}
+		log.Printf("Файлы хранятся в локальном каталоге %s: он должен быть общим для всех реплик", dir)
+		return blob.NewLocalStore(dir)
+	case "s3":
+	default:
+		return nil, fmt.Errorf("неизвестный тип хранилища файлов: %q", backend)
+	}
+
+	s3Store, err := blob.NewS3Store(blob.S3Config{
+		Endpoint:  os.Getenv("S3_ENDPOINT"),
+		Region:    os.Getenv("S3_REGION"),
+		Bucket:    os.Getenv("S3_BUCKET"),
+		AccessKey: os.Getenv("S3_ACCESS_KEY"),
+		SecretKey: os.Getenv("S3_SECRET_KEY"),
+		PathStyle: os.Getenv("S3_PATH_STYLE") == "true",
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Проверяем доступность бакета и создаём его при первом запуске
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := s3Store.EnsureBucket(ctx); err != nil {
+		return nil, fmt.Errorf("S3 недоступно: %w", err)
+	}
+	return s3Store, nil
+}
+
+// openURLSigner создаёт подпись ссылок на файлы с ключами из URL_SIGNING_KEYS
+// ("id:secret,..."; первым ключом подписываются новые ссылки). Без настройки
+// используется случайный ключ: ссылки не переживают перезапуск и действуют
+// только на той реплике, которая их выдала.
+func openURLSigner(spec string) (*signedurl.Signer, error) {
+	keys, err := signedurl.ParseKeys(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(keys) == 0 {
+		log.Printf("URL_SIGNING_KEYS не задан: подписанные ссылки действуют только до перезапуска этой реплики")
+		secret := make([]byte, signedurl.MinSecretLength)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, err
+		}
+		keys = []signedurl.Key{{ID: "local-" + hex.EncodeToString(secret[:4]), Secret: secret}}
+	}
+	return signedurl.NewSigner(keys...)
+}
+
 func main() {
 	// Читаем настройки из переменных окружения
 	mongoURI := os.Getenv("MONGO_URI")
@@ -118,20 +242,27 @@ func main() {
 		grpcPort = grpcPort[1:]
 	}
 
-	// Подключение к MongoDB
-	mongoStorage, err := storage.NewMongoStorage(mongoURI, dbName)
+	// Хранилище: MongoDB по умолчанию или память процесса (STORAGE_BACKEND=memory)
+	store, err := openStorage(os.Getenv("STORAGE_BACKEND"), mongoURI, dbName)
 	if err != nil {
-		log.Fatalf("Ошибка подключения к MongoDB: %v", err)
+		log.Fatalf("Ошибка инициализации хранилища: %v", err)
 	}
 	defer func() {
-		if err := mongoStorage.Close(context.Background()); err != nil {
-			log.Printf("Ошибка при закрытии подключения к MongoDB: %v", err)
+		if err := store.Close(context.Background()); err != nil {
+			log.Printf("Ошибка при закрытии хранилища: %v", err)
 		}
 	}()
 
-	// Проверяем доступность MongoDB
-	if err := mongoStorage.Ping(context.Background()); err != nil {
-		log.Fatalf("MongoDB недоступна: %v", err)
+	// Хранилище файлов: локальный каталог по умолчанию или S3 (BLOB_BACKEND=s3)
+	blobs, err := openBlobStore(os.Getenv("BLOB_BACKEND"))
+	if err != nil {
+		log.Fatalf("Ошибка инициализации хранилища файлов: %v", err)
+	}
+
+	// Ключи подписи ссылок на файлы: должны совпадать на всех репликах
+	signer, err := openURLSigner(os.Getenv("URL_SIGNING_KEYS"))
+	if err != nil {
+		log.Fatalf("Ошибка настройки подписи ссылок: %v", err)
 	}
 
 	// Подключение к AuthService (Api-service)
@@ -146,12 +277,25 @@ func main() {
 
 	authClient := authpb.NewAuthServiceClient(conn)
 
+	// Хаб доставки событий по WebSocket
+	eventHub := hub.New()
+
+	// Сервисный слой, общий для HTTP и gRPC
+	chatSvc := service.New(store, blobs, signer, eventHub)
+
+	// Периодическая очистка удалённых чатов и сообщений с истёкшим сроком хранения
+	// и брошенных загрузок файлов
+	purgeCtx, stopPurge := context.WithCancel(context.Background())
+	defer stopPurge()
+	go chatSvc.RunPurge(purgeCtx, time.Hour)
+
 	// Запуск gRPC-сервера
 	grpcServer := grpc.NewServer(
 		grpc.UnaryInterceptor(tokenAuthInterceptor(authClient)),
+		grpc.StreamInterceptor(tokenStreamAuthInterceptor(authClient)),
 	)
 
-	chatService := &ChatService{MongoStorage: mongoStorage}
+	chatService := &ChatService{Service: chatSvc}
 	chatpb.RegisterChatServiceServer(grpcServer, chatService)
 
 	grpcListener, err := net.Listen("tcp", ":"+grpcPort)
@@ -167,7 +311,7 @@ func main() {
 	}()
 
 	// Настройка HTTP-сервера
-	mux := router.SetupRoutes(mongoStorage, authClient)
+	mux := router.SetupRoutes(chatSvc, authClient, eventHub)
 	handlerWithMiddleware := middleware.AuthMiddleware(authClient, mux)
 
 	server := &http.Server{
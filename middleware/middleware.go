@@ -11,10 +11,32 @@ import (
 	"google.golang.org/grpc/status"
 )
 
+// Запросы по подписанным ссылкам (пути с этим префиксом) проходят без токена:
+// их подлинность обработчик проверяет по подписи в ссылке
+const SignedPathPrefix = "/signed/"
+
+// Путь докачиваемых загрузок: запрос OPTIONS к нему проходит без токена, ведь
+// клиент tus узнаёт из ответа только версию и расширения протокола
+const UploadsPath = "/api/uploads"
+
 // AuthMiddleware проверяет токен через AuthService
 func AuthMiddleware(authClient authpb.AuthServiceClient, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, SignedPathPrefix) || (r.Method == http.MethodOptions && r.URL.Path == UploadsPath) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
 		authHeader := r.Header.Get("Authorization")
+
+		// Браузеры не позволяют передать заголовок при открытии WebSocket,
+		// поэтому для запроса на upgrade принимаем токен из параметра запроса
+		if authHeader == "" && isWebSocketUpgrade(r) {
+			if token := r.URL.Query().Get("token"); token != "" {
+				authHeader = "Bearer " + token
+			}
+		}
+
 		if authHeader == "" {
 			http.Error(w, "Отсутствует токен аутентификации", http.StatusUnauthorized)
 			return
@@ -65,4 +87,10 @@ func AuthMiddleware(authClient authpb.AuthServiceClient, next http.Handler) http
 		ctx := context.WithValue(r.Context(), UserIDKey, resp.UserId)
         next.ServeHTTP(w, r.WithContext(ctx))
 	})
-}
\ No newline at end of file
+}
+
+// isWebSocketUpgrade проверяет, является ли запрос запросом на открытие WebSocket
+func isWebSocketUpgrade(r *http.Request) bool {
+	return strings.EqualFold(r.Header.Get("Upgrade"), "websocket") &&
+		strings.Contains(strings.ToLower(r.Header.Get("Connection")), "upgrade")
+}
@@ -20,21 +20,44 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	ChatService_CreateChat_FullMethodName            = "/chat.ChatService/CreateChat"
-	ChatService_GetChat_FullMethodName               = "/chat.ChatService/GetChat"
-	ChatService_UpdateChat_FullMethodName            = "/chat.ChatService/UpdateChat"
-	ChatService_DeleteChat_FullMethodName            = "/chat.ChatService/DeleteChat"
-	ChatService_ListUserChats_FullMethodName         = "/chat.ChatService/ListUserChats"
-	ChatService_SendMessage_FullMethodName           = "/chat.ChatService/SendMessage"
-	ChatService_EditMessage_FullMethodName           = "/chat.ChatService/EditMessage"
-	ChatService_DeleteMessage_FullMethodName         = "/chat.ChatService/DeleteMessage"
-	ChatService_GetMessages_FullMethodName           = "/chat.ChatService/GetMessages"
-	ChatService_AddParticipant_FullMethodName        = "/chat.ChatService/AddParticipant"
-	ChatService_RemoveParticipant_FullMethodName     = "/chat.ChatService/RemoveParticipant"
-	ChatService_ListChatParticipants_FullMethodName  = "/chat.ChatService/ListChatParticipants"
-	ChatService_SetMessageReaction_FullMethodName    = "/chat.ChatService/SetMessageReaction"
-	ChatService_RemoveMessageReaction_FullMethodName = "/chat.ChatService/RemoveMessageReaction"
-	ChatService_MarkMessageAsRead_FullMethodName     = "/chat.ChatService/MarkMessageAsRead"
+	ChatService_CreateChat_FullMethodName             = "/chat.ChatService/CreateChat"
+	ChatService_GetChat_FullMethodName                = "/chat.ChatService/GetChat"
+	ChatService_UpdateChat_FullMethodName             = "/chat.ChatService/UpdateChat"
+	ChatService_DeleteChat_FullMethodName             = "/chat.ChatService/DeleteChat"
+	ChatService_RestoreChat_FullMethodName            = "/chat.ChatService/RestoreChat"
+	ChatService_ListDeletedChats_FullMethodName       = "/chat.ChatService/ListDeletedChats"
+	ChatService_ListUserChats_FullMethodName          = "/chat.ChatService/ListUserChats"
+	ChatService_Sync_FullMethodName                   = "/chat.ChatService/Sync"
+	ChatService_SendMessage_FullMethodName            = "/chat.ChatService/SendMessage"
+	ChatService_EditMessage_FullMethodName            = "/chat.ChatService/EditMessage"
+	ChatService_GetMessageRevisions_FullMethodName    = "/chat.ChatService/GetMessageRevisions"
+	ChatService_DeleteMessage_FullMethodName          = "/chat.ChatService/DeleteMessage"
+	ChatService_GetMessages_FullMethodName            = "/chat.ChatService/GetMessages"
+	ChatService_GetThread_FullMethodName              = "/chat.ChatService/GetThread"
+	ChatService_SearchMessages_FullMethodName         = "/chat.ChatService/SearchMessages"
+	ChatService_GetFileURL_FullMethodName             = "/chat.ChatService/GetFileURL"
+	ChatService_AddParticipant_FullMethodName         = "/chat.ChatService/AddParticipant"
+	ChatService_RemoveParticipant_FullMethodName      = "/chat.ChatService/RemoveParticipant"
+	ChatService_ListChatParticipants_FullMethodName   = "/chat.ChatService/ListChatParticipants"
+	ChatService_PromoteMember_FullMethodName          = "/chat.ChatService/PromoteMember"
+	ChatService_DemoteMember_FullMethodName           = "/chat.ChatService/DemoteMember"
+	ChatService_TransferOwnership_FullMethodName      = "/chat.ChatService/TransferOwnership"
+	ChatService_PinMessage_FullMethodName             = "/chat.ChatService/PinMessage"
+	ChatService_UnpinMessage_FullMethodName           = "/chat.ChatService/UnpinMessage"
+	ChatService_CreateInvite_FullMethodName           = "/chat.ChatService/CreateInvite"
+	ChatService_ListInvites_FullMethodName            = "/chat.ChatService/ListInvites"
+	ChatService_RevokeInvite_FullMethodName           = "/chat.ChatService/RevokeInvite"
+	ChatService_JoinChatByInvite_FullMethodName       = "/chat.ChatService/JoinChatByInvite"
+	ChatService_SetJoinApproval_FullMethodName        = "/chat.ChatService/SetJoinApproval"
+	ChatService_RequestToJoinChat_FullMethodName      = "/chat.ChatService/RequestToJoinChat"
+	ChatService_ListJoinRequests_FullMethodName       = "/chat.ChatService/ListJoinRequests"
+	ChatService_ApproveJoinRequest_FullMethodName     = "/chat.ChatService/ApproveJoinRequest"
+	ChatService_RejectJoinRequest_FullMethodName      = "/chat.ChatService/RejectJoinRequest"
+	ChatService_SetMessageReaction_FullMethodName     = "/chat.ChatService/SetMessageReaction"
+	ChatService_RemoveMessageReaction_FullMethodName  = "/chat.ChatService/RemoveMessageReaction"
+	ChatService_MarkMessageAsRead_FullMethodName      = "/chat.ChatService/MarkMessageAsRead"
+	ChatService_MarkMessageAsDelivered_FullMethodName = "/chat.ChatService/MarkMessageAsDelivered"
+	ChatService_SubscribeChatEvents_FullMethodName    = "/chat.ChatService/SubscribeChatEvents"
 )
 
 // ChatServiceClient is the client API for ChatService service.
@@ -45,17 +68,40 @@ type ChatServiceClient interface {
 	GetChat(ctx context.Context, in *GetChatRequest, opts ...grpc.CallOption) (*ChatResponse, error)
 	UpdateChat(ctx context.Context, in *UpdateChatRequest, opts ...grpc.CallOption) (*ChatResponse, error)
 	DeleteChat(ctx context.Context, in *DeleteChatRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	RestoreChat(ctx context.Context, in *RestoreChatRequest, opts ...grpc.CallOption) (*ChatResponse, error)
+	ListDeletedChats(ctx context.Context, in *ListDeletedChatsRequest, opts ...grpc.CallOption) (*ListChatsResponse, error)
 	ListUserChats(ctx context.Context, in *ListUserChatsRequest, opts ...grpc.CallOption) (*ListChatsResponse, error)
+	Sync(ctx context.Context, in *SyncRequest, opts ...grpc.CallOption) (*SyncResponse, error)
 	SendMessage(ctx context.Context, in *SendMessageRequest, opts ...grpc.CallOption) (*MessageResponse, error)
 	EditMessage(ctx context.Context, in *EditMessageRequest, opts ...grpc.CallOption) (*MessageResponse, error)
+	GetMessageRevisions(ctx context.Context, in *GetMessageRevisionsRequest, opts ...grpc.CallOption) (*MessageRevisionsResponse, error)
 	DeleteMessage(ctx context.Context, in *DeleteMessageRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
 	GetMessages(ctx context.Context, in *GetMessagesRequest, opts ...grpc.CallOption) (*ListMessagesResponse, error)
+	GetThread(ctx context.Context, in *GetThreadRequest, opts ...grpc.CallOption) (*ThreadResponse, error)
+	SearchMessages(ctx context.Context, in *SearchMessagesRequest, opts ...grpc.CallOption) (*SearchMessagesResponse, error)
+	GetFileURL(ctx context.Context, in *GetFileURLRequest, opts ...grpc.CallOption) (*FileURLResponse, error)
 	AddParticipant(ctx context.Context, in *AddParticipantRequest, opts ...grpc.CallOption) (*ChatResponse, error)
 	RemoveParticipant(ctx context.Context, in *RemoveParticipantRequest, opts ...grpc.CallOption) (*ChatResponse, error)
 	ListChatParticipants(ctx context.Context, in *ListChatParticipantsRequest, opts ...grpc.CallOption) (*ListParticipantsResponse, error)
+	PromoteMember(ctx context.Context, in *ChangeMemberRoleRequest, opts ...grpc.CallOption) (*ChatResponse, error)
+	DemoteMember(ctx context.Context, in *ChangeMemberRoleRequest, opts ...grpc.CallOption) (*ChatResponse, error)
+	TransferOwnership(ctx context.Context, in *ChangeMemberRoleRequest, opts ...grpc.CallOption) (*ChatResponse, error)
+	PinMessage(ctx context.Context, in *PinMessageRequest, opts ...grpc.CallOption) (*ChatResponse, error)
+	UnpinMessage(ctx context.Context, in *PinMessageRequest, opts ...grpc.CallOption) (*ChatResponse, error)
+	CreateInvite(ctx context.Context, in *CreateInviteRequest, opts ...grpc.CallOption) (*InviteResponse, error)
+	ListInvites(ctx context.Context, in *ListInvitesRequest, opts ...grpc.CallOption) (*ListInvitesResponse, error)
+	RevokeInvite(ctx context.Context, in *RevokeInviteRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	JoinChatByInvite(ctx context.Context, in *JoinChatByInviteRequest, opts ...grpc.CallOption) (*ChatResponse, error)
+	SetJoinApproval(ctx context.Context, in *SetJoinApprovalRequest, opts ...grpc.CallOption) (*ChatResponse, error)
+	RequestToJoinChat(ctx context.Context, in *RequestToJoinChatRequest, opts ...grpc.CallOption) (*JoinRequestResponse, error)
+	ListJoinRequests(ctx context.Context, in *ListJoinRequestsRequest, opts ...grpc.CallOption) (*ListJoinRequestsResponse, error)
+	ApproveJoinRequest(ctx context.Context, in *DecideJoinRequestRequest, opts ...grpc.CallOption) (*JoinRequestResponse, error)
+	RejectJoinRequest(ctx context.Context, in *DecideJoinRequestRequest, opts ...grpc.CallOption) (*JoinRequestResponse, error)
 	SetMessageReaction(ctx context.Context, in *SetMessageReactionRequest, opts ...grpc.CallOption) (*MessageResponse, error)
 	RemoveMessageReaction(ctx context.Context, in *RemoveMessageReactionRequest, opts ...grpc.CallOption) (*MessageResponse, error)
 	MarkMessageAsRead(ctx context.Context, in *MarkMessageAsReadRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	MarkMessageAsDelivered(ctx context.Context, in *MarkMessageAsDeliveredRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	SubscribeChatEvents(ctx context.Context, in *SubscribeChatEventsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ChatEvent], error)
 }
 
 type chatServiceClient struct {
@@ -106,6 +152,26 @@ func (c *chatServiceClient) DeleteChat(ctx context.Context, in *DeleteChatReques
 	return out, nil
 }
 
+func (c *chatServiceClient) RestoreChat(ctx context.Context, in *RestoreChatRequest, opts ...grpc.CallOption) (*ChatResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ChatResponse)
+	err := c.cc.Invoke(ctx, ChatService_RestoreChat_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chatServiceClient) ListDeletedChats(ctx context.Context, in *ListDeletedChatsRequest, opts ...grpc.CallOption) (*ListChatsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListChatsResponse)
+	err := c.cc.Invoke(ctx, ChatService_ListDeletedChats_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *chatServiceClient) ListUserChats(ctx context.Context, in *ListUserChatsRequest, opts ...grpc.CallOption) (*ListChatsResponse, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(ListChatsResponse)
@@ -116,6 +182,16 @@ func (c *chatServiceClient) ListUserChats(ctx context.Context, in *ListUserChats
 	return out, nil
 }
 
+func (c *chatServiceClient) Sync(ctx context.Context, in *SyncRequest, opts ...grpc.CallOption) (*SyncResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SyncResponse)
+	err := c.cc.Invoke(ctx, ChatService_Sync_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *chatServiceClient) SendMessage(ctx context.Context, in *SendMessageRequest, opts ...grpc.CallOption) (*MessageResponse, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(MessageResponse)
@@ -136,6 +212,16 @@ func (c *chatServiceClient) EditMessage(ctx context.Context, in *EditMessageRequ
 	return out, nil
 }
 
+func (c *chatServiceClient) GetMessageRevisions(ctx context.Context, in *GetMessageRevisionsRequest, opts ...grpc.CallOption) (*MessageRevisionsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(MessageRevisionsResponse)
+	err := c.cc.Invoke(ctx, ChatService_GetMessageRevisions_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *chatServiceClient) DeleteMessage(ctx context.Context, in *DeleteMessageRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(emptypb.Empty)
@@ -156,6 +242,36 @@ func (c *chatServiceClient) GetMessages(ctx context.Context, in *GetMessagesRequ
 	return out, nil
 }
 
+func (c *chatServiceClient) GetThread(ctx context.Context, in *GetThreadRequest, opts ...grpc.CallOption) (*ThreadResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ThreadResponse)
+	err := c.cc.Invoke(ctx, ChatService_GetThread_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chatServiceClient) SearchMessages(ctx context.Context, in *SearchMessagesRequest, opts ...grpc.CallOption) (*SearchMessagesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SearchMessagesResponse)
+	err := c.cc.Invoke(ctx, ChatService_SearchMessages_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chatServiceClient) GetFileURL(ctx context.Context, in *GetFileURLRequest, opts ...grpc.CallOption) (*FileURLResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(FileURLResponse)
+	err := c.cc.Invoke(ctx, ChatService_GetFileURL_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *chatServiceClient) AddParticipant(ctx context.Context, in *AddParticipantRequest, opts ...grpc.CallOption) (*ChatResponse, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(ChatResponse)
@@ -186,6 +302,146 @@ func (c *chatServiceClient) ListChatParticipants(ctx context.Context, in *ListCh
 	return out, nil
 }
 
+func (c *chatServiceClient) PromoteMember(ctx context.Context, in *ChangeMemberRoleRequest, opts ...grpc.CallOption) (*ChatResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ChatResponse)
+	err := c.cc.Invoke(ctx, ChatService_PromoteMember_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chatServiceClient) DemoteMember(ctx context.Context, in *ChangeMemberRoleRequest, opts ...grpc.CallOption) (*ChatResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ChatResponse)
+	err := c.cc.Invoke(ctx, ChatService_DemoteMember_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chatServiceClient) TransferOwnership(ctx context.Context, in *ChangeMemberRoleRequest, opts ...grpc.CallOption) (*ChatResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ChatResponse)
+	err := c.cc.Invoke(ctx, ChatService_TransferOwnership_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chatServiceClient) PinMessage(ctx context.Context, in *PinMessageRequest, opts ...grpc.CallOption) (*ChatResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ChatResponse)
+	err := c.cc.Invoke(ctx, ChatService_PinMessage_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chatServiceClient) UnpinMessage(ctx context.Context, in *PinMessageRequest, opts ...grpc.CallOption) (*ChatResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ChatResponse)
+	err := c.cc.Invoke(ctx, ChatService_UnpinMessage_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chatServiceClient) CreateInvite(ctx context.Context, in *CreateInviteRequest, opts ...grpc.CallOption) (*InviteResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(InviteResponse)
+	err := c.cc.Invoke(ctx, ChatService_CreateInvite_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chatServiceClient) ListInvites(ctx context.Context, in *ListInvitesRequest, opts ...grpc.CallOption) (*ListInvitesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListInvitesResponse)
+	err := c.cc.Invoke(ctx, ChatService_ListInvites_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chatServiceClient) RevokeInvite(ctx context.Context, in *RevokeInviteRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, ChatService_RevokeInvite_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chatServiceClient) JoinChatByInvite(ctx context.Context, in *JoinChatByInviteRequest, opts ...grpc.CallOption) (*ChatResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ChatResponse)
+	err := c.cc.Invoke(ctx, ChatService_JoinChatByInvite_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chatServiceClient) SetJoinApproval(ctx context.Context, in *SetJoinApprovalRequest, opts ...grpc.CallOption) (*ChatResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ChatResponse)
+	err := c.cc.Invoke(ctx, ChatService_SetJoinApproval_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chatServiceClient) RequestToJoinChat(ctx context.Context, in *RequestToJoinChatRequest, opts ...grpc.CallOption) (*JoinRequestResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(JoinRequestResponse)
+	err := c.cc.Invoke(ctx, ChatService_RequestToJoinChat_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chatServiceClient) ListJoinRequests(ctx context.Context, in *ListJoinRequestsRequest, opts ...grpc.CallOption) (*ListJoinRequestsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListJoinRequestsResponse)
+	err := c.cc.Invoke(ctx, ChatService_ListJoinRequests_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chatServiceClient) ApproveJoinRequest(ctx context.Context, in *DecideJoinRequestRequest, opts ...grpc.CallOption) (*JoinRequestResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(JoinRequestResponse)
+	err := c.cc.Invoke(ctx, ChatService_ApproveJoinRequest_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chatServiceClient) RejectJoinRequest(ctx context.Context, in *DecideJoinRequestRequest, opts ...grpc.CallOption) (*JoinRequestResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(JoinRequestResponse)
+	err := c.cc.Invoke(ctx, ChatService_RejectJoinRequest_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *chatServiceClient) SetMessageReaction(ctx context.Context, in *SetMessageReactionRequest, opts ...grpc.CallOption) (*MessageResponse, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(MessageResponse)
@@ -216,6 +472,35 @@ func (c *chatServiceClient) MarkMessageAsRead(ctx context.Context, in *MarkMessa
 	return out, nil
 }
 
+func (c *chatServiceClient) MarkMessageAsDelivered(ctx context.Context, in *MarkMessageAsDeliveredRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, ChatService_MarkMessageAsDelivered_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chatServiceClient) SubscribeChatEvents(ctx context.Context, in *SubscribeChatEventsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ChatEvent], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &ChatService_ServiceDesc.Streams[0], ChatService_SubscribeChatEvents_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[SubscribeChatEventsRequest, ChatEvent]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ChatService_SubscribeChatEventsClient = grpc.ServerStreamingClient[ChatEvent]
+
 // ChatServiceServer is the server API for ChatService service.
 // All implementations must embed UnimplementedChatServiceServer
 // for forward compatibility.
@@ -224,17 +509,40 @@ type ChatServiceServer interface {
 	GetChat(context.Context, *GetChatRequest) (*ChatResponse, error)
 	UpdateChat(context.Context, *UpdateChatRequest) (*ChatResponse, error)
 	DeleteChat(context.Context, *DeleteChatRequest) (*emptypb.Empty, error)
+	RestoreChat(context.Context, *RestoreChatRequest) (*ChatResponse, error)
+	ListDeletedChats(context.Context, *ListDeletedChatsRequest) (*ListChatsResponse, error)
 	ListUserChats(context.Context, *ListUserChatsRequest) (*ListChatsResponse, error)
+	Sync(context.Context, *SyncRequest) (*SyncResponse, error)
 	SendMessage(context.Context, *SendMessageRequest) (*MessageResponse, error)
 	EditMessage(context.Context, *EditMessageRequest) (*MessageResponse, error)
+	GetMessageRevisions(context.Context, *GetMessageRevisionsRequest) (*MessageRevisionsResponse, error)
 	DeleteMessage(context.Context, *DeleteMessageRequest) (*emptypb.Empty, error)
 	GetMessages(context.Context, *GetMessagesRequest) (*ListMessagesResponse, error)
+	GetThread(context.Context, *GetThreadRequest) (*ThreadResponse, error)
+	SearchMessages(context.Context, *SearchMessagesRequest) (*SearchMessagesResponse, error)
+	GetFileURL(context.Context, *GetFileURLRequest) (*FileURLResponse, error)
 	AddParticipant(context.Context, *AddParticipantRequest) (*ChatResponse, error)
 	RemoveParticipant(context.Context, *RemoveParticipantRequest) (*ChatResponse, error)
 	ListChatParticipants(context.Context, *ListChatParticipantsRequest) (*ListParticipantsResponse, error)
+	PromoteMember(context.Context, *ChangeMemberRoleRequest) (*ChatResponse, error)
+	DemoteMember(context.Context, *ChangeMemberRoleRequest) (*ChatResponse, error)
+	TransferOwnership(context.Context, *ChangeMemberRoleRequest) (*ChatResponse, error)
+	PinMessage(context.Context, *PinMessageRequest) (*ChatResponse, error)
+	UnpinMessage(context.Context, *PinMessageRequest) (*ChatResponse, error)
+	CreateInvite(context.Context, *CreateInviteRequest) (*InviteResponse, error)
+	ListInvites(context.Context, *ListInvitesRequest) (*ListInvitesResponse, error)
+	RevokeInvite(context.Context, *RevokeInviteRequest) (*emptypb.Empty, error)
+	JoinChatByInvite(context.Context, *JoinChatByInviteRequest) (*ChatResponse, error)
+	SetJoinApproval(context.Context, *SetJoinApprovalRequest) (*ChatResponse, error)
+	RequestToJoinChat(context.Context, *RequestToJoinChatRequest) (*JoinRequestResponse, error)
+	ListJoinRequests(context.Context, *ListJoinRequestsRequest) (*ListJoinRequestsResponse, error)
+	ApproveJoinRequest(context.Context, *DecideJoinRequestRequest) (*JoinRequestResponse, error)
+	RejectJoinRequest(context.Context, *DecideJoinRequestRequest) (*JoinRequestResponse, error)
 	SetMessageReaction(context.Context, *SetMessageReactionRequest) (*MessageResponse, error)
 	RemoveMessageReaction(context.Context, *RemoveMessageReactionRequest) (*MessageResponse, error)
 	MarkMessageAsRead(context.Context, *MarkMessageAsReadRequest) (*emptypb.Empty, error)
+	MarkMessageAsDelivered(context.Context, *MarkMessageAsDeliveredRequest) (*emptypb.Empty, error)
+	SubscribeChatEvents(*SubscribeChatEventsRequest, grpc.ServerStreamingServer[ChatEvent]) error
 	mustEmbedUnimplementedChatServiceServer()
 }
 
@@ -257,21 +565,42 @@ func (UnimplementedChatServiceServer) UpdateChat(context.Context, *UpdateChatReq
 func (UnimplementedChatServiceServer) DeleteChat(context.Context, *DeleteChatRequest) (*emptypb.Empty, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method DeleteChat not implemented")
 }
+func (UnimplementedChatServiceServer) RestoreChat(context.Context, *RestoreChatRequest) (*ChatResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RestoreChat not implemented")
+}
+func (UnimplementedChatServiceServer) ListDeletedChats(context.Context, *ListDeletedChatsRequest) (*ListChatsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListDeletedChats not implemented")
+}
 func (UnimplementedChatServiceServer) ListUserChats(context.Context, *ListUserChatsRequest) (*ListChatsResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method ListUserChats not implemented")
 }
+func (UnimplementedChatServiceServer) Sync(context.Context, *SyncRequest) (*SyncResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Sync not implemented")
+}
 func (UnimplementedChatServiceServer) SendMessage(context.Context, *SendMessageRequest) (*MessageResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method SendMessage not implemented")
 }
 func (UnimplementedChatServiceServer) EditMessage(context.Context, *EditMessageRequest) (*MessageResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method EditMessage not implemented")
 }
+func (UnimplementedChatServiceServer) GetMessageRevisions(context.Context, *GetMessageRevisionsRequest) (*MessageRevisionsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetMessageRevisions not implemented")
+}
 func (UnimplementedChatServiceServer) DeleteMessage(context.Context, *DeleteMessageRequest) (*emptypb.Empty, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method DeleteMessage not implemented")
 }
 func (UnimplementedChatServiceServer) GetMessages(context.Context, *GetMessagesRequest) (*ListMessagesResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetMessages not implemented")
 }
+func (UnimplementedChatServiceServer) GetThread(context.Context, *GetThreadRequest) (*ThreadResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetThread not implemented")
+}
+func (UnimplementedChatServiceServer) SearchMessages(context.Context, *SearchMessagesRequest) (*SearchMessagesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SearchMessages not implemented")
+}
+func (UnimplementedChatServiceServer) GetFileURL(context.Context, *GetFileURLRequest) (*FileURLResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetFileURL not implemented")
+}
 func (UnimplementedChatServiceServer) AddParticipant(context.Context, *AddParticipantRequest) (*ChatResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method AddParticipant not implemented")
 }
@@ -281,6 +610,48 @@ func (UnimplementedChatServiceServer) RemoveParticipant(context.Context, *Remove
 func (UnimplementedChatServiceServer) ListChatParticipants(context.Context, *ListChatParticipantsRequest) (*ListParticipantsResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method ListChatParticipants not implemented")
 }
+func (UnimplementedChatServiceServer) PromoteMember(context.Context, *ChangeMemberRoleRequest) (*ChatResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PromoteMember not implemented")
+}
+func (UnimplementedChatServiceServer) DemoteMember(context.Context, *ChangeMemberRoleRequest) (*ChatResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DemoteMember not implemented")
+}
+func (UnimplementedChatServiceServer) TransferOwnership(context.Context, *ChangeMemberRoleRequest) (*ChatResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method TransferOwnership not implemented")
+}
+func (UnimplementedChatServiceServer) PinMessage(context.Context, *PinMessageRequest) (*ChatResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PinMessage not implemented")
+}
+func (UnimplementedChatServiceServer) UnpinMessage(context.Context, *PinMessageRequest) (*ChatResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UnpinMessage not implemented")
+}
+func (UnimplementedChatServiceServer) CreateInvite(context.Context, *CreateInviteRequest) (*InviteResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateInvite not implemented")
+}
+func (UnimplementedChatServiceServer) ListInvites(context.Context, *ListInvitesRequest) (*ListInvitesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListInvites not implemented")
+}
+func (UnimplementedChatServiceServer) RevokeInvite(context.Context, *RevokeInviteRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RevokeInvite not implemented")
+}
+func (UnimplementedChatServiceServer) JoinChatByInvite(context.Context, *JoinChatByInviteRequest) (*ChatResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method JoinChatByInvite not implemented")
+}
+func (UnimplementedChatServiceServer) SetJoinApproval(context.Context, *SetJoinApprovalRequest) (*ChatResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetJoinApproval not implemented")
+}
+func (UnimplementedChatServiceServer) RequestToJoinChat(context.Context, *RequestToJoinChatRequest) (*JoinRequestResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RequestToJoinChat not implemented")
+}
+func (UnimplementedChatServiceServer) ListJoinRequests(context.Context, *ListJoinRequestsRequest) (*ListJoinRequestsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListJoinRequests not implemented")
+}
+func (UnimplementedChatServiceServer) ApproveJoinRequest(context.Context, *DecideJoinRequestRequest) (*JoinRequestResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ApproveJoinRequest not implemented")
+}
+func (UnimplementedChatServiceServer) RejectJoinRequest(context.Context, *DecideJoinRequestRequest) (*JoinRequestResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RejectJoinRequest not implemented")
+}
 func (UnimplementedChatServiceServer) SetMessageReaction(context.Context, *SetMessageReactionRequest) (*MessageResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method SetMessageReaction not implemented")
 }
@@ -290,6 +661,12 @@ func (UnimplementedChatServiceServer) RemoveMessageReaction(context.Context, *Re
 func (UnimplementedChatServiceServer) MarkMessageAsRead(context.Context, *MarkMessageAsReadRequest) (*emptypb.Empty, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method MarkMessageAsRead not implemented")
 }
+func (UnimplementedChatServiceServer) MarkMessageAsDelivered(context.Context, *MarkMessageAsDeliveredRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method MarkMessageAsDelivered not implemented")
+}
+func (UnimplementedChatServiceServer) SubscribeChatEvents(*SubscribeChatEventsRequest, grpc.ServerStreamingServer[ChatEvent]) error {
+	return status.Errorf(codes.Unimplemented, "method SubscribeChatEvents not implemented")
+}
 func (UnimplementedChatServiceServer) mustEmbedUnimplementedChatServiceServer() {}
 func (UnimplementedChatServiceServer) testEmbeddedByValue()                     {}
 
@@ -383,6 +760,42 @@ func _ChatService_DeleteChat_Handler(srv interface{}, ctx context.Context, dec f
 	return interceptor(ctx, in, info, handler)
 }
 
+func _ChatService_RestoreChat_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RestoreChatRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChatServiceServer).RestoreChat(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ChatService_RestoreChat_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChatServiceServer).RestoreChat(ctx, req.(*RestoreChatRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ChatService_ListDeletedChats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListDeletedChatsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChatServiceServer).ListDeletedChats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ChatService_ListDeletedChats_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChatServiceServer).ListDeletedChats(ctx, req.(*ListDeletedChatsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _ChatService_ListUserChats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(ListUserChatsRequest)
 	if err := dec(in); err != nil {
@@ -401,6 +814,24 @@ func _ChatService_ListUserChats_Handler(srv interface{}, ctx context.Context, de
 	return interceptor(ctx, in, info, handler)
 }
 
+func _ChatService_Sync_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SyncRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChatServiceServer).Sync(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ChatService_Sync_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChatServiceServer).Sync(ctx, req.(*SyncRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _ChatService_SendMessage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(SendMessageRequest)
 	if err := dec(in); err != nil {
@@ -437,6 +868,24 @@ func _ChatService_EditMessage_Handler(srv interface{}, ctx context.Context, dec
 	return interceptor(ctx, in, info, handler)
 }
 
+func _ChatService_GetMessageRevisions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetMessageRevisionsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChatServiceServer).GetMessageRevisions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ChatService_GetMessageRevisions_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChatServiceServer).GetMessageRevisions(ctx, req.(*GetMessageRevisionsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _ChatService_DeleteMessage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(DeleteMessageRequest)
 	if err := dec(in); err != nil {
@@ -473,20 +922,74 @@ func _ChatService_GetMessages_Handler(srv interface{}, ctx context.Context, dec
 	return interceptor(ctx, in, info, handler)
 }
 
-func _ChatService_AddParticipant_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(AddParticipantRequest)
+func _ChatService_GetThread_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetThreadRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(ChatServiceServer).AddParticipant(ctx, in)
+		return srv.(ChatServiceServer).GetThread(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: ChatService_AddParticipant_FullMethodName,
+		FullMethod: ChatService_GetThread_FullMethodName,
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(ChatServiceServer).AddParticipant(ctx, req.(*AddParticipantRequest))
+		return srv.(ChatServiceServer).GetThread(ctx, req.(*GetThreadRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ChatService_SearchMessages_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SearchMessagesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChatServiceServer).SearchMessages(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ChatService_SearchMessages_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChatServiceServer).SearchMessages(ctx, req.(*SearchMessagesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ChatService_GetFileURL_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetFileURLRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChatServiceServer).GetFileURL(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ChatService_GetFileURL_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChatServiceServer).GetFileURL(ctx, req.(*GetFileURLRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ChatService_AddParticipant_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AddParticipantRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChatServiceServer).AddParticipant(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ChatService_AddParticipant_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChatServiceServer).AddParticipant(ctx, req.(*AddParticipantRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
@@ -527,6 +1030,258 @@ func _ChatService_ListChatParticipants_Handler(srv interface{}, ctx context.Cont
 	return interceptor(ctx, in, info, handler)
 }
 
+func _ChatService_PromoteMember_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ChangeMemberRoleRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChatServiceServer).PromoteMember(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ChatService_PromoteMember_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChatServiceServer).PromoteMember(ctx, req.(*ChangeMemberRoleRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ChatService_DemoteMember_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ChangeMemberRoleRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChatServiceServer).DemoteMember(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ChatService_DemoteMember_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChatServiceServer).DemoteMember(ctx, req.(*ChangeMemberRoleRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ChatService_TransferOwnership_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ChangeMemberRoleRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChatServiceServer).TransferOwnership(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ChatService_TransferOwnership_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChatServiceServer).TransferOwnership(ctx, req.(*ChangeMemberRoleRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ChatService_PinMessage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PinMessageRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChatServiceServer).PinMessage(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ChatService_PinMessage_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChatServiceServer).PinMessage(ctx, req.(*PinMessageRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ChatService_UnpinMessage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PinMessageRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChatServiceServer).UnpinMessage(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ChatService_UnpinMessage_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChatServiceServer).UnpinMessage(ctx, req.(*PinMessageRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ChatService_CreateInvite_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateInviteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChatServiceServer).CreateInvite(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ChatService_CreateInvite_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChatServiceServer).CreateInvite(ctx, req.(*CreateInviteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ChatService_ListInvites_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListInvitesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChatServiceServer).ListInvites(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ChatService_ListInvites_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChatServiceServer).ListInvites(ctx, req.(*ListInvitesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ChatService_RevokeInvite_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RevokeInviteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChatServiceServer).RevokeInvite(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ChatService_RevokeInvite_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChatServiceServer).RevokeInvite(ctx, req.(*RevokeInviteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ChatService_JoinChatByInvite_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(JoinChatByInviteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChatServiceServer).JoinChatByInvite(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ChatService_JoinChatByInvite_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChatServiceServer).JoinChatByInvite(ctx, req.(*JoinChatByInviteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ChatService_SetJoinApproval_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetJoinApprovalRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChatServiceServer).SetJoinApproval(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ChatService_SetJoinApproval_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChatServiceServer).SetJoinApproval(ctx, req.(*SetJoinApprovalRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ChatService_RequestToJoinChat_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RequestToJoinChatRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChatServiceServer).RequestToJoinChat(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ChatService_RequestToJoinChat_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChatServiceServer).RequestToJoinChat(ctx, req.(*RequestToJoinChatRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ChatService_ListJoinRequests_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListJoinRequestsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChatServiceServer).ListJoinRequests(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ChatService_ListJoinRequests_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChatServiceServer).ListJoinRequests(ctx, req.(*ListJoinRequestsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ChatService_ApproveJoinRequest_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DecideJoinRequestRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChatServiceServer).ApproveJoinRequest(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ChatService_ApproveJoinRequest_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChatServiceServer).ApproveJoinRequest(ctx, req.(*DecideJoinRequestRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ChatService_RejectJoinRequest_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DecideJoinRequestRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChatServiceServer).RejectJoinRequest(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ChatService_RejectJoinRequest_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChatServiceServer).RejectJoinRequest(ctx, req.(*DecideJoinRequestRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _ChatService_SetMessageReaction_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(SetMessageReactionRequest)
 	if err := dec(in); err != nil {
@@ -581,6 +1336,35 @@ func _ChatService_MarkMessageAsRead_Handler(srv interface{}, ctx context.Context
 	return interceptor(ctx, in, info, handler)
 }
 
+func _ChatService_MarkMessageAsDelivered_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MarkMessageAsDeliveredRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChatServiceServer).MarkMessageAsDelivered(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ChatService_MarkMessageAsDelivered_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChatServiceServer).MarkMessageAsDelivered(ctx, req.(*MarkMessageAsDeliveredRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ChatService_SubscribeChatEvents_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SubscribeChatEventsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ChatServiceServer).SubscribeChatEvents(m, &grpc.GenericServerStream[SubscribeChatEventsRequest, ChatEvent]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ChatService_SubscribeChatEventsServer = grpc.ServerStreamingServer[ChatEvent]
+
 // ChatService_ServiceDesc is the grpc.ServiceDesc for ChatService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -604,10 +1388,22 @@ var ChatService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "DeleteChat",
 			Handler:    _ChatService_DeleteChat_Handler,
 		},
+		{
+			MethodName: "RestoreChat",
+			Handler:    _ChatService_RestoreChat_Handler,
+		},
+		{
+			MethodName: "ListDeletedChats",
+			Handler:    _ChatService_ListDeletedChats_Handler,
+		},
 		{
 			MethodName: "ListUserChats",
 			Handler:    _ChatService_ListUserChats_Handler,
 		},
+		{
+			MethodName: "Sync",
+			Handler:    _ChatService_Sync_Handler,
+		},
 		{
 			MethodName: "SendMessage",
 			Handler:    _ChatService_SendMessage_Handler,
@@ -616,6 +1412,10 @@ var ChatService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "EditMessage",
 			Handler:    _ChatService_EditMessage_Handler,
 		},
+		{
+			MethodName: "GetMessageRevisions",
+			Handler:    _ChatService_GetMessageRevisions_Handler,
+		},
 		{
 			MethodName: "DeleteMessage",
 			Handler:    _ChatService_DeleteMessage_Handler,
@@ -624,6 +1424,18 @@ var ChatService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetMessages",
 			Handler:    _ChatService_GetMessages_Handler,
 		},
+		{
+			MethodName: "GetThread",
+			Handler:    _ChatService_GetThread_Handler,
+		},
+		{
+			MethodName: "SearchMessages",
+			Handler:    _ChatService_SearchMessages_Handler,
+		},
+		{
+			MethodName: "GetFileURL",
+			Handler:    _ChatService_GetFileURL_Handler,
+		},
 		{
 			MethodName: "AddParticipant",
 			Handler:    _ChatService_AddParticipant_Handler,
@@ -636,6 +1448,62 @@ var ChatService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "ListChatParticipants",
 			Handler:    _ChatService_ListChatParticipants_Handler,
 		},
+		{
+			MethodName: "PromoteMember",
+			Handler:    _ChatService_PromoteMember_Handler,
+		},
+		{
+			MethodName: "DemoteMember",
+			Handler:    _ChatService_DemoteMember_Handler,
+		},
+		{
+			MethodName: "TransferOwnership",
+			Handler:    _ChatService_TransferOwnership_Handler,
+		},
+		{
+			MethodName: "PinMessage",
+			Handler:    _ChatService_PinMessage_Handler,
+		},
+		{
+			MethodName: "UnpinMessage",
+			Handler:    _ChatService_UnpinMessage_Handler,
+		},
+		{
+			MethodName: "CreateInvite",
+			Handler:    _ChatService_CreateInvite_Handler,
+		},
+		{
+			MethodName: "ListInvites",
+			Handler:    _ChatService_ListInvites_Handler,
+		},
+		{
+			MethodName: "RevokeInvite",
+			Handler:    _ChatService_RevokeInvite_Handler,
+		},
+		{
+			MethodName: "JoinChatByInvite",
+			Handler:    _ChatService_JoinChatByInvite_Handler,
+		},
+		{
+			MethodName: "SetJoinApproval",
+			Handler:    _ChatService_SetJoinApproval_Handler,
+		},
+		{
+			MethodName: "RequestToJoinChat",
+			Handler:    _ChatService_RequestToJoinChat_Handler,
+		},
+		{
+			MethodName: "ListJoinRequests",
+			Handler:    _ChatService_ListJoinRequests_Handler,
+		},
+		{
+			MethodName: "ApproveJoinRequest",
+			Handler:    _ChatService_ApproveJoinRequest_Handler,
+		},
+		{
+			MethodName: "RejectJoinRequest",
+			Handler:    _ChatService_RejectJoinRequest_Handler,
+		},
 		{
 			MethodName: "SetMessageReaction",
 			Handler:    _ChatService_SetMessageReaction_Handler,
@@ -648,7 +1516,17 @@ var ChatService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "MarkMessageAsRead",
 			Handler:    _ChatService_MarkMessageAsRead_Handler,
 		},
+		{
+			MethodName: "MarkMessageAsDelivered",
+			Handler:    _ChatService_MarkMessageAsDelivered_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "SubscribeChatEvents",
+			Handler:       _ChatService_SubscribeChatEvents_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "chat.proto",
 }
@@ -2,51 +2,109 @@ package router
 
 import (
 	"chat-service/handler"
+	"chat-service/hub"
 	"chat-service/middleware"
 	authpb "chat-service/proto/auth-service/proto"
-	"chat-service/storage" // Импортируем вашу реализацию хранилища
+	"chat-service/service"
 
 	"github.com/gorilla/mux"
 )
 
 // SetupRoutes устанавливает маршруты для чатов
-func SetupRoutes(storage storage.Storage, authClient authpb.AuthServiceClient) *mux.Router {
+func SetupRoutes(svc *service.Service, authClient authpb.AuthServiceClient, h *hub.Hub) *mux.Router {
 	router := mux.NewRouter()
 	// Создание нового чата
-	router.HandleFunc("/api/chats", handler.CreateChatHandler(storage)).Methods("POST")
+	router.HandleFunc("/api/chats", handler.CreateChatHandler(svc)).Methods("POST")
 	// Получение списка чатов пользователя
-	router.HandleFunc("/api/chats", handler.GetUserChatsHandler(storage)).Methods("GET")
+	router.HandleFunc("/api/chats", handler.GetUserChatsHandler(svc)).Methods("GET")
+	// Удалённые чаты, которые можно восстановить (до маршрутов с {chatID})
+	router.HandleFunc("/api/chats/deleted", handler.GetDeletedChatsHandler(svc)).Methods("GET")
 	// Обновление информации о чате (требуется аутентификация)
-	router.Handle("/api/chats/{chatID}", middleware.AuthMiddleware(authClient, handler.UpdateChatHandler(storage))).Methods("PUT")
+	router.Handle("/api/chats/{chatID}", middleware.AuthMiddleware(authClient, handler.UpdateChatHandler(svc))).Methods("PUT")
 	// Удаление чата
-	router.HandleFunc("/api/chats/{chatID}", handler.DeleteChatHandler(storage)).Methods("DELETE")
+	router.HandleFunc("/api/chats/{chatID}", handler.DeleteChatHandler(svc)).Methods("DELETE")
 	// Получение информации о чате по его ID
-	router.HandleFunc("/api/chats/{chatID}", handler.GetChatByIDHandler(storage)).Methods("GET")
+	router.HandleFunc("/api/chats/{chatID}", handler.GetChatByIDHandler(svc)).Methods("GET")
+	// Восстановление удалённого чата
+	router.HandleFunc("/api/chats/{chatID}/restore", handler.RestoreChatHandler(svc)).Methods("POST")
 	// Установка или обновление аватара чата
-	router.HandleFunc("/api/chats/{chatID}/avatar", handler.SetChatAvatarHandler(storage)).Methods("PUT")
+	router.HandleFunc("/api/chats/{chatID}/avatar", handler.SetChatAvatarHandler(svc)).Methods("PUT")
 	// Добавление участника в чат
-	router.HandleFunc("/api/chats/{chatID}/participants", handler.AddParticipantHandler(storage)).Methods("POST")
+	router.HandleFunc("/api/chats/{chatID}/participants", handler.AddParticipantHandler(svc)).Methods("POST")
 	// Удаление участника из чата
-	router.HandleFunc("/api/chats/{chatID}/participants", handler.RemoveParticipantHandler(storage)).Methods("DELETE")
+	router.HandleFunc("/api/chats/{chatID}/participants", handler.RemoveParticipantHandler(svc)).Methods("DELETE")
 	// Отправка сообщения в чат
-	router.Handle("/api/messages", middleware.AuthMiddleware(authClient, handler.SendMessageHandler(storage))).Methods("POST")
+	router.Handle("/api/messages", middleware.AuthMiddleware(authClient, handler.SendMessageHandler(svc))).Methods("POST")
 	// Получение истории сообщений в чате
-	router.Handle("/api/chats/{chatID}/history", handler.GetChatHistoryHandler(storage)).Methods("GET")
+	router.Handle("/api/chats/{chatID}/history", handler.GetChatHistoryHandler(svc)).Methods("GET")
+	// Получение ветки ответов на сообщение
+	router.HandleFunc("/api/messages/{messageID}/thread", handler.GetMessageThreadHandler(svc)).Methods("GET")
+	// История правок сообщения
+	router.HandleFunc("/api/messages/{messageID}/revisions", handler.GetMessageRevisionsHandler(svc)).Methods("GET")
 	// Редактирование сообщения по его ID
-	router.HandleFunc("/api/messages/{messageID}", handler.EditMessageHandler(storage)).Methods("PUT")
-	// Удаление сообщения по его ID
-	router.HandleFunc("/api/messages/{messageID}", handler.DeleteMessageHandler(storage)).Methods("DELETE")
+	router.HandleFunc("/api/messages/{messageID}", handler.EditMessageHandler(svc)).Methods("PUT")
+	// Удаление сообщения по его ID (для всех или только для себя)
+	router.HandleFunc("/api/messages/{messageID}", handler.DeleteMessageHandler(svc)).Methods("DELETE")
+	// Назначение администратора группового чата
+	router.HandleFunc("/api/chats/{chatID}/admins", handler.PromoteMemberHandler(svc)).Methods("POST")
+	// Снятие прав администратора
+	router.HandleFunc("/api/chats/{chatID}/admins", handler.DemoteMemberHandler(svc)).Methods("DELETE")
+	// Передача владения групповым чатом
+	router.HandleFunc("/api/chats/{chatID}/owner", handler.TransferOwnershipHandler(svc)).Methods("PUT")
+	// Закрепление сообщения в чате
+	router.HandleFunc("/api/chats/{chatID}/pins", handler.PinMessageHandler(svc)).Methods("POST")
+	// Открепление сообщения
+	router.HandleFunc("/api/chats/{chatID}/pins/{messageID}", handler.UnpinMessageHandler(svc)).Methods("DELETE")
+	// Создание пригласительной ссылки в групповой чат
+	router.HandleFunc("/api/chats/{chatID}/invites", handler.CreateInviteHandler(svc)).Methods("POST")
+	// Получение действующих приглашений чата
+	router.HandleFunc("/api/chats/{chatID}/invites", handler.GetChatInvitesHandler(svc)).Methods("GET")
+	// Отзыв приглашения
+	router.HandleFunc("/api/invites/{token}", handler.RevokeInviteHandler(svc)).Methods("DELETE")
+	// Вступление в чат по приглашению
+	router.HandleFunc("/api/invites/{token}/join", handler.JoinByInviteHandler(svc)).Methods("POST")
+	// Включение или выключение одобрения заявок на вступление
+	router.HandleFunc("/api/chats/{chatID}/approval", handler.SetApprovalRequiredHandler(svc)).Methods("PUT")
+	// Подача заявки на вступление в чат
+	router.HandleFunc("/api/chats/{chatID}/join-requests", handler.RequestToJoinHandler(svc)).Methods("POST")
+	// Получение заявок на вступление (для администраторов)
+	router.HandleFunc("/api/chats/{chatID}/join-requests", handler.GetJoinRequestsHandler(svc)).Methods("GET")
+	// Одобрение заявки на вступление
+	router.HandleFunc("/api/join-requests/{requestID}/approve", handler.ApproveJoinRequestHandler(svc)).Methods("POST")
+	// Отклонение заявки на вступление
+	router.HandleFunc("/api/join-requests/{requestID}/reject", handler.RejectJoinRequestHandler(svc)).Methods("POST")
 	// Получение списка участников чата
-	router.HandleFunc("/api/chats/{chatID}/participants", handler.GetChatParticipantsHandler(storage)).Methods("GET")
+	router.HandleFunc("/api/chats/{chatID}/participants", handler.GetChatParticipantsHandler(svc)).Methods("GET")
+	// Отметка сообщений чата прочитанными (до указанного или до последнего)
+	router.HandleFunc("/api/chats/{chatID}/read", handler.MarkChatReadHandler(svc)).Methods("POST")
 	// Выход пользователя из чата
-	router.HandleFunc("/api/chats/{chatID}/leave", handler.LeaveChatHandler(storage)).Methods("DELETE")
+	router.HandleFunc("/api/chats/{chatID}/leave", handler.LeaveChatHandler(svc)).Methods("DELETE")
 	// Загрузка файла в сообщение
-	router.HandleFunc("/api/messages/upload", handler.UploadFileHandler(storage)).Methods("POST")
+	router.HandleFunc("/api/messages/upload", handler.UploadFileHandler(svc)).Methods("POST")
+	// Докачиваемая загрузка больших файлов частями (протокол tus); OPTIONS не требует
+	// токена, см. middleware.AuthMiddleware
+	router.HandleFunc(middleware.UploadsPath, handler.UploadOptionsHandler()).Methods("OPTIONS")
+	router.HandleFunc("/api/uploads", handler.CreateUploadHandler(svc)).Methods("POST")
+	router.HandleFunc("/api/uploads/{uploadID}", handler.UploadStatusHandler(svc)).Methods("HEAD")
+	router.HandleFunc("/api/uploads/{uploadID}", handler.AppendUploadHandler(svc)).Methods("PATCH")
+	router.HandleFunc("/api/uploads/{uploadID}", handler.CancelUploadHandler(svc)).Methods("DELETE")
+	// Скачивание файла сообщения участником чата (по URL из ответа на загрузку)
+	router.HandleFunc("/uploads/{key:.+}", handler.DownloadFileHandler(svc)).Methods("GET", "HEAD")
+	// Подписанная ссылка на файл сообщения для доступа без токена
+	router.HandleFunc("/api/messages/{messageID}/file-url", handler.SignFileURLHandler(svc)).Methods("GET")
+	// Скачивание файла по подписанной ссылке (токен не требуется, см. middleware.AuthMiddleware)
+	router.HandleFunc(middleware.SignedPathPrefix+"uploads/{key:.+}", handler.SignedDownloadHandler(svc)).Methods("GET", "HEAD")
 	// Добавление реакции на сообщение
-	router.HandleFunc("/api/messages/{messageID}/reactions", handler.AddReactionHandler(storage)).Methods("POST")
+	router.HandleFunc("/api/messages/{messageID}/reactions", handler.AddReactionHandler(svc)).Methods("POST")
 	// Удаление реакции с сообщения
-	router.HandleFunc("/api/messages/{messageID}/reactions", handler.RemoveReactionHandler(storage)).Methods("DELETE")
-	// Обновление статуса сообщения (например, прочитано/доставлено)
-	router.HandleFunc("/api/messages/{messageID}/status", handler.UpdateMessageStatusHandler(storage)).Methods("POST")
+	router.HandleFunc("/api/messages/{messageID}/reactions", handler.RemoveReactionHandler(svc)).Methods("DELETE")
+	// Отметка сообщений чата до указанного как доставленных или прочитанных
+	router.HandleFunc("/api/messages/{messageID}/status", handler.UpdateMessageStatusHandler(svc)).Methods("POST")
+	// Изменения в чатах пользователя после предыдущей синхронизации
+	router.HandleFunc("/api/sync", handler.SyncHandler(svc)).Methods("GET")
+	// Поиск сообщений по тексту во всех чатах пользователя
+	router.HandleFunc("/api/search/messages", handler.SearchMessagesHandler(svc)).Methods("GET")
+	// WebSocket-подключение для получения событий чатов в реальном времени
+	router.HandleFunc("/api/ws", handler.WebSocketHandler(h)).Methods("GET")
 	return router
 }
\ No newline at end of file
@@ -0,0 +1,343 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+
+	"chat-service/hub"
+	"chat-service/storage"
+)
+
+// CreateChat создаёт чат. Создатель автоматически становится участником.
+func (s *Service) CreateChat(ctx context.Context, userID int32, name string, description string, memberIDs []int32, isGroup bool) (*storage.Chat, error) {
+	if userID == 0 {
+		return nil, ErrUnauthenticated
+	}
+
+	if name == "" || len(memberIDs) == 0 {
+		return nil, invalidArgument("отсутствуют обязательные поля")
+	}
+
+	for _, memberID := range memberIDs {
+		if memberID <= 0 {
+			return nil, ErrInvalidUserID
+		}
+	}
+
+	// Для личных чатов должен быть указан ровно один другой участник
+	if !isGroup && (len(memberIDs) != 1 || memberIDs[0] == userID) {
+		return nil, invalidArgument("личный чат должен содержать ровно одного другого участника")
+	}
+
+	members := make([]int32, 0, len(memberIDs)+1)
+	for _, memberID := range memberIDs {
+		if memberID != userID {
+			members = append(members, memberID)
+		}
+	}
+	members = append(members, userID)
+
+	chatID, err := s.store.CreateChat(ctx, name, members, isGroup, description, userID)
+	if err != nil {
+		return nil, fromStorage(err)
+	}
+
+	return s.chat(ctx, chatID)
+}
+
+// CreateChannel создаёт канал. Создатель становится владельцем, остальные - подписчиками.
+func (s *Service) CreateChannel(ctx context.Context, userID int32, name string, description string, subscriberIDs []int32) (*storage.Chat, error) {
+	if userID == 0 {
+		return nil, ErrUnauthenticated
+	}
+
+	if name == "" {
+		return nil, invalidArgument("отсутствуют обязательные поля")
+	}
+
+	members := make([]int32, 0, len(subscriberIDs)+1)
+	for _, subscriberID := range subscriberIDs {
+		if subscriberID <= 0 {
+			return nil, ErrInvalidUserID
+		}
+		if subscriberID != userID && !containsUserID(members, subscriberID) {
+			members = append(members, subscriberID)
+		}
+	}
+	members = append(members, userID)
+
+	chatID, err := s.store.CreateChannel(ctx, name, members, description, userID)
+	if err != nil {
+		return nil, fromStorage(err)
+	}
+
+	return s.chat(ctx, chatID)
+}
+
+// GetChat возвращает чат, если пользователь является его участником
+func (s *Service) GetChat(ctx context.Context, userID int32, chatID string) (*storage.Chat, error) {
+	return s.memberChat(ctx, chatID, userID)
+}
+
+// ChatSummary - чат в списке чатов пользователя. Превью последнего сообщения
+// хранится в самом чате, счётчики непрочитанного считаются для пользователя.
+type ChatSummary struct {
+	*storage.Chat
+	UnreadCount    int64
+	UnreadMentions int64
+}
+
+// MarshalJSON дополняет JSON чата счётчиками непрочитанного. Без него
+// использовался бы метод встроенного чата, и счётчики бы потерялись.
+func (c ChatSummary) MarshalJSON() ([]byte, error) {
+	chat, err := json.Marshal(c.Chat)
+	if err != nil {
+		return nil, err
+	}
+
+	// Поля чата собираются в словарь, чтобы не зависеть от того, как именно
+	// storage.Chat оформляет свой JSON
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(chat, &fields); err != nil {
+		return nil, err
+	}
+	if fields == nil {
+		fields = make(map[string]json.RawMessage, 2)
+	}
+
+	fields["UnreadCount"], _ = json.Marshal(c.UnreadCount)
+	fields["UnreadMentions"], _ = json.Marshal(c.UnreadMentions)
+	return json.Marshal(fields)
+}
+
+// ListUserChats возвращает чаты пользователя от недавно активных к давним
+// вместе с числом непрочитанных сообщений и упоминаний
+func (s *Service) ListUserChats(ctx context.Context, userID int32) ([]*ChatSummary, error) {
+	if userID == 0 {
+		return nil, ErrUnauthenticated
+	}
+
+	chats, err := s.store.GetUserChats(ctx, userID)
+	if err != nil {
+		return nil, fromStorage(err)
+	}
+	return s.chatSummaries(ctx, userID, chats)
+}
+
+// chatSummaries дополняет чаты счётчиками непрочитанных пользователем сообщений
+func (s *Service) chatSummaries(ctx context.Context, userID int32, chats []*storage.Chat) ([]*ChatSummary, error) {
+	unread, err := s.store.CountUnread(ctx, userID, chats)
+	if err != nil {
+		return nil, fromStorage(err)
+	}
+
+	summaries := make([]*ChatSummary, 0, len(chats))
+	for _, chat := range chats {
+		count := unread[chat.ID]
+		summaries = append(summaries, &ChatSummary{
+			Chat:           chat,
+			UnreadCount:    count.Messages,
+			UnreadMentions: count.Mentions,
+		})
+	}
+	return summaries, nil
+}
+
+// UpdateChat изменяет название и описание чата
+func (s *Service) UpdateChat(ctx context.Context, userID int32, chatID string, name string, description string) (*storage.Chat, error) {
+	if name == "" && description == "" {
+		return nil, invalidArgument("должно быть указано хотя бы одно поле для обновления")
+	}
+
+	if _, err := s.updatableChat(ctx, chatID, userID); err != nil {
+		return nil, err
+	}
+
+	if err := s.store.UpdateChatInfo(ctx, chatID, name, description); err != nil {
+		return nil, fromStorage(err)
+	}
+
+	return s.chatUpdated(ctx, chatID)
+}
+
+// SetChatAvatar устанавливает URL аватара чата
+func (s *Service) SetChatAvatar(ctx context.Context, userID int32, chatID string, avatarURL string) (*storage.Chat, error) {
+	if avatarURL == "" {
+		return nil, invalidArgument("не указан аватар")
+	}
+
+	chat, err := s.updatableChat(ctx, chatID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.store.SetChatAvatar(ctx, chatID, avatarURL); err != nil {
+		return nil, fromStorage(err)
+	}
+
+	// Загруженный файл прежнего аватара больше не нужен
+	if chat.Avatar != avatarURL {
+		s.discardAvatar(chat, chat.Avatar)
+	}
+	return s.chatUpdated(ctx, chatID)
+}
+
+// DeleteChat переносит чат вместе с сообщениями в корзину. В течение срока хранения
+// чат можно восстановить через RestoreChat.
+func (s *Service) DeleteChat(ctx context.Context, userID int32, chatID string) error {
+	chat, err := s.memberChat(ctx, chatID, userID)
+	if err != nil {
+		return err
+	}
+
+	if !can(chat, userID, actionDeleteChat) {
+		return ErrCannotDeleteChat
+	}
+
+	if err := s.store.DeleteChat(ctx, chatID); err != nil {
+		return fromStorage(err)
+	}
+
+	// Состав чата уже удалён из хранилища, поэтому рассылаем по сохранённому
+	s.publish(chat, hub.EventChatDeleted, nil)
+	return nil
+}
+
+// ListParticipants возвращает страницу участников чата (подписчиков канала)
+func (s *Service) ListParticipants(ctx context.Context, userID int32, chatID string, query storage.ParticipantPageQuery) (*storage.ParticipantPage, error) {
+	if _, err := s.memberChat(ctx, chatID, userID); err != nil {
+		return nil, err
+	}
+
+	page, err := s.store.GetChatParticipantsPage(ctx, chatID, query)
+	if err != nil {
+		return nil, fromStorage(err)
+	}
+	return page, nil
+}
+
+// AddParticipant добавляет пользователя в групповой чат
+func (s *Service) AddParticipant(ctx context.Context, userID int32, chatID string, targetID int32) (*storage.Chat, error) {
+	if targetID <= 0 {
+		return nil, ErrInvalidUserID
+	}
+
+	if _, err := s.participantsManagedChat(ctx, chatID, userID, actionAddMembers); err != nil {
+		return nil, err
+	}
+
+	if err := s.store.AddParticipant(ctx, chatID, targetID); err != nil {
+		return nil, fromStorage(err)
+	}
+
+	chat := s.publishFresh(ctx, chatID, hub.EventParticipantAdded, hub.ParticipantPayload{UserID: targetID})
+	if chat == nil {
+		return s.chat(ctx, chatID)
+	}
+	return chat, nil
+}
+
+// RemoveParticipant исключает пользователя из группового чата
+func (s *Service) RemoveParticipant(ctx context.Context, userID int32, chatID string, targetID int32) (*storage.Chat, error) {
+	if targetID <= 0 {
+		return nil, ErrInvalidUserID
+	}
+
+	chat, err := s.participantsManagedChat(ctx, chatID, userID, actionRemoveMembers)
+	if err != nil {
+		return nil, err
+	}
+
+	if !canRemove(chat, userID, targetID) {
+		return nil, ErrCannotRemoveUser
+	}
+
+	if err := s.store.RemoveParticipant(ctx, chatID, targetID); err != nil {
+		return nil, fromStorage(err)
+	}
+
+	// Исключённый пользователь тоже должен узнать об изменении
+	chat = s.publishFresh(ctx, chatID, hub.EventParticipantRemoved, hub.ParticipantPayload{UserID: targetID}, targetID)
+	if chat == nil {
+		return s.chat(ctx, chatID)
+	}
+	return chat, nil
+}
+
+// LeaveChat исключает пользователя из чата по его собственному желанию.
+// Если уходит владелец группы, владение переходит к администратору или другому участнику.
+func (s *Service) LeaveChat(ctx context.Context, userID int32, chatID string) error {
+	chat, err := s.memberChat(ctx, chatID, userID)
+	if err != nil {
+		return err
+	}
+
+	if chat.IsGroup && chat.RoleOf(userID) == storage.RoleOwner {
+		if successorID := successor(chat, userID); successorID != 0 {
+			if err := s.store.TransferOwnership(ctx, chatID, userID, successorID); err != nil {
+				return fromStorage(err)
+			}
+			s.publish(chat, hub.EventMemberRoleChanged, hub.RolePayload{UserID: successorID, Role: storage.RoleOwner})
+		}
+	}
+
+	if err := s.store.LeaveChat(ctx, chatID, userID); err != nil {
+		return fromStorage(err)
+	}
+
+	s.publishFresh(ctx, chatID, hub.EventParticipantRemoved, hub.ParticipantPayload{UserID: userID}, userID)
+	return nil
+}
+
+// postableChat возвращает чат, если пользователь может публиковать в нём сообщения
+func (s *Service) postableChat(ctx context.Context, chatID string, userID int32) (*storage.Chat, error) {
+	chat, err := s.memberChat(ctx, chatID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !can(chat, userID, actionPost) {
+		return nil, ErrCannotPost
+	}
+	return chat, nil
+}
+
+// updatableChat возвращает чат, если пользователь может изменять его настройки
+func (s *Service) updatableChat(ctx context.Context, chatID string, userID int32) (*storage.Chat, error) {
+	chat, err := s.memberChat(ctx, chatID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !can(chat, userID, actionUpdateChat) {
+		return nil, ErrCannotUpdateChat
+	}
+	return chat, nil
+}
+
+// participantsManagedChat возвращает групповой чат, если пользователь может изменять его состав
+func (s *Service) participantsManagedChat(ctx context.Context, chatID string, userID int32, act action) (*storage.Chat, error) {
+	chat, err := s.memberChat(ctx, chatID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !chat.IsGroup {
+		return nil, ErrNotGroupChat
+	}
+	if !can(chat, userID, act) {
+		return nil, ErrCannotManageUsers
+	}
+	return chat, nil
+}
+
+// chatUpdated перечитывает чат после изменения и оповещает участников
+func (s *Service) chatUpdated(ctx context.Context, chatID string) (*storage.Chat, error) {
+	chat, err := s.chat(ctx, chatID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.publish(chat, hub.EventChatUpdated, chat)
+	return chat, nil
+}
@@ -0,0 +1,147 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"chat-service/hub"
+	"chat-service/storage"
+)
+
+// Удалить сообщение для всех автор может в течение deleteForEveryoneWindow
+// после отправки. Удалённый чат можно восстановить в течение deletedRetention,
+// после чего он, как и надгробия удалённых сообщений, очищается окончательно.
+const (
+	deleteForEveryoneWindow = 48 * time.Hour
+	deletedRetention        = 30 * 24 * time.Hour
+)
+
+// HideMessage удаляет сообщение только для пользователя. Удалить для себя можно
+// любое сообщение чата, остальные участники продолжают его видеть.
+func (s *Service) HideMessage(ctx context.Context, userID int32, messageID string) error {
+	message, chat, err := s.memberMessage(ctx, messageID, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.store.HideMessage(ctx, messageID, userID); err != nil {
+		return fromStorage(err)
+	}
+
+	// Об удалении для себя узнают только другие устройства пользователя
+	if s.hub != nil {
+		s.hub.Publish([]int32{userID}, hub.Event{
+			Type:    hub.EventMessageDeleted,
+			ChatID:  chat.ID.Hex(),
+			Payload: hub.MessageDeletedPayload{MessageID: message.ID.Hex()},
+		})
+	}
+	return nil
+}
+
+// ListDeletedChats возвращает чаты из корзины, которые пользователь может восстановить
+func (s *Service) ListDeletedChats(ctx context.Context, userID int32) ([]*storage.Chat, error) {
+	if userID == 0 {
+		return nil, ErrUnauthenticated
+	}
+
+	chats, err := s.store.GetDeletedChats(ctx, userID)
+	if err != nil {
+		return nil, fromStorage(err)
+	}
+
+	restorable := []*storage.Chat{}
+	for _, chat := range chats {
+		if restorableChat(chat) && can(chat, userID, actionDeleteChat) {
+			restorable = append(restorable, chat)
+		}
+	}
+	return restorable, nil
+}
+
+// RestoreChat возвращает чат из корзины. Восстановить чат может тот, кто вправе его удалить.
+func (s *Service) RestoreChat(ctx context.Context, userID int32, chatID string) (*storage.Chat, error) {
+	if userID == 0 {
+		return nil, ErrUnauthenticated
+	}
+
+	chat, err := s.store.GetDeletedChat(ctx, chatID)
+	if err != nil {
+		return nil, fromStorage(err)
+	}
+
+	// Чат с истёкшим сроком хранения ждёт очистки и восстановлению не подлежит
+	if !restorableChat(chat) {
+		return nil, ErrChatNotFound
+	}
+	if !isMember(chat, userID) {
+		return nil, ErrNotChatMember
+	}
+	if !can(chat, userID, actionDeleteChat) {
+		return nil, ErrCannotRestoreChat
+	}
+
+	if err := s.store.RestoreChat(ctx, chatID); err != nil {
+		return nil, fromStorage(err)
+	}
+
+	if restored := s.publishFresh(ctx, chatID, hub.EventChatRestored, nil); restored != nil {
+		return restored, nil
+	}
+	return s.chat(ctx, chatID)
+}
+
+// restorableChat сообщает, что срок хранения удалённого чата ещё не истёк
+func restorableChat(chat *storage.Chat) bool {
+	return chat.DeletedAt != nil && time.Since(*chat.DeletedAt) < deletedRetention
+}
+
+// PurgeDeleted окончательно удаляет чаты и надгробия сообщений с истёкшим сроком
+// хранения, а вместе с чатами - их аватары и файлы их сообщений
+func (s *Service) PurgeDeleted(ctx context.Context) error {
+	purged, err := s.store.PurgeDeleted(ctx, time.Now().Add(-deletedRetention))
+
+	// Файлы уже очищенных чатов больше никому не нужны, даже если очистка прервалась
+	if purged != nil {
+		for _, chat := range purged.Chats {
+			s.discardAvatar(chat, chat.Avatar)
+		}
+		for _, attachment := range purged.Attachments {
+			s.discardUpload(attachment.Key)
+		}
+	}
+	if err != nil {
+		return fromStorage(err)
+	}
+	return nil
+}
+
+// RunPurge периодически вызывает PurgeDeleted и PurgeUploads, пока не будет отменён контекст
+func (s *Service) RunPurge(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.PurgeDeleted(ctx); err != nil {
+			log.Printf("Ошибка очистки удалённых данных: %v", err)
+		}
+		if err := s.PurgeUploads(ctx); err != nil {
+			log.Printf("Ошибка очистки брошенных загрузок: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// hiddenTombstone показывает пользователю удалённое им для себя сообщение как надгробие
+func hiddenTombstone(message *storage.Message) {
+	deletedAt := message.ChangedAt
+	message.DeletedAt = &deletedAt
+	message.Content = ""
+	message.Reactions, message.Mentions, message.ReplyTo = nil, nil, nil
+}
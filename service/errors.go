@@ -0,0 +1,187 @@
+package service
+
+import (
+	"errors"
+	"log"
+
+	"chat-service/storage"
+)
+
+// Code - категория доменной ошибки. HTTP и gRPC адаптеры преобразуют её
+// в соответствующий статус ответа.
+type Code int
+
+const (
+	CodeInternal Code = iota
+	CodeUnauthenticated
+	CodeInvalidArgument
+	CodeNotFound
+	CodeForbidden
+	CodeConflict
+	// CodeFailedPrecondition - состояние ресурса изменилось с тех пор, как клиент
+	// его получил: нужно запросить актуальное состояние и повторить операцию
+	CodeFailedPrecondition
+)
+
+// Error - доменная ошибка сервиса с сообщением, которое можно показать клиенту
+type Error struct {
+	Code    Code
+	Message string
+	Err     error
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is позволяет сравнивать ошибки по коду и сообщению через errors.Is
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code && t.Message == e.Message
+}
+
+// Ошибки, общие для HTTP и gRPC API
+var (
+	ErrUnauthenticated      = &Error{Code: CodeUnauthenticated, Message: "не удалось определить пользователя"}
+	ErrInvalidChatID        = &Error{Code: CodeInvalidArgument, Message: "некорректный chatID"}
+	ErrInvalidMessageID     = &Error{Code: CodeInvalidArgument, Message: "некорректный messageID"}
+	ErrInvalidUserID        = &Error{Code: CodeInvalidArgument, Message: "некорректный ID пользователя"}
+	ErrChatNotFound         = &Error{Code: CodeNotFound, Message: "чат не найден"}
+	ErrMessageNotFound      = &Error{Code: CodeNotFound, Message: "сообщение не найдено"}
+	ErrNotGroupChat         = &Error{Code: CodeInvalidArgument, Message: "чат не является групповым"}
+	ErrNotChatMember        = &Error{Code: CodeForbidden, Message: "вы не являетесь участником этого чата"}
+	ErrNotMessageAuthor     = &Error{Code: CodeForbidden, Message: "вы не являетесь автором сообщения"}
+	ErrCannotUpdateChat     = &Error{Code: CodeForbidden, Message: "у вас нет прав на обновление этого чата"}
+	ErrCannotDeleteChat     = &Error{Code: CodeForbidden, Message: "у вас нет прав на удаление этого чата"}
+	ErrCannotRestoreChat    = &Error{Code: CodeForbidden, Message: "у вас нет прав на восстановление этого чата"}
+	ErrCannotManageUsers    = &Error{Code: CodeForbidden, Message: "у вас нет прав на управление участниками этого чата"}
+	ErrReactionExists       = &Error{Code: CodeConflict, Message: "реакция уже добавлена этим пользователем"}
+	ErrInvalidCursor        = &Error{Code: CodeInvalidArgument, Message: "некорректный курсор пагинации"}
+	ErrInvalidReply         = &Error{Code: CodeInvalidArgument, Message: "исходное сообщение не найдено в этом чате"}
+	ErrInvalidStatus        = &Error{Code: CodeInvalidArgument, Message: "статус должен быть delivered или read"}
+	ErrCannotManageRoles    = &Error{Code: CodeForbidden, Message: "назначать администраторов может только владелец чата"}
+	ErrNotChatOwner         = &Error{Code: CodeForbidden, Message: "передать владение может только владелец чата"}
+	ErrCannotChangeOwner    = &Error{Code: CodeInvalidArgument, Message: "роль владельца меняется только передачей владения"}
+	ErrCannotRemoveUser     = &Error{Code: CodeForbidden, Message: "у вас нет прав на исключение этого участника"}
+	ErrTargetNotMember      = &Error{Code: CodeInvalidArgument, Message: "пользователь не является участником чата"}
+	ErrInviteNotFound       = &Error{Code: CodeNotFound, Message: "приглашение не найдено или отозвано"}
+	ErrInviteExpired        = &Error{Code: CodeForbidden, Message: "срок действия приглашения истёк"}
+	ErrInviteExhausted      = &Error{Code: CodeForbidden, Message: "приглашение больше не действует: достигнут лимит использований"}
+	ErrAlreadyMember        = &Error{Code: CodeConflict, Message: "вы уже являетесь участником этого чата"}
+	ErrCannotPost           = &Error{Code: CodeForbidden, Message: "публиковать сообщения в канале могут только администраторы"}
+	ErrIdempotencyKeyReused = &Error{Code: CodeConflict, Message: "ключ идемпотентности уже использован для сообщения в другом чате"}
+	ErrApprovalNotRequired  = &Error{Code: CodeInvalidArgument, Message: "чат не принимает заявки на вступление"}
+	ErrInvalidJoinRequestID = &Error{Code: CodeInvalidArgument, Message: "некорректный ID заявки на вступление"}
+	ErrJoinRequestNotFound  = &Error{Code: CodeNotFound, Message: "заявка на вступление не найдена"}
+	ErrJoinRequestExists    = &Error{Code: CodeConflict, Message: "заявка на вступление уже подана и ожидает рассмотрения"}
+	ErrJoinRequestDecided   = &Error{Code: CodeConflict, Message: "заявка на вступление уже рассмотрена"}
+	ErrInvalidSyncToken     = &Error{Code: CodeInvalidArgument, Message: "некорректный токен синхронизации"}
+	ErrSyncTokenExpired     = &Error{Code: CodeInvalidArgument, Message: "токен синхронизации устарел, требуется полная синхронизация"}
+	ErrDeleteWindowExpired  = &Error{Code: CodeForbidden, Message: "время удаления сообщения для всех истекло"}
+	ErrEditConflict         = &Error{Code: CodeFailedPrecondition, Message: "сообщение уже изменено: обновите его и повторите правку"}
+	ErrCannotPinMessages    = &Error{Code: CodeForbidden, Message: "закреплять сообщения в этом чате могут только администраторы"}
+	ErrAlreadyPinned        = &Error{Code: CodeConflict, Message: "сообщение уже закреплено"}
+	ErrNotPinned            = &Error{Code: CodeNotFound, Message: "сообщение не закреплено в этом чате"}
+	ErrPinLimitReached      = &Error{Code: CodeConflict, Message: "в чате уже закреплено максимальное число сообщений"}
+	ErrSystemMessage        = &Error{Code: CodeInvalidArgument, Message: "системные уведомления отправляет только сервис и их нельзя изменить"}
+	ErrFileNotFound         = &Error{Code: CodeNotFound, Message: "файл не найден"}
+	ErrNoAttachment         = &Error{Code: CodeInvalidArgument, Message: "к сообщению не приложен файл"}
+	ErrFileMessage          = &Error{Code: CodeInvalidArgument, Message: "файлы отправляются только через загрузку"}
+	ErrInvalidSignedURL     = &Error{Code: CodeForbidden, Message: "недействительная подпись ссылки"}
+	ErrSignedURLExpired     = &Error{Code: CodeForbidden, Message: "срок действия ссылки истёк"}
+	ErrSignedURLsDisabled   = &Error{Code: CodeInternal, Message: "подписанные ссылки не настроены"}
+	ErrInvalidUploadID      = &Error{Code: CodeInvalidArgument, Message: "некорректный ID загрузки"}
+	ErrUploadNotFound       = &Error{Code: CodeNotFound, Message: "загрузка не найдена или её срок истёк"}
+	ErrUploadOffsetMismatch = &Error{Code: CodeFailedPrecondition, Message: "позиция части не совпадает с числом принятых байт: запросите текущую позицию"}
+	ErrUploadSizeExceeded   = &Error{Code: CodeInvalidArgument, Message: "часть выходит за объявленный размер файла"}
+	ErrUploadTooLarge       = &Error{Code: CodeInvalidArgument, Message: "размер файла превышает допустимый"}
+	ErrUploadIncomplete     = &Error{Code: CodeFailedPrecondition, Message: "файл загружен не полностью"}
+	ErrTooManyUploadParts   = &Error{Code: CodeInvalidArgument, Message: "файл передан слишком большим числом частей"}
+)
+
+func invalidArgument(message string) error {
+	return &Error{Code: CodeInvalidArgument, Message: message}
+}
+
+// CodeOf возвращает код доменной ошибки. Для прочих ошибок возвращается CodeInternal.
+func CodeOf(err error) Code {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Code
+	}
+	return CodeInternal
+}
+
+// fromStorage преобразует ошибку хранилища в доменную ошибку
+func fromStorage(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	switch {
+	case errors.Is(err, storage.ErrInvalidChatID):
+		return ErrInvalidChatID
+	case errors.Is(err, storage.ErrInvalidMessageID):
+		return ErrInvalidMessageID
+	case errors.Is(err, storage.ErrInvalidUserID):
+		return ErrInvalidUserID
+	case errors.Is(err, storage.ErrChatNotFound):
+		return ErrChatNotFound
+	case errors.Is(err, storage.ErrMessageNotFound):
+		return ErrMessageNotFound
+	case errors.Is(err, storage.ErrNotGroupChat):
+		return ErrNotGroupChat
+	case errors.Is(err, storage.ErrForbidden):
+		return ErrNotMessageAuthor
+	case errors.Is(err, storage.ErrReactionExists):
+		return ErrReactionExists
+	case errors.Is(err, storage.ErrInvalidCursor):
+		return ErrInvalidCursor
+	case errors.Is(err, storage.ErrInvalidReply):
+		return ErrInvalidReply
+	case errors.Is(err, storage.ErrNotMember):
+		return ErrTargetNotMember
+	case errors.Is(err, storage.ErrInviteNotFound):
+		return ErrInviteNotFound
+	case errors.Is(err, storage.ErrInviteExpired):
+		return ErrInviteExpired
+	case errors.Is(err, storage.ErrInviteExhausted):
+		return ErrInviteExhausted
+	case errors.Is(err, storage.ErrInvalidJoinRequestID):
+		return ErrInvalidJoinRequestID
+	case errors.Is(err, storage.ErrJoinRequestNotFound):
+		return ErrJoinRequestNotFound
+	case errors.Is(err, storage.ErrJoinRequestExists):
+		return ErrJoinRequestExists
+	case errors.Is(err, storage.ErrJoinRequestDecided):
+		return ErrJoinRequestDecided
+	case errors.Is(err, storage.ErrRevisionConflict):
+		return ErrEditConflict
+	case errors.Is(err, storage.ErrAlreadyPinned):
+		return ErrAlreadyPinned
+	case errors.Is(err, storage.ErrNotPinned):
+		return ErrNotPinned
+	case errors.Is(err, storage.ErrPinLimitReached):
+		return ErrPinLimitReached
+	case errors.Is(err, storage.ErrInvalidUploadID):
+		return ErrInvalidUploadID
+	case errors.Is(err, storage.ErrUploadNotFound):
+		return ErrUploadNotFound
+	case errors.Is(err, storage.ErrUploadOffsetMismatch):
+		return ErrUploadOffsetMismatch
+	case errors.Is(err, storage.ErrUploadSizeExceeded):
+		return ErrUploadSizeExceeded
+	case errors.Is(err, storage.ErrEmptyUpdate), errors.Is(err, storage.ErrEmptyContent),
+		errors.Is(err, storage.ErrEmptyReaction), errors.Is(err, storage.ErrEmptyAvatar),
+		errors.Is(err, storage.ErrNoCreator), errors.Is(err, storage.ErrInvalidRole),
+		errors.Is(err, storage.ErrInvalidJoinRequestStatus):
+		return &Error{Code: CodeInvalidArgument, Message: err.Error(), Err: err}
+	}
+
+	log.Printf("Ошибка хранилища: %v", err)
+	return &Error{Code: CodeInternal, Message: "внутренняя ошибка сервера", Err: err}
+}
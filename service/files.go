@@ -0,0 +1,266 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"mime"
+	"path"
+	"strings"
+	"unicode"
+
+	"chat-service/blob"
+	"chat-service/storage"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Загруженные файлы доступны по URL вида /uploads/<ключ в хранилище файлов>
+const uploadsURLPrefix = "/uploads/"
+
+// Каталоги хранилища файлов для вложений сообщений и аватаров чатов. Аватары
+// лежат в подкаталоге чата: avatars/<chatID>/<имя>.
+const (
+	filesPrefix   = "files"
+	avatarsPrefix = "avatars"
+)
+
+// Максимальная длина расширения загруженного файла вместе с точкой (в байтах)
+const maxUploadExtLength = 16
+
+// Максимальная длина сохраняемого имени загруженного файла (в символах)
+const maxFilenameLength = 255
+
+// Тип содержимого файла, если он не определяется по расширению
+const defaultContentType = "application/octet-stream"
+
+// UploadFile сохраняет файл и отправляет в чат сообщение с типом "file".
+// size - размер файла в байтах или -1, если он неизвестен.
+// clientMessageID (необязательно) - ключ идемпотентности: при повторе запроса
+// файл не сохраняется заново, а возвращается исходное сообщение.
+func (s *Service) UploadFile(ctx context.Context, userID int32, chatID string, filename string, r io.Reader, size int64, clientMessageID string) (*storage.Message, error) {
+	// Права и повтор проверяем до записи файла, чтобы не хранить файлы без сообщений
+	chat, err := s.postableChat(ctx, chatID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if original, err := s.replayedMessage(ctx, chat, userID, clientMessageID); original != nil || err != nil {
+		return original, err
+	}
+
+	attachment, err := s.saveUpload(ctx, filesPrefix, filename, r, size)
+	if err != nil {
+		return nil, err
+	}
+	return s.sendFileMessage(ctx, userID, chatID, attachment, clientMessageID)
+}
+
+// sendFileMessage отправляет в чат сообщение с сохранённым файлом. Если сообщение
+// не сохранено, файл удаляется.
+func (s *Service) sendFileMessage(ctx context.Context, userID int32, chatID string, attachment *storage.Attachment, clientMessageID string) (*storage.Message, error) {
+	message, err := s.sendMessage(ctx, userID, storage.NewMessage{
+		ChatID:          chatID,
+		Content:         uploadsURLPrefix + attachment.Key,
+		Type:            storage.MessageTypeFile,
+		ClientMessageID: clientMessageID,
+		Attachment:      attachment,
+	})
+	if err != nil || message.Content != uploadsURLPrefix+attachment.Key {
+		// Сообщение не сохранено или параллельный повтор вернул исходное сообщение с другим файлом
+		s.discardUpload(attachment.Key)
+	}
+	return message, err
+}
+
+// SetChatAvatarFile сохраняет файл аватара и устанавливает его для чата.
+// size - размер файла в байтах или -1, если он неизвестен.
+func (s *Service) SetChatAvatarFile(ctx context.Context, userID int32, chatID string, filename string, r io.Reader, size int64) (*storage.Chat, error) {
+	current, err := s.updatableChat(ctx, chatID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	attachment, err := s.saveUpload(ctx, avatarsPrefix+"/"+current.ID.Hex(), filename, r, size)
+	if err != nil {
+		return nil, err
+	}
+
+	chat, err := s.SetChatAvatar(ctx, userID, chatID, uploadsURLPrefix+attachment.Key)
+	if err != nil {
+		s.discardUpload(attachment.Key)
+	}
+	return chat, err
+}
+
+// OpenFile открывает для скачивания файл по ключу из URL /uploads/<ключ>.
+// Файл сообщения доступен участникам чата, в котором отправлено сообщение, пока
+// сообщение не удалено для всех или для самого пользователя. Аватар доступен
+// участникам чата, пока он установлен.
+func (s *Service) OpenFile(ctx context.Context, userID int32, key string) (*storage.Attachment, *blob.Reader, error) {
+	if chatID, ok := avatarChatID(key); ok {
+		return s.openAvatar(ctx, userID, chatID, key)
+	}
+
+	message, err := s.fileMessage(ctx, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	if message.IsHiddenFor(userID) {
+		return nil, nil, ErrFileNotFound
+	}
+
+	if _, err := s.memberChat(ctx, message.ChatID.Hex(), userID); err != nil {
+		return nil, nil, err
+	}
+	return s.openAttachment(ctx, message.Attachment)
+}
+
+// fileMessage возвращает сообщение, к которому приложен файл с ключом key
+func (s *Service) fileMessage(ctx context.Context, key string) (*storage.Message, error) {
+	if blob.ValidateKey(key) != nil {
+		return nil, ErrFileNotFound
+	}
+
+	message, err := s.store.GetMessageByAttachmentKey(ctx, key)
+	if errors.Is(err, storage.ErrMessageNotFound) {
+		return nil, ErrFileNotFound
+	}
+	if err != nil {
+		return nil, fromStorage(err)
+	}
+	return message, nil
+}
+
+// openAvatar открывает текущий аватар чата для его участника
+func (s *Service) openAvatar(ctx context.Context, userID int32, chatID string, key string) (*storage.Attachment, *blob.Reader, error) {
+	chat, err := s.memberChat(ctx, chatID, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Заменённый аватар больше не выдаётся, даже если файл ещё не удалён
+	if chat.Avatar != uploadsURLPrefix+key || blob.ValidateKey(key) != nil {
+		return nil, nil, ErrFileNotFound
+	}
+
+	contentType := mime.TypeByExtension(path.Ext(key))
+	if contentType == "" {
+		contentType = defaultContentType
+	}
+	return s.openAttachment(ctx, &storage.Attachment{Key: key, Filename: path.Base(key), ContentType: contentType})
+}
+
+// avatarChatID возвращает ID чата, которому принадлежит аватар с ключом key.
+// Для ключей вне каталога аватаров возвращает false.
+func avatarChatID(key string) (string, bool) {
+	parts := strings.Split(key, "/")
+	if len(parts) != 3 || parts[0] != avatarsPrefix || !primitive.IsValidObjectID(parts[1]) {
+		return "", false
+	}
+	return parts[1], true
+}
+
+// discardAvatar удаляет файл заменённого аватара чата. URL, не указывающие
+// на загруженный аватар этого чата, не трогаются.
+func (s *Service) discardAvatar(chat *storage.Chat, avatarURL string) {
+	key, ok := strings.CutPrefix(avatarURL, uploadsURLPrefix)
+	if !ok {
+		return
+	}
+	if chatID, ok := avatarChatID(key); ok && chatID == chat.ID.Hex() {
+		s.discardUpload(key)
+	}
+}
+
+// openAttachment открывает приложенный файл в хранилище файлов
+func (s *Service) openAttachment(ctx context.Context, attachment *storage.Attachment) (*storage.Attachment, *blob.Reader, error) {
+	info, err := s.blobs.Stat(ctx, attachment.Key)
+	if errors.Is(err, blob.ErrNotFound) {
+		return nil, nil, ErrFileNotFound
+	}
+	if err != nil {
+		log.Printf("Ошибка чтения файла %s: %v", attachment.Key, err)
+		return nil, nil, &Error{Code: CodeInternal, Message: "ошибка чтения файла", Err: err}
+	}
+	return attachment, blob.NewReader(ctx, s.blobs, info), nil
+}
+
+// saveUpload записывает файл в хранилище файлов под уникальным ключом в каталоге
+// prefix и возвращает сведения о нём. Расширение в ключе сохраняется.
+func (s *Service) saveUpload(ctx context.Context, prefix string, filename string, r io.Reader, size int64) (*storage.Attachment, error) {
+	attachment := newAttachment(prefix, filename, size)
+	if err := s.blobs.Put(ctx, attachment.Key, r, size, attachment.ContentType); err != nil {
+		log.Printf("Ошибка сохранения файла %s: %v", attachment.Key, err)
+		return nil, &Error{Code: CodeInternal, Message: "ошибка сохранения файла", Err: err}
+	}
+
+	// Размер потока без известной длины узнаём после сохранения
+	if attachment.Size < 0 {
+		info, err := s.blobs.Stat(ctx, attachment.Key)
+		if err != nil {
+			log.Printf("Ошибка чтения сохранённого файла %s: %v", attachment.Key, err)
+			s.discardUpload(attachment.Key)
+			return nil, &Error{Code: CodeInternal, Message: "ошибка сохранения файла", Err: err}
+		}
+		attachment.Size = info.Size
+	}
+	return attachment, nil
+}
+
+// newAttachment возвращает сведения о новом файле с уникальным ключом в каталоге prefix
+func newAttachment(prefix string, filename string, size int64) *storage.Attachment {
+	ext := uploadExt(filename)
+	attachment := &storage.Attachment{
+		Key:         prefix + "/" + primitive.NewObjectID().Hex() + ext,
+		Filename:    uploadFilename(filename),
+		ContentType: mime.TypeByExtension(ext),
+		Size:        size,
+	}
+	if attachment.ContentType == "" {
+		attachment.ContentType = defaultContentType
+	}
+	return attachment
+}
+
+// uploadExt возвращает расширение имени файла в нижнем регистре. Расширения
+// длиннее maxUploadExtLength или не из букв и цифр отбрасываются.
+func uploadExt(filename string) string {
+	ext := strings.ToLower(path.Ext(strings.ReplaceAll(filename, "\\", "/")))
+	if len(ext) < 2 || len(ext) > maxUploadExtLength {
+		return ""
+	}
+	for _, r := range ext[1:] {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			return ""
+		}
+	}
+	return ext
+}
+
+// uploadFilename возвращает имя загруженного файла без пути и управляющих символов
+func uploadFilename(filename string) string {
+	name := path.Base(strings.ReplaceAll(filename, "\\", "/"))
+	name = strings.TrimSpace(strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, name))
+	if name == "." || name == "/" {
+		return ""
+	}
+	if runes := []rune(name); len(runes) > maxFilenameLength {
+		name = string(runes[:maxFilenameLength])
+	}
+	return name
+}
+
+// discardUpload удаляет файл, который не попал в сообщение или чат либо
+// принадлежал удалённому сообщению. Ошибка удаления не прерывает операцию.
+func (s *Service) discardUpload(key string) {
+	if err := s.blobs.Delete(context.Background(), key); err != nil {
+		log.Printf("Не удалось удалить неиспользованный файл %s: %v", key, err)
+	}
+}
@@ -0,0 +1,152 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"log"
+	"time"
+
+	"chat-service/hub"
+	"chat-service/storage"
+)
+
+// inviteTokenBytes - длина случайной части токена приглашения
+const inviteTokenBytes = 16
+
+// CreateInvite создаёт приглашение в групповой чат. Нулевой ttl означает бессрочное
+// приглашение, нулевой maxUses - без ограничения числа использований.
+func (s *Service) CreateInvite(ctx context.Context, userID int32, chatID string, ttl time.Duration, maxUses int32) (*storage.Invite, error) {
+	if ttl < 0 {
+		return nil, invalidArgument("срок действия приглашения не может быть отрицательным")
+	}
+	if maxUses < 0 {
+		return nil, invalidArgument("число использований не может быть отрицательным")
+	}
+
+	chat, err := s.participantsManagedChat(ctx, chatID, userID, actionAddMembers)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := newInviteToken()
+	if err != nil {
+		return nil, &Error{Code: CodeInternal, Message: "внутренняя ошибка сервера", Err: err}
+	}
+
+	invite := &storage.Invite{
+		Token:     token,
+		ChatID:    chat.ID,
+		CreatorID: userID,
+		CreatedAt: time.Now(),
+		MaxUses:   maxUses,
+	}
+	if ttl > 0 {
+		expiresAt := invite.CreatedAt.Add(ttl)
+		invite.ExpiresAt = &expiresAt
+	}
+
+	if err := s.store.CreateInvite(ctx, invite); err != nil {
+		return nil, fromStorage(err)
+	}
+	return invite, nil
+}
+
+// ListInvites возвращает действующие приглашения чата
+func (s *Service) ListInvites(ctx context.Context, userID int32, chatID string) ([]*storage.Invite, error) {
+	if _, err := s.participantsManagedChat(ctx, chatID, userID, actionAddMembers); err != nil {
+		return nil, err
+	}
+
+	invites, err := s.store.GetChatInvites(ctx, chatID)
+	if err != nil {
+		return nil, fromStorage(err)
+	}
+	return invites, nil
+}
+
+// RevokeInvite отзывает приглашение. Отозвать его может тот, кто вправе приглашать в чат.
+func (s *Service) RevokeInvite(ctx context.Context, userID int32, token string) error {
+	invite, err := s.invite(ctx, token)
+	if err != nil {
+		return err
+	}
+
+	if _, err := s.participantsManagedChat(ctx, invite.ChatID.Hex(), userID, actionAddMembers); err != nil {
+		return err
+	}
+
+	if err := s.store.RevokeInvite(ctx, token); err != nil {
+		return fromStorage(err)
+	}
+	return nil
+}
+
+// JoinByInvite добавляет пользователя в чат по приглашению. Участник чата
+// может перейти по ссылке повторно - использование при этом не засчитывается.
+// Приглашение выдаёт администратор, поэтому заявка на вступление не требуется
+// даже в чатах с одобрением. Использование засчитывается атомарно до добавления
+// участника, а если добавить его не удалось, возвращается.
+func (s *Service) JoinByInvite(ctx context.Context, userID int32, token string) (*storage.Chat, error) {
+	if userID == 0 {
+		return nil, ErrUnauthenticated
+	}
+
+	invite, err := s.invite(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	chatID := invite.ChatID.Hex()
+	chat, err := s.chat(ctx, chatID)
+	if err != nil {
+		return nil, err
+	}
+	if isMember(chat, userID) {
+		return chat, nil
+	}
+
+	// Срок и лимит проверяет тот же запрос, что засчитывает использование,
+	// поэтому параллельные переходы по ссылке не превысят лимит
+	if _, err := s.store.RedeemInvite(ctx, token); err != nil {
+		return nil, fromStorage(err)
+	}
+
+	if err := s.store.AddParticipant(ctx, chatID, userID); err != nil {
+		if releaseErr := s.store.ReleaseInvite(context.Background(), token); releaseErr != nil {
+			log.Printf("Не удалось вернуть использование приглашения в чат %s: %v", chatID, releaseErr)
+		}
+		return nil, fromStorage(err)
+	}
+
+	chat = s.publishFresh(ctx, chatID, hub.EventParticipantAdded, hub.ParticipantPayload{UserID: userID})
+	if chat == nil {
+		return s.chat(ctx, chatID)
+	}
+	return chat, nil
+}
+
+// invite возвращает действующее приглашение по токену
+func (s *Service) invite(ctx context.Context, token string) (*storage.Invite, error) {
+	if token == "" {
+		return nil, ErrInviteNotFound
+	}
+
+	invite, err := s.store.GetInvite(ctx, token)
+	if err != nil {
+		return nil, fromStorage(err)
+	}
+	if invite.Revoked {
+		return nil, ErrInviteNotFound
+	}
+	return invite, nil
+}
+
+// newInviteToken генерирует случайный токен, пригодный для использования в URL
+func newInviteToken() (string, error) {
+	b := make([]byte, inviteTokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
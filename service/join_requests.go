@@ -0,0 +1,177 @@
+package service
+
+import (
+	"context"
+	"log"
+
+	"chat-service/hub"
+	"chat-service/storage"
+)
+
+// SetApprovalRequired включает или выключает вступление в групповой чат по одобренным заявкам
+func (s *Service) SetApprovalRequired(ctx context.Context, userID int32, chatID string, required bool) (*storage.Chat, error) {
+	chat, err := s.updatableChat(ctx, chatID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !chat.IsGroup {
+		return nil, ErrNotGroupChat
+	}
+
+	if err := s.store.SetApprovalRequired(ctx, chatID, required); err != nil {
+		return nil, fromStorage(err)
+	}
+
+	return s.chatUpdated(ctx, chatID)
+}
+
+// RequestToJoin подаёт заявку на вступление в групповой чат с одобрением.
+// Владелец и администраторы чата узнают о новой заявке через событие join_request.created.
+func (s *Service) RequestToJoin(ctx context.Context, userID int32, chatID string, message string) (*storage.JoinRequest, error) {
+	if userID == 0 {
+		return nil, ErrUnauthenticated
+	}
+
+	chat, err := s.chat(ctx, chatID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !chat.IsGroup {
+		return nil, ErrNotGroupChat
+	}
+	if isMember(chat, userID) {
+		return nil, ErrAlreadyMember
+	}
+	if !chat.ApprovalRequired {
+		return nil, ErrApprovalNotRequired
+	}
+
+	requestID, err := s.store.CreateJoinRequest(ctx, chatID, userID, message)
+	if err != nil {
+		return nil, fromStorage(err)
+	}
+
+	request, err := s.joinRequest(ctx, requestID)
+	if err != nil {
+		return nil, err
+	}
+
+	// О заявке узнают только те, кто может её рассмотреть
+	if s.hub != nil {
+		s.hub.Publish(managers(chat, actionAddMembers), hub.Event{
+			Type:    hub.EventJoinRequestCreated,
+			ChatID:  chat.ID.Hex(),
+			Payload: request,
+		})
+	}
+	return request, nil
+}
+
+// ListJoinRequests возвращает заявки на вступление в чат. По умолчанию - нерассмотренные.
+func (s *Service) ListJoinRequests(ctx context.Context, userID int32, chatID string, status string) ([]*storage.JoinRequest, error) {
+	if status == "" {
+		status = storage.JoinRequestPending
+	}
+
+	if _, err := s.participantsManagedChat(ctx, chatID, userID, actionAddMembers); err != nil {
+		return nil, err
+	}
+
+	requests, err := s.store.GetJoinRequests(ctx, chatID, status)
+	if err != nil {
+		return nil, fromStorage(err)
+	}
+	return requests, nil
+}
+
+// ApproveJoinRequest одобряет заявку и добавляет заявителя в чат
+func (s *Service) ApproveJoinRequest(ctx context.Context, userID int32, requestID string) (*storage.JoinRequest, error) {
+	request, chat, err := s.pendingJoinRequest(ctx, userID, requestID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Решение фиксируется до добавления заявителя: из параллельных одобрений
+	// одной заявки состав чата меняет только то, чьё решение сохранилось
+	request, err = s.decideJoinRequest(ctx, userID, requestID, storage.JoinRequestApproved)
+	if err != nil {
+		return nil, err
+	}
+
+	chatID, applicantID := chat.ID.Hex(), request.UserID
+	if err := s.store.AddParticipant(ctx, chatID, applicantID); err != nil {
+		// Заявитель не добавлен: заявка возвращается на рассмотрение
+		if reopenErr := s.store.ReopenJoinRequest(context.Background(), requestID, userID); reopenErr != nil {
+			log.Printf("Не удалось вернуть на рассмотрение заявку %s: %v", requestID, reopenErr)
+		}
+		return nil, fromStorage(err)
+	}
+
+	noticeID := s.saveNotice(ctx, chat, userID, storage.Notice{Action: storage.NoticeJoinRequestApproved, UserID: applicantID})
+
+	// Заявитель уже в составе чата и получит все события
+	if chat := s.publishFresh(ctx, chatID, hub.EventJoinRequestApproved, request); chat != nil {
+		s.publish(chat, hub.EventParticipantAdded, hub.ParticipantPayload{UserID: applicantID})
+		s.publishNotice(ctx, chat, noticeID)
+	}
+	return request, nil
+}
+
+// RejectJoinRequest отклоняет заявку. Заявитель узнаёт о решении через событие join_request.rejected.
+func (s *Service) RejectJoinRequest(ctx context.Context, userID int32, requestID string) (*storage.JoinRequest, error) {
+	request, chat, err := s.pendingJoinRequest(ctx, userID, requestID)
+	if err != nil {
+		return nil, err
+	}
+
+	request, err = s.decideJoinRequest(ctx, userID, requestID, storage.JoinRequestRejected)
+	if err != nil {
+		return nil, err
+	}
+
+	noticeID := s.saveNotice(ctx, chat, userID, storage.Notice{Action: storage.NoticeJoinRequestRejected, UserID: request.UserID})
+
+	if chat := s.publishFresh(ctx, chat.ID.Hex(), hub.EventJoinRequestRejected, request, request.UserID); chat != nil {
+		s.publishNotice(ctx, chat, noticeID)
+	}
+	return request, nil
+}
+
+// pendingJoinRequest возвращает нерассмотренную заявку и её чат, если пользователь
+// может рассматривать заявки в этом чате
+func (s *Service) pendingJoinRequest(ctx context.Context, userID int32, requestID string) (*storage.JoinRequest, *storage.Chat, error) {
+	request, err := s.joinRequest(ctx, requestID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	chat, err := s.participantsManagedChat(ctx, request.ChatID.Hex(), userID, actionAddMembers)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if request.Status != storage.JoinRequestPending {
+		return nil, nil, ErrJoinRequestDecided
+	}
+	return request, chat, nil
+}
+
+// decideJoinRequest фиксирует решение администратора и возвращает обновлённую заявку
+func (s *Service) decideJoinRequest(ctx context.Context, userID int32, requestID string, status string) (*storage.JoinRequest, error) {
+	if err := s.store.DecideJoinRequest(ctx, requestID, status, userID); err != nil {
+		return nil, fromStorage(err)
+	}
+
+	return s.joinRequest(ctx, requestID)
+}
+
+// joinRequest возвращает заявку на вступление по ID
+func (s *Service) joinRequest(ctx context.Context, requestID string) (*storage.JoinRequest, error) {
+	request, err := s.store.GetJoinRequest(ctx, requestID)
+	if err != nil {
+		return nil, fromStorage(err)
+	}
+	return request, nil
+}
@@ -0,0 +1,385 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"chat-service/hub"
+	"chat-service/storage"
+)
+
+// Упоминание участника в тексте сообщения: @<ID пользователя>
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w@])@(\d+)\b`)
+
+// Максимальная длина ключа идемпотентности, выдаваемого клиентом
+const maxClientMessageIDLength = 128
+
+// SendMessage сохраняет сообщение в чате от имени пользователя и рассылает его
+// участникам. msg.ReplyToID (необязательно) - сообщение того же чата, на которое дан ответ.
+// Если указан msg.ClientMessageID и сообщение с этим ключом уже сохранено,
+// возвращается исходное сообщение, а новое не создаётся.
+// Сообщения с файлами отправляют только UploadFile и FinalizeUpload.
+func (s *Service) SendMessage(ctx context.Context, userID int32, msg storage.NewMessage) (*storage.Message, error) {
+	if msg.Type == storage.MessageTypeFile || msg.Attachment != nil {
+		return nil, ErrFileMessage
+	}
+	return s.sendMessage(ctx, userID, msg)
+}
+
+// sendMessage сохраняет и рассылает сообщение любого типа, кроме системного
+func (s *Service) sendMessage(ctx context.Context, userID int32, msg storage.NewMessage) (*storage.Message, error) {
+	if strings.TrimSpace(msg.Content) == "" || msg.Type == "" {
+		return nil, invalidArgument("отсутствуют обязательные поля")
+	}
+	if msg.Type == storage.MessageTypeSystem || msg.Notice != nil {
+		return nil, ErrSystemMessage
+	}
+
+	chat, err := s.postableChat(ctx, msg.ChatID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if original, err := s.replayedMessage(ctx, chat, userID, msg.ClientMessageID); original != nil || err != nil {
+		return original, err
+	}
+
+	msg.SenderID = userID
+	if msg.Type == "text" {
+		msg.Mentions = mentions(chat, msg.Content, userID)
+	}
+
+	messageID, err := s.store.SaveMessage(ctx, msg)
+	if errors.Is(err, storage.ErrDuplicateMessage) {
+		// Параллельный запрос с тем же ключом успел сохранить сообщение первым
+		return s.replayedMessage(ctx, chat, userID, msg.ClientMessageID)
+	}
+	if err != nil {
+		return nil, fromStorage(err)
+	}
+
+	// Отправитель прочитал чат как минимум до своего сообщения
+	if err := s.advanceRead(ctx, chat, userID, messageID); err != nil {
+		log.Printf("Не удалось обновить отметку прочтения отправителя в чате %s: %v", msg.ChatID, err)
+	} else if fresh, err := s.store.GetChatByID(ctx, msg.ChatID); err == nil {
+		chat = fresh
+	}
+
+	message, err := s.chatMessage(ctx, chat, messageID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.publish(chat, hub.EventMessageCreated, message)
+	return message, nil
+}
+
+// replayedMessage возвращает сообщение, уже сохранённое пользователем с ключом
+// идемпотентности, или nil, если ключ не указан или ещё не использовался.
+// Если сообщение с тех пор удалили, возвращается его надгробие.
+func (s *Service) replayedMessage(ctx context.Context, chat *storage.Chat, userID int32, clientMessageID string) (*storage.Message, error) {
+	if clientMessageID == "" {
+		return nil, nil
+	}
+	if len(clientMessageID) > maxClientMessageIDLength {
+		return nil, invalidArgument("слишком длинный ключ идемпотентности")
+	}
+
+	original, err := s.store.GetMessageByClientID(ctx, userID, clientMessageID)
+	if errors.Is(err, storage.ErrMessageNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fromStorage(err)
+	}
+
+	// Ключ уже использован для сообщения в другом чате
+	if original.ChatID != chat.ID {
+		return nil, ErrIdempotencyKeyReused
+	}
+	withReceipts(chat, original)
+	return original, nil
+}
+
+// EditMessage изменяет текст сообщения. Редактировать можно только свои сообщения.
+// expectedRevision - ревизия, которую видел клиент: если сообщение с тех пор
+// уже изменили, правка отклоняется с ErrEditConflict.
+func (s *Service) EditMessage(ctx context.Context, userID int32, messageID string, content string, expectedRevision int64) (*storage.Message, error) {
+	if strings.TrimSpace(content) == "" {
+		return nil, invalidArgument("новое содержимое не должно быть пустым")
+	}
+	if expectedRevision < 0 {
+		return nil, invalidArgument("некорректная ревизия сообщения")
+	}
+
+	message, chat, err := s.memberMessage(ctx, messageID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if message.SenderID != userID {
+		return nil, ErrNotMessageAuthor
+	}
+	if message.Type == storage.MessageTypeSystem {
+		return nil, ErrSystemMessage
+	}
+
+	if err := s.store.EditMessage(ctx, messageID, userID, content, expectedRevision); err != nil {
+		return nil, fromStorage(err)
+	}
+
+	message, err = s.chatMessage(ctx, chat, messageID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.publish(chat, hub.EventMessageEdited, message)
+	return message, nil
+}
+
+// GetMessageRevisions возвращает историю правок сообщения от первой версии
+// к текущей. Последний элемент - текущее содержимое сообщения.
+func (s *Service) GetMessageRevisions(ctx context.Context, userID int32, messageID string) ([]*storage.MessageRevision, error) {
+	message, _, err := s.memberMessage(ctx, messageID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	revisions, err := s.store.GetMessageRevisions(ctx, messageID)
+	if err != nil {
+		return nil, fromStorage(err)
+	}
+
+	current := &storage.MessageRevision{
+		MessageID: message.ID,
+		ChatID:    message.ChatID,
+		Revision:  message.Revision,
+		Content:   message.Content,
+		CreatedAt: message.CreatedAt,
+	}
+	if message.EditedAt != nil {
+		current.CreatedAt = *message.EditedAt
+	}
+	return append(revisions, current), nil
+}
+
+// DeleteMessage удаляет сообщение для всех участников. Удалять можно только свои
+// сообщения и только в течение deleteForEveryoneWindow после отправки.
+func (s *Service) DeleteMessage(ctx context.Context, userID int32, messageID string) error {
+	message, chat, err := s.memberMessage(ctx, messageID, userID)
+	if err != nil {
+		return err
+	}
+
+	if message.SenderID != userID {
+		return ErrNotMessageAuthor
+	}
+	if time.Since(message.CreatedAt) > deleteForEveryoneWindow {
+		return ErrDeleteWindowExpired
+	}
+
+	if err := s.store.DeleteMessage(ctx, messageID, userID); err != nil {
+		return fromStorage(err)
+	}
+
+	// Файл удалённого сообщения больше никому не доступен
+	if message.Attachment != nil {
+		s.discardUpload(message.Attachment.Key)
+	}
+
+	s.publish(chat, hub.EventMessageDeleted, hub.MessageDeletedPayload{MessageID: messageID})
+	return nil
+}
+
+// GetMessages возвращает страницу истории сообщений чата от новых к старым
+func (s *Service) GetMessages(ctx context.Context, userID int32, chatID string, query storage.MessagePageQuery) (*storage.MessagePage, error) {
+	if query.Limit < 0 {
+		return nil, invalidArgument("некорректные параметры пагинации")
+	}
+
+	chat, err := s.memberChat(ctx, chatID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	query.ViewerID = userID
+	page, err := s.store.GetMessagesPage(ctx, chatID, query)
+	if err != nil {
+		return nil, fromStorage(err)
+	}
+
+	withReceipts(chat, page.Messages...)
+	return page, nil
+}
+
+// GetThread возвращает корневое сообщение ветки и страницу ответов в ней от новых к старым
+func (s *Service) GetThread(ctx context.Context, userID int32, rootID string, query storage.MessagePageQuery) (*storage.Message, *storage.MessagePage, error) {
+	if query.Limit < 0 {
+		return nil, nil, invalidArgument("некорректные параметры пагинации")
+	}
+
+	root, chat, err := s.memberMessage(ctx, rootID, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Для ответа внутри ветки возвращаем всю ветку его корневого сообщения
+	if root.ReplyTo != nil {
+		if root, err = s.chatMessage(ctx, chat, root.ReplyTo.ThreadID.Hex()); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	query.ViewerID = userID
+	page, err := s.store.GetThreadMessages(ctx, root.ID.Hex(), query)
+	if err != nil {
+		return nil, nil, fromStorage(err)
+	}
+
+	withReceipts(chat, page.Messages...)
+	return root, page, nil
+}
+
+// AddReaction добавляет реакцию пользователя к сообщению
+func (s *Service) AddReaction(ctx context.Context, userID int32, messageID string, reaction string) (*storage.Message, error) {
+	if reaction == "" {
+		return nil, invalidArgument("не указана реакция")
+	}
+
+	message, chat, err := s.memberMessage(ctx, messageID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if hasReaction(message, reaction, userID) {
+		return nil, ErrReactionExists
+	}
+
+	if err := s.addReaction(ctx, chat, messageID, reaction, userID); err != nil {
+		return nil, err
+	}
+	return s.chatMessage(ctx, chat, messageID)
+}
+
+// RemoveReaction удаляет реакцию пользователя с сообщения
+func (s *Service) RemoveReaction(ctx context.Context, userID int32, messageID string, reaction string) (*storage.Message, error) {
+	if reaction == "" {
+		return nil, invalidArgument("не указана реакция")
+	}
+
+	_, chat, err := s.memberMessage(ctx, messageID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.removeReaction(ctx, chat, messageID, reaction, userID); err != nil {
+		return nil, err
+	}
+	return s.chatMessage(ctx, chat, messageID)
+}
+
+// SetReaction устанавливает единственную реакцию пользователя, заменяя предыдущие
+func (s *Service) SetReaction(ctx context.Context, userID int32, messageID string, reaction string) (*storage.Message, error) {
+	if reaction == "" {
+		return nil, invalidArgument("не указана реакция")
+	}
+
+	message, chat, err := s.memberMessage(ctx, messageID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, r := range message.Reactions {
+		if r.UserID != userID || r.Reaction == reaction {
+			continue
+		}
+		if err := s.removeReaction(ctx, chat, messageID, r.Reaction, userID); err != nil {
+			return nil, err
+		}
+	}
+
+	if !hasReaction(message, reaction, userID) {
+		if err := s.addReaction(ctx, chat, messageID, reaction, userID); err != nil {
+			return nil, err
+		}
+	}
+	return s.chatMessage(ctx, chat, messageID)
+}
+
+// ClearReactions удаляет все реакции пользователя с сообщения
+func (s *Service) ClearReactions(ctx context.Context, userID int32, messageID string) (*storage.Message, error) {
+	message, chat, err := s.memberMessage(ctx, messageID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, r := range message.Reactions {
+		if r.UserID != userID {
+			continue
+		}
+		if err := s.removeReaction(ctx, chat, messageID, r.Reaction, userID); err != nil {
+			return nil, err
+		}
+	}
+	return s.chatMessage(ctx, chat, messageID)
+}
+
+func (s *Service) addReaction(ctx context.Context, chat *storage.Chat, messageID string, reaction string, userID int32) error {
+	if err := s.store.AddReaction(ctx, messageID, reaction, userID); err != nil {
+		return fromStorage(err)
+	}
+
+	s.publish(chat, hub.EventReactionAdded, hub.ReactionPayload{MessageID: messageID, Reaction: reaction, UserID: userID})
+	return nil
+}
+
+func (s *Service) removeReaction(ctx context.Context, chat *storage.Chat, messageID string, reaction string, userID int32) error {
+	if err := s.store.RemoveReaction(ctx, messageID, reaction, userID); err != nil {
+		return fromStorage(err)
+	}
+
+	s.publish(chat, hub.EventReactionRemoved, hub.ReactionPayload{MessageID: messageID, Reaction: reaction, UserID: userID})
+	return nil
+}
+
+// mentions возвращает участников чата, упомянутых в тексте как @<ID пользователя>.
+// Автор сообщения и пользователи не из чата не учитываются.
+func mentions(chat *storage.Chat, content string, senderID int32) []int32 {
+	var ids []int32
+	for _, match := range mentionPattern.FindAllStringSubmatch(content, -1) {
+		id, err := strconv.ParseInt(match[1], 10, 32)
+		if err != nil {
+			continue
+		}
+
+		userID := int32(id)
+		if userID == senderID || !isMember(chat, userID) || containsUserID(ids, userID) {
+			continue
+		}
+		ids = append(ids, userID)
+	}
+	return ids
+}
+
+func containsUserID(userIDs []int32, userID int32) bool {
+	for _, id := range userIDs {
+		if id == userID {
+			return true
+		}
+	}
+	return false
+}
+
+func hasReaction(message *storage.Message, reaction string, userID int32) bool {
+	for _, r := range message.Reactions {
+		if r.UserID == userID && r.Reaction == reaction {
+			return true
+		}
+	}
+	return false
+}
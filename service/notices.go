@@ -0,0 +1,51 @@
+package service
+
+import (
+	"context"
+	"log"
+
+	"chat-service/hub"
+	"chat-service/storage"
+)
+
+// Тексты системных уведомлений для клиентов, не разбирающих Notice
+var noticeContent = map[string]string{
+	storage.NoticeMessagePinned:       "Сообщение закреплено",
+	storage.NoticeMessageUnpinned:     "Сообщение откреплено",
+	storage.NoticeJoinRequestApproved: "Заявка на вступление одобрена",
+	storage.NoticeJoinRequestRejected: "Заявка на вступление отклонена",
+}
+
+// saveNotice сохраняет в чате системное уведомление от имени участника, выполнившего
+// действие, и возвращает его ID. Действие к этому моменту уже сохранено и без
+// уведомления всё равно действует, поэтому ошибка только логируется, а ID пуст.
+func (s *Service) saveNotice(ctx context.Context, chat *storage.Chat, userID int32, notice storage.Notice) string {
+	chatID := chat.ID.Hex()
+	noticeID, err := s.store.SaveMessage(ctx, storage.NewMessage{
+		ChatID:   chatID,
+		SenderID: userID,
+		Content:  noticeContent[notice.Action],
+		Type:     storage.MessageTypeSystem,
+		Notice:   &notice,
+	})
+	if err != nil {
+		log.Printf("Не удалось сохранить уведомление %s в чате %s: %v", notice.Action, chatID, err)
+		return ""
+	}
+
+	// Своё уведомление участник уже видел
+	if err := s.advanceRead(ctx, chat, userID, noticeID); err != nil {
+		log.Printf("Не удалось обновить отметку прочтения в чате %s: %v", chatID, err)
+	}
+	return noticeID
+}
+
+// publishNotice рассылает участникам чата уведомление, сохранённое saveNotice
+func (s *Service) publishNotice(ctx context.Context, chat *storage.Chat, noticeID string) {
+	if noticeID == "" {
+		return
+	}
+	if notice, err := s.chatMessage(ctx, chat, noticeID); err == nil {
+		s.publish(chat, hub.EventMessageCreated, notice)
+	}
+}
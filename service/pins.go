@@ -0,0 +1,73 @@
+package service
+
+import (
+	"context"
+
+	"chat-service/storage"
+)
+
+// PinMessage закрепляет сообщение чата и публикует в чате системное уведомление.
+// Закреплять могут администраторы и владелец группы, в личном чате - оба участника.
+func (s *Service) PinMessage(ctx context.Context, userID int32, chatID string, messageID string) (*storage.Chat, error) {
+	chat, message, err := s.pinnableMessage(ctx, chatID, messageID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if message.Type == storage.MessageTypeSystem {
+		return nil, invalidArgument("системное уведомление нельзя закрепить")
+	}
+
+	if err := s.store.PinMessage(ctx, chatID, messageID, userID); err != nil {
+		return nil, fromStorage(err)
+	}
+	return s.pinsChanged(ctx, chat, userID, storage.NoticeMessagePinned, message)
+}
+
+// UnpinMessage открепляет сообщение чата и публикует в чате системное уведомление
+func (s *Service) UnpinMessage(ctx context.Context, userID int32, chatID string, messageID string) (*storage.Chat, error) {
+	chat, message, err := s.pinnableMessage(ctx, chatID, messageID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.store.UnpinMessage(ctx, chatID, messageID); err != nil {
+		return nil, fromStorage(err)
+	}
+	return s.pinsChanged(ctx, chat, userID, storage.NoticeMessageUnpinned, message)
+}
+
+// pinnableMessage возвращает чат и его сообщение, если пользователь может
+// закреплять в этом чате сообщения
+func (s *Service) pinnableMessage(ctx context.Context, chatID string, messageID string, userID int32) (*storage.Chat, *storage.Message, error) {
+	chat, err := s.memberChat(ctx, chatID, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !can(chat, userID, actionPinMessages) {
+		return nil, nil, ErrCannotPinMessages
+	}
+
+	message, err := s.message(ctx, messageID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if message.ChatID != chat.ID || message.IsHiddenFor(userID) {
+		return nil, nil, ErrMessageNotFound
+	}
+	return chat, message, nil
+}
+
+// pinsChanged сохраняет системное уведомление о закреплении или откреплении,
+// рассылает его вместе с обновлённым чатом и возвращает чат
+func (s *Service) pinsChanged(ctx context.Context, chat *storage.Chat, userID int32, action string, message *storage.Message) (*storage.Chat, error) {
+	noticeID := s.saveNotice(ctx, chat, userID, storage.Notice{Action: action, MessageID: message.ID})
+
+	chat, err := s.chatUpdated(ctx, chat.ID.Hex())
+	if err != nil {
+		return nil, err
+	}
+
+	s.publishNotice(ctx, chat, noticeID)
+	return chat, nil
+}
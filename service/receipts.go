@@ -0,0 +1,145 @@
+package service
+
+import (
+	"context"
+
+	"chat-service/hub"
+	"chat-service/storage"
+)
+
+// UpdateMessageStatus отмечает сообщения чата до messageID включительно
+// как доставленные ("delivered") или прочитанные ("read") пользователем
+func (s *Service) UpdateMessageStatus(ctx context.Context, userID int32, messageID string, status string) error {
+	switch status {
+	case storage.StatusDelivered:
+		return s.MarkDelivered(ctx, userID, messageID)
+	case storage.StatusRead:
+		return s.MarkRead(ctx, userID, messageID)
+	}
+	return ErrInvalidStatus
+}
+
+// MarkDelivered отмечает сообщения чата до messageID включительно как доставленные пользователю
+func (s *Service) MarkDelivered(ctx context.Context, userID int32, messageID string) error {
+	_, chat, err := s.memberMessage(ctx, messageID, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.store.MarkDelivered(ctx, chat.ID.Hex(), userID, messageID); err != nil {
+		return fromStorage(err)
+	}
+
+	if chat.IsChannel {
+		return nil
+	}
+
+	s.publish(chat, hub.EventMessageStatus, hub.StatusPayload{MessageID: messageID, UserID: userID, Status: storage.StatusDelivered})
+	return nil
+}
+
+// MarkRead отмечает сообщения чата до messageID включительно как прочитанные пользователем
+func (s *Service) MarkRead(ctx context.Context, userID int32, messageID string) error {
+	_, chat, err := s.memberMessage(ctx, messageID, userID)
+	if err != nil {
+		return err
+	}
+	return s.markRead(ctx, chat, userID, messageID)
+}
+
+// MarkChatRead отмечает прочитанными все сообщения чата до messageID включительно.
+// Если messageID не указан, отмечаются все сообщения чата.
+func (s *Service) MarkChatRead(ctx context.Context, userID int32, chatID string, messageID string) error {
+	chat, err := s.memberChat(ctx, chatID, userID)
+	if err != nil {
+		return err
+	}
+
+	if messageID == "" {
+		page, err := s.store.GetMessagesPage(ctx, chatID, storage.MessagePageQuery{Limit: 1})
+		if err != nil {
+			return fromStorage(err)
+		}
+		if len(page.Messages) == 0 {
+			return nil
+		}
+		messageID = page.Messages[0].ID.Hex()
+	} else {
+		message, err := s.message(ctx, messageID)
+		if err != nil {
+			return err
+		}
+		if message.ChatID != chat.ID {
+			return ErrMessageNotFound
+		}
+	}
+
+	return s.markRead(ctx, chat, userID, messageID)
+}
+
+func (s *Service) markRead(ctx context.Context, chat *storage.Chat, userID int32, messageID string) error {
+	if err := s.advanceRead(ctx, chat, userID, messageID); err != nil {
+		return fromStorage(err)
+	}
+
+	// Прочтения подписчиков канала не рассылаются: их слишком много
+	if chat.IsChannel {
+		return nil
+	}
+
+	s.publish(chat, hub.EventMessageStatus, hub.StatusPayload{MessageID: messageID, UserID: userID, Status: storage.StatusRead})
+	return nil
+}
+
+// advanceRead сдвигает отметку прочтения пользователя. В каналах при этом
+// увеличиваются счётчики просмотров постов.
+func (s *Service) advanceRead(ctx context.Context, chat *storage.Chat, userID int32, messageID string) error {
+	if chat.IsChannel {
+		return s.store.MarkViewed(ctx, chat.ID.Hex(), userID, messageID)
+	}
+	return s.store.MarkRead(ctx, chat.ID.Hex(), userID, messageID)
+}
+
+// withReceipts заполняет у сообщений списки доставки и прочтения и общий статус
+// по отметкам участников чата. Получатели сообщения - все участники, кроме отправителя.
+// У постов канала вместо отметок ведётся счётчик просмотров Views.
+func withReceipts(chat *storage.Chat, messages ...*storage.Message) {
+	if chat.IsChannel {
+		return
+	}
+
+	for _, message := range messages {
+		message.DeliveredTo = []int32{}
+		message.ReadBy = []int32{}
+
+		recipients := 0
+		for _, memberID := range chat.MemberIDs {
+			if memberID == message.SenderID {
+				continue
+			}
+			recipients++
+
+			if covers(chat.DeliveredUpTo(memberID), message) {
+				message.DeliveredTo = append(message.DeliveredTo, memberID)
+			}
+			if covers(chat.ReadUpTo(memberID), message) {
+				message.ReadBy = append(message.ReadBy, memberID)
+			}
+		}
+
+		switch {
+		case recipients > 0 && len(message.ReadBy) == recipients:
+			message.Status = storage.StatusRead
+		case recipients > 0 && len(message.DeliveredTo) == recipients:
+			message.Status = storage.StatusDelivered
+		default:
+			message.Status = storage.StatusSent
+		}
+	}
+}
+
+// covers сообщает, покрывает ли отметка сообщение: отметка - номер последнего
+// отмеченного сообщения, сообщения без номера отметками не покрываются
+func covers(marker int64, message *storage.Message) bool {
+	return message.Seq > 0 && marker >= message.Seq
+}
@@ -0,0 +1,187 @@
+package service
+
+import (
+	"context"
+
+	"chat-service/hub"
+	"chat-service/storage"
+)
+
+// action - действие с чатом, право на которое зависит от роли участника
+type action int
+
+const (
+	actionUpdateChat    action = iota // изменение названия, описания и аватара
+	actionAddMembers                  // добавление участников
+	actionRemoveMembers               // исключение участников
+	actionManageRoles                 // назначение и снятие администраторов, передача владения
+	actionDeleteChat                  // удаление чата
+	actionPost                        // публикация сообщений
+	actionPinMessages                 // закрепление и открепление сообщений
+)
+
+// permissions - матрица прав групповых чатов и каналов: роль -> разрешённые действия.
+// В личных чатах оба участника равноправны, а в группах писать может любой участник, см. can.
+var permissions = map[string]map[action]bool{
+	storage.RoleOwner: {
+		actionUpdateChat:    true,
+		actionAddMembers:    true,
+		actionRemoveMembers: true,
+		actionManageRoles:   true,
+		actionDeleteChat:    true,
+		actionPost:          true,
+		actionPinMessages:   true,
+	},
+	storage.RoleAdmin: {
+		actionUpdateChat:    true,
+		actionAddMembers:    true,
+		actionRemoveMembers: true,
+		actionPost:          true,
+		actionPinMessages:   true,
+	},
+	storage.RoleMember: {},
+}
+
+// can сообщает, может ли пользователь выполнить действие в чате.
+// Личный чат любой из участников может изменить или удалить и закрепить в нём сообщение,
+// а состав личного чата не меняется.
+// Публиковать в канале могут только администраторы и владелец.
+func can(chat *storage.Chat, userID int32, act action) bool {
+	if !chat.IsGroup {
+		return isMember(chat, userID) && (act == actionUpdateChat || act == actionDeleteChat || act == actionPost || act == actionPinMessages)
+	}
+	if act == actionPost && !chat.IsChannel {
+		return isMember(chat, userID)
+	}
+	return permissions[chat.RoleOf(userID)][act]
+}
+
+// managers возвращает участников, которым разрешено действие в групповом чате
+func managers(chat *storage.Chat, act action) []int32 {
+	var userIDs []int32
+	for _, memberID := range chat.MemberIDs {
+		if can(chat, memberID, act) {
+			userIDs = append(userIDs, memberID)
+		}
+	}
+	return userIDs
+}
+
+// canRemove: администратор исключает только обычных участников, владелец - любых, кроме себя
+func canRemove(chat *storage.Chat, userID int32, targetID int32) bool {
+	if !can(chat, userID, actionRemoveMembers) || userID == targetID {
+		return false
+	}
+	switch chat.RoleOf(targetID) {
+	case storage.RoleOwner:
+		return false
+	case storage.RoleAdmin:
+		return chat.RoleOf(userID) == storage.RoleOwner
+	}
+	return true
+}
+
+// PromoteMember назначает участника группового чата администратором
+func (s *Service) PromoteMember(ctx context.Context, userID int32, chatID string, targetID int32) (*storage.Chat, error) {
+	return s.setMemberRole(ctx, userID, chatID, targetID, storage.RoleAdmin)
+}
+
+// DemoteMember снимает с участника группового чата права администратора
+func (s *Service) DemoteMember(ctx context.Context, userID int32, chatID string, targetID int32) (*storage.Chat, error) {
+	return s.setMemberRole(ctx, userID, chatID, targetID, storage.RoleMember)
+}
+
+// TransferOwnership передаёт владение групповым чатом другому участнику.
+// Прежний владелец остаётся в чате администратором.
+func (s *Service) TransferOwnership(ctx context.Context, userID int32, chatID string, targetID int32) (*storage.Chat, error) {
+	chat, err := s.rolesManagedChat(ctx, chatID, userID, targetID)
+	if err != nil {
+		return nil, err
+	}
+
+	if chat.RoleOf(userID) != storage.RoleOwner {
+		return nil, ErrNotChatOwner
+	}
+
+	if err := s.store.TransferOwnership(ctx, chatID, userID, targetID); err != nil {
+		return nil, fromStorage(err)
+	}
+
+	return s.rolesChanged(ctx, chatID,
+		hub.RolePayload{UserID: targetID, Role: storage.RoleOwner},
+		hub.RolePayload{UserID: userID, Role: storage.RoleAdmin},
+	)
+}
+
+func (s *Service) setMemberRole(ctx context.Context, userID int32, chatID string, targetID int32, role string) (*storage.Chat, error) {
+	chat, err := s.rolesManagedChat(ctx, chatID, userID, targetID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Роль владельца меняется только передачей владения
+	if chat.RoleOf(targetID) == storage.RoleOwner {
+		return nil, ErrCannotChangeOwner
+	}
+
+	if err := s.store.SetMemberRole(ctx, chatID, targetID, role); err != nil {
+		return nil, fromStorage(err)
+	}
+
+	return s.rolesChanged(ctx, chatID, hub.RolePayload{UserID: targetID, Role: role})
+}
+
+// rolesManagedChat возвращает групповой чат, если пользователь может менять роли,
+// а целевой пользователь состоит в чате
+func (s *Service) rolesManagedChat(ctx context.Context, chatID string, userID int32, targetID int32) (*storage.Chat, error) {
+	if targetID <= 0 {
+		return nil, ErrInvalidUserID
+	}
+
+	chat, err := s.memberChat(ctx, chatID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !chat.IsGroup {
+		return nil, ErrNotGroupChat
+	}
+	if !can(chat, userID, actionManageRoles) {
+		return nil, ErrCannotManageRoles
+	}
+	if !isMember(chat, targetID) {
+		return nil, ErrTargetNotMember
+	}
+	return chat, nil
+}
+
+// rolesChanged перечитывает чат и оповещает участников о каждой изменённой роли
+func (s *Service) rolesChanged(ctx context.Context, chatID string, changes ...hub.RolePayload) (*storage.Chat, error) {
+	chat, err := s.chat(ctx, chatID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, change := range changes {
+		s.publish(chat, hub.EventMemberRoleChanged, change)
+	}
+	return chat, nil
+}
+
+// successor выбирает нового владельца вместо уходящего: первого администратора,
+// а если их нет - первого из оставшихся участников. Возвращает 0, если в чате никого не останется.
+func successor(chat *storage.Chat, ownerID int32) int32 {
+	var firstMember int32
+	for _, memberID := range chat.MemberIDs {
+		if memberID == ownerID {
+			continue
+		}
+		if chat.RoleOf(memberID) == storage.RoleAdmin {
+			return memberID
+		}
+		if firstMember == 0 {
+			firstMember = memberID
+		}
+	}
+	return firstMember
+}
@@ -0,0 +1,77 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"unicode/utf8"
+
+	"chat-service/storage"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Максимальная длина поискового запроса (в символах)
+const maxSearchQueryLength = 256
+
+// SearchMessages ищет сообщения по тексту в чатах пользователя от более
+// релевантных к менее релевантным. chatID (необязательно) ограничивает поиск
+// одним чатом; остальные фильтры и курсор страницы передаются в query.
+// Чаты и фильтр по удалённым для себя сообщениям определяет сервис.
+func (s *Service) SearchMessages(ctx context.Context, userID int32, chatID string, query storage.SearchQuery) (*storage.SearchPage, error) {
+	if userID == 0 {
+		return nil, ErrUnauthenticated
+	}
+
+	query.Text = strings.TrimSpace(query.Text)
+	switch {
+	case query.Text == "":
+		return nil, invalidArgument("не указан текст запроса")
+	case utf8.RuneCountInString(query.Text) > maxSearchQueryLength:
+		return nil, invalidArgument("слишком длинный поисковый запрос")
+	case query.Limit < 0:
+		return nil, invalidArgument("некорректные параметры пагинации")
+	case !query.Since.IsZero() && !query.Until.IsZero() && !query.Since.Before(query.Until):
+		return nil, invalidArgument("начало периода поиска должно быть раньше его конца")
+	}
+
+	chats, err := s.searchableChats(ctx, userID, chatID)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[primitive.ObjectID]*storage.Chat, len(chats))
+	query.ChatIDs = make([]primitive.ObjectID, 0, len(chats))
+	for _, chat := range chats {
+		byID[chat.ID] = chat
+		query.ChatIDs = append(query.ChatIDs, chat.ID)
+	}
+	query.ViewerID = userID
+
+	page, err := s.store.SearchMessages(ctx, query)
+	if err != nil {
+		return nil, fromStorage(err)
+	}
+
+	for _, result := range page.Results {
+		withReceipts(byID[result.Message.ChatID], result.Message)
+	}
+	return page, nil
+}
+
+// searchableChats возвращает чаты, в которых ищет пользователь: указанный чат,
+// если пользователь в нём состоит, иначе все его чаты
+func (s *Service) searchableChats(ctx context.Context, userID int32, chatID string) ([]*storage.Chat, error) {
+	if chatID != "" {
+		chat, err := s.memberChat(ctx, chatID, userID)
+		if err != nil {
+			return nil, err
+		}
+		return []*storage.Chat{chat}, nil
+	}
+
+	chats, err := s.store.GetUserChats(ctx, userID)
+	if err != nil {
+		return nil, fromStorage(err)
+	}
+	return chats, nil
+}
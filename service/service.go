@@ -0,0 +1,146 @@
+package service
+
+import (
+	"context"
+	"log"
+
+	"chat-service/blob"
+	"chat-service/hub"
+	"chat-service/signedurl"
+	"chat-service/storage"
+)
+
+// Service содержит бизнес-правила чатов. HTTP-обработчики и gRPC ChatService
+// являются тонкими адаптерами над ним, поэтому оба API ведут себя одинаково.
+type Service struct {
+	store  storage.Storage
+	blobs  blob.Store
+	signer *signedurl.Signer
+	hub    *hub.Hub
+}
+
+// New создаёт сервис поверх хранилища данных и хранилища файлов. signer подписывает
+// ссылки на файлы; если он nil, подписанные ссылки не выдаются.
+// Хаб может быть nil - тогда события не рассылаются.
+func New(store storage.Storage, blobs blob.Store, signer *signedurl.Signer, h *hub.Hub) *Service {
+	return &Service{store: store, blobs: blobs, signer: signer, hub: h}
+}
+
+// Storage возвращает хранилище, с которым работает сервис
+func (s *Service) Storage() storage.Storage {
+	return s.store
+}
+
+// Hub возвращает хаб событий сервиса
+func (s *Service) Hub() *hub.Hub {
+	return s.hub
+}
+
+// isMember проверяет, является ли пользователь участником чата
+func isMember(chat *storage.Chat, userID int32) bool {
+	for _, memberID := range chat.MemberIDs {
+		if memberID == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// chat возвращает чат по ID
+func (s *Service) chat(ctx context.Context, chatID string) (*storage.Chat, error) {
+	chat, err := s.store.GetChatByID(ctx, chatID)
+	if err != nil {
+		return nil, fromStorage(err)
+	}
+	return chat, nil
+}
+
+// memberChat возвращает чат, если пользователь является его участником
+func (s *Service) memberChat(ctx context.Context, chatID string, userID int32) (*storage.Chat, error) {
+	if userID == 0 {
+		return nil, ErrUnauthenticated
+	}
+
+	chat, err := s.chat(ctx, chatID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !isMember(chat, userID) {
+		return nil, ErrNotChatMember
+	}
+	return chat, nil
+}
+
+// message возвращает сообщение по ID
+func (s *Service) message(ctx context.Context, messageID string) (*storage.Message, error) {
+	message, err := s.store.GetMessageByID(ctx, messageID)
+	if err != nil {
+		return nil, fromStorage(err)
+	}
+	return message, nil
+}
+
+// chatMessage возвращает сообщение чата с заполненными отметками доставки и прочтения
+func (s *Service) chatMessage(ctx context.Context, chat *storage.Chat, messageID string) (*storage.Message, error) {
+	message, err := s.message(ctx, messageID)
+	if err != nil {
+		return nil, err
+	}
+
+	withReceipts(chat, message)
+	return message, nil
+}
+
+// memberMessage возвращает сообщение и его чат, если пользователь состоит в этом чате.
+// Сообщение, удалённое пользователем для себя, считается ненайденным.
+func (s *Service) memberMessage(ctx context.Context, messageID string, userID int32) (*storage.Message, *storage.Chat, error) {
+	message, err := s.message(ctx, messageID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if message.IsHiddenFor(userID) {
+		return nil, nil, ErrMessageNotFound
+	}
+
+	chat, err := s.memberChat(ctx, message.ChatID.Hex(), userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	withReceipts(chat, message)
+	return message, chat, nil
+}
+
+// publish рассылает событие участникам чата и дополнительным получателям
+func (s *Service) publish(chat *storage.Chat, eventType string, payload interface{}, extraUserIDs ...int32) {
+	if s.hub == nil {
+		return
+	}
+
+	recipients := make([]int32, 0, len(chat.MemberIDs)+len(extraUserIDs))
+	recipients = append(recipients, chat.MemberIDs...)
+	recipients = append(recipients, extraUserIDs...)
+
+	s.hub.Publish(recipients, hub.Event{
+		Type:    eventType,
+		ChatID:  chat.ID.Hex(),
+		Payload: payload,
+	})
+}
+
+// publishFresh перечитывает чат и рассылает событие его актуальным участникам.
+// Ошибка чтения не прерывает операцию: изменение уже сохранено.
+func (s *Service) publishFresh(ctx context.Context, chatID string, eventType string, payload interface{}, extraUserIDs ...int32) *storage.Chat {
+	chat, err := s.store.GetChatByID(ctx, chatID)
+	if err != nil {
+		log.Printf("Не удалось получить чат %s для рассылки события %s: %v", chatID, eventType, err)
+		return nil
+	}
+
+	if payload == nil {
+		payload = chat
+	}
+	s.publish(chat, eventType, payload, extraUserIDs...)
+	return chat
+}
@@ -0,0 +1,404 @@
+package service_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"chat-service/blob"
+	"chat-service/hub"
+	"chat-service/service"
+	"chat-service/storage"
+)
+
+// newService создаёт сервис поверх хранилища в памяти и локального хранилища файлов
+func newService(t *testing.T, store storage.Storage) *service.Service {
+	t.Helper()
+	blobs, err := blob.NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocalStore: %v", err)
+	}
+	return service.New(store, blobs, nil, hub.New())
+}
+
+// agedStorage выдаёт сообщения так, будто они отправлены на age раньше
+type agedStorage struct {
+	storage.Storage
+	age time.Duration
+}
+
+func (s *agedStorage) GetMessageByID(ctx context.Context, messageID string) (*storage.Message, error) {
+	message, err := s.Storage.GetMessageByID(ctx, messageID)
+	if err == nil {
+		message.CreatedAt = message.CreatedAt.Add(-s.age)
+	}
+	return message, err
+}
+
+func createGroup(t *testing.T, svc *service.Service, ownerID int32, memberIDs ...int32) string {
+	t.Helper()
+	chat, err := svc.CreateChat(context.Background(), ownerID, "группа", "", memberIDs, true)
+	if err != nil {
+		t.Fatalf("CreateChat: %v", err)
+	}
+	return chat.ID.Hex()
+}
+
+func sendText(t *testing.T, svc *service.Service, userID int32, chatID string, content string) *storage.Message {
+	t.Helper()
+	message, err := svc.SendMessage(context.Background(), userID, storage.NewMessage{ChatID: chatID, Content: content, Type: "text"})
+	if err != nil {
+		t.Fatalf("SendMessage: %v", err)
+	}
+	return message
+}
+
+// expectError проверяет, что операция завершилась доменной ошибкой want
+func expectError(t *testing.T, name string, err error, want error) {
+	t.Helper()
+	if !errors.Is(err, want) {
+		t.Errorf("%s: ожидалась ошибка %q, получено %v", name, want, err)
+	}
+}
+
+func TestMembership(t *testing.T) {
+	ctx := context.Background()
+	svc := newService(t, storage.NewMemoryStorage())
+	chatID := createGroup(t, svc, 1, 2)
+	message := sendText(t, svc, 1, chatID, "привет")
+
+	_, err := svc.GetChat(ctx, 3, chatID)
+	expectError(t, "GetChat чужого чата", err, service.ErrNotChatMember)
+	_, err = svc.SendMessage(ctx, 3, storage.NewMessage{ChatID: chatID, Content: "привет", Type: "text"})
+	expectError(t, "SendMessage в чужой чат", err, service.ErrNotChatMember)
+	_, err = svc.GetMessages(ctx, 3, chatID, storage.MessagePageQuery{})
+	expectError(t, "GetMessages чужого чата", err, service.ErrNotChatMember)
+	_, err = svc.AddReaction(ctx, 3, message.ID.Hex(), "👍")
+	expectError(t, "AddReaction к сообщению чужого чата", err, service.ErrNotChatMember)
+	err = svc.LeaveChat(ctx, 3, chatID)
+	expectError(t, "LeaveChat чужого чата", err, service.ErrNotChatMember)
+
+	_, err = svc.GetChat(ctx, 0, chatID)
+	expectError(t, "GetChat без пользователя", err, service.ErrUnauthenticated)
+	_, err = svc.GetChat(ctx, 1, "не-id")
+	expectError(t, "GetChat с некорректным ID", err, service.ErrInvalidChatID)
+	_, err = svc.GetChat(ctx, 1, "000000000000000000000000")
+	expectError(t, "GetChat несуществующего чата", err, service.ErrChatNotFound)
+
+	// Вышедший из чата участник теряет доступ к нему
+	if err := svc.LeaveChat(ctx, 2, chatID); err != nil {
+		t.Fatalf("LeaveChat: %v", err)
+	}
+	_, err = svc.GetChat(ctx, 2, chatID)
+	expectError(t, "GetChat после выхода", err, service.ErrNotChatMember)
+}
+
+func TestMessageAuthor(t *testing.T) {
+	ctx := context.Background()
+	svc := newService(t, storage.NewMemoryStorage())
+	chatID := createGroup(t, svc, 1, 2)
+	message := sendText(t, svc, 1, chatID, "привет")
+	messageID := message.ID.Hex()
+
+	_, err := svc.EditMessage(ctx, 2, messageID, "правка", message.Revision)
+	expectError(t, "EditMessage чужого сообщения", err, service.ErrNotMessageAuthor)
+	err = svc.DeleteMessage(ctx, 2, messageID)
+	expectError(t, "DeleteMessage чужого сообщения", err, service.ErrNotMessageAuthor)
+
+	// Правка по устаревшей ревизии отклоняется
+	edited, err := svc.EditMessage(ctx, 1, messageID, "правка", message.Revision)
+	if err != nil {
+		t.Fatalf("EditMessage: %v", err)
+	}
+	_, err = svc.EditMessage(ctx, 1, messageID, "вторая правка", message.Revision)
+	expectError(t, "EditMessage по устаревшей ревизии", err, service.ErrEditConflict)
+	if _, err := svc.EditMessage(ctx, 1, messageID, "вторая правка", edited.Revision); err != nil {
+		t.Errorf("EditMessage по текущей ревизии: %v", err)
+	}
+
+	_, err = svc.SendMessage(ctx, 1, storage.NewMessage{ChatID: chatID, Content: "уведомление", Type: storage.MessageTypeSystem})
+	expectError(t, "SendMessage системного уведомления", err, service.ErrSystemMessage)
+
+	if err := svc.DeleteMessage(ctx, 1, messageID); err != nil {
+		t.Fatalf("DeleteMessage: %v", err)
+	}
+	err = svc.DeleteMessage(ctx, 1, messageID)
+	expectError(t, "повторный DeleteMessage", err, service.ErrMessageNotFound)
+}
+
+func TestDeleteWindow(t *testing.T) {
+	ctx := context.Background()
+	store := &agedStorage{Storage: storage.NewMemoryStorage()}
+	svc := newService(t, store)
+	chatID := createGroup(t, svc, 1, 2)
+	message := sendText(t, svc, 1, chatID, "привет")
+
+	store.age = 49 * time.Hour
+	err := svc.DeleteMessage(ctx, 1, message.ID.Hex())
+	expectError(t, "DeleteMessage после 48 часов", err, service.ErrDeleteWindowExpired)
+
+	// Удалить сообщение для себя можно в любое время
+	if err := svc.HideMessage(ctx, 1, message.ID.Hex()); err != nil {
+		t.Errorf("HideMessage: %v", err)
+	}
+
+	message = sendText(t, svc, 1, chatID, "ещё одно")
+	store.age = 47 * time.Hour
+	if err := svc.DeleteMessage(ctx, 1, message.ID.Hex()); err != nil {
+		t.Errorf("DeleteMessage в пределах 48 часов: %v", err)
+	}
+}
+
+func TestRoles(t *testing.T) {
+	ctx := context.Background()
+	svc := newService(t, storage.NewMemoryStorage())
+	chatID := createGroup(t, svc, 1, 2, 3)
+
+	// Обычный участник не управляет чатом
+	_, err := svc.UpdateChat(ctx, 2, chatID, "новое название", "")
+	expectError(t, "UpdateChat участником", err, service.ErrCannotUpdateChat)
+	_, err = svc.AddParticipant(ctx, 2, chatID, 4)
+	expectError(t, "AddParticipant участником", err, service.ErrCannotManageUsers)
+	_, err = svc.RemoveParticipant(ctx, 2, chatID, 3)
+	expectError(t, "RemoveParticipant участником", err, service.ErrCannotManageUsers)
+	err = svc.DeleteChat(ctx, 2, chatID)
+	expectError(t, "DeleteChat участником", err, service.ErrCannotDeleteChat)
+	_, err = svc.PromoteMember(ctx, 2, chatID, 3)
+	expectError(t, "PromoteMember участником", err, service.ErrCannotManageRoles)
+
+	if _, err := svc.PromoteMember(ctx, 1, chatID, 2); err != nil {
+		t.Fatalf("PromoteMember: %v", err)
+	}
+
+	// Администратор меняет состав и настройки, но не роли
+	if _, err := svc.UpdateChat(ctx, 2, chatID, "новое название", ""); err != nil {
+		t.Errorf("UpdateChat администратором: %v", err)
+	}
+	_, err = svc.PromoteMember(ctx, 2, chatID, 3)
+	expectError(t, "PromoteMember администратором", err, service.ErrCannotManageRoles)
+	_, err = svc.TransferOwnership(ctx, 2, chatID, 3)
+	expectError(t, "TransferOwnership администратором", err, service.ErrCannotManageRoles)
+	_, err = svc.RemoveParticipant(ctx, 2, chatID, 1)
+	expectError(t, "RemoveParticipant владельца", err, service.ErrCannotRemoveUser)
+	err = svc.DeleteChat(ctx, 2, chatID)
+	expectError(t, "DeleteChat администратором", err, service.ErrCannotDeleteChat)
+
+	_, err = svc.DemoteMember(ctx, 1, chatID, 1)
+	expectError(t, "DemoteMember владельца", err, service.ErrCannotChangeOwner)
+	_, err = svc.PromoteMember(ctx, 1, chatID, 4)
+	expectError(t, "PromoteMember не участника", err, service.ErrTargetNotMember)
+
+	if _, err := svc.RemoveParticipant(ctx, 2, chatID, 3); err != nil {
+		t.Errorf("RemoveParticipant администратором: %v", err)
+	}
+
+	// Состав личного чата не меняется
+	direct, err := svc.CreateChat(ctx, 1, "личный", "", []int32{2}, false)
+	if err != nil {
+		t.Fatalf("CreateChat: %v", err)
+	}
+	_, err = svc.AddParticipant(ctx, 1, direct.ID.Hex(), 3)
+	expectError(t, "AddParticipant в личный чат", err, service.ErrNotGroupChat)
+}
+
+func TestChannelPosting(t *testing.T) {
+	ctx := context.Background()
+	svc := newService(t, storage.NewMemoryStorage())
+	channel, err := svc.CreateChannel(ctx, 1, "канал", "", []int32{2, 3})
+	if err != nil {
+		t.Fatalf("CreateChannel: %v", err)
+	}
+	chatID := channel.ID.Hex()
+
+	_, err = svc.SendMessage(ctx, 2, storage.NewMessage{ChatID: chatID, Content: "привет", Type: "text"})
+	expectError(t, "SendMessage подписчиком", err, service.ErrCannotPost)
+	_, err = svc.UploadFile(ctx, 2, chatID, "файл.txt", nil, 0, "")
+	expectError(t, "UploadFile подписчиком", err, service.ErrCannotPost)
+
+	sendText(t, svc, 1, chatID, "новость")
+	if _, err := svc.PromoteMember(ctx, 1, chatID, 2); err != nil {
+		t.Fatalf("PromoteMember: %v", err)
+	}
+	sendText(t, svc, 2, chatID, "новость от администратора")
+}
+
+// failingAddStorage отказывает в добавлении участников
+type failingAddStorage struct {
+	storage.Storage
+}
+
+func (s *failingAddStorage) AddParticipant(ctx context.Context, chatID string, userID int32) error {
+	return errors.New("сбой хранилища")
+}
+
+// decisionBarrier придерживает решения по заявкам, пока их не примут оба администратора,
+// чтобы параллельные одобрения гарантированно пересеклись
+type decisionBarrier struct {
+	storage.Storage
+	arrived sync.WaitGroup
+}
+
+func (s *decisionBarrier) DecideJoinRequest(ctx context.Context, requestID string, status string, deciderID int32) error {
+	s.arrived.Done()
+	s.arrived.Wait()
+	return s.Storage.DecideJoinRequest(ctx, requestID, status, deciderID)
+}
+
+// requestToJoin создаёт групповой чат с одобрением заявок и заявку пользователя 3 в него
+func requestToJoin(t *testing.T, svc *service.Service) (chatID string, requestID string) {
+	t.Helper()
+	ctx := context.Background()
+	chatID = createGroup(t, svc, 1, 2)
+	if _, err := svc.PromoteMember(ctx, 1, chatID, 2); err != nil {
+		t.Fatalf("PromoteMember: %v", err)
+	}
+	if _, err := svc.SetApprovalRequired(ctx, 1, chatID, true); err != nil {
+		t.Fatalf("SetApprovalRequired: %v", err)
+	}
+	request, err := svc.RequestToJoin(ctx, 3, chatID, "")
+	if err != nil {
+		t.Fatalf("RequestToJoin: %v", err)
+	}
+	return chatID, request.ID.Hex()
+}
+
+func TestConcurrentJoinRequestApproval(t *testing.T) {
+	ctx := context.Background()
+	store := &decisionBarrier{Storage: storage.NewMemoryStorage()}
+	store.arrived.Add(2)
+	svc := newService(t, store)
+	chatID, requestID := requestToJoin(t, svc)
+
+	// Владелец и администратор одновременно одобряют одну заявку
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	for i, approverID := range []int32{1, 2} {
+		wg.Add(1)
+		go func(i int, approverID int32) {
+			defer wg.Done()
+			_, errs[i] = svc.ApproveJoinRequest(ctx, approverID, requestID)
+		}(i, approverID)
+	}
+	wg.Wait()
+
+	approved := 0
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			approved++
+		case !errors.Is(err, service.ErrJoinRequestDecided):
+			t.Fatalf("ApproveJoinRequest: %v", err)
+		}
+	}
+	if approved != 1 {
+		t.Fatalf("заявку одобрили %d раз, ожидалось одно одобрение", approved)
+	}
+
+	if _, err := svc.GetChat(ctx, 3, chatID); err != nil {
+		t.Errorf("одобренный заявитель не в чате: %v", err)
+	}
+	requests, err := svc.ListJoinRequests(ctx, 1, chatID, storage.JoinRequestApproved)
+	if err != nil || len(requests) != 1 {
+		t.Errorf("одобренных заявок %d (%v), ожидалась одна", len(requests), err)
+	}
+}
+
+func TestJoinRequestReopenedOnFailure(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemoryStorage()
+	chatID, requestID := requestToJoin(t, newService(t, store))
+
+	// Заявитель не добавлен в чат, и заявка снова ждёт решения
+	svc := newService(t, &failingAddStorage{Storage: store})
+	if _, err := svc.ApproveJoinRequest(ctx, 1, requestID); err == nil {
+		t.Fatal("ApproveJoinRequest не вернул ошибку хранилища")
+	}
+	requests, err := svc.ListJoinRequests(ctx, 1, chatID, storage.JoinRequestPending)
+	if err != nil || len(requests) != 1 || requests[0].ID.Hex() != requestID {
+		t.Fatalf("заявка не вернулась на рассмотрение: %v (%v)", requests, err)
+	}
+	_, err = svc.GetChat(ctx, 3, chatID)
+	expectError(t, "GetChat заявителем", err, service.ErrNotChatMember)
+}
+
+func TestChatSummaryJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		summary service.ChatSummary
+	}{
+		{"чат", service.ChatSummary{Chat: &storage.Chat{Name: "группа", MemberIDs: []int32{1, 2}}, UnreadCount: 3, UnreadMentions: 1}},
+		{"канал", service.ChatSummary{Chat: &storage.Chat{Name: "канал", IsChannel: true}, UnreadCount: 3, UnreadMentions: 1}},
+		{"без чата", service.ChatSummary{UnreadCount: 3, UnreadMentions: 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := json.Marshal(tt.summary)
+			if err != nil {
+				t.Fatalf("Marshal: %v", err)
+			}
+
+			var decoded struct {
+				Name           string
+				UnreadCount    int64
+				UnreadMentions int64
+			}
+			if err := json.Unmarshal(data, &decoded); err != nil {
+				t.Fatalf("Unmarshal %s: %v", data, err)
+			}
+			if decoded.UnreadCount != 3 || decoded.UnreadMentions != 1 {
+				t.Errorf("счётчики непрочитанного потеряны: %s", data)
+			}
+			if tt.summary.Chat != nil && decoded.Name != tt.summary.Chat.Name {
+				t.Errorf("поля чата потеряны: %s", data)
+			}
+		})
+	}
+}
+
+func TestClientMessageIDHidden(t *testing.T) {
+	ctx := context.Background()
+	svc := newService(t, storage.NewMemoryStorage())
+	chatID := createGroup(t, svc, 1, 2)
+
+	message, err := svc.SendMessage(ctx, 1, storage.NewMessage{ChatID: chatID, Content: "привет", Type: "text", ClientMessageID: "секретный-ключ"})
+	if err != nil {
+		t.Fatalf("SendMessage: %v", err)
+	}
+	if message.ClientMessageID != "секретный-ключ" {
+		t.Errorf("ClientMessageID = %q, ожидался ключ отправителя", message.ClientMessageID)
+	}
+
+	// Сообщение в JSON видят все участники чата: ключа отправителя в нём нет
+	data, err := json.Marshal(message)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	if strings.Contains(string(data), "секретный-ключ") {
+		t.Errorf("ключ идемпотентности попал в JSON сообщения: %s", data)
+	}
+}
+
+func TestFileMessagesOnlyFromUploads(t *testing.T) {
+	ctx := context.Background()
+	svc := newService(t, storage.NewMemoryStorage())
+	chatID := createGroup(t, svc, 1, 2)
+
+	// Сообщение типа file со ссылкой на произвольный URL выдавало бы себя за вложение
+	_, err := svc.SendMessage(ctx, 1, storage.NewMessage{ChatID: chatID, Content: "https://example.com/file.exe", Type: storage.MessageTypeFile})
+	expectError(t, "SendMessage файла без загрузки", err, service.ErrFileMessage)
+	_, err = svc.SendMessage(ctx, 1, storage.NewMessage{ChatID: chatID, Content: "привет", Type: "text", Attachment: &storage.Attachment{Key: "files/чужой"}})
+	expectError(t, "SendMessage с вложением без загрузки", err, service.ErrFileMessage)
+
+	message, err := svc.UploadFile(ctx, 1, chatID, "отчёт.txt", strings.NewReader("содержимое"), -1, "")
+	if err != nil {
+		t.Fatalf("UploadFile: %v", err)
+	}
+	if message.Type != storage.MessageTypeFile || message.Attachment == nil {
+		t.Errorf("загруженный файл отправлен сообщением %+v", message)
+	}
+}
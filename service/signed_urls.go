@@ -0,0 +1,80 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"time"
+
+	"chat-service/blob"
+	"chat-service/signedurl"
+	"chat-service/storage"
+)
+
+// Подписанная ссылка открывает файл сообщения без токена, поэтому её можно
+// вставить в <img>, передать видеоплееру или в push-уведомление. Ссылка выдаётся
+// участнику чата и действует до истечения срока, даже если он покинет чат.
+
+// Срок действия подписанной ссылки по умолчанию и максимальный
+const (
+	DefaultSignedURLTTL = 15 * time.Minute
+	MaxSignedURLTTL     = 24 * time.Hour
+)
+
+// Подписанные ссылки на файлы имеют вид /signed/uploads/<ключ>?expires=...&kid=...&sig=...
+const signedUploadsURLPrefix = "/signed/uploads/"
+
+// SignedURL - подписанная ссылка на файл и время окончания её действия
+type SignedURL struct {
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// SignFileURL выдаёт участнику чата подписанную ссылку на файл сообщения,
+// действующую ttl (0 - DefaultSignedURLTTL)
+func (s *Service) SignFileURL(ctx context.Context, userID int32, messageID string, ttl time.Duration) (*SignedURL, error) {
+	if s.signer == nil {
+		return nil, ErrSignedURLsDisabled
+	}
+	if ttl < 0 || ttl > MaxSignedURLTTL {
+		return nil, invalidArgument("срок действия ссылки должен быть не больше 24 часов")
+	}
+	if ttl == 0 {
+		ttl = DefaultSignedURLTTL
+	}
+
+	message, _, err := s.memberMessage(ctx, messageID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if message.Attachment == nil {
+		return nil, ErrNoAttachment
+	}
+
+	// Срок действия в ссылке указывается с точностью до секунды
+	expiresAt := time.Now().Add(ttl).Truncate(time.Second)
+	params := s.signer.Sign(message.Attachment.Key, expiresAt)
+	path := (&url.URL{Path: signedUploadsURLPrefix + message.Attachment.Key}).EscapedPath()
+	return &SignedURL{URL: path + "?" + params.Encode(), ExpiresAt: expiresAt.UTC()}, nil
+}
+
+// OpenSignedFile открывает файл по подписанной ссылке: params - параметры
+// запроса с подписью. Файл удалённого сообщения недоступен и по действующей ссылке.
+func (s *Service) OpenSignedFile(ctx context.Context, key string, params url.Values) (*storage.Attachment, *blob.Reader, error) {
+	if s.signer == nil {
+		return nil, nil, ErrSignedURLsDisabled
+	}
+
+	if err := s.signer.Verify(key, params); err != nil {
+		if errors.Is(err, signedurl.ErrExpired) {
+			return nil, nil, ErrSignedURLExpired
+		}
+		return nil, nil, ErrInvalidSignedURL
+	}
+
+	message, err := s.fileMessage(ctx, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return s.openAttachment(ctx, message.Attachment)
+}
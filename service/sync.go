@@ -0,0 +1,164 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"chat-service/storage"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// syncLag - запас, с которым выдаётся токен синхронизации. Изменения, записанные
+// незадолго до синхронизации (в том числе узлами с немного отстающими часами),
+// будут выданы повторно при следующей синхронизации, а не потеряны. Повтор
+// безопасен: клиент получает текущее состояние сообщений и чатов, а не события.
+const syncLag = 10 * time.Second
+
+// SyncResult - изменения в чатах пользователя с момента предыдущей синхронизации
+type SyncResult struct {
+	Chats        []*ChatSummary     // новые и изменённые чаты пользователя
+	RemovedChats []string           // чаты, участником которых пользователь больше не является
+	Messages     []*storage.Message // новые, изменённые и удалённые (надгробия) сообщения
+	Token        string             // токен для следующей синхронизации
+	HasMore      bool               // изменения не поместились в ответ: повторить запрос с новым токеном
+}
+
+// Sync возвращает изменения в чатах пользователя после токена предыдущей синхронизации:
+// изменённые чаты (название, состав, роли, отметки, последнее сообщение), чаты,
+// из которых пользователь вышел или которые удалены, и изменённые сообщения
+// от давних изменений к недавним.
+//
+// Пустой токен означает первую синхронизацию: возвращаются все чаты без сообщений,
+// историю клиент загружает постранично. Так же клиенту следует поступить с чатами,
+// которые появились у него после предыдущей синхронизации. Токен старше срока
+// хранения удалённых данных отклоняется: клиент должен синхронизироваться заново.
+func (s *Service) Sync(ctx context.Context, userID int32, token string, limit int64) (*SyncResult, error) {
+	if userID == 0 {
+		return nil, ErrUnauthenticated
+	}
+	if limit < 0 {
+		return nil, invalidArgument("некорректный размер порции изменений")
+	}
+
+	cursor, err := parseSyncToken(token)
+	if err != nil {
+		return nil, err
+	}
+	syncedAt := time.Now()
+
+	// Надгробия и выходы из чатов старше срока хранения уже очищены
+	if token != "" && cursor.ChangedAt.Before(syncedAt.Add(-deletedRetention)) {
+		return nil, ErrSyncTokenExpired
+	}
+
+	chats, err := s.store.GetUserChats(ctx, userID)
+	if err != nil {
+		return nil, fromStorage(err)
+	}
+
+	current := make(map[primitive.ObjectID]*storage.Chat, len(chats))
+	chatIDs := make([]primitive.ObjectID, 0, len(chats))
+	var changed []*storage.Chat
+	for _, chat := range chats {
+		current[chat.ID] = chat
+		chatIDs = append(chatIDs, chat.ID)
+		if !chat.ChangedAt.Before(cursor.ChangedAt) {
+			changed = append(changed, chat)
+		}
+	}
+
+	result := &SyncResult{RemovedChats: []string{}, Messages: []*storage.Message{}}
+	if result.Chats, err = s.chatSummaries(ctx, userID, changed); err != nil {
+		return nil, err
+	}
+
+	// Токен следующей синхронизации не сдвигается назад
+	next := storage.ChangeCursor{ChangedAt: syncedAt.Add(-syncLag)}
+	if !next.ChangedAt.After(cursor.ChangedAt) {
+		next = cursor
+	}
+
+	if token != "" {
+		if result.RemovedChats, err = s.removedChats(ctx, userID, cursor.ChangedAt, current); err != nil {
+			return nil, err
+		}
+
+		page, err := s.store.GetMessageChanges(ctx, chatIDs, cursor, limit)
+		if err != nil {
+			return nil, fromStorage(err)
+		}
+		for _, message := range page.Messages {
+			withReceipts(current[message.ChatID], message)
+			if message.IsHiddenFor(userID) {
+				hiddenTombstone(message)
+			}
+		}
+		result.Messages, result.HasMore = page.Messages, page.HasMore
+
+		// Следующая порция начинается сразу после последнего выданного сообщения
+		if page.HasMore {
+			last := page.Messages[len(page.Messages)-1]
+			next = storage.ChangeCursor{ChangedAt: last.ChangedAt, MessageID: last.ID}
+		}
+	}
+
+	result.Token = formatSyncToken(next)
+	return result, nil
+}
+
+// removedChats возвращает чаты, которые пользователь покинул начиная с момента since.
+// Чаты, в которые он с тех пор вернулся, не учитываются.
+func (s *Service) removedChats(ctx context.Context, userID int32, since time.Time, current map[primitive.ObjectID]*storage.Chat) ([]string, error) {
+	removals, err := s.store.GetChatRemovals(ctx, userID, since)
+	if err != nil {
+		return nil, fromStorage(err)
+	}
+
+	removed := []string{}
+	seen := make(map[primitive.ObjectID]bool, len(removals))
+	for _, removal := range removals {
+		if current[removal.ChatID] != nil || seen[removal.ChatID] {
+			continue
+		}
+		seen[removal.ChatID] = true
+		removed = append(removed, removal.ChatID.Hex())
+	}
+	return removed, nil
+}
+
+// formatSyncToken кодирует позицию в потоке изменений в непрозрачный токен
+func formatSyncToken(cursor storage.ChangeCursor) string {
+	raw := fmt.Sprintf("%d.%s", cursor.ChangedAt.UnixMilli(), cursor.MessageID.Hex())
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// parseSyncToken разбирает токен синхронизации. Пустой токен - начало потока изменений.
+func parseSyncToken(token string) (storage.ChangeCursor, error) {
+	if token == "" {
+		return storage.ChangeCursor{}, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return storage.ChangeCursor{}, ErrInvalidSyncToken
+	}
+	millis, messageID, ok := strings.Cut(string(raw), ".")
+	if !ok {
+		return storage.ChangeCursor{}, ErrInvalidSyncToken
+	}
+
+	ms, err := strconv.ParseInt(millis, 10, 64)
+	if err != nil || ms < 0 {
+		return storage.ChangeCursor{}, ErrInvalidSyncToken
+	}
+	id, err := primitive.ObjectIDFromHex(messageID)
+	if err != nil {
+		return storage.ChangeCursor{}, ErrInvalidSyncToken
+	}
+	return storage.ChangeCursor{ChangedAt: time.UnixMilli(ms), MessageID: id}, nil
+}
@@ -0,0 +1,288 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"time"
+
+	"chat-service/blob"
+	"chat-service/storage"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Докачиваемая загрузка: клиент открывает сеанс с полным размером файла, передаёт
+// файл частями, а после обрыва связи запрашивает число принятых байт и продолжает
+// с этой позиции. Часть, передача которой оборвалась, не сохраняется и передаётся
+// заново. Когда файл принят целиком, части собираются в один файл и в чат
+// отправляется сообщение с ним, как при обычной загрузке.
+
+// MaxUploadSize - максимальный размер файла, загружаемого частями (в байтах)
+const MaxUploadSize int64 = 2 << 30
+
+// Сеанс, в который ничего не передавалось uploadSessionTTL, считается брошенным
+// и удаляется вместе с принятыми частями
+const uploadSessionTTL = 24 * time.Hour
+
+// Максимальное число частей в сеансе загрузки
+const maxUploadParts = 10000
+
+// Каталог хранилища файлов для частей незавершённых загрузок
+const partsPrefix = "parts"
+
+// NewUpload - параметры нового сеанса загрузки
+type NewUpload struct {
+	ChatID   string
+	Filename string
+	Size     int64 // Полный размер файла в байтах
+	// Ключ идемпотентности итогового сообщения. Если не задан, им служит ID сеанса.
+	ClientMessageID string
+}
+
+// CreateUpload открывает сеанс загрузки файла в чат. Права на отправку сообщений
+// проверяются при открытии сеанса и ещё раз при отправке файла.
+func (s *Service) CreateUpload(ctx context.Context, userID int32, upload NewUpload) (*storage.UploadSession, error) {
+	if upload.Size < 0 {
+		return nil, invalidArgument("некорректный размер файла")
+	}
+	if upload.Size > MaxUploadSize {
+		return nil, ErrUploadTooLarge
+	}
+	if len(upload.ClientMessageID) > maxClientMessageIDLength {
+		return nil, invalidArgument("слишком длинный ключ идемпотентности")
+	}
+
+	chat, err := s.postableChat(ctx, upload.ChatID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	session := &storage.UploadSession{
+		ID:              primitive.NewObjectID(),
+		UserID:          userID,
+		ChatID:          chat.ID,
+		Filename:        uploadFilename(upload.Filename),
+		Size:            upload.Size,
+		Parts:           []storage.UploadPart{},
+		ClientMessageID: upload.ClientMessageID,
+		CreatedAt:       now,
+		ExpiresAt:       now.Add(uploadSessionTTL),
+	}
+	if err := s.store.CreateUploadSession(ctx, session); err != nil {
+		return nil, fromStorage(err)
+	}
+	return session, nil
+}
+
+// GetUpload возвращает сеанс загрузки: по нему клиент узнаёт, с какой позиции
+// продолжить. Сеанс виден только открывшему его пользователю.
+func (s *Service) GetUpload(ctx context.Context, userID int32, uploadID string) (*storage.UploadSession, error) {
+	session, err := s.store.GetUploadSession(ctx, uploadID)
+	if err != nil {
+		return nil, fromStorage(err)
+	}
+
+	// Чужой и истёкший, но ещё не очищенный сеанс считаются ненайденными
+	if session.UserID != userID || !time.Now().Before(session.ExpiresAt) {
+		return nil, ErrUploadNotFound
+	}
+	return session, nil
+}
+
+// AppendUpload дописывает к файлу часть, начинающуюся с позиции offset, и продлевает
+// сеанс. length - длина части в байтах или -1, если она неизвестна. Позиция должна
+// совпадать с числом принятых байт, иначе возвращается ErrUploadOffsetMismatch.
+func (s *Service) AppendUpload(ctx context.Context, userID int32, uploadID string, offset int64, r io.Reader, length int64) (*storage.UploadSession, error) {
+	session, err := s.GetUpload(ctx, userID, uploadID)
+	if err != nil {
+		return nil, err
+	}
+	if offset != session.Offset {
+		return nil, ErrUploadOffsetMismatch
+	}
+
+	remaining := session.Size - offset
+	if length > remaining {
+		return nil, ErrUploadSizeExceeded
+	}
+	if length == 0 {
+		return session, nil
+	}
+	if len(session.Parts) >= maxUploadParts {
+		return nil, ErrTooManyUploadParts
+	}
+
+	// Читаем не больше одного лишнего байта: по нему видно, что часть не помещается в файл
+	part := storage.UploadPart{Key: partsPrefix + "/" + session.ID.Hex() + "/" + primitive.NewObjectID().Hex(), Size: length}
+	if err := s.blobs.Put(ctx, part.Key, io.LimitReader(r, remaining+1), length, defaultContentType); err != nil {
+		log.Printf("Ошибка сохранения части загрузки %s: %v", part.Key, err)
+		return nil, &Error{Code: CodeInternal, Message: "ошибка сохранения части файла", Err: err}
+	}
+
+	if part.Size < 0 {
+		info, err := s.blobs.Stat(ctx, part.Key)
+		if err != nil {
+			log.Printf("Ошибка чтения сохранённой части загрузки %s: %v", part.Key, err)
+			s.discardUpload(part.Key)
+			return nil, &Error{Code: CodeInternal, Message: "ошибка сохранения части файла", Err: err}
+		}
+		part.Size = info.Size
+	}
+	if part.Size == 0 || part.Size > remaining {
+		s.discardUpload(part.Key)
+		if part.Size > remaining {
+			return nil, ErrUploadSizeExceeded
+		}
+		return session, nil
+	}
+
+	// Часть учитывается, только если за время передачи сеанс не изменился
+	updated, err := s.store.AppendUploadPart(ctx, uploadID, offset, part, time.Now().Add(uploadSessionTTL))
+	if err != nil {
+		s.discardUpload(part.Key)
+		return nil, fromStorage(err)
+	}
+	return updated, nil
+}
+
+// FinalizeUpload собирает принятый целиком файл и отправляет в чат сообщение
+// с ним. Сеанс закрывается после отправки сообщения, а при ошибке остаётся
+// открытым, и завершение можно повторить.
+func (s *Service) FinalizeUpload(ctx context.Context, userID int32, uploadID string) (*storage.Message, error) {
+	session, err := s.GetUpload(ctx, userID, uploadID)
+	if err != nil {
+		return nil, err
+	}
+	if !session.Complete() {
+		return nil, ErrUploadIncomplete
+	}
+
+	// Ключ идемпотентности не даёт повторному или параллельному завершению
+	// отправить файл дважды
+	clientMessageID := session.ClientMessageID
+	if clientMessageID == "" {
+		clientMessageID = "upload-" + session.ID.Hex()
+	}
+
+	chat, err := s.postableChat(ctx, session.ChatID.Hex(), userID)
+	if err != nil {
+		return nil, err
+	}
+	if original, err := s.replayedMessage(ctx, chat, userID, clientMessageID); original != nil || err != nil {
+		if original != nil {
+			s.removeUpload(uploadID)
+		}
+		return original, err
+	}
+
+	attachment := newAttachment(filesPrefix, session.Filename, session.Size)
+	content := &partsReader{ctx: ctx, blobs: s.blobs, parts: session.Parts}
+	err = s.blobs.Put(ctx, attachment.Key, content, attachment.Size, attachment.ContentType)
+	content.Close()
+	if err != nil {
+		log.Printf("Ошибка сборки загруженного файла %s: %v", attachment.Key, err)
+		return nil, &Error{Code: CodeInternal, Message: "ошибка сохранения файла", Err: err}
+	}
+
+	message, err := s.sendFileMessage(ctx, userID, session.ChatID.Hex(), attachment, clientMessageID)
+	if err != nil {
+		return nil, err
+	}
+	s.removeUpload(uploadID)
+	return message, nil
+}
+
+// CancelUpload закрывает сеанс загрузки и удаляет принятые части
+func (s *Service) CancelUpload(ctx context.Context, userID int32, uploadID string) error {
+	if _, err := s.GetUpload(ctx, userID, uploadID); err != nil {
+		return err
+	}
+
+	session, err := s.store.DeleteUploadSession(ctx, uploadID)
+	if err != nil {
+		return fromStorage(err)
+	}
+	s.discardParts(session)
+	return nil
+}
+
+// PurgeUploads удаляет брошенные сеансы загрузки вместе с принятыми частями
+func (s *Service) PurgeUploads(ctx context.Context) error {
+	sessions, err := s.store.PurgeUploadSessions(ctx, time.Now())
+
+	// Части уже удалённых сеансов больше никому не нужны, даже если очистка прервалась
+	for _, session := range sessions {
+		s.discardParts(session)
+	}
+	if err != nil {
+		return fromStorage(err)
+	}
+	return nil
+}
+
+// removeUpload закрывает завершённый сеанс загрузки. Ошибка не прерывает
+// операцию: сеанс удалит очистка брошенных загрузок.
+func (s *Service) removeUpload(uploadID string) {
+	session, err := s.store.DeleteUploadSession(context.Background(), uploadID)
+	if errors.Is(err, storage.ErrUploadNotFound) {
+		// Параллельное завершение уже закрыло сеанс
+		return
+	}
+	if err != nil {
+		log.Printf("Не удалось закрыть сеанс загрузки %s: %v", uploadID, err)
+		return
+	}
+	s.discardParts(session)
+}
+
+// discardParts удаляет части файла закрытого сеанса загрузки
+func (s *Service) discardParts(session *storage.UploadSession) {
+	for _, part := range session.Parts {
+		s.discardUpload(part.Key)
+	}
+}
+
+// partsReader читает части загрузки одну за другой как единый файл
+type partsReader struct {
+	ctx   context.Context
+	blobs blob.Store
+	parts []storage.UploadPart
+	body  io.ReadCloser
+}
+
+func (r *partsReader) Read(p []byte) (int, error) {
+	for {
+		if r.body == nil {
+			if len(r.parts) == 0 {
+				return 0, io.EOF
+			}
+			body, _, err := r.blobs.Get(r.ctx, r.parts[0].Key, 0)
+			if err != nil {
+				return 0, err
+			}
+			r.body, r.parts = body, r.parts[1:]
+		}
+
+		n, err := r.body.Read(p)
+		if err == io.EOF {
+			r.Close()
+			if n == 0 {
+				continue
+			}
+			err = nil
+		}
+		return n, err
+	}
+}
+
+func (r *partsReader) Close() error {
+	if r.body == nil {
+		return nil
+	}
+	err := r.body.Close()
+	r.body = nil
+	return err
+}
@@ -0,0 +1,137 @@
+// Package signedurl подписывает ссылки на ресурсы HMAC-SHA256 с ограниченным
+// сроком действия. Ссылку проверяет любая реплика сервиса с теми же ключами,
+// без обращения к сервису аутентификации.
+//
+// Ключи подписи ротируются: новые ссылки подписываются первым ключом списка,
+// а проверяются всеми ключами. Порядок смены ключа: добавить новый ключ
+// в конец списка на всех репликах, затем переместить его в начало, а старый
+// ключ удалить, когда истекут подписанные им ссылки.
+package signedurl
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Параметры подписанной ссылки
+const (
+	ParamExpires   = "expires" // время окончания действия, Unix-время в секундах
+	ParamKeyID     = "kid"     // ключ, которым подписана ссылка
+	ParamSignature = "sig"     // подпись
+)
+
+// Минимальная длина секрета ключа подписи (в байтах)
+const MinSecretLength = 32
+
+var (
+	ErrInvalidSignature = errors.New("недействительная подпись ссылки")
+	ErrExpired          = errors.New("срок действия ссылки истёк")
+)
+
+// Key - ключ подписи
+type Key struct {
+	ID     string
+	Secret []byte
+}
+
+// Signer подписывает и проверяет ссылки
+type Signer struct {
+	keys []Key
+}
+
+// NewSigner создаёт Signer. Ссылки подписываются первым ключом.
+func NewSigner(keys ...Key) (*Signer, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("не указаны ключи подписи ссылок")
+	}
+
+	seen := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		if key.ID == "" || strings.ContainsAny(key.ID, ":,&=") {
+			return nil, fmt.Errorf("некорректный ID ключа подписи: %q", key.ID)
+		}
+		if seen[key.ID] {
+			return nil, fmt.Errorf("повторяющийся ID ключа подписи: %q", key.ID)
+		}
+		if len(key.Secret) < MinSecretLength {
+			return nil, fmt.Errorf("секрет ключа подписи %q короче %d байт", key.ID, MinSecretLength)
+		}
+		seen[key.ID] = true
+	}
+	return &Signer{keys: keys}, nil
+}
+
+// ParseKeys разбирает список ключей вида "id1:secret1,id2:secret2"
+func ParseKeys(spec string) ([]Key, error) {
+	var keys []Key
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		id, secret, ok := strings.Cut(item, ":")
+		if !ok {
+			return nil, fmt.Errorf("ключ подписи должен иметь вид id:secret")
+		}
+		keys = append(keys, Key{ID: id, Secret: []byte(secret)})
+	}
+	return keys, nil
+}
+
+// Sign возвращает параметры ссылки на ресурс, действующей до expiresAt.
+// resource - неизменяемая часть ссылки, например путь к файлу.
+func (s *Signer) Sign(resource string, expiresAt time.Time) url.Values {
+	key := s.keys[0]
+	expires := strconv.FormatInt(expiresAt.Unix(), 10)
+	return url.Values{
+		ParamExpires:   {expires},
+		ParamKeyID:     {key.ID},
+		ParamSignature: {signature(key, resource, expires)},
+	}
+}
+
+// Verify проверяет подпись и срок действия ссылки на ресурс
+func (s *Signer) Verify(resource string, params url.Values) error {
+	expires := params.Get(ParamExpires)
+	expiresAt, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+
+	key, ok := s.key(params.Get(ParamKeyID))
+	if !ok {
+		return ErrInvalidSignature
+	}
+	if !hmac.Equal([]byte(signature(key, resource, expires)), []byte(params.Get(ParamSignature))) {
+		return ErrInvalidSignature
+	}
+
+	// Срок проверяется после подписи, чтобы подделанная ссылка не выдавала себя за истёкшую
+	if !time.Now().Before(time.Unix(expiresAt, 0)) {
+		return ErrExpired
+	}
+	return nil
+}
+
+func (s *Signer) key(id string) (Key, bool) {
+	for _, key := range s.keys {
+		if key.ID == id {
+			return key, true
+		}
+	}
+	return Key{}, false
+}
+
+// signature подписывает ресурс вместе со сроком действия и ID ключа
+func signature(key Key, resource string, expires string) string {
+	mac := hmac.New(sha256.New, key.Secret)
+	mac.Write([]byte(key.ID + "\n" + expires + "\n" + resource))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,169 @@
+package signedurl
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	oldKey = Key{ID: "old", Secret: []byte(strings.Repeat("o", MinSecretLength))}
+	newKey = Key{ID: "new", Secret: []byte(strings.Repeat("n", MinSecretLength))}
+)
+
+const resource = "/uploads/files/report.pdf"
+
+func newSigner(t *testing.T, keys ...Key) *Signer {
+	t.Helper()
+	signer, err := NewSigner(keys...)
+	if err != nil {
+		t.Fatalf("NewSigner: %v", err)
+	}
+	return signer
+}
+
+// with возвращает копию параметров ссылки с заменённым значением
+func with(params url.Values, name string, value string) url.Values {
+	changed := url.Values{}
+	for k, v := range params {
+		changed[k] = append([]string(nil), v...)
+	}
+	changed.Set(name, value)
+	return changed
+}
+
+func TestVerify(t *testing.T) {
+	signer := newSigner(t, newKey)
+	params := signer.Sign(resource, time.Now().Add(time.Hour))
+
+	if err := signer.Verify(resource, params); err != nil {
+		t.Fatalf("Verify действующей ссылки: %v", err)
+	}
+	if kid := params.Get(ParamKeyID); kid != newKey.ID {
+		t.Errorf("ссылка подписана ключом %q, ожидался %q", kid, newKey.ID)
+	}
+
+	sig := params.Get(ParamSignature)
+	tampered := []byte(sig)
+	if tampered[0] == 'A' {
+		tampered[0] = 'B'
+	} else {
+		tampered[0] = 'A'
+	}
+
+	tests := []struct {
+		name   string
+		params url.Values
+	}{
+		{"изменённая подпись", with(params, ParamSignature, string(tampered))},
+		{"пустая подпись", with(params, ParamSignature, "")},
+		{"продлённый срок", with(params, ParamExpires, "9999999999")},
+		{"некорректный срок", with(params, ParamExpires, "завтра")},
+		{"неизвестный ключ", with(params, ParamKeyID, "unknown")},
+		{"без параметров", url.Values{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := signer.Verify(resource, tt.params); !errors.Is(err, ErrInvalidSignature) {
+				t.Errorf("ожидалась ErrInvalidSignature, получено %v", err)
+			}
+		})
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	expiresAt := time.Now().Add(time.Hour)
+	before := newSigner(t, oldKey).Sign(resource, expiresAt)
+
+	// Новый ключ подписывает, старый ещё принимается
+	rotated := newSigner(t, newKey, oldKey)
+	if err := rotated.Verify(resource, before); err != nil {
+		t.Errorf("Verify ссылки, подписанной старым ключом: %v", err)
+	}
+	after := rotated.Sign(resource, expiresAt)
+	if kid := after.Get(ParamKeyID); kid != newKey.ID {
+		t.Errorf("после ротации ссылка подписана ключом %q, ожидался %q", kid, newKey.ID)
+	}
+	if err := rotated.Verify(resource, after); err != nil {
+		t.Errorf("Verify ссылки, подписанной новым ключом: %v", err)
+	}
+
+	// Удалённый старый ключ больше не принимается
+	retired := newSigner(t, newKey)
+	if err := retired.Verify(resource, before); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("ссылка удалённого ключа: ожидалась ErrInvalidSignature, получено %v", err)
+	}
+	if err := retired.Verify(resource, after); err != nil {
+		t.Errorf("Verify ссылки, подписанной новым ключом: %v", err)
+	}
+}
+
+func TestExpired(t *testing.T) {
+	signer := newSigner(t, newKey)
+	params := signer.Sign(resource, time.Now().Add(-time.Second))
+
+	if err := signer.Verify(resource, params); !errors.Is(err, ErrExpired) {
+		t.Errorf("истёкшая ссылка: ожидалась ErrExpired, получено %v", err)
+	}
+
+	// Подделанная ссылка не выдаёт себя за истёкшую
+	forged := with(params, ParamSignature, "forged")
+	if err := signer.Verify(resource, forged); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("истёкшая поддельная ссылка: ожидалась ErrInvalidSignature, получено %v", err)
+	}
+}
+
+func TestSignatureBoundToResourceAndKey(t *testing.T) {
+	signer := newSigner(t, newKey, oldKey)
+	params := signer.Sign(resource, time.Now().Add(time.Hour))
+
+	// Подпись одного файла не открывает доступ к другому
+	for _, other := range []string{"/uploads/files/other.pdf", resource + "x", strings.ToUpper(resource)} {
+		if err := signer.Verify(other, params); !errors.Is(err, ErrInvalidSignature) {
+			t.Errorf("подпись перенесена на %q: ожидалась ErrInvalidSignature, получено %v", other, err)
+		}
+	}
+
+	// Подпись не переносится на другой ключ
+	if err := signer.Verify(resource, with(params, ParamKeyID, oldKey.ID)); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("подпись перенесена на другой ключ: ожидалась ErrInvalidSignature, получено %v", err)
+	}
+}
+
+func TestNewSigner(t *testing.T) {
+	secret := []byte(strings.Repeat("s", MinSecretLength))
+	tests := []struct {
+		name string
+		keys []Key
+	}{
+		{"без ключей", nil},
+		{"пустой ID", []Key{{ID: "", Secret: secret}}},
+		{"ID с разделителем", []Key{{ID: "a:b", Secret: secret}}},
+		{"повторяющийся ID", []Key{{ID: "a", Secret: secret}, {ID: "a", Secret: secret}}},
+		{"короткий секрет", []Key{{ID: "a", Secret: secret[1:]}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewSigner(tt.keys...); err == nil {
+				t.Error("NewSigner не вернул ошибку")
+			}
+		})
+	}
+}
+
+func TestParseKeys(t *testing.T) {
+	keys, err := ParseKeys(" new:secret-one , old:secret:two ,")
+	if err != nil {
+		t.Fatalf("ParseKeys: %v", err)
+	}
+	if len(keys) != 2 || keys[0].ID != "new" || string(keys[0].Secret) != "secret-one" ||
+		keys[1].ID != "old" || string(keys[1].Secret) != "secret:two" {
+		t.Errorf("ParseKeys вернул %+v", keys)
+	}
+
+	if _, err := ParseKeys("без-секрета"); err == nil {
+		t.Error("ParseKeys ключа без секрета не вернул ошибку")
+	}
+}
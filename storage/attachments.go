@@ -0,0 +1,16 @@
+package storage
+
+// Содержимое файлов хранится в хранилище файлов (пакет blob), а сообщение
+// с типом MessageTypeFile хранит ключ файла и сведения о нём. По ключу
+// GetMessageByAttachmentKey находит сообщение, чтобы проверить, кому файл доступен.
+
+// Тип сообщения с приложенным файлом
+const MessageTypeFile = "file"
+
+// Attachment - файл, приложенный к сообщению
+type Attachment struct {
+	Key         string `bson:"key"`          // ключ в хранилище файлов
+	Filename    string `bson:"filename"`     // исходное имя файла
+	ContentType string `bson:"content_type"` // тип содержимого
+	Size        int64  `bson:"size"`         // размер в байтах
+}
@@ -0,0 +1,83 @@
+package storage
+
+import (
+	"encoding/json"
+	"strconv"
+)
+
+// Канал - групповой чат (IsGroup == true) с флагом IsChannel: публикуют в нём
+// только администраторы и владелец, остальные участники - подписчики.
+// Вместо отметок прочтения у постов канала ведутся счётчики просмотров.
+// Подписчиков могут быть тысячи, поэтому в JSON канала вместо их списка и
+// отметок передаётся только их число (MemberCount), а сам список отдаётся
+// постранично (GetChatParticipantsPage).
+
+// Размер страницы участников по умолчанию и максимальный
+const (
+	DefaultParticipantsLimit int64 = 100
+	MaxParticipantsLimit     int64 = 1000
+)
+
+// ParticipantPageQuery - параметры постраничного получения участников чата.
+// Курсор - непрозрачная строка из ParticipantPage.NextCursor, пустой курсор - первая страница.
+type ParticipantPageQuery struct {
+	Cursor string
+	Limit  int64
+}
+
+// ParticipantPage - страница участников в порядке вступления.
+// Пустой NextCursor означает, что участников больше нет.
+type ParticipantPage struct {
+	UserIDs    []int32
+	Total      int64 // Общее число участников (подписчиков канала)
+	NextCursor string
+}
+
+// offset возвращает позицию в списке участников, с которой начинается страница
+func (q ParticipantPageQuery) offset() (int64, error) {
+	if q.Cursor == "" {
+		return 0, nil
+	}
+	offset, err := strconv.ParseInt(q.Cursor, 10, 64)
+	if err != nil || offset < 0 {
+		return 0, ErrInvalidCursor
+	}
+	return offset, nil
+}
+
+// limit возвращает размер страницы с учётом значения по умолчанию и ограничения
+func (q ParticipantPageQuery) limit() int64 {
+	switch {
+	case q.Limit <= 0:
+		return DefaultParticipantsLimit
+	case q.Limit > MaxParticipantsLimit:
+		return MaxParticipantsLimit
+	}
+	return q.Limit
+}
+
+// newParticipantPage собирает страницу, начинающуюся с позиции offset
+func newParticipantPage(userIDs []int32, offset int64, total int64) *ParticipantPage {
+	page := &ParticipantPage{UserIDs: userIDs, Total: total}
+	if next := offset + int64(len(userIDs)); len(userIDs) > 0 && next < total {
+		page.NextCursor = strconv.FormatInt(next, 10)
+	}
+	return page
+}
+
+// MarshalJSON кодирует чат без списка подписчиков и их отметок, если чат - канал
+func (c Chat) MarshalJSON() ([]byte, error) {
+	// Тип без методов, иначе json.Marshal вызовет MarshalJSON повторно
+	type chatFields Chat
+	if !c.IsChannel {
+		return json.Marshal(chatFields(c))
+	}
+
+	// Пустые поля верхнего уровня перекрывают одноимённые поля чата и опускаются
+	return json.Marshal(struct {
+		chatFields
+		MemberIDs        []int32          `json:",omitempty"`
+		DeliveredMarkers map[string]int64 `json:",omitempty"`
+		ReadMarkers      map[string]int64 `json:",omitempty"`
+	}{chatFields: chatFields(c)})
+}
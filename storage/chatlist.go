@@ -0,0 +1,45 @@
+package storage
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Максимальная длина превью последнего сообщения в списке чатов (в символах)
+const lastMessageSnippetLength = 100
+
+// LastMessage - превью последнего сообщения, хранящееся в документе чата.
+// Благодаря ему список чатов строится без чтения истории каждого чата.
+type LastMessage struct {
+	MessageID primitive.ObjectID `bson:"message_id"`
+	Seq       int64              `bson:"seq,omitempty"` // Номер сообщения: превью не заменяется более ранним
+	SenderID  int32              `bson:"sender_id"`
+	Type      string             `bson:"type"`
+	Snippet   string             `bson:"snippet"`
+	CreatedAt time.Time          `bson:"created_at"`
+}
+
+// UnreadCount - число непрочитанных пользователем сообщений чата
+// и упоминаний пользователя среди них
+type UnreadCount struct {
+	Messages int64
+	Mentions int64
+}
+
+func newLastMessage(message *Message) *LastMessage {
+	return &LastMessage{
+		MessageID: message.ID,
+		Seq:       message.Seq,
+		SenderID:  message.SenderID,
+		Type:      message.Type,
+		Snippet:   snippet(message.Content, lastMessageSnippetLength),
+		CreatedAt: message.CreatedAt,
+	}
+}
+
+// hasUnread сообщает, могут ли в чате быть непрочитанные пользователем сообщения.
+// Чаты, прочитанные до последнего сообщения, не нужно пересчитывать.
+func hasUnread(chat *Chat, userID int32) bool {
+	return chat.ReadUpTo(userID) < chat.LastSeq
+}
@@ -0,0 +1,39 @@
+package storage
+
+import (
+	"time"
+)
+
+// Удаление бывает трёх видов:
+//   - удаление сообщения для всех (DeleteMessage) оставляет надгробие (DeletedAt);
+//   - удаление сообщения для себя (HideMessage) только добавляет пользователя
+//     в HiddenFor: остальные участники видят сообщение как прежде;
+//   - удалённый чат (DeleteChat) переносится в корзину вместе с сообщениями
+//     (ChatDeletedAt) и может быть восстановлен (RestoreChat).
+//
+// Надгробия, корзина и записи о выходе из чатов не хранятся вечно:
+// PurgeDeleted окончательно удаляет всё, что удалено раньше заданного момента.
+// Файлы очищенных чатов хранятся вне базы, поэтому PurgeDeleted возвращает
+// сведения о них, а удаляет их вызывающий.
+
+// Purged - окончательно удалённые чаты и файлы их сообщений
+type Purged struct {
+	Chats       []*Chat
+	Attachments []*Attachment
+}
+
+// IsHiddenFor сообщает, что пользователь удалил сообщение для себя
+func (m *Message) IsHiddenFor(userID int32) bool {
+	return containsUser(m.HiddenFor, userID)
+}
+
+// hidden сообщает, что сообщение не выдаётся при обычном чтении:
+// оно удалено для всех или находится в удалённом чате
+func (m *Message) hidden() bool {
+	return m.DeletedAt != nil || m.ChatDeletedAt != nil
+}
+
+// expired сообщает, что момент удаления наступил раньше before
+func expired(deletedAt *time.Time, before time.Time) bool {
+	return deletedAt != nil && deletedAt.Before(before)
+}
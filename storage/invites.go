@@ -0,0 +1,43 @@
+package storage
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Invite - пригласительная ссылка в групповой чат. Токен служит идентификатором.
+type Invite struct {
+	Token     string             `bson:"_id"`
+	ChatID    primitive.ObjectID `bson:"chat_id"`
+	CreatorID int32              `bson:"creator_id"`
+	CreatedAt time.Time          `bson:"created_at"`
+	ExpiresAt *time.Time         `bson:"expires_at,omitempty"` // nil - бессрочное приглашение
+	MaxUses   int32              `bson:"max_uses"`             // 0 - без ограничения числа использований
+	Uses      int32              `bson:"uses"`
+	Revoked   bool               `bson:"revoked"`
+}
+
+// Expired сообщает, истёк ли срок действия приглашения к моменту at
+func (i *Invite) Expired(at time.Time) bool {
+	return i.ExpiresAt != nil && !at.Before(*i.ExpiresAt)
+}
+
+// Exhausted сообщает, исчерпан ли лимит использований приглашения
+func (i *Invite) Exhausted() bool {
+	return i.MaxUses > 0 && i.Uses >= i.MaxUses
+}
+
+// redeemError объясняет, почему приглашение нельзя использовать в момент at.
+// Возвращает nil, если приглашение действительно.
+func (i *Invite) redeemError(at time.Time) error {
+	switch {
+	case i.Revoked:
+		return ErrInviteNotFound
+	case i.Expired(at):
+		return ErrInviteExpired
+	case i.Exhausted():
+		return ErrInviteExhausted
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package storage
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Состояния заявки на вступление в групповой чат
+const (
+	JoinRequestPending  = "pending"
+	JoinRequestApproved = "approved"
+	JoinRequestRejected = "rejected"
+)
+
+// JoinRequest - заявка пользователя на вступление в групповой чат с одобрением.
+// Решения по заявкам не удаляются и остаются в истории чата.
+type JoinRequest struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	ChatID    primitive.ObjectID `bson:"chat_id"`
+	UserID    int32              `bson:"user_id"`
+	Message   string             `bson:"message,omitempty"` // Сопроводительный текст заявителя
+	Status    string             `bson:"status"`
+	CreatedAt time.Time          `bson:"created_at"`
+	DecidedBy int32              `bson:"decided_by,omitempty"` // Администратор, принявший решение
+	DecidedAt *time.Time         `bson:"decided_at,omitempty"`
+}
+
+// IsValidJoinRequestStatus сообщает, является ли строка известным состоянием заявки
+func IsValidJoinRequestStatus(status string) bool {
+	return status == JoinRequestPending || status == JoinRequestApproved || status == JoinRequestRejected
+}
@@ -0,0 +1,1732 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MemoryStorage - хранилище в памяти процесса. Повторяет поведение MongoStorage
+// (те же проверки и ошибки) и подходит для тестов и локального запуска без базы.
+// Все методы безопасны для конкурентного использования.
+type MemoryStorage struct {
+	mu sync.RWMutex
+
+	chats     map[primitive.ObjectID]*Chat
+	chatOrder []primitive.ObjectID
+
+	messages     map[primitive.ObjectID]*Message
+	chatMessages map[primitive.ObjectID][]primitive.ObjectID
+	// Ответы веток по ID корневого сообщения
+	threads map[primitive.ObjectID][]primitive.ObjectID
+
+	invites      map[string]*Invite
+	joinRequests map[primitive.ObjectID]*JoinRequest
+	removals     []*ChatRemoval
+
+	// Корзина удалённых чатов
+	trash map[primitive.ObjectID]*deletedChat
+	// Журнал прежних ревизий по ID сообщения
+	revisions map[primitive.ObjectID][]*MessageRevision
+	// Сеансы докачиваемой загрузки файлов
+	uploads map[primitive.ObjectID]*UploadSession
+}
+
+// deletedChat - чат в корзине вместе с убранными из индексов историей и ветками
+type deletedChat struct {
+	chat     *Chat
+	messages []primitive.ObjectID
+	threads  map[primitive.ObjectID][]primitive.ObjectID
+}
+
+// NewMemoryStorage создаёт пустое хранилище в памяти
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{
+		chats:        make(map[primitive.ObjectID]*Chat),
+		messages:     make(map[primitive.ObjectID]*Message),
+		chatMessages: make(map[primitive.ObjectID][]primitive.ObjectID),
+		threads:      make(map[primitive.ObjectID][]primitive.ObjectID),
+		invites:      make(map[string]*Invite),
+		joinRequests: make(map[primitive.ObjectID]*JoinRequest),
+		trash:        make(map[primitive.ObjectID]*deletedChat),
+		revisions:    make(map[primitive.ObjectID][]*MessageRevision),
+		uploads:      make(map[primitive.ObjectID]*UploadSession),
+	}
+}
+
+func (m *MemoryStorage) Close(ctx context.Context) error {
+	return nil
+}
+
+func (m *MemoryStorage) Ping(ctx context.Context) error {
+	return nil
+}
+
+func (m *MemoryStorage) CreateChat(ctx context.Context, name string, memberIDs []int32, isGroup bool, description string, creatorID int32) (string, error) {
+	return m.insertChat(name, memberIDs, isGroup, false, description, creatorID)
+}
+
+func (m *MemoryStorage) CreateChannel(ctx context.Context, name string, memberIDs []int32, description string, creatorID int32) (string, error) {
+	return m.insertChat(name, memberIDs, true, true, description, creatorID)
+}
+
+func (m *MemoryStorage) insertChat(name string, memberIDs []int32, isGroup bool, isChannel bool, description string, creatorID int32) (string, error) {
+	if creatorID == 0 {
+		return "", ErrNoCreator
+	}
+
+	createdAt := now()
+	chat := &Chat{
+		ID:             primitive.NewObjectID(),
+		Name:           name,
+		Description:    description,
+		CreatorID:      creatorID,
+		MemberIDs:      append([]int32{}, memberIDs...),
+		IsGroup:        isGroup,
+		IsChannel:      isChannel,
+		CreatedAt:      createdAt,
+		LastActivityAt: createdAt,
+		ChangedAt:      createdAt,
+	}
+	if isGroup {
+		chat.Roles = map[string]string{markerKey(creatorID): RoleOwner}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.chats[chat.ID] = chat
+	m.chatOrder = append(m.chatOrder, chat.ID)
+	return chat.ID.Hex(), nil
+}
+
+func (m *MemoryStorage) UpdateChatInfo(ctx context.Context, chatID string, name string, description string) error {
+	objID, err := primitive.ObjectIDFromHex(chatID)
+	if err != nil {
+		return ErrInvalidChatID
+	}
+
+	if name == "" && description == "" {
+		return ErrEmptyUpdate
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	chat, ok := m.chats[objID]
+	if !ok {
+		return ErrChatNotFound
+	}
+
+	if name != "" {
+		chat.Name = name
+	}
+	if description != "" {
+		chat.Description = description
+	}
+	chat.ChangedAt = now()
+	return nil
+}
+
+func (m *MemoryStorage) SetChatAvatar(ctx context.Context, chatID string, avatar string) error {
+	objID, err := primitive.ObjectIDFromHex(chatID)
+	if err != nil {
+		return ErrInvalidChatID
+	}
+
+	if avatar == "" {
+		return ErrEmptyAvatar
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	chat, ok := m.chats[objID]
+	if !ok {
+		return ErrChatNotFound
+	}
+
+	chat.Avatar = avatar
+	chat.ChangedAt = now()
+	return nil
+}
+
+func (m *MemoryStorage) AddParticipant(ctx context.Context, chatID string, userID int32) error {
+	objID, err := primitive.ObjectIDFromHex(chatID)
+	if err != nil {
+		return ErrInvalidChatID
+	}
+
+	if userID == 0 {
+		return ErrInvalidUserID
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Как и в MongoStorage, состав меняется только у групповых чатов
+	chat, ok := m.chats[objID]
+	if !ok || !chat.IsGroup {
+		return ErrNotGroupChat
+	}
+
+	if !containsUser(chat.MemberIDs, userID) {
+		chat.MemberIDs = append(chat.MemberIDs, userID)
+	}
+	chat.ChangedAt = now()
+	return nil
+}
+
+func (m *MemoryStorage) RemoveParticipant(ctx context.Context, chatID string, userID int32) error {
+	objID, err := primitive.ObjectIDFromHex(chatID)
+	if err != nil {
+		return ErrInvalidChatID
+	}
+
+	if userID == 0 {
+		return ErrInvalidUserID
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	chat, ok := m.chats[objID]
+	if !ok || !chat.IsGroup {
+		return ErrNotGroupChat
+	}
+
+	chat.MemberIDs = removeUser(chat.MemberIDs, userID)
+	delete(chat.Roles, markerKey(userID))
+	chat.ChangedAt = now()
+	m.recordRemovals(objID, userID)
+	return nil
+}
+
+func (m *MemoryStorage) SaveMessage(ctx context.Context, msg NewMessage) (string, error) {
+	chatObjectID, err := primitive.ObjectIDFromHex(msg.ChatID)
+	if err != nil {
+		return "", ErrInvalidChatID
+	}
+
+	var parentID primitive.ObjectID
+	if msg.ReplyToID != "" {
+		if parentID, err = primitive.ObjectIDFromHex(msg.ReplyToID); err != nil {
+			return "", ErrInvalidMessageID
+		}
+	}
+
+	createdAt := now()
+	message := &Message{
+		ID:              primitive.NewObjectID(),
+		ChatID:          chatObjectID,
+		SenderID:        msg.SenderID,
+		Content:         msg.Content,
+		Type:            msg.Type,
+		CreatedAt:       createdAt,
+		ChangedAt:       createdAt,
+		ClientMessageID: msg.ClientMessageID,
+	}
+	if len(msg.Mentions) > 0 {
+		message.Mentions = append([]int32{}, msg.Mentions...)
+	}
+	if msg.Notice != nil {
+		notice := *msg.Notice
+		message.Notice = &notice
+	}
+	if msg.Attachment != nil {
+		attachment := *msg.Attachment
+		message.Attachment = &attachment
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Повтор запроса с тем же ключом: возвращаем исходное сообщение
+	if original := m.messageByClientID(msg.SenderID, msg.ClientMessageID); original != nil {
+		return original.ID.Hex(), ErrDuplicateMessage
+	}
+
+	chat, ok := m.chats[chatObjectID]
+	if !ok {
+		return "", ErrChatNotFound
+	}
+
+	// Отвечать можно только на сообщения того же чата
+	if msg.ReplyToID != "" {
+		parent, ok := m.messages[parentID]
+		if !ok || parent.hidden() || parent.ChatID != chatObjectID {
+			return "", ErrInvalidReply
+		}
+		message.ReplyTo = newReplyTo(parent)
+	}
+
+	chat.LastSeq++
+	message.Seq = chat.LastSeq
+
+	m.messages[message.ID] = message
+	m.chatMessages[chatObjectID] = append(m.chatMessages[chatObjectID], message.ID)
+
+	if message.ReplyTo != nil {
+		threadID := message.ReplyTo.ThreadID
+		m.threads[threadID] = append(m.threads[threadID], message.ID)
+		if root, ok := m.messages[threadID]; ok {
+			root.ReplyCount++
+			root.ChangedAt = createdAt
+		}
+	}
+
+	// Превью не заменяется более ранним сообщением, как в MongoStorage
+	if chat.LastMessage == nil || chat.LastMessage.Seq < message.Seq {
+		chat.LastMessage = newLastMessage(message)
+		chat.LastActivityAt = message.CreatedAt
+		chat.ChangedAt = message.CreatedAt
+	}
+	return message.ID.Hex(), nil
+}
+
+func (m *MemoryStorage) EditMessage(ctx context.Context, messageID string, userID int32, newContent string, expectedRevision int64) error {
+	objID, err := primitive.ObjectIDFromHex(messageID)
+	if err != nil {
+		return ErrInvalidMessageID
+	}
+
+	if strings.TrimSpace(newContent) == "" {
+		return ErrEmptyContent
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	message, ok := m.messages[objID]
+	if !ok || message.hidden() {
+		return ErrMessageNotFound
+	}
+
+	if message.SenderID != userID {
+		return ErrForbidden
+	}
+	if message.Revision != expectedRevision {
+		return ErrRevisionConflict
+	}
+
+	editedAt := now()
+	m.revisions[objID] = append(m.revisions[objID], newRevision(message, editedAt))
+	message.Content = newContent
+	message.EditedAt = &editedAt
+	message.ChangedAt = editedAt
+	message.Revision++
+	m.refreshLastMessage(message.ChatID, objID)
+	return nil
+}
+
+func (m *MemoryStorage) GetMessageRevisions(ctx context.Context, messageID string) ([]*MessageRevision, error) {
+	objID, err := primitive.ObjectIDFromHex(messageID)
+	if err != nil {
+		return nil, ErrInvalidMessageID
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	revisions := make([]*MessageRevision, 0, len(m.revisions[objID]))
+	for _, revision := range m.revisions[objID] {
+		r := *revision
+		revisions = append(revisions, &r)
+	}
+	return revisions, nil
+}
+
+func (m *MemoryStorage) PinMessage(ctx context.Context, chatID string, messageID string, userID int32) error {
+	chatObjectID, err := primitive.ObjectIDFromHex(chatID)
+	if err != nil {
+		return ErrInvalidChatID
+	}
+	messageObjectID, err := primitive.ObjectIDFromHex(messageID)
+	if err != nil {
+		return ErrInvalidMessageID
+	}
+
+	if userID == 0 {
+		return ErrInvalidUserID
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	message, ok := m.messages[messageObjectID]
+	if !ok || message.hidden() || message.ChatID != chatObjectID {
+		return ErrMessageNotFound
+	}
+
+	chat, ok := m.chats[chatObjectID]
+	if !ok {
+		return ErrChatNotFound
+	}
+	if chat.IsPinned(messageObjectID) || len(chat.PinnedMessages) >= MaxPinnedMessages {
+		return chat.pinError(messageObjectID)
+	}
+
+	pinnedAt := now()
+	chat.PinnedMessages = append(chat.PinnedMessages, PinnedMessage{MessageID: messageObjectID, PinnedBy: userID, PinnedAt: pinnedAt})
+	chat.ChangedAt = pinnedAt
+	return nil
+}
+
+func (m *MemoryStorage) UnpinMessage(ctx context.Context, chatID string, messageID string) error {
+	chatObjectID, err := primitive.ObjectIDFromHex(chatID)
+	if err != nil {
+		return ErrInvalidChatID
+	}
+	messageObjectID, err := primitive.ObjectIDFromHex(messageID)
+	if err != nil {
+		return ErrInvalidMessageID
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	chat, ok := m.chats[chatObjectID]
+	if !ok {
+		return ErrChatNotFound
+	}
+	if !chat.IsPinned(messageObjectID) {
+		return ErrNotPinned
+	}
+
+	m.unpin(chat, messageObjectID, now())
+	return nil
+}
+
+// unpin убирает сообщение из закреплённых. Вызывается под блокировкой.
+func (m *MemoryStorage) unpin(chat *Chat, messageID primitive.ObjectID, changedAt time.Time) {
+	pins := make([]PinnedMessage, 0, len(chat.PinnedMessages))
+	for _, pin := range chat.PinnedMessages {
+		if pin.MessageID != messageID {
+			pins = append(pins, pin)
+		}
+	}
+	if len(pins) == 0 {
+		pins = nil
+	}
+	chat.PinnedMessages = pins
+	chat.ChangedAt = changedAt
+}
+
+// DeleteMessage удаляет сообщение, только если оно принадлежит пользователю.
+// Чужое сообщение, как и в MongoStorage, считается ненайденным. Вместо сообщения
+// остаётся надгробие, которое не попадает в историю, но видно при синхронизации.
+// Ключ идемпотентности надгробие сохраняет.
+func (m *MemoryStorage) DeleteMessage(ctx context.Context, messageID string, userID int32) error {
+	objID, err := primitive.ObjectIDFromHex(messageID)
+	if err != nil {
+		return ErrInvalidMessageID
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	message, ok := m.messages[objID]
+	if !ok || message.hidden() || message.SenderID != userID {
+		return ErrMessageNotFound
+	}
+
+	replyTo := message.ReplyTo
+	deletedAt := now()
+	message.DeletedAt = &deletedAt
+	message.ChangedAt = deletedAt
+	message.Content = ""
+	message.Reactions, message.Mentions, message.ReplyTo = nil, nil, nil
+	message.Attachment = nil
+	m.chatMessages[message.ChatID] = removeID(m.chatMessages[message.ChatID], objID)
+	delete(m.revisions, objID)
+
+	// Удалённое сообщение открепляется
+	if chat, ok := m.chats[message.ChatID]; ok && chat.IsPinned(objID) {
+		m.unpin(chat, objID, deletedAt)
+	}
+
+	// Удалённый ответ больше не учитывается в ветке
+	if replyTo != nil {
+		threadID := replyTo.ThreadID
+		m.threads[threadID] = removeID(m.threads[threadID], objID)
+		if root, ok := m.messages[threadID]; ok {
+			root.ReplyCount--
+			root.ChangedAt = deletedAt
+		}
+	}
+
+	m.refreshLastMessage(message.ChatID, objID)
+	return nil
+}
+
+// refreshLastMessage пересчитывает превью, если изменённое или удалённое сообщение
+// было последним в чате. Вызывается под блокировкой.
+func (m *MemoryStorage) refreshLastMessage(chatID primitive.ObjectID, messageID primitive.ObjectID) {
+	chat, ok := m.chats[chatID]
+	if !ok || chat.LastMessage == nil || chat.LastMessage.MessageID != messageID {
+		return
+	}
+
+	chat.LastMessage = nil
+	if ids := m.chatMessages[chatID]; len(ids) > 0 {
+		chat.LastMessage = newLastMessage(m.messages[ids[len(ids)-1]])
+	}
+	chat.ChangedAt = now()
+}
+
+// HideMessage удаляет сообщение только для пользователя: остальные участники
+// продолжают его видеть
+func (m *MemoryStorage) HideMessage(ctx context.Context, messageID string, userID int32) error {
+	objID, err := primitive.ObjectIDFromHex(messageID)
+	if err != nil {
+		return ErrInvalidMessageID
+	}
+	if userID == 0 {
+		return ErrInvalidUserID
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	message, ok := m.messages[objID]
+	if !ok || message.hidden() {
+		return ErrMessageNotFound
+	}
+
+	if !containsUser(message.HiddenFor, userID) {
+		message.HiddenFor = append(message.HiddenFor, userID)
+	}
+	message.ChangedAt = now()
+	return nil
+}
+
+func (m *MemoryStorage) GetUserChats(ctx context.Context, userID int32) ([]*Chat, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	// Чаты, где пользователь является участником
+	var chats []*Chat
+	for _, id := range m.chatOrder {
+		chat := m.chats[id]
+		if containsUser(chat.MemberIDs, userID) {
+			chats = append(chats, cloneChat(chat))
+		}
+	}
+
+	// Сначала чаты с самой свежей активностью, как в MongoStorage
+	sort.SliceStable(chats, func(i, j int) bool {
+		if !chats[i].LastActivityAt.Equal(chats[j].LastActivityAt) {
+			return chats[i].LastActivityAt.After(chats[j].LastActivityAt)
+		}
+		return bytes.Compare(chats[i].ID[:], chats[j].ID[:]) > 0
+	})
+	return chats, nil
+}
+
+// CountUnread считает непрочитанные пользователем сообщения и упоминания в переданных чатах.
+// Собственные сообщения пользователя непрочитанными не считаются.
+func (m *MemoryStorage) CountUnread(ctx context.Context, userID int32, chats []*Chat) (map[primitive.ObjectID]UnreadCount, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	counts := make(map[primitive.ObjectID]UnreadCount, len(chats))
+	for _, chat := range chats {
+		if !hasUnread(chat, userID) {
+			continue
+		}
+
+		// Непрочитаны сообщения с номером больше отметки прочтения
+		readUpTo := chat.ReadUpTo(userID)
+		var count UnreadCount
+		for _, id := range m.chatMessages[chat.ID] {
+			message := m.messages[id]
+			if message.Seq <= readUpTo || message.SenderID == userID {
+				continue
+			}
+			count.Messages++
+			if containsUser(message.Mentions, userID) {
+				count.Mentions++
+			}
+		}
+		if count.Messages > 0 {
+			counts[chat.ID] = count
+		}
+	}
+	return counts, nil
+}
+
+func (m *MemoryStorage) GetMessages(ctx context.Context, chatID string) ([]*Message, error) {
+	chatObjectID, err := primitive.ObjectIDFromHex(chatID)
+	if err != nil {
+		return nil, ErrInvalidChatID
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var messages []*Message
+	for _, id := range m.chatMessages[chatObjectID] {
+		messages = append(messages, cloneMessage(m.messages[id]))
+	}
+	return messages, nil
+}
+
+// GetMessagesPage возвращает страницу истории чата от новых сообщений к старым.
+// ID сообщений возрастают в порядке создания, поэтому курсоры сравниваются по ID, как в MongoStorage.
+func (m *MemoryStorage) GetMessagesPage(ctx context.Context, chatID string, query MessagePageQuery) (*MessagePage, error) {
+	chatObjectID, err := primitive.ObjectIDFromHex(chatID)
+	if err != nil {
+		return nil, ErrInvalidChatID
+	}
+
+	before, after, err := query.bounds()
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.messagesPage(m.chatMessages[chatObjectID], before, after, query), nil
+}
+
+// GetThreadMessages возвращает страницу ответов в ветке корневого сообщения
+// от новых к старым
+func (m *MemoryStorage) GetThreadMessages(ctx context.Context, rootID string, query MessagePageQuery) (*MessagePage, error) {
+	rootObjectID, err := primitive.ObjectIDFromHex(rootID)
+	if err != nil {
+		return nil, ErrInvalidMessageID
+	}
+
+	before, after, err := query.bounds()
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.messagesPage(m.threads[rootObjectID], before, after, query), nil
+}
+
+// messagesPage выбирает страницу из списка ID сообщений, упорядоченного по возрастанию.
+// Вызывается под блокировкой.
+func (m *MemoryStorage) messagesPage(ids []primitive.ObjectID, before primitive.ObjectID, after primitive.ObjectID, query MessagePageQuery) *MessagePage {
+	// Сообщения, удалённые зрителем для себя, исключаются до выбора диапазона
+	if query.ViewerID != 0 {
+		visible := make([]primitive.ObjectID, 0, len(ids))
+		for _, id := range ids {
+			if !m.messages[id].IsHiddenFor(query.ViewerID) {
+				visible = append(visible, id)
+			}
+		}
+		ids = visible
+	}
+
+	// Индексы первого и последнего (не включительно) сообщений в диапазоне курсоров.
+	// Номера сообщений возрастают в том же порядке, что и ID.
+	lo, hi := 0, len(ids)
+	switch {
+	case query.bySeq():
+		if query.AfterSeq > 0 {
+			lo = sort.Search(len(ids), func(i int) bool { return m.messages[ids[i]].Seq > query.AfterSeq })
+		}
+		if query.BeforeSeq > 0 {
+			hi = sort.Search(len(ids), func(i int) bool { return m.messages[ids[i]].Seq >= query.BeforeSeq })
+		}
+	default:
+		if !after.IsZero() {
+			lo = sort.Search(len(ids), func(i int) bool { return bytes.Compare(ids[i][:], after[:]) > 0 })
+		}
+		if !before.IsZero() {
+			hi = sort.Search(len(ids), func(i int) bool { return bytes.Compare(ids[i][:], before[:]) >= 0 })
+		}
+	}
+	if lo > hi {
+		lo = hi
+	}
+
+	// От курсора after берём ближайшие к нему сообщения, иначе - самые новые
+	limit := int(query.limit())
+	if hi-lo > limit {
+		if query.ascending() {
+			hi = lo + limit
+		} else {
+			lo = hi - limit
+		}
+	}
+
+	messages := make([]*Message, 0, hi-lo)
+	for i := hi - 1; i >= lo; i-- {
+		messages = append(messages, cloneMessage(m.messages[ids[i]]))
+	}
+	return newMessagePage(messages, lo > 0, hi < len(ids), query.bySeq())
+}
+
+func (m *MemoryStorage) GetChatParticipants(ctx context.Context, chatID string) ([]int32, error) {
+	objID, err := primitive.ObjectIDFromHex(chatID)
+	if err != nil {
+		return nil, ErrInvalidChatID
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	chat, ok := m.chats[objID]
+	if !ok {
+		return nil, ErrChatNotFound
+	}
+	return append([]int32{}, chat.MemberIDs...), nil
+}
+
+func (m *MemoryStorage) GetChatParticipantsPage(ctx context.Context, chatID string, query ParticipantPageQuery) (*ParticipantPage, error) {
+	objID, err := primitive.ObjectIDFromHex(chatID)
+	if err != nil {
+		return nil, ErrInvalidChatID
+	}
+
+	offset, err := query.offset()
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	chat, ok := m.chats[objID]
+	if !ok {
+		return nil, ErrChatNotFound
+	}
+
+	total := int64(len(chat.MemberIDs))
+	lo, hi := offset, offset+query.limit()
+	if lo > total {
+		lo = total
+	}
+	if hi > total {
+		hi = total
+	}
+	return newParticipantPage(append([]int32{}, chat.MemberIDs[lo:hi]...), offset, total), nil
+}
+
+func (m *MemoryStorage) LeaveChat(ctx context.Context, chatID string, userID int32) error {
+	objID, err := primitive.ObjectIDFromHex(chatID)
+	if err != nil {
+		return ErrInvalidChatID
+	}
+
+	if userID == 0 {
+		return ErrInvalidUserID
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	chat, ok := m.chats[objID]
+	if !ok {
+		return ErrChatNotFound
+	}
+
+	chat.MemberIDs = removeUser(chat.MemberIDs, userID)
+	delete(chat.Roles, markerKey(userID))
+	chat.ChangedAt = now()
+	m.recordRemovals(objID, userID)
+	return nil
+}
+
+func (m *MemoryStorage) SetMemberRole(ctx context.Context, chatID string, userID int32, role string) error {
+	objID, err := primitive.ObjectIDFromHex(chatID)
+	if err != nil {
+		return ErrInvalidChatID
+	}
+
+	if userID == 0 {
+		return ErrInvalidUserID
+	}
+
+	if role != RoleAdmin && role != RoleMember {
+		return ErrInvalidRole
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	chat, ok := m.chats[objID]
+	if !ok {
+		return ErrChatNotFound
+	}
+	if !containsUser(chat.MemberIDs, userID) {
+		return ErrNotMember
+	}
+
+	key := markerKey(userID)
+	chat.ChangedAt = now()
+	if role == RoleMember {
+		delete(chat.Roles, key)
+		return nil
+	}
+	if chat.Roles == nil {
+		chat.Roles = make(map[string]string)
+	}
+	chat.Roles[key] = role
+	return nil
+}
+
+func (m *MemoryStorage) TransferOwnership(ctx context.Context, chatID string, fromID int32, toID int32) error {
+	objID, err := primitive.ObjectIDFromHex(chatID)
+	if err != nil {
+		return ErrInvalidChatID
+	}
+
+	if fromID == 0 || toID == 0 {
+		return ErrInvalidUserID
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	chat, ok := m.chats[objID]
+	if !ok {
+		return ErrChatNotFound
+	}
+	if !containsUser(chat.MemberIDs, fromID) || !containsUser(chat.MemberIDs, toID) {
+		return ErrNotMember
+	}
+
+	if chat.Roles == nil {
+		chat.Roles = make(map[string]string)
+	}
+	chat.Roles[markerKey(fromID)] = RoleAdmin
+	chat.Roles[markerKey(toID)] = RoleOwner
+	chat.ChangedAt = now()
+	return nil
+}
+
+func (m *MemoryStorage) AddReaction(ctx context.Context, messageID string, reaction string, userID int32) error {
+	objID, err := primitive.ObjectIDFromHex(messageID)
+	if err != nil {
+		return ErrInvalidMessageID
+	}
+
+	if reaction == "" {
+		return ErrEmptyReaction
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	message, ok := m.messages[objID]
+	if !ok || message.hidden() {
+		return ErrMessageNotFound
+	}
+
+	for _, r := range message.Reactions {
+		if r.Reaction == reaction && r.UserID == userID {
+			return ErrReactionExists
+		}
+	}
+
+	message.Reactions = append(message.Reactions, Reaction{Reaction: reaction, UserID: userID})
+	message.ChangedAt = now()
+	return nil
+}
+
+func (m *MemoryStorage) RemoveReaction(ctx context.Context, messageID string, reaction string, userID int32) error {
+	objID, err := primitive.ObjectIDFromHex(messageID)
+	if err != nil {
+		return ErrInvalidMessageID
+	}
+
+	if reaction == "" {
+		return ErrEmptyReaction
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	message, ok := m.messages[objID]
+	if !ok || message.hidden() {
+		return ErrMessageNotFound
+	}
+
+	reactions := message.Reactions[:0:0]
+	for _, r := range message.Reactions {
+		if r.Reaction != reaction || r.UserID != userID {
+			reactions = append(reactions, r)
+		}
+	}
+	message.Reactions = reactions
+	message.ChangedAt = now()
+	return nil
+}
+
+func (m *MemoryStorage) MarkDelivered(ctx context.Context, chatID string, userID int32, messageID string) error {
+	return m.advanceMarkers(chatID, userID, messageID, false)
+}
+
+func (m *MemoryStorage) MarkRead(ctx context.Context, chatID string, userID int32, messageID string) error {
+	return m.advanceMarkers(chatID, userID, messageID, true)
+}
+
+func (m *MemoryStorage) MarkViewed(ctx context.Context, chatID string, userID int32, messageID string) error {
+	chatObjID, msgObjID, err := markerIDs(chatID, userID, messageID)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	chat, seq, err := m.markedMessage(chatObjID, msgObjID)
+	if err != nil || seq == 0 {
+		return err
+	}
+
+	previous := chat.ReadUpTo(userID)
+	if previous >= seq {
+		return nil
+	}
+
+	key := markerKey(userID)
+	chat.DeliveredMarkers = advanceMarker(chat.DeliveredMarkers, key, seq)
+	chat.ReadMarkers = advanceMarker(chat.ReadMarkers, key, seq)
+	chat.ChangedAt = now()
+
+	for _, id := range m.chatMessages[chatObjID] {
+		message := m.messages[id]
+		if message.SenderID != userID && message.Seq > previous && message.Seq <= seq {
+			message.Views++
+		}
+	}
+	return nil
+}
+
+// advanceMarkers сдвигает отметку доставки (и прочтения, если read) вперёд до номера сообщения messageID
+func (m *MemoryStorage) advanceMarkers(chatID string, userID int32, messageID string, read bool) error {
+	chatObjID, msgObjID, err := markerIDs(chatID, userID, messageID)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	chat, seq, err := m.markedMessage(chatObjID, msgObjID)
+	if err != nil || seq == 0 {
+		return err
+	}
+
+	key := markerKey(userID)
+	chat.DeliveredMarkers = advanceMarker(chat.DeliveredMarkers, key, seq)
+	if read {
+		chat.ReadMarkers = advanceMarker(chat.ReadMarkers, key, seq)
+	}
+	chat.ChangedAt = now()
+	return nil
+}
+
+// markedMessage возвращает чат и номер отмечаемого в нём сообщения
+func (m *MemoryStorage) markedMessage(chatObjID primitive.ObjectID, msgObjID primitive.ObjectID) (*Chat, int64, error) {
+	chat, ok := m.chats[chatObjID]
+	if !ok {
+		return nil, 0, ErrChatNotFound
+	}
+
+	message, ok := m.messages[msgObjID]
+	if !ok || message.ChatID != chatObjID {
+		return nil, 0, ErrMessageNotFound
+	}
+	return chat, message.Seq, nil
+}
+
+func (m *MemoryStorage) GetChatByID(ctx context.Context, chatID string) (*Chat, error) {
+	objID, err := primitive.ObjectIDFromHex(chatID)
+	if err != nil {
+		return nil, ErrInvalidChatID
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	chat, ok := m.chats[objID]
+	if !ok {
+		return nil, ErrChatNotFound
+	}
+	return cloneChat(chat), nil
+}
+
+func (m *MemoryStorage) GetMessageByID(ctx context.Context, messageID string) (*Message, error) {
+	objID, err := primitive.ObjectIDFromHex(messageID)
+	if err != nil {
+		return nil, ErrInvalidMessageID
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	message, ok := m.messages[objID]
+	if !ok || message.hidden() {
+		return nil, ErrMessageNotFound
+	}
+	return cloneMessage(message), nil
+}
+
+func (m *MemoryStorage) GetMessageByClientID(ctx context.Context, senderID int32, clientMessageID string) (*Message, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	message := m.messageByClientID(senderID, clientMessageID)
+	if message == nil {
+		return nil, ErrMessageNotFound
+	}
+	return cloneMessage(message), nil
+}
+
+func (m *MemoryStorage) GetMessageByAttachmentKey(ctx context.Context, key string) (*Message, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, message := range m.messages {
+		if key != "" && message.Attachment != nil && message.Attachment.Key == key && !message.hidden() {
+			return cloneMessage(message), nil
+		}
+	}
+	return nil, ErrMessageNotFound
+}
+
+// messageByClientID ищет сообщение отправителя по ключу идемпотентности. Вызывается под блокировкой.
+func (m *MemoryStorage) messageByClientID(senderID int32, clientMessageID string) *Message {
+	if clientMessageID == "" {
+		return nil
+	}
+	for _, message := range m.messages {
+		if message.SenderID == senderID && message.ClientMessageID == clientMessageID {
+			return message
+		}
+	}
+	return nil
+}
+
+// DeleteChat переносит чат в корзину. Сообщения чата остаются в хранилище, но не
+// выдаются при чтении, пока чат не восстановлен или не очищен. Приглашения
+// и заявки на вступление, как и в MongoStorage, удаляются сразу.
+func (m *MemoryStorage) DeleteChat(ctx context.Context, chatID string) error {
+	objID, err := primitive.ObjectIDFromHex(chatID)
+	if err != nil {
+		return ErrInvalidChatID
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	chat, ok := m.chats[objID]
+	if !ok {
+		return ErrChatNotFound
+	}
+
+	deletedAt := now()
+	trashed := &deletedChat{
+		chat:     chat,
+		messages: m.chatMessages[objID],
+		threads:  make(map[primitive.ObjectID][]primitive.ObjectID),
+	}
+	for id, message := range m.messages {
+		if message.ChatID != objID {
+			continue
+		}
+		message.ChatDeletedAt = &deletedAt
+		if replies, ok := m.threads[id]; ok {
+			trashed.threads[id] = replies
+			delete(m.threads, id)
+		}
+	}
+	delete(m.chatMessages, objID)
+	for token, invite := range m.invites {
+		if invite.ChatID == objID {
+			delete(m.invites, token)
+		}
+	}
+	for id, request := range m.joinRequests {
+		if request.ChatID == objID {
+			delete(m.joinRequests, id)
+		}
+	}
+
+	chat.DeletedAt = &deletedAt
+	chat.ChangedAt = deletedAt
+	m.trash[objID] = trashed
+
+	m.recordRemovals(objID, chat.MemberIDs...)
+	delete(m.chats, objID)
+	for i, id := range m.chatOrder {
+		if id == objID {
+			m.chatOrder = append(m.chatOrder[:i:i], m.chatOrder[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+// RestoreChat возвращает чат из корзины вместе с его сообщениями
+func (m *MemoryStorage) RestoreChat(ctx context.Context, chatID string) error {
+	objID, err := primitive.ObjectIDFromHex(chatID)
+	if err != nil {
+		return ErrInvalidChatID
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	trashed, ok := m.trash[objID]
+	if !ok {
+		return ErrChatNotFound
+	}
+	delete(m.trash, objID)
+
+	chat := trashed.chat
+	chat.DeletedAt = nil
+	chat.ChangedAt = now()
+	m.chats[objID] = chat
+	m.chatOrder = append(m.chatOrder, objID)
+
+	if trashed.messages != nil {
+		m.chatMessages[objID] = trashed.messages
+	}
+	for rootID, replies := range trashed.threads {
+		m.threads[rootID] = replies
+	}
+	for _, message := range m.messages {
+		if message.ChatID == objID {
+			message.ChatDeletedAt = nil
+		}
+	}
+	return nil
+}
+
+// GetDeletedChat возвращает чат из корзины
+func (m *MemoryStorage) GetDeletedChat(ctx context.Context, chatID string) (*Chat, error) {
+	objID, err := primitive.ObjectIDFromHex(chatID)
+	if err != nil {
+		return nil, ErrInvalidChatID
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	trashed, ok := m.trash[objID]
+	if !ok {
+		return nil, ErrChatNotFound
+	}
+	return cloneChat(trashed.chat), nil
+}
+
+// GetDeletedChats возвращает чаты пользователя из корзины, недавно удалённые - первыми
+func (m *MemoryStorage) GetDeletedChats(ctx context.Context, userID int32) ([]*Chat, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	chats := []*Chat{}
+	for _, trashed := range m.trash {
+		if containsUser(trashed.chat.MemberIDs, userID) {
+			chats = append(chats, cloneChat(trashed.chat))
+		}
+	}
+	sort.Slice(chats, func(i, j int) bool {
+		if !chats[i].DeletedAt.Equal(*chats[j].DeletedAt) {
+			return chats[i].DeletedAt.After(*chats[j].DeletedAt)
+		}
+		return bytes.Compare(chats[i].ID[:], chats[j].ID[:]) > 0
+	})
+	return chats, nil
+}
+
+// PurgeDeleted окончательно удаляет то, что удалено раньше момента before: чаты
+// из корзины вместе с сообщениями, надгробия сообщений и записи о выходе из чатов
+func (m *MemoryStorage) PurgeDeleted(ctx context.Context, before time.Time) (*Purged, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	purged := &Purged{}
+	purgedChats := make(map[primitive.ObjectID]bool)
+	for id, trashed := range m.trash {
+		if expired(trashed.chat.DeletedAt, before) {
+			purgedChats[id] = true
+			purged.Chats = append(purged.Chats, cloneChat(trashed.chat))
+			delete(m.trash, id)
+		}
+	}
+
+	for id, message := range m.messages {
+		if purgedChats[message.ChatID] || expired(message.DeletedAt, before) {
+			if message.Attachment != nil {
+				attachment := *message.Attachment
+				purged.Attachments = append(purged.Attachments, &attachment)
+			}
+			delete(m.messages, id)
+			delete(m.revisions, id)
+		}
+	}
+	removals := m.removals[:0]
+	for _, removal := range m.removals {
+		if !removal.RemovedAt.Before(before) {
+			removals = append(removals, removal)
+		}
+	}
+	m.removals = removals
+	return purged, nil
+}
+
+// recordRemovals записывает выход пользователей из чата. Вызывается под блокировкой.
+func (m *MemoryStorage) recordRemovals(chatID primitive.ObjectID, userIDs ...int32) {
+	removedAt := now()
+	for _, userID := range userIDs {
+		m.removals = append(m.removals, &ChatRemoval{
+			ID:        primitive.NewObjectID(),
+			ChatID:    chatID,
+			UserID:    userID,
+			RemovedAt: removedAt,
+		})
+	}
+}
+
+// SearchMessages ищет сообщения чатов query.ChatIDs, содержащие слова запроса.
+// Хранилище в памяти само служит локальным поисковым индексом: слова сообщений
+// приводятся к основе тем же стеммером, что и слова запроса.
+func (m *MemoryStorage) SearchMessages(ctx context.Context, query SearchQuery) (*SearchPage, error) {
+	after, err := query.cursor()
+	if err != nil {
+		return nil, err
+	}
+
+	terms := searchTerms(query.Text)
+	if len(terms) == 0 {
+		return &SearchPage{Results: []*SearchResult{}}, nil
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var results []*SearchResult
+	for _, chatID := range query.ChatIDs {
+		for _, id := range m.chatMessages[chatID] {
+			message := m.messages[id]
+			if !query.matches(message) {
+				continue
+			}
+			if score := searchScore(terms, tokenize(message.Content)); score > 0 {
+				results = append(results, &SearchResult{Message: cloneMessage(message), Score: score})
+			}
+		}
+	}
+
+	page := newSearchPage(results, after, query.limit())
+	for _, result := range page.Results {
+		result.withSnippet(terms)
+	}
+	return page, nil
+}
+
+// GetMessageChanges возвращает порцию сообщений переданных чатов, изменённых после
+// позиции курсора, в порядке изменения. Надгробия удалённых сообщений включаются.
+func (m *MemoryStorage) GetMessageChanges(ctx context.Context, chatIDs []primitive.ObjectID, after ChangeCursor, limit int64) (*ChangePage, error) {
+	chats := make(map[primitive.ObjectID]bool, len(chatIDs))
+	for _, id := range chatIDs {
+		chats[id] = true
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	messages := []*Message{}
+	for _, message := range m.messages {
+		if chats[message.ChatID] && after.precedes(message) {
+			messages = append(messages, cloneMessage(message))
+		}
+	}
+	sort.Slice(messages, func(i, j int) bool {
+		return ChangeCursor{ChangedAt: messages[i].ChangedAt, MessageID: messages[i].ID}.precedes(messages[j])
+	})
+
+	page := &ChangePage{Messages: messages}
+	if limit = changesLimit(limit); int64(len(messages)) > limit {
+		page.Messages, page.HasMore = messages[:limit], true
+	}
+	return page, nil
+}
+
+// GetChatRemovals возвращает выходы пользователя из чатов начиная с момента since
+func (m *MemoryStorage) GetChatRemovals(ctx context.Context, userID int32, since time.Time) ([]*ChatRemoval, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	removals := []*ChatRemoval{}
+	for _, removal := range m.removals {
+		if removal.UserID == userID && !removal.RemovedAt.Before(since) {
+			r := *removal
+			removals = append(removals, &r)
+		}
+	}
+	return removals, nil
+}
+
+func (m *MemoryStorage) CreateInvite(ctx context.Context, invite *Invite) error {
+	if invite.Token == "" {
+		return ErrInviteNotFound
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.invites[invite.Token] = cloneInvite(invite)
+	return nil
+}
+
+func (m *MemoryStorage) GetInvite(ctx context.Context, token string) (*Invite, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	invite, ok := m.invites[token]
+	if !ok {
+		return nil, ErrInviteNotFound
+	}
+	return cloneInvite(invite), nil
+}
+
+func (m *MemoryStorage) GetChatInvites(ctx context.Context, chatID string) ([]*Invite, error) {
+	chatObjectID, err := primitive.ObjectIDFromHex(chatID)
+	if err != nil {
+		return nil, ErrInvalidChatID
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	invites := []*Invite{}
+	for _, invite := range m.invites {
+		if invite.ChatID == chatObjectID && !invite.Revoked {
+			invites = append(invites, cloneInvite(invite))
+		}
+	}
+
+	// От новых к старым, как в MongoStorage
+	sort.Slice(invites, func(i, j int) bool { return invites[i].CreatedAt.After(invites[j].CreatedAt) })
+	return invites, nil
+}
+
+func (m *MemoryStorage) RevokeInvite(ctx context.Context, token string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	invite, ok := m.invites[token]
+	if !ok || invite.Revoked {
+		return ErrInviteNotFound
+	}
+
+	invite.Revoked = true
+	return nil
+}
+
+func (m *MemoryStorage) RedeemInvite(ctx context.Context, token string) (*Invite, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	invite, ok := m.invites[token]
+	if !ok {
+		return nil, ErrInviteNotFound
+	}
+	if err := invite.redeemError(time.Now()); err != nil {
+		return nil, err
+	}
+
+	invite.Uses++
+	return cloneInvite(invite), nil
+}
+
+func (m *MemoryStorage) ReleaseInvite(ctx context.Context, token string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	invite, ok := m.invites[token]
+	if !ok || invite.Uses == 0 {
+		return ErrInviteNotFound
+	}
+
+	invite.Uses--
+	return nil
+}
+
+func (m *MemoryStorage) SetApprovalRequired(ctx context.Context, chatID string, required bool) error {
+	objID, err := primitive.ObjectIDFromHex(chatID)
+	if err != nil {
+		return ErrInvalidChatID
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	chat, ok := m.chats[objID]
+	if !ok {
+		return ErrChatNotFound
+	}
+
+	chat.ApprovalRequired = required
+	chat.ChangedAt = now()
+	return nil
+}
+
+func (m *MemoryStorage) CreateJoinRequest(ctx context.Context, chatID string, userID int32, message string) (string, error) {
+	chatObjectID, err := primitive.ObjectIDFromHex(chatID)
+	if err != nil {
+		return "", ErrInvalidChatID
+	}
+
+	if userID == 0 {
+		return "", ErrInvalidUserID
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, request := range m.joinRequests {
+		if request.ChatID == chatObjectID && request.UserID == userID && request.Status == JoinRequestPending {
+			return "", ErrJoinRequestExists
+		}
+	}
+
+	request := &JoinRequest{
+		ID:        primitive.NewObjectID(),
+		ChatID:    chatObjectID,
+		UserID:    userID,
+		Message:   message,
+		Status:    JoinRequestPending,
+		CreatedAt: now(),
+	}
+	m.joinRequests[request.ID] = request
+	return request.ID.Hex(), nil
+}
+
+func (m *MemoryStorage) GetJoinRequest(ctx context.Context, requestID string) (*JoinRequest, error) {
+	objID, err := primitive.ObjectIDFromHex(requestID)
+	if err != nil {
+		return nil, ErrInvalidJoinRequestID
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	request, ok := m.joinRequests[objID]
+	if !ok {
+		return nil, ErrJoinRequestNotFound
+	}
+	return cloneJoinRequest(request), nil
+}
+
+func (m *MemoryStorage) GetJoinRequests(ctx context.Context, chatID string, status string) ([]*JoinRequest, error) {
+	chatObjectID, err := primitive.ObjectIDFromHex(chatID)
+	if err != nil {
+		return nil, ErrInvalidChatID
+	}
+
+	if status != "" && !IsValidJoinRequestStatus(status) {
+		return nil, ErrInvalidJoinRequestStatus
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	requests := []*JoinRequest{}
+	for _, request := range m.joinRequests {
+		if request.ChatID == chatObjectID && (status == "" || request.Status == status) {
+			requests = append(requests, cloneJoinRequest(request))
+		}
+	}
+
+	// В порядке подачи, как в MongoStorage
+	sort.Slice(requests, func(i, j int) bool {
+		return bytes.Compare(requests[i].ID[:], requests[j].ID[:]) < 0
+	})
+	return requests, nil
+}
+
+func (m *MemoryStorage) DecideJoinRequest(ctx context.Context, requestID string, status string, deciderID int32) error {
+	objID, err := primitive.ObjectIDFromHex(requestID)
+	if err != nil {
+		return ErrInvalidJoinRequestID
+	}
+
+	if status != JoinRequestApproved && status != JoinRequestRejected {
+		return ErrInvalidJoinRequestStatus
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	request, ok := m.joinRequests[objID]
+	if !ok {
+		return ErrJoinRequestNotFound
+	}
+	if request.Status != JoinRequestPending {
+		return ErrJoinRequestDecided
+	}
+
+	decidedAt := now()
+	request.Status = status
+	request.DecidedBy = deciderID
+	request.DecidedAt = &decidedAt
+	return nil
+}
+
+func (m *MemoryStorage) ReopenJoinRequest(ctx context.Context, requestID string, deciderID int32) error {
+	objID, err := primitive.ObjectIDFromHex(requestID)
+	if err != nil {
+		return ErrInvalidJoinRequestID
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	request, ok := m.joinRequests[objID]
+	if !ok || request.Status == JoinRequestPending || request.DecidedBy != deciderID {
+		return ErrJoinRequestNotFound
+	}
+	for _, other := range m.joinRequests {
+		if other.ChatID == request.ChatID && other.UserID == request.UserID && other.Status == JoinRequestPending {
+			return ErrJoinRequestExists
+		}
+	}
+
+	request.Status = JoinRequestPending
+	request.DecidedBy = 0
+	request.DecidedAt = nil
+	return nil
+}
+
+// now возвращает текущее время с точностью MongoDB (миллисекунды),
+// чтобы значения из обоих хранилищ совпадали
+func (m *MemoryStorage) CreateUploadSession(ctx context.Context, session *UploadSession) error {
+	if session.ID.IsZero() {
+		return ErrInvalidUploadID
+	}
+	if session.UserID == 0 {
+		return ErrInvalidUserID
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.uploads[session.ID] = cloneUploadSession(session)
+	return nil
+}
+
+func (m *MemoryStorage) GetUploadSession(ctx context.Context, uploadID string) (*UploadSession, error) {
+	objID, err := primitive.ObjectIDFromHex(uploadID)
+	if err != nil {
+		return nil, ErrInvalidUploadID
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	session, ok := m.uploads[objID]
+	if !ok {
+		return nil, ErrUploadNotFound
+	}
+	return cloneUploadSession(session), nil
+}
+
+func (m *MemoryStorage) AppendUploadPart(ctx context.Context, uploadID string, offset int64, part UploadPart, expiresAt time.Time) (*UploadSession, error) {
+	objID, err := primitive.ObjectIDFromHex(uploadID)
+	if err != nil {
+		return nil, ErrInvalidUploadID
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	session, ok := m.uploads[objID]
+	if !ok {
+		return nil, ErrUploadNotFound
+	}
+	if session.Offset != offset {
+		return nil, ErrUploadOffsetMismatch
+	}
+	if offset+part.Size > session.Size {
+		return nil, ErrUploadSizeExceeded
+	}
+
+	session.Parts = append(session.Parts, part)
+	session.Offset += part.Size
+	session.ExpiresAt = expiresAt
+	return cloneUploadSession(session), nil
+}
+
+func (m *MemoryStorage) DeleteUploadSession(ctx context.Context, uploadID string) (*UploadSession, error) {
+	objID, err := primitive.ObjectIDFromHex(uploadID)
+	if err != nil {
+		return nil, ErrInvalidUploadID
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	session, ok := m.uploads[objID]
+	if !ok {
+		return nil, ErrUploadNotFound
+	}
+	delete(m.uploads, objID)
+	return session, nil
+}
+
+func (m *MemoryStorage) PurgeUploadSessions(ctx context.Context, before time.Time) ([]*UploadSession, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	purged := []*UploadSession{}
+	for id, session := range m.uploads {
+		if session.ExpiresAt.Before(before) {
+			purged = append(purged, session)
+			delete(m.uploads, id)
+		}
+	}
+	return purged, nil
+}
+
+func now() time.Time {
+	return time.Now().Truncate(time.Millisecond)
+}
+
+func removeID(ids []primitive.ObjectID, id primitive.ObjectID) []primitive.ObjectID {
+	for i := range ids {
+		if ids[i] == id {
+			return append(ids[:i:i], ids[i+1:]...)
+		}
+	}
+	return ids
+}
+
+func removeUser(userIDs []int32, userID int32) []int32 {
+	out := make([]int32, 0, len(userIDs))
+	for _, id := range userIDs {
+		if id != userID {
+			out = append(out, id)
+		}
+	}
+	return out
+}
+
+// cloneChat копирует чат, чтобы вызывающий код не мог изменить данные хранилища
+func cloneChat(chat *Chat) *Chat {
+	c := *chat
+	c.MemberIDs = append([]int32{}, chat.MemberIDs...)
+	c.MemberCount = len(chat.MemberIDs)
+	c.DeliveredMarkers = cloneMarkers(chat.DeliveredMarkers)
+	c.ReadMarkers = cloneMarkers(chat.ReadMarkers)
+	if chat.Roles != nil {
+		c.Roles = make(map[string]string, len(chat.Roles))
+		for key, role := range chat.Roles {
+			c.Roles[key] = role
+		}
+	}
+	if chat.LastMessage != nil {
+		last := *chat.LastMessage
+		c.LastMessage = &last
+	}
+	if chat.DeletedAt != nil {
+		deletedAt := *chat.DeletedAt
+		c.DeletedAt = &deletedAt
+	}
+	if chat.PinnedMessages != nil {
+		c.PinnedMessages = append([]PinnedMessage{}, chat.PinnedMessages...)
+	}
+	return &c
+}
+
+// advanceMarker ведёт себя как $max: отметка только сдвигается вперёд
+func advanceMarker(markers map[string]int64, key string, seq int64) map[string]int64 {
+	if markers == nil {
+		markers = make(map[string]int64)
+	}
+	if seq > markers[key] {
+		markers[key] = seq
+	}
+	return markers
+}
+
+func cloneMarkers(markers map[string]int64) map[string]int64 {
+	if markers == nil {
+		return nil
+	}
+	c := make(map[string]int64, len(markers))
+	for key, seq := range markers {
+		c[key] = seq
+	}
+	return c
+}
+
+func cloneInvite(invite *Invite) *Invite {
+	i := *invite
+	if invite.ExpiresAt != nil {
+		expiresAt := *invite.ExpiresAt
+		i.ExpiresAt = &expiresAt
+	}
+	return &i
+}
+
+func cloneUploadSession(session *UploadSession) *UploadSession {
+	u := *session
+	u.Parts = append([]UploadPart{}, session.Parts...)
+	return &u
+}
+
+func cloneJoinRequest(request *JoinRequest) *JoinRequest {
+	r := *request
+	if request.DecidedAt != nil {
+		decidedAt := *request.DecidedAt
+		r.DecidedAt = &decidedAt
+	}
+	return &r
+}
+
+// cloneMessage копирует сообщение вместе со списком реакций
+func cloneMessage(message *Message) *Message {
+	msg := *message
+	if message.Reactions != nil {
+		msg.Reactions = append([]Reaction{}, message.Reactions...)
+	}
+	if message.ReplyTo != nil {
+		replyTo := *message.ReplyTo
+		msg.ReplyTo = &replyTo
+	}
+	if message.Mentions != nil {
+		msg.Mentions = append([]int32{}, message.Mentions...)
+	}
+	if message.DeletedAt != nil {
+		deletedAt := *message.DeletedAt
+		msg.DeletedAt = &deletedAt
+	}
+	if message.HiddenFor != nil {
+		msg.HiddenFor = append([]int32{}, message.HiddenFor...)
+	}
+	if message.ChatDeletedAt != nil {
+		chatDeletedAt := *message.ChatDeletedAt
+		msg.ChatDeletedAt = &chatDeletedAt
+	}
+	if message.EditedAt != nil {
+		editedAt := *message.EditedAt
+		msg.EditedAt = &editedAt
+	}
+	if message.Notice != nil {
+		notice := *message.Notice
+		msg.Notice = &notice
+	}
+	if message.Attachment != nil {
+		attachment := *message.Attachment
+		msg.Attachment = &attachment
+	}
+	return &msg
+}
@@ -0,0 +1,14 @@
+package storage_test
+
+import (
+	"testing"
+
+	"chat-service/storage"
+	"chat-service/storage/storagetest"
+)
+
+func TestMemoryStorage(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.Storage {
+		return storage.NewMemoryStorage()
+	})
+}
@@ -0,0 +1,54 @@
+package storage_test
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"chat-service/storage"
+	"chat-service/storage/storagetest"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// TestMongoStorage проверяет MongoStorage на сервере из MONGO_TEST_URI,
+// например mongodb://localhost:27017. Каждый тест работает в отдельной базе,
+// которая удаляется после него. Без переменной окружения тест пропускается.
+func TestMongoStorage(t *testing.T) {
+	uri := os.Getenv("MONGO_TEST_URI")
+	if uri == "" {
+		t.Skip("MONGO_TEST_URI не задан")
+	}
+
+	client, err := mongo.Connect(context.Background(), options.Client().ApplyURI(uri))
+	if err != nil {
+		t.Fatalf("подключение к MongoDB: %v", err)
+	}
+	t.Cleanup(func() { client.Disconnect(context.Background()) })
+
+	storagetest.Run(t, func(t *testing.T) storage.Storage {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		dbName := "chat_service_test_" + primitive.NewObjectID().Hex()
+		s, err := storage.NewMongoStorage(uri, dbName)
+		if err != nil {
+			t.Fatalf("NewMongoStorage: %v", err)
+		}
+		t.Cleanup(func() {
+			ctx := context.Background()
+			if err := client.Database(dbName).Drop(ctx); err != nil {
+				t.Errorf("удаление тестовой базы %s: %v", dbName, err)
+			}
+			s.Close(ctx)
+		})
+
+		if err := s.EnsureIndexes(ctx); err != nil {
+			t.Fatalf("EnsureIndexes: %v", err)
+		}
+		return s
+	})
+}
@@ -0,0 +1,116 @@
+package storage
+
+import (
+	"strconv"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Размер страницы истории по умолчанию и максимальный
+const (
+	DefaultPageLimit int64 = 50
+	MaxPageLimit     int64 = 100
+)
+
+// MessagePageQuery - параметры курсорной пагинации истории чата.
+// Курсором служит ID сообщения: Before выбирает сообщения старше него,
+// After - новее. Без курсоров возвращаются самые новые сообщения.
+//
+// Вместо ID можно указать порядковые номера сообщений в чате: BeforeSeq и AfterSeq
+// ограничивают выборку номерами строго меньше и строго больше заданных. Так клиент
+// догружает пропущенный диапазон, например AfterSeq = 10 и BeforeSeq = 15 вернут
+// сообщения 11-14. Курсоры по ID и по номерам одновременно не допускаются.
+//
+// Если указан ViewerID, сообщения, которые этот пользователь удалил для себя, пропускаются.
+type MessagePageQuery struct {
+	Before    string
+	After     string
+	BeforeSeq int64
+	AfterSeq  int64
+	Limit     int64
+	ViewerID  int32
+}
+
+// MessagePage - страница истории, сообщения упорядочены от новых к старым.
+// NextCursor передаётся как Before для получения более старых сообщений,
+// PrevCursor - как After для более новых. Пустой курсор означает, что
+// в этом направлении сообщений больше нет. Если страница выбрана по номерам,
+// курсоры - тоже номера сообщений, и их передают как BeforeSeq и AfterSeq.
+type MessagePage struct {
+	Messages   []*Message
+	NextCursor string
+	PrevCursor string
+}
+
+// bounds разбирает курсоры запроса. Пустой курсор возвращается как нулевой ObjectID.
+// Для курсоров по номерам проверяется только их корректность.
+func (q MessagePageQuery) bounds() (before primitive.ObjectID, after primitive.ObjectID, err error) {
+	if q.BeforeSeq < 0 || q.AfterSeq < 0 {
+		return before, after, ErrInvalidCursor
+	}
+	if q.bySeq() && (q.Before != "" || q.After != "") {
+		return before, after, ErrInvalidCursor
+	}
+	if q.Before != "" {
+		if before, err = primitive.ObjectIDFromHex(q.Before); err != nil {
+			return before, after, ErrInvalidCursor
+		}
+	}
+	if q.After != "" {
+		if after, err = primitive.ObjectIDFromHex(q.After); err != nil {
+			return before, after, ErrInvalidCursor
+		}
+	}
+	return before, after, nil
+}
+
+// limit возвращает размер страницы с учётом значения по умолчанию и ограничения
+func (q MessagePageQuery) limit() int64 {
+	switch {
+	case q.Limit <= 0:
+		return DefaultPageLimit
+	case q.Limit > MaxPageLimit:
+		return MaxPageLimit
+	}
+	return q.Limit
+}
+
+// ascending сообщает, что страницу нужно выбирать от курсора After вверх,
+// чтобы получить ближайшие к нему сообщения
+func (q MessagePageQuery) ascending() bool {
+	return q.After != "" || q.AfterSeq > 0
+}
+
+// bySeq сообщает, что курсоры заданы порядковыми номерами сообщений
+func (q MessagePageQuery) bySeq() bool {
+	return q.BeforeSeq > 0 || q.AfterSeq > 0
+}
+
+// newMessagePage собирает страницу из сообщений, упорядоченных от новых к старым.
+// Курсоры страницы - номера сообщений, если bySeq, иначе ID.
+func newMessagePage(messages []*Message, hasOlder bool, hasNewer bool, bySeq bool) *MessagePage {
+	page := &MessagePage{Messages: messages}
+	if len(messages) == 0 {
+		return page
+	}
+
+	cursor := func(message *Message) string {
+		if bySeq {
+			return strconv.FormatInt(message.Seq, 10)
+		}
+		return message.ID.Hex()
+	}
+	if hasOlder {
+		page.NextCursor = cursor(messages[len(messages)-1])
+	}
+	if hasNewer {
+		page.PrevCursor = cursor(messages[0])
+	}
+	return page
+}
+
+func reverseMessages(messages []*Message) {
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
+	}
+}
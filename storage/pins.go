@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Закреплённые сообщения хранятся в документе чата в порядке закрепления,
+// поэтому приходят вместе с чатом из GetChatByID. Удалённое для всех сообщение
+// открепляется автоматически.
+
+// Максимальное число закреплённых сообщений в чате
+const MaxPinnedMessages = 50
+
+// lastPinSlot - поле документа чата с последним допустимым закреплением:
+// если оно занято, лимит исчерпан
+var lastPinSlot = "pinned_messages." + strconv.Itoa(MaxPinnedMessages-1)
+
+// Тип системного сообщения: уведомление о действии участника в чате.
+// Такие сообщения сохраняет сам сервис, отправитель - участник, выполнивший действие.
+const MessageTypeSystem = "system"
+
+// Действия, о которых сообщают системные уведомления
+const (
+	NoticeMessagePinned       = "message.pinned"
+	NoticeMessageUnpinned     = "message.unpinned"
+	NoticeJoinRequestApproved = "join_request.approved"
+	NoticeJoinRequestRejected = "join_request.rejected"
+)
+
+// PinnedMessage - закреплённое в чате сообщение
+type PinnedMessage struct {
+	MessageID primitive.ObjectID `bson:"message_id"`
+	PinnedBy  int32              `bson:"pinned_by"`
+	PinnedAt  time.Time          `bson:"pinned_at"`
+}
+
+// Notice - содержание системного уведомления: действие и сообщение или пользователь,
+// к которому оно относится
+type Notice struct {
+	Action    string             `bson:"action"`
+	MessageID primitive.ObjectID `bson:"message_id,omitempty"`
+	UserID    int32              `bson:"user_id,omitempty"` // Заявитель в уведомлениях о заявках
+}
+
+// IsPinned сообщает, закреплено ли сообщение в чате
+func (c *Chat) IsPinned(messageID primitive.ObjectID) bool {
+	for _, pin := range c.PinnedMessages {
+		if pin.MessageID == messageID {
+			return true
+		}
+	}
+	return false
+}
+
+// pinError определяет, почему сообщение не удалось закрепить в существующем чате
+func (c *Chat) pinError(messageID primitive.ObjectID) error {
+	if c.IsPinned(messageID) {
+		return ErrAlreadyPinned
+	}
+	return ErrPinLimitReached
+}
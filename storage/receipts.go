@@ -0,0 +1,54 @@
+package storage
+
+import (
+	"strconv"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Статусы доставки сообщения
+const (
+	StatusSent      = "sent"      // сохранено, но доставлено не всем получателям
+	StatusDelivered = "delivered" // доставлено всем получателям
+	StatusRead      = "read"      // прочитано всеми получателями
+)
+
+// Отметки доставки и прочтения хранятся в документе чата: для каждого участника
+// запоминается порядковый номер (Seq) последнего доставленного и последнего
+// прочитанного сообщения. Номера выдаются атомарно и не зависят от реплики,
+// создавшей сообщение, поэтому одна отметка покрывает все более ранние сообщения
+// чата, а её сдвиг - одна атомарная операция $max. Сообщения без номера
+// отметки не сдвигают.
+
+// markerKey возвращает ключ отметки пользователя. Ключи документов MongoDB - строки.
+func markerKey(userID int32) string {
+	return strconv.FormatInt(int64(userID), 10)
+}
+
+// ReadUpTo возвращает номер последнего сообщения чата, прочитанного пользователем
+func (c *Chat) ReadUpTo(userID int32) int64 {
+	return c.ReadMarkers[markerKey(userID)]
+}
+
+// DeliveredUpTo возвращает номер последнего сообщения чата, доставленного пользователю
+func (c *Chat) DeliveredUpTo(userID int32) int64 {
+	return c.DeliveredMarkers[markerKey(userID)]
+}
+
+// markerIDs разбирает и проверяет аргументы сдвига отметки
+func markerIDs(chatID string, userID int32, messageID string) (primitive.ObjectID, primitive.ObjectID, error) {
+	chatObjID, err := primitive.ObjectIDFromHex(chatID)
+	if err != nil {
+		return primitive.NilObjectID, primitive.NilObjectID, ErrInvalidChatID
+	}
+
+	msgObjID, err := primitive.ObjectIDFromHex(messageID)
+	if err != nil {
+		return primitive.NilObjectID, primitive.NilObjectID, ErrInvalidMessageID
+	}
+
+	if userID == 0 {
+		return primitive.NilObjectID, primitive.NilObjectID, ErrInvalidUserID
+	}
+	return chatObjID, msgObjID, nil
+}
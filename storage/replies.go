@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Максимальная длина цитаты исходного сообщения в ответе (в символах)
+const replySnippetLength = 100
+
+// NewMessage - данные нового сообщения для SaveMessage
+type NewMessage struct {
+	ChatID   string
+	SenderID int32
+	Content  string
+	Type     string
+
+	// ReplyToID - ID сообщения того же чата, на которое дан ответ (необязательно)
+	ReplyToID string
+	// Mentions - упомянутые в сообщении участники чата (необязательно)
+	Mentions []int32
+	// ClientMessageID - ключ идемпотентности, выданный клиентом (необязательно).
+	// Повторное сохранение с тем же ключом от того же отправителя возвращает
+	// ID исходного сообщения и ErrDuplicateMessage.
+	ClientMessageID string
+	// Notice - содержание системного уведомления (только у сообщений типа MessageTypeSystem)
+	Notice *Notice
+	// Attachment - приложенный файл (только у сообщений типа MessageTypeFile)
+	Attachment *Attachment
+}
+
+// ReplyTo - ссылка на исходное сообщение с цитатой, сохранённой в момент ответа
+type ReplyTo struct {
+	MessageID primitive.ObjectID `bson:"message_id"`
+	ThreadID  primitive.ObjectID `bson:"thread_id"` // корневое сообщение ветки
+	SenderID  int32              `bson:"sender_id"`
+	Type      string             `bson:"type"`
+	Snippet   string             `bson:"snippet"`
+}
+
+// newReplyTo строит ссылку на исходное сообщение. Ответ на ответ попадает
+// в ветку исходного корневого сообщения.
+func newReplyTo(parent *Message) *ReplyTo {
+	threadID := parent.ID
+	if parent.ReplyTo != nil {
+		threadID = parent.ReplyTo.ThreadID
+	}
+
+	return &ReplyTo{
+		MessageID: parent.ID,
+		ThreadID:  threadID,
+		SenderID:  parent.SenderID,
+		Type:      parent.Type,
+		Snippet:   snippet(parent.Content, replySnippetLength),
+	}
+}
+
+// snippet обрезает текст до n символов, добавляя многоточие
+func snippet(text string, n int) string {
+	runes := []rune(text)
+	if len(runes) <= n {
+		return text
+	}
+	return string(runes[:n]) + "…"
+}
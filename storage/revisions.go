@@ -0,0 +1,40 @@
+package storage
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Каждая правка увеличивает Message.Revision на единицу (у неотредактированного
+// сообщения ревизия 0), а прежнее содержимое сохраняется в журнал ревизий.
+// Правка применяется, только если клиент видел текущую ревизию: иначе две
+// одновременные правки с разных устройств молча перезаписали бы друг друга.
+
+// MessageRevision - прежнее содержимое сообщения в журнале правок
+type MessageRevision struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty"`
+	MessageID  primitive.ObjectID `bson:"message_id"`
+	ChatID     primitive.ObjectID `bson:"chat_id"`
+	Revision   int64              `bson:"revision"`
+	Content    string             `bson:"content"`
+	CreatedAt  time.Time          `bson:"created_at"`  // Когда содержимое было записано
+	ReplacedAt time.Time          `bson:"replaced_at"` // Когда его заменила следующая правка
+}
+
+// newRevision возвращает запись журнала для содержимого, которое заменяет правка
+func newRevision(message *Message, replacedAt time.Time) *MessageRevision {
+	createdAt := message.CreatedAt
+	if message.EditedAt != nil {
+		createdAt = *message.EditedAt
+	}
+	return &MessageRevision{
+		ID:         primitive.NewObjectID(),
+		MessageID:  message.ID,
+		ChatID:     message.ChatID,
+		Revision:   message.Revision,
+		Content:    message.Content,
+		CreatedAt:  createdAt,
+		ReplacedAt: replacedAt,
+	}
+}
@@ -0,0 +1,61 @@
+package storage
+
+// Роли участников группового чата
+const (
+	RoleOwner  = "owner"  // владелец: единственный, может всё, включая удаление чата и назначение администраторов
+	RoleAdmin  = "admin"  // администратор: изменяет настройки и состав чата
+	RoleMember = "member" // обычный участник
+)
+
+// Роли хранятся в документе чата: ID пользователя -> роль. Обычные участники
+// в карте не хранятся, поэтому вступление и выход не требуют её изменения.
+
+// RoleOf возвращает роль участника группового чата. В чатах, созданных до
+// появления ролей, владельцем считается создатель, если он ещё в чате.
+// Для пользователей не из чата возвращается пустая строка.
+func (c *Chat) RoleOf(userID int32) string {
+	if !containsUser(c.MemberIDs, userID) {
+		return ""
+	}
+	if role, ok := c.Roles[markerKey(userID)]; ok {
+		return role
+	}
+	if c.IsGroup && c.CreatorID == userID && !c.hasOwnerRole() {
+		return RoleOwner
+	}
+	return RoleMember
+}
+
+// hasOwnerRole сообщает, назначен ли владелец явно
+func (c *Chat) hasOwnerRole() bool {
+	for _, role := range c.Roles {
+		if role == RoleOwner {
+			return true
+		}
+	}
+	return false
+}
+
+// OwnerID возвращает владельца группового чата или 0, если владельца нет
+func (c *Chat) OwnerID() int32 {
+	for _, memberID := range c.MemberIDs {
+		if c.RoleOf(memberID) == RoleOwner {
+			return memberID
+		}
+	}
+	return 0
+}
+
+// IsValidRole сообщает, является ли строка известной ролью
+func IsValidRole(role string) bool {
+	return role == RoleOwner || role == RoleAdmin || role == RoleMember
+}
+
+func containsUser(userIDs []int32, userID int32) bool {
+	for _, id := range userIDs {
+		if id == userID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,256 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/base64"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Результаты поиска упорядочены по релевантности, при равной релевантности -
+// от новых сообщений к старым. Курсор страницы - непрозрачная строка с
+// релевантностью и ID последнего выданного сообщения.
+
+// Размер страницы результатов поиска по умолчанию и максимальный
+const (
+	DefaultSearchLimit int64 = 20
+	MaxSearchLimit     int64 = 100
+)
+
+// Длина фрагмента сообщения вокруг найденных слов (в символах) и число
+// символов контекста перед первым найденным словом
+const (
+	snippetLength  = 160
+	snippetContext = 40
+)
+
+// SearchQuery - параметры поиска сообщений. Text обязателен, остальные
+// фильтры необязательны. Ищется только в чатах ChatIDs.
+type SearchQuery struct {
+	Text     string
+	ChatIDs  []primitive.ObjectID
+	SenderID int32     // 0 - любой отправитель
+	Since    time.Time // нулевое время - без ограничения
+	Until    time.Time // нулевое время - без ограничения
+	Type     string    // пустой тип - все сообщения, кроме системных уведомлений
+
+	// ViewerID - пользователь, для которого ищутся сообщения: удалённые им
+	// для себя сообщения в результаты не попадают
+	ViewerID int32
+
+	Cursor string
+	Limit  int64
+}
+
+// Highlight - найденное слово во фрагменте сообщения: позиции начала и конца (в символах)
+type Highlight struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// SearchResult - найденное сообщение с фрагментом, в котором подсвечены найденные слова
+type SearchResult struct {
+	Message    *Message    `json:"message"`
+	Score      float64     `json:"score"`
+	Snippet    string      `json:"snippet"`
+	Highlights []Highlight `json:"highlights"`
+}
+
+// SearchPage - страница результатов поиска. Пустой NextCursor означает,
+// что результатов больше нет.
+type SearchPage struct {
+	Results    []*SearchResult `json:"results"`
+	NextCursor string          `json:"next_cursor"`
+}
+
+// searchCursor - позиция в результатах поиска
+type searchCursor struct {
+	score     float64
+	messageID primitive.ObjectID
+}
+
+// limit возвращает размер страницы с учётом значения по умолчанию и ограничения
+func (q SearchQuery) limit() int64 {
+	switch {
+	case q.Limit <= 0:
+		return DefaultSearchLimit
+	case q.Limit > MaxSearchLimit:
+		return MaxSearchLimit
+	}
+	return q.Limit
+}
+
+// cursor разбирает курсор запроса. Для первой страницы возвращается nil.
+func (q SearchQuery) cursor() (*searchCursor, error) {
+	if q.Cursor == "" {
+		return nil, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(q.Cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	parts := strings.SplitN(string(raw), ":", 2)
+	if len(parts) != 2 {
+		return nil, ErrInvalidCursor
+	}
+	score, err := strconv.ParseFloat(parts[0], 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	messageID, err := primitive.ObjectIDFromHex(parts[1])
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return &searchCursor{score: score, messageID: messageID}, nil
+}
+
+// encode возвращает курсор, указывающий на результат
+func (c searchCursor) encode() string {
+	raw := strconv.FormatFloat(c.score, 'g', -1, 64) + ":" + c.messageID.Hex()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// after сообщает, что результат идёт в выдаче после позиции курсора
+func (c *searchCursor) after(score float64, messageID primitive.ObjectID) bool {
+	if c == nil {
+		return true
+	}
+	if score != c.score {
+		return score < c.score
+	}
+	return bytes.Compare(messageID[:], c.messageID[:]) < 0
+}
+
+// matches сообщает, подходит ли сообщение под фильтры запроса (кроме текста)
+func (q SearchQuery) matches(message *Message) bool {
+	if message.hidden() || message.IsHiddenFor(q.ViewerID) {
+		return false
+	}
+	if q.SenderID != 0 && message.SenderID != q.SenderID {
+		return false
+	}
+	if !q.Since.IsZero() && message.CreatedAt.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && !message.CreatedAt.Before(q.Until) {
+		return false
+	}
+	if q.Type == "" {
+		return message.Type != MessageTypeSystem
+	}
+	return message.Type == q.Type
+}
+
+// newSearchPage сортирует найденные результаты, оставляет идущие после курсора
+// и собирает из них страницу
+func newSearchPage(results []*SearchResult, after *searchCursor, limit int64) *SearchPage {
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return bytes.Compare(results[i].Message.ID[:], results[j].Message.ID[:]) > 0
+	})
+
+	page := &SearchPage{Results: []*SearchResult{}}
+	for _, result := range results {
+		if !after.after(result.Score, result.Message.ID) {
+			continue
+		}
+		if int64(len(page.Results)) == limit {
+			last := page.Results[len(page.Results)-1]
+			page.NextCursor = searchCursor{score: last.Score, messageID: last.Message.ID}.encode()
+			break
+		}
+		page.Results = append(page.Results, result)
+	}
+	return page
+}
+
+// searchScore оценивает релевантность текста запросу: каждое найденное слово
+// запроса добавляет единицу, а частота найденных слов в тексте различает
+// сообщения с одинаковым набором слов. Возвращает 0, если слов запроса в тексте нет.
+func searchScore(terms []string, tokens []token) float64 {
+	if len(tokens) == 0 {
+		return 0
+	}
+
+	var matched, occurrences int
+	for _, term := range terms {
+		found := false
+		for _, t := range tokens {
+			if t.stem == term {
+				occurrences++
+				found = true
+			}
+		}
+		if found {
+			matched++
+		}
+	}
+	if matched == 0 {
+		return 0
+	}
+	return float64(matched) + float64(occurrences)/float64(len(tokens))
+}
+
+// withSnippet заполняет фрагмент сообщения вокруг первого найденного слова
+// и позиции найденных слов в нём
+func (r *SearchResult) withSnippet(terms []string) *SearchResult {
+	content := []rune(r.Message.Content)
+	tokens := tokenize(r.Message.Content)
+
+	matched := make([]token, 0, len(tokens))
+	for _, t := range tokens {
+		for _, term := range terms {
+			if t.stem == term {
+				matched = append(matched, t)
+				break
+			}
+		}
+	}
+
+	// Фрагмент начинается незадолго до первого найденного слова с начала слова
+	start := 0
+	if len(matched) > 0 && matched[0].start > snippetContext {
+		start = matched[0].start - snippetContext
+		for start < matched[0].start && isWordRune(content[start-1]) {
+			start++
+		}
+	}
+	// и по возможности не обрывает слово в конце
+	end := start + snippetLength
+	if end >= len(content) {
+		end = len(content)
+	} else {
+		cut := end
+		for cut > start && isWordRune(content[cut]) {
+			cut--
+		}
+		if cut > start {
+			end = cut
+		}
+	}
+
+	prefix, suffix := "", ""
+	if start > 0 {
+		prefix = "…"
+	}
+	if end < len(content) {
+		suffix = "…"
+	}
+	r.Snippet = prefix + string(content[start:end]) + suffix
+
+	shift := len([]rune(prefix)) - start
+	r.Highlights = []Highlight{}
+	for _, t := range matched {
+		if t.start >= start && t.end <= end {
+			r.Highlights = append(r.Highlights, Highlight{Start: t.start + shift, End: t.end + shift})
+		}
+	}
+	return r
+}
@@ -0,0 +1,212 @@
+package storage
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Поиск по тексту сообщений работает со словами, приведёнными к основе:
+// «сообщения», «сообщением» и «сообщений» ищутся одинаково. MongoStorage
+// полагается на текстовый индекс с русским языком, MemoryStorage и подсветка
+// найденных слов используют стеммер ниже - упрощённую версию алгоритма
+// Snowball для русского языка. Слова на латинице только приводятся
+// к нижнему регистру.
+
+// token - слово текста и его положение в тексте (в символах)
+type token struct {
+	word       string // слово в нижнем регистре
+	stem       string // основа слова
+	start, end int
+}
+
+// Служебные слова не ищутся: они есть почти в каждом сообщении
+var stopWords = makeSet(
+	"и", "в", "во", "не", "что", "он", "на", "я", "с", "со", "как", "а", "то", "все",
+	"она", "так", "его", "но", "да", "ты", "к", "у", "же", "вы", "за", "бы", "по",
+	"только", "ее", "мне", "было", "вот", "от", "меня", "еще", "нет", "о", "из", "ему",
+	"когда", "ну", "ли", "если", "уже", "или", "ни", "быть", "был", "до", "вас", "там",
+	"они", "тут", "где", "есть", "ней", "для", "мы", "тебя", "их", "чем", "была", "без",
+	"чего", "тоже", "себе", "под", "будет", "ж", "тогда", "кто", "этот", "того", "это",
+	"этого", "этом", "при", "об", "про", "над", "эти", "нас", "них", "эту", "этой",
+	"the", "a", "an", "and", "or", "of", "to", "in", "on", "is", "are", "it", "for",
+)
+
+// tokenize разбивает текст на слова, пропуская служебные
+func tokenize(text string) []token {
+	var tokens []token
+	runes := []rune(text)
+	for start := 0; start < len(runes); {
+		if !isWordRune(runes[start]) {
+			start++
+			continue
+		}
+		end := start
+		for end < len(runes) && isWordRune(runes[end]) {
+			end++
+		}
+
+		word := normalizeWord(runes[start:end])
+		if !stopWords[word] {
+			tokens = append(tokens, token{word: word, stem: stem(word), start: start, end: end})
+		}
+		start = end
+	}
+	return tokens
+}
+
+// searchTerms возвращает различные основы слов поискового запроса
+func searchTerms(text string) []string {
+	var terms []string
+	seen := make(map[string]bool)
+	for _, t := range tokenize(text) {
+		if !seen[t.stem] {
+			seen[t.stem] = true
+			terms = append(terms, t.stem)
+		}
+	}
+	return terms
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// normalizeWord приводит слово к нижнему регистру и заменяет «ё» на «е»
+func normalizeWord(word []rune) string {
+	return strings.ReplaceAll(strings.ToLower(string(word)), "ё", "е")
+}
+
+// stem возвращает основу слова: русские слова проходят через стеммер,
+// остальные остаются как есть
+func stem(word string) string {
+	runes := []rune(word)
+	for _, r := range runes {
+		if unicode.Is(unicode.Cyrillic, r) {
+			return string(stemRussian(runes))
+		}
+	}
+	return word
+}
+
+// Окончания русских слов по группам алгоритма Snowball. Окончания первой
+// группы отбрасываются, только если перед ними стоит «а» или «я».
+var (
+	perfectiveGerund1 = []string{"в", "вши", "вшись"}
+	perfectiveGerund2 = []string{"ив", "ивши", "ившись", "ыв", "ывши", "ывшись"}
+	adjective         = []string{
+		"ее", "ие", "ые", "ое", "ими", "ыми", "ей", "ий", "ый", "ой", "ем", "им", "ым", "ом",
+		"его", "ого", "ему", "ому", "их", "ых", "ую", "юю", "ая", "яя", "ою", "ею",
+	}
+	participle1 = []string{"ем", "нн", "вш", "ющ", "щ"}
+	participle2 = []string{"ивш", "ывш", "ующ"}
+	reflexive   = []string{"ся", "сь"}
+	verb1       = []string{"ла", "на", "ете", "йте", "ли", "й", "л", "ем", "н", "ло", "но", "ет", "ют", "ны", "ть", "ешь", "нно"}
+	verb2       = []string{
+		"ила", "ыла", "ена", "ейте", "уйте", "ите", "или", "ыли", "ей", "уй", "ил", "ыл", "им", "ым", "ен",
+		"ило", "ыло", "ено", "ят", "ует", "уют", "ит", "ыт", "ены", "ить", "ыть", "ишь", "ую", "ю",
+	}
+	noun = []string{
+		"а", "ев", "ов", "ие", "ье", "е", "иями", "ями", "ами", "еи", "ии", "и", "ией", "ей", "ой", "ий", "й",
+		"иям", "ям", "ием", "ем", "ам", "ом", "о", "у", "ах", "иях", "ях", "ы", "ь", "ию", "ью", "ю", "ия", "ья", "я",
+	}
+	superlative   = []string{"ейше", "ейш"}
+	derivational  = []string{"ость", "ост"}
+	russianVowels = "аеиоуыэюя"
+)
+
+// stemRussian отбрасывает у русского слова окончание и словообразовательные суффиксы
+func stemRussian(word []rune) []rune {
+	rv, r2 := russianRegions(word)
+	if rv >= len(word) {
+		return word
+	}
+
+	// Шаг 1: деепричастие, иначе возвратная частица и затем прилагательное, глагол или существительное
+	if w, ok := cutEnding(word, rv, perfectiveGerund1, perfectiveGerund2); ok {
+		word = w
+	} else {
+		word, _ = cutEnding(word, rv, nil, reflexive)
+		if w, ok := cutEnding(word, rv, nil, adjective); ok {
+			word, _ = cutEnding(w, rv, participle1, participle2)
+		} else if w, ok := cutEnding(word, rv, verb1, verb2); ok {
+			word = w
+		} else {
+			word, _ = cutEnding(word, rv, nil, noun)
+		}
+	}
+
+	// Шаг 2: конечная «и»
+	word, _ = cutEnding(word, rv, nil, []string{"и"})
+
+	// Шаг 3: словообразовательный суффикс в области R2
+	word, _ = cutEnding(word, r2, nil, derivational)
+
+	// Шаг 4: превосходная степень, удвоенная «н» и мягкий знак
+	word, _ = cutEnding(word, rv, nil, superlative)
+	if w, ok := cutEnding(word, rv, nil, []string{"нн"}); ok {
+		word = append(w, 'н')
+	} else {
+		word, _ = cutEnding(word, rv, nil, []string{"ь"})
+	}
+	return word
+}
+
+// russianRegions возвращает начало областей RV и R2 слова: RV начинается
+// после первой гласной, R1 - после первой согласной, следующей за гласной,
+// R2 - то же внутри R1
+func russianRegions(word []rune) (rv int, r2 int) {
+	rv, r2 = len(word), len(word)
+	for i, r := range word {
+		if isRussianVowel(r) {
+			rv = i + 1
+			break
+		}
+	}
+	r1 := regionAfterVowelConsonant(word, 0)
+	r2 = regionAfterVowelConsonant(word, r1)
+	return rv, r2
+}
+
+func regionAfterVowelConsonant(word []rune, from int) int {
+	for i := from + 1; i < len(word); i++ {
+		if !isRussianVowel(word[i]) && isRussianVowel(word[i-1]) {
+			return i + 1
+		}
+	}
+	return len(word)
+}
+
+func isRussianVowel(r rune) bool {
+	return strings.ContainsRune(russianVowels, r)
+}
+
+// cutEnding отбрасывает самое длинное из окончаний, целиком лежащее в области,
+// начинающейся с позиции region. Окончания из afterAOrYa отбрасываются, только
+// если перед ними в той же области стоит «а» или «я».
+func cutEnding(word []rune, region int, afterAOrYa []string, endings []string) ([]rune, bool) {
+	best := -1
+	for group, list := range [][]string{afterAOrYa, endings} {
+		for _, ending := range list {
+			start := len(word) - len([]rune(ending))
+			if start < region || (best >= 0 && start >= best) || string(word[start:]) != ending {
+				continue
+			}
+			if group == 0 && (start-1 < region || (word[start-1] != 'а' && word[start-1] != 'я')) {
+				continue
+			}
+			best = start
+		}
+	}
+	if best < 0 {
+		return word, false
+	}
+	return word[:best], true
+}
+
+func makeSet(words ...string) map[string]bool {
+	set := make(map[string]bool, len(words))
+	for _, word := range words {
+		set[word] = true
+	}
+	return set
+}
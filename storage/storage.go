@@ -17,11 +17,24 @@ type MongoStorage struct {
 	client      *mongo.Client
 	chatColl    *mongo.Collection
 	messageColl *mongo.Collection
+	inviteColl  *mongo.Collection
+	joinColl    *mongo.Collection
+	removalColl *mongo.Collection
+	trashColl   *mongo.Collection
+
+	revisionColl *mongo.Collection
+	uploadColl   *mongo.Collection
 }
 
 const (
 	chatsCollection    = "chats"
 	messagesCollection = "messages"
+	invitesCollection  = "invites"
+	joinCollection     = "join_requests"
+	removalsCollection = "chat_removals"
+	trashCollection    = "deleted_chats"
+	revisionCollection = "message_revisions"
+	uploadCollection   = "upload_sessions"
 )
 
 func NewMongoStorage(uri string, dbName string) (*MongoStorage, error) {
@@ -34,6 +47,13 @@ func NewMongoStorage(uri string, dbName string) (*MongoStorage, error) {
 		client:      client,
 		chatColl:    db.Collection(chatsCollection),
 		messageColl: db.Collection(messagesCollection),
+		inviteColl:  db.Collection(invitesCollection),
+		joinColl:    db.Collection(joinCollection),
+		removalColl: db.Collection(removalsCollection),
+		trashColl:   db.Collection(trashCollection),
+
+		revisionColl: db.Collection(revisionCollection),
+		uploadColl:   db.Collection(uploadCollection),
 	}, nil
 }
 
@@ -45,18 +65,199 @@ func (m *MongoStorage) Ping(ctx context.Context) error {
 	return m.client.Ping(ctx, nil)
 }
 
+// EnsureIndexes создаёт индексы, необходимые для запросов хранилища
+func (m *MongoStorage) EnsureIndexes(ctx context.Context) error {
+	// История чата выбирается и сортируется по (chat_id, _id)
+	_, err := m.messageColl.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "chat_id", Value: 1}, {Key: "_id", Value: 1}},
+	})
+	if err != nil {
+		log.Printf("Ошибка создания индекса сообщений: %v", err)
+		return err
+	}
+
+	// Ответы ветки выбираются по корневому сообщению
+	_, err = m.messageColl.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "reply_to.thread_id", Value: 1}, {Key: "_id", Value: 1}},
+		Options: options.Index().SetSparse(true),
+	})
+	if err != nil {
+		log.Printf("Ошибка создания индекса ответов: %v", err)
+		return err
+	}
+
+	// Список чатов пользователя сортируется по последней активности
+	_, err = m.chatColl.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "member_ids", Value: 1}, {Key: "last_activity_at", Value: -1}},
+	})
+	if err != nil {
+		log.Printf("Ошибка создания индекса чатов: %v", err)
+		return err
+	}
+
+	// Приглашения выбираются по чату
+	_, err = m.inviteColl.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "chat_id", Value: 1}},
+	})
+	if err != nil {
+		log.Printf("Ошибка создания индекса приглашений: %v", err)
+		return err
+	}
+
+	// У пользователя может быть только одна нерассмотренная заявка в чат
+	_, err = m.joinColl.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "chat_id", Value: 1}, {Key: "user_id", Value: 1}},
+		Options: options.Index().
+			SetUnique(true).
+			SetPartialFilterExpression(bson.M{"status": JoinRequestPending}),
+	})
+	if err != nil {
+		log.Printf("Ошибка создания индекса заявок на вступление: %v", err)
+		return err
+	}
+
+	// Поиск по тексту сообщений: слова приводятся к основе по правилам русского языка
+	_, err = m.messageColl.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "content", Value: "text"}},
+		Options: options.Index().SetDefaultLanguage("russian").SetLanguageOverride("search_language"),
+	})
+	if err != nil {
+		log.Printf("Ошибка создания текстового индекса сообщений: %v", err)
+		return err
+	}
+
+	// Ключ идемпотентности уникален в пределах отправителя
+	_, err = m.messageColl.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "sender_id", Value: 1}, {Key: "client_message_id", Value: 1}},
+		Options: options.Index().
+			SetUnique(true).
+			SetPartialFilterExpression(bson.M{"client_message_id": bson.M{"$exists": true}}),
+	})
+	if err != nil {
+		log.Printf("Ошибка создания индекса ключей идемпотентности: %v", err)
+		return err
+	}
+
+	// Поиск сообщения по ключу приложенного файла при скачивании
+	_, err = m.messageColl.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "attachment.key", Value: 1}},
+		Options: options.Index().
+			SetPartialFilterExpression(bson.M{"attachment.key": bson.M{"$exists": true}}),
+	})
+	if err != nil {
+		log.Printf("Ошибка создания индекса ключей файлов: %v", err)
+		return err
+	}
+
+	// Номер сообщения уникален в пределах чата, по нему же идёт пагинация
+	_, err = m.messageColl.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "chat_id", Value: 1}, {Key: "seq", Value: 1}},
+		Options: options.Index().
+			SetUnique(true).
+			SetPartialFilterExpression(bson.M{"seq": bson.M{"$exists": true}}),
+	})
+	if err != nil {
+		log.Printf("Ошибка создания индекса номеров сообщений: %v", err)
+		return err
+	}
+
+	// Изменения сообщений для синхронизации выбираются по чату в порядке изменения
+	_, err = m.messageColl.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "chat_id", Value: 1}, {Key: "changed_at", Value: 1}, {Key: "_id", Value: 1}},
+	})
+	if err != nil {
+		log.Printf("Ошибка создания индекса изменений сообщений: %v", err)
+		return err
+	}
+
+	// Выходы из чатов выбираются по пользователю и времени
+	_, err = m.removalColl.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "removed_at", Value: 1}},
+	})
+	if err != nil {
+		log.Printf("Ошибка создания индекса выходов из чатов: %v", err)
+		return err
+	}
+
+	// Корзина удалённых чатов выбирается по участнику, очистка идёт по времени удаления
+	_, err = m.trashColl.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "member_ids", Value: 1}, {Key: "deleted_at", Value: -1}},
+	})
+	if err != nil {
+		log.Printf("Ошибка создания индекса удалённых чатов: %v", err)
+		return err
+	}
+
+	// Надгробия сообщений очищаются по времени удаления
+	_, err = m.messageColl.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "deleted_at", Value: 1}},
+		Options: options.Index().SetSparse(true),
+	})
+	if err != nil {
+		log.Printf("Ошибка создания индекса удалённых сообщений: %v", err)
+		return err
+	}
+
+	// Журнал правок выбирается по сообщению, ревизия сообщения записывается один раз
+	_, err = m.revisionColl.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "message_id", Value: 1}, {Key: "revision", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		log.Printf("Ошибка создания индекса ревизий сообщений: %v", err)
+		return err
+	}
+
+	// Очередь заявок выбирается по чату и состоянию
+	_, err = m.joinColl.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "chat_id", Value: 1}, {Key: "status", Value: 1}, {Key: "_id", Value: 1}},
+	})
+	if err != nil {
+		log.Printf("Ошибка создания индекса заявок на вступление: %v", err)
+		return err
+	}
+
+	// Брошенные сеансы загрузки очищаются по сроку действия
+	_, err = m.uploadColl.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "expires_at", Value: 1}},
+	})
+	if err != nil {
+		log.Printf("Ошибка создания индекса сеансов загрузки: %v", err)
+		return err
+	}
+	return nil
+}
+
 func (m *MongoStorage) CreateChat(ctx context.Context, name string, memberIDs []int32, isGroup bool, description string, creatorID int32) (string, error) {
+    return m.insertChat(ctx, name, memberIDs, isGroup, false, description, creatorID)
+}
+
+// CreateChannel создаёт канал. Создатель становится его владельцем.
+func (m *MongoStorage) CreateChannel(ctx context.Context, name string, memberIDs []int32, description string, creatorID int32) (string, error) {
+    return m.insertChat(ctx, name, memberIDs, true, true, description, creatorID)
+}
+
+func (m *MongoStorage) insertChat(ctx context.Context, name string, memberIDs []int32, isGroup bool, isChannel bool, description string, creatorID int32) (string, error) {
     if creatorID == 0 {
-        return "", errors.New("не указан создатель чата")
+        return "", ErrNoCreator
     }
 
+    createdAt := time.Now()
     chat := bson.M{
-        "name":        name,
-        "member_ids":  memberIDs,
-        "creator_id":  creatorID, // Сохраняем ID создателя чата
-        "is_group":    isGroup,
-        "description": description,
-        "created_at":  time.Now(),
+        "name":             name,
+        "member_ids":       memberIDs,
+        "creator_id":       creatorID, // Сохраняем ID создателя чата
+        "is_group":         isGroup,
+        "description":      description,
+        "created_at":       createdAt,
+        "last_activity_at": createdAt,
+        "changed_at":       createdAt,
+    }
+    if isGroup {
+        chat["roles"] = bson.M{markerKey(creatorID): RoleOwner} // Создатель группы становится владельцем
+    }
+    if isChannel {
+        chat["is_channel"] = true
     }
 
     res, err := m.chatColl.InsertOne(ctx, chat)
@@ -77,12 +278,12 @@ func (m *MongoStorage) UpdateChatInfo(ctx context.Context, chatID string, name s
     // Преобразуем chatID в ObjectID
     objID, err := primitive.ObjectIDFromHex(chatID)
     if err != nil {
-        return errors.New("некорректный chatID")
+        return ErrInvalidChatID
     }
 
     // Проверяем, что передано хотя бы одно поле для обновления
     if name == "" && description == "" {
-        return errors.New("не переданы данные для обновления")
+        return ErrEmptyUpdate
     }
 
     // Создаём объект для обновления
@@ -93,6 +294,7 @@ func (m *MongoStorage) UpdateChatInfo(ctx context.Context, chatID string, name s
     if description != "" {
         update["description"] = description
     }
+    update["changed_at"] = time.Now()
 
     // Обновляем чат (без проверки на is_group)
     res, err := m.chatColl.UpdateOne(ctx, bson.M{"_id": objID}, bson.M{"$set": update})
@@ -103,7 +305,7 @@ func (m *MongoStorage) UpdateChatInfo(ctx context.Context, chatID string, name s
 
     // Проверяем, был ли обновлён хотя бы один документ
     if res.MatchedCount == 0 {
-        return errors.New("чат не найден")
+        return ErrChatNotFound
     }
 
     return nil
@@ -114,16 +316,16 @@ func (m *MongoStorage) SetChatAvatar(ctx context.Context, chatID string, avatar
     objID, err := primitive.ObjectIDFromHex(chatID)
     if err != nil {
         log.Printf("Некорректный идентификатор чата: %v", err)
-        return errors.New("некорректный идентификатор чата")
+        return ErrInvalidChatID
     }
 
     // Проверяем, что avatar не пустой
     if avatar == "" {
-        return errors.New("не указан аватар")
+        return ErrEmptyAvatar
     }
 
     // Обновляем аватар чата
-    update := bson.M{"$set": bson.M{"avatar": avatar}}
+    update := bson.M{"$set": bson.M{"avatar": avatar, "changed_at": time.Now()}}
 
     // Выполняем обновление
     res, err := m.chatColl.UpdateOne(ctx, bson.M{"_id": objID}, update)
@@ -134,7 +336,7 @@ func (m *MongoStorage) SetChatAvatar(ctx context.Context, chatID string, avatar
 
     // Проверяем, был ли обновлен хотя бы один документ
     if res.MatchedCount == 0 {
-        return errors.New("чат не найден")
+        return ErrChatNotFound
     }
 
     return nil
@@ -144,16 +346,16 @@ func (m *MongoStorage) AddParticipant(ctx context.Context, chatID string, userID
     // Преобразуем chatID в ObjectID
     objID, err := primitive.ObjectIDFromHex(chatID)
     if err != nil {
-        return errors.New("некорректный chatID")
+        return ErrInvalidChatID
     }
 
     // Проверяем, что userID не равен 0
     if userID == 0 {
-        return errors.New("некорректный userID")
+        return ErrInvalidUserID
     }
 
     // Обновляем список участников чата
-    update := bson.M{"$addToSet": bson.M{"member_ids": userID}}
+    update := bson.M{"$addToSet": bson.M{"member_ids": userID}, "$set": bson.M{"changed_at": time.Now()}}
 
     // Выполняем обновление (только для групповых чатов)
     res, err := m.chatColl.UpdateOne(ctx, bson.M{"_id": objID, "is_group": true}, update)
@@ -164,7 +366,7 @@ func (m *MongoStorage) AddParticipant(ctx context.Context, chatID string, userID
 
     // Проверяем, был ли обновлён хотя бы один документ
     if res.MatchedCount == 0 {
-        return errors.New("чат не найден или не является групповым")
+        return ErrNotGroupChat
     }
 
     return nil
@@ -174,16 +376,20 @@ func (m *MongoStorage) RemoveParticipant(ctx context.Context, chatID string, use
     // Преобразуем chatID в ObjectID
     objID, err := primitive.ObjectIDFromHex(chatID)
     if err != nil {
-        return errors.New("некорректный chatID")
+        return ErrInvalidChatID
     }
 
     // Проверяем, что userID не равен 0
     if userID == 0 {
-        return errors.New("некорректный userID")
+        return ErrInvalidUserID
     }
 
-    // Удаляем участника из списка участников чата
-    update := bson.M{"$pull": bson.M{"member_ids": userID}}
+    // Удаляем участника из списка участников чата вместе с его ролью
+    update := bson.M{
+        "$pull":  bson.M{"member_ids": userID},
+        "$unset": bson.M{"roles." + markerKey(userID): ""},
+        "$set":   bson.M{"changed_at": time.Now()},
+    }
 
     // Выполняем обновление (только для групповых чатов)
     res, err := m.chatColl.UpdateOne(ctx, bson.M{"_id": objID, "is_group": true}, update)
@@ -194,29 +400,93 @@ func (m *MongoStorage) RemoveParticipant(ctx context.Context, chatID string, use
 
     // Проверяем, был ли обновлён хотя бы один документ
     if res.MatchedCount == 0 {
-        return errors.New("чат не найден или не является групповым")
+        return ErrNotGroupChat
     }
 
+    m.recordRemovals(ctx, objID, userID)
     return nil
 }
 
-func (m *MongoStorage) SaveMessage(ctx context.Context, chatID string, senderID int32, content string, messageType string) (string, error) {
-    chatObjectID, err := primitive.ObjectIDFromHex(chatID)
+// SaveMessage сохраняет сообщение. Если указан ReplyToID, исходное сообщение
+// должно находиться в том же чате: в ответ сохраняется его цитата, а счётчик
+// ответов корневого сообщения ветки увеличивается.
+func (m *MongoStorage) SaveMessage(ctx context.Context, msg NewMessage) (string, error) {
+    chatObjectID, err := primitive.ObjectIDFromHex(msg.ChatID)
     if err != nil {
         log.Printf("Некорректный идентификатор чата: %v", err)
-        return "", errors.New("некорректный идентификатор чата")
+        return "", ErrInvalidChatID
     }
 
+    createdAt := time.Now()
     message := bson.M{
         "chat_id":    chatObjectID,
-        "sender_id":  senderID,
-        "content":    content,
-        "type":       messageType, // Например, "file"
-        "created_at": time.Now(),
+        "sender_id":  msg.SenderID,
+        "content":    msg.Content,
+        "type":       msg.Type, // Например, "file"
+        "created_at": createdAt,
+        "changed_at": createdAt,
+    }
+    if len(msg.Mentions) > 0 {
+        message["mentions"] = msg.Mentions
+    }
+    if msg.ClientMessageID != "" {
+        message["client_message_id"] = msg.ClientMessageID
+    }
+    if msg.Notice != nil {
+        message["notice"] = msg.Notice
+    }
+    if msg.Attachment != nil {
+        message["attachment"] = msg.Attachment
+    }
+
+    var replyTo *ReplyTo
+    if msg.ReplyToID != "" {
+        parentID, err := primitive.ObjectIDFromHex(msg.ReplyToID)
+        if err != nil {
+            return "", ErrInvalidMessageID
+        }
+
+        var parent Message
+        err = m.messageColl.FindOne(ctx, notDeleted(bson.M{"_id": parentID, "chat_id": chatObjectID})).Decode(&parent)
+        if err != nil {
+            if err == mongo.ErrNoDocuments {
+                return "", ErrInvalidReply
+            }
+            log.Printf("Ошибка получения исходного сообщения: %v", err)
+            return "", errors.New("ошибка получения исходного сообщения")
+        }
+
+        replyTo = newReplyTo(&parent)
+        message["reply_to"] = replyTo
+    }
+
+    // Повтор с уже использованным ключом не должен расходовать номер сообщения
+    if msg.ClientMessageID != "" {
+        original, err := m.GetMessageByClientID(ctx, msg.SenderID, msg.ClientMessageID)
+        if err == nil {
+            return original.ID.Hex(), ErrDuplicateMessage
+        }
+        if err != ErrMessageNotFound {
+            return "", err
+        }
     }
 
+    seq, err := m.nextSeq(ctx, chatObjectID)
+    if err != nil {
+        return "", err
+    }
+    message["seq"] = seq
+
     res, err := m.messageColl.InsertOne(ctx, message)
     if err != nil {
+        // Повтор запроса с тем же ключом: возвращаем исходное сообщение
+        if mongo.IsDuplicateKeyError(err) && msg.ClientMessageID != "" {
+            original, err := m.GetMessageByClientID(ctx, msg.SenderID, msg.ClientMessageID)
+            if err != nil {
+                return "", err
+            }
+            return original.ID.Hex(), ErrDuplicateMessage
+        }
         log.Printf("Ошибка сохранения сообщения: %v", err)
         return "", err
     }
@@ -226,29 +496,153 @@ func (m *MongoStorage) SaveMessage(ctx context.Context, chatID string, senderID
         return "", errors.New("не удалось преобразовать InsertedID в ObjectID")
     }
 
+    if replyTo != nil {
+        m.incReplyCount(ctx, replyTo.ThreadID, 1)
+    }
+
+    m.setLastMessage(ctx, chatObjectID, newLastMessage(&Message{
+        ID:        objectID,
+        Seq:       seq,
+        SenderID:  msg.SenderID,
+        Content:   msg.Content,
+        Type:      msg.Type,
+        CreatedAt: createdAt,
+    }))
+
     return objectID.Hex(), nil
 }
 
-func (m *MongoStorage) EditMessage(ctx context.Context, messageID string, userID int32, newContent string) error {
+// nextSeq атомарно выделяет следующий порядковый номер сообщения в чате.
+// Номер, выделенный под несохранённое сообщение, не возвращается, поэтому
+// пропуски в нумерации возможны только при сбоях записи и удалении сообщений.
+func (m *MongoStorage) nextSeq(ctx context.Context, chatID primitive.ObjectID) (int64, error) {
+    var chat struct {
+        LastSeq int64 `bson:"last_seq"`
+    }
+    opts := options.FindOneAndUpdate().
+        SetReturnDocument(options.After).
+        SetProjection(bson.M{"last_seq": 1})
+    err := m.chatColl.FindOneAndUpdate(ctx, bson.M{"_id": chatID}, bson.M{"$inc": bson.M{"last_seq": 1}}, opts).Decode(&chat)
+    if err != nil {
+        if err == mongo.ErrNoDocuments {
+            return 0, ErrChatNotFound
+        }
+        log.Printf("Ошибка выделения номера сообщения в чате %s: %v", chatID.Hex(), err)
+        return 0, errors.New("ошибка выделения номера сообщения")
+    }
+    return chat.LastSeq, nil
+}
+
+// setLastMessage обновляет превью последнего сообщения и время активности чата.
+// Параллельно сохранённые сообщения записывают превью в произвольном порядке,
+// поэтому превью заменяется, только если у нового сообщения номер больше.
+// Ошибка только логируется: сообщение к этому моменту уже сохранено.
+func (m *MongoStorage) setLastMessage(ctx context.Context, chatID primitive.ObjectID, last *LastMessage) {
+    filter := bson.M{
+        "_id": chatID,
+        "$or": []bson.M{
+            {"last_message.seq": bson.M{"$lt": last.Seq}},
+            // Превью ещё нет или оно сохранено до появления номеров
+            {"last_message.seq": bson.M{"$exists": false}},
+        },
+    }
+    update := bson.M{"$set": bson.M{"last_message": last, "last_activity_at": last.CreatedAt, "changed_at": last.CreatedAt}}
+    if _, err := m.chatColl.UpdateOne(ctx, filter, update); err != nil {
+        log.Printf("Ошибка обновления последнего сообщения чата %s: %v", chatID.Hex(), err)
+    }
+}
+
+// refreshLastMessage пересчитывает превью, если изменённое или удалённое сообщение
+// было последним в чате. Время активности чата не меняется.
+func (m *MongoStorage) refreshLastMessage(ctx context.Context, chatID primitive.ObjectID, messageID primitive.ObjectID) {
+    update := bson.M{"$unset": bson.M{"last_message": ""}, "$set": bson.M{"changed_at": time.Now()}}
+    latest, err := m.findMessages(ctx, notDeleted(bson.M{"chat_id": chatID}), options.Find().SetSort(bson.D{{Key: "seq", Value: -1}, {Key: "_id", Value: -1}}).SetLimit(1))
+    if err != nil {
+        return
+    }
+    if len(latest) > 0 {
+        update = bson.M{"$set": bson.M{"last_message": newLastMessage(latest[0]), "changed_at": time.Now()}}
+    }
+
+    filter := bson.M{"_id": chatID, "last_message.message_id": messageID}
+    if _, err := m.chatColl.UpdateOne(ctx, filter, update); err != nil {
+        log.Printf("Ошибка обновления последнего сообщения чата %s: %v", chatID.Hex(), err)
+    }
+}
+
+// incReplyCount изменяет счётчик ответов корневого сообщения ветки.
+// Ошибка только логируется: сообщение к этому моменту уже сохранено или удалено.
+func (m *MongoStorage) incReplyCount(ctx context.Context, threadID primitive.ObjectID, delta int) {
+    update := bson.M{"$inc": bson.M{"reply_count": delta}, "$set": bson.M{"changed_at": time.Now()}}
+    _, err := m.messageColl.UpdateOne(ctx, bson.M{"_id": threadID}, update)
+    if err != nil {
+        log.Printf("Ошибка обновления счётчика ответов сообщения %s: %v", threadID.Hex(), err)
+    }
+}
+
+// EditMessage заменяет содержимое сообщения, если его текущая ревизия равна
+// expectedRevision, иначе возвращает ErrRevisionConflict. Прежнее содержимое
+// сохраняется в журнал ревизий.
+func (m *MongoStorage) EditMessage(ctx context.Context, messageID string, userID int32, newContent string, expectedRevision int64) error {
     // Преобразуем messageID в ObjectID
     objID, err := primitive.ObjectIDFromHex(messageID)
     if err != nil {
-        return errors.New("некорректный messageID")
+        return ErrInvalidMessageID
     }
 
     // Проверяем, что новое содержимое не пустое
     if strings.TrimSpace(newContent) == "" {
-        return errors.New("содержимое сообщения не может быть пустым")
+        return ErrEmptyContent
+    }
+
+    // Правка применяется атомарно и только к ожидаемой ревизии сообщения автора.
+    // Ревизия 0 в документе не хранится.
+    filter := notDeleted(bson.M{"_id": objID, "sender_id": userID, "revision": expectedRevision})
+    if expectedRevision == 0 {
+        filter["revision"] = bson.M{"$exists": false}
+    }
+
+    // Обновляем содержимое сообщения и добавляем время редактирования
+    editedAt := time.Now()
+    update := bson.M{
+        "$set": bson.M{
+            "content":    newContent,
+            "edited_at":  editedAt,
+            "changed_at": editedAt,
+        },
+        "$inc": bson.M{"revision": 1},
+    }
+
+    // Документ до обновления содержит заменяемое содержимое
+    var previous Message
+    err = m.messageColl.FindOneAndUpdate(ctx, filter, update).Decode(&previous)
+    if err == mongo.ErrNoDocuments {
+        return m.editError(ctx, objID, userID)
+    }
+    if err != nil {
+        log.Printf("Ошибка редактирования сообщения: %v", err)
+        return errors.New("ошибка редактирования сообщения")
+    }
+
+    // Правка уже применена, поэтому ошибка записи журнала только логируется
+    if _, err := m.revisionColl.InsertOne(ctx, newRevision(&previous, editedAt)); err != nil {
+        log.Printf("Ошибка сохранения ревизии сообщения %s: %v", messageID, err)
     }
 
-    // Находим сообщение и проверяем автора
+    m.refreshLastMessage(ctx, previous.ChatID, objID)
+    return nil
+}
+
+// editError определяет, почему правка не применилась: сообщения нет,
+// его редактирует не автор или ревизия уже изменилась
+func (m *MongoStorage) editError(ctx context.Context, objID primitive.ObjectID, userID int32) error {
     var message struct {
         SenderID int32 `bson:"sender_id"`
     }
-    err = m.messageColl.FindOne(ctx, bson.M{"_id": objID}).Decode(&message)
+    err := m.messageColl.FindOne(ctx, notDeleted(bson.M{"_id": objID})).Decode(&message)
     if err != nil {
         if err == mongo.ErrNoDocuments {
-            return errors.New("сообщение не найдено")
+            return ErrMessageNotFound
         }
         log.Printf("Ошибка получения сообщения: %v", err)
         return errors.New("ошибка получения сообщения")
@@ -256,33 +650,125 @@ func (m *MongoStorage) EditMessage(ctx context.Context, messageID string, userID
 
     // Проверяем, что редактирует автор сообщения
     if message.SenderID != userID {
-        return errors.New("вы не можете редактировать чужое сообщение")
+        return ErrForbidden
     }
+    return ErrRevisionConflict
+}
 
-    // Обновляем содержимое сообщения и добавляем время редактирования
+// GetMessageRevisions возвращает журнал прежних ревизий сообщения от первой к последней
+func (m *MongoStorage) GetMessageRevisions(ctx context.Context, messageID string) ([]*MessageRevision, error) {
+    objID, err := primitive.ObjectIDFromHex(messageID)
+    if err != nil {
+        return nil, ErrInvalidMessageID
+    }
+
+    findOptions := options.Find().SetSort(bson.D{{Key: "revision", Value: 1}})
+    cursor, err := m.revisionColl.Find(ctx, bson.M{"message_id": objID}, findOptions)
+    if err != nil {
+        log.Printf("Ошибка получения ревизий сообщения: %v", err)
+        return nil, errors.New("ошибка получения ревизий сообщения")
+    }
+    defer cursor.Close(ctx)
+
+    revisions := []*MessageRevision{}
+    if err := cursor.All(ctx, &revisions); err != nil {
+        log.Printf("Ошибка декодирования ревизий сообщения: %v", err)
+        return nil, err
+    }
+    return revisions, nil
+}
+
+// PinMessage закрепляет сообщение чата. В чате может быть не больше
+// MaxPinnedMessages закреплённых сообщений.
+func (m *MongoStorage) PinMessage(ctx context.Context, chatID string, messageID string, userID int32) error {
+    chatObjectID, err := primitive.ObjectIDFromHex(chatID)
+    if err != nil {
+        return ErrInvalidChatID
+    }
+    messageObjectID, err := primitive.ObjectIDFromHex(messageID)
+    if err != nil {
+        return ErrInvalidMessageID
+    }
+
+    if userID == 0 {
+        return ErrInvalidUserID
+    }
+
+    // Закрепить можно только сообщение этого чата
+    count, err := m.messageColl.CountDocuments(ctx, notDeleted(bson.M{"_id": messageObjectID, "chat_id": chatObjectID}))
+    if err != nil {
+        log.Printf("Ошибка получения сообщения: %v", err)
+        return errors.New("ошибка получения сообщения")
+    }
+    if count == 0 {
+        return ErrMessageNotFound
+    }
+
+    // Лимит и повторное закрепление проверяются в том же запросе, что и запись
+    pinnedAt := time.Now()
+    filter := bson.M{
+        "_id":                        chatObjectID,
+        "pinned_messages.message_id": bson.M{"$ne": messageObjectID},
+        lastPinSlot:                  bson.M{"$exists": false},
+    }
     update := bson.M{
-        "$set": bson.M{
-            "content":   newContent,
-            "edited_at": time.Now(),
-        },
+        "$push": bson.M{"pinned_messages": PinnedMessage{MessageID: messageObjectID, PinnedBy: userID, PinnedAt: pinnedAt}},
+        "$set":  bson.M{"changed_at": pinnedAt},
     }
 
-    // Выполняем обновление
-    res, err := m.messageColl.UpdateOne(ctx, bson.M{"_id": objID}, update)
+    res, err := m.chatColl.UpdateOne(ctx, filter, update)
     if err != nil {
-        log.Printf("Ошибка редактирования сообщения: %v", err)
-        return errors.New("ошибка редактирования сообщения")
+        log.Printf("Ошибка закрепления сообщения: %v", err)
+        return errors.New("ошибка закрепления сообщения")
+    }
+
+    if res.MatchedCount == 0 {
+        chat, err := m.GetChatByID(ctx, chatID)
+        if err != nil {
+            return err
+        }
+        return chat.pinError(messageObjectID)
+    }
+
+    return nil
+}
+
+// UnpinMessage открепляет сообщение чата
+func (m *MongoStorage) UnpinMessage(ctx context.Context, chatID string, messageID string) error {
+    chatObjectID, err := primitive.ObjectIDFromHex(chatID)
+    if err != nil {
+        return ErrInvalidChatID
+    }
+    messageObjectID, err := primitive.ObjectIDFromHex(messageID)
+    if err != nil {
+        return ErrInvalidMessageID
+    }
+
+    res, err := m.chatColl.UpdateOne(ctx,
+        bson.M{"_id": chatObjectID, "pinned_messages.message_id": messageObjectID},
+        bson.M{
+            "$pull": bson.M{"pinned_messages": bson.M{"message_id": messageObjectID}},
+            "$set":  bson.M{"changed_at": time.Now()},
+        },
+    )
+    if err != nil {
+        log.Printf("Ошибка открепления сообщения: %v", err)
+        return errors.New("ошибка открепления сообщения")
     }
 
-    // Проверяем, был ли обновлён хотя бы один документ
     if res.MatchedCount == 0 {
-        return errors.New("сообщение не найдено")
+        if _, err := m.GetChatByID(ctx, chatID); err != nil {
+            return err
+        }
+        return ErrNotPinned
     }
 
     return nil
 }
 
-// DeleteMessage удаляет сообщение по его ID и ID пользователя.
+// DeleteMessage удаляет сообщение по его ID и ID пользователя. Вместо сообщения
+// остаётся надгробие без содержимого, по которому клиенты узнают об удалении.
+// Ключ идемпотентности надгробие сохраняет: повтор отправки не создаст сообщение заново.
 func (m *MongoStorage) DeleteMessage(ctx context.Context, messageID string, userID int32) error {
     // Преобразование в ObjectID
     objID, err := primitive.ObjectIDFromHex(messageID)
@@ -291,35 +777,92 @@ func (m *MongoStorage) DeleteMessage(ctx context.Context, messageID string, user
     }
 
     // Условие удаления: сообщение должно принадлежать пользователю
-    filter := bson.M{
+    filter := notDeleted(bson.M{
         "_id":      objID,
         "sender_id": userID, // Обратите внимание на "sender_id" (нижнее подчеркивание)
-    }
+    })
 
-    // Выполнение удаления
-    result, err := m.messageColl.DeleteOne(ctx, filter)
+    // Выполнение удаления: содержимое стирается, номер и отправитель остаются
+    deletedAt := time.Now()
+    update := bson.M{
+        "$set": bson.M{"deleted_at": deletedAt, "changed_at": deletedAt, "content": ""},
+        "$unset": bson.M{
+            "reactions":  "",
+            "mentions":   "",
+            "reply_to":   "",
+            "attachment": "",
+        },
+    }
+    var deleted Message
+    err = m.messageColl.FindOneAndUpdate(ctx, filter, update).Decode(&deleted)
     if err != nil {
+        // Проверка, было ли сообщение удалено
+        if err == mongo.ErrNoDocuments {
+            return ErrMessageNotFound
+        }
         return err
     }
 
-    // Проверка, было ли сообщение удалено
-    if result.DeletedCount == 0 {
-        return ErrMessageNotFound
+    // Удалённый ответ больше не учитывается в ветке
+    if deleted.ReplyTo != nil {
+        m.incReplyCount(ctx, deleted.ReplyTo.ThreadID, -1)
     }
 
+    // Прежние ревизии удалённого сообщения тоже больше не показываются
+    if _, err := m.revisionColl.DeleteMany(ctx, bson.M{"message_id": objID}); err != nil {
+        log.Printf("Ошибка удаления ревизий сообщения %s: %v", messageID, err)
+    }
+
+    // Удалённое сообщение открепляется
+    if _, err := m.chatColl.UpdateOne(ctx,
+        bson.M{"_id": deleted.ChatID, "pinned_messages.message_id": objID},
+        bson.M{
+            "$pull": bson.M{"pinned_messages": bson.M{"message_id": objID}},
+            "$set":  bson.M{"changed_at": deletedAt},
+        },
+    ); err != nil {
+        log.Printf("Ошибка открепления удалённого сообщения %s: %v", messageID, err)
+    }
+
+    m.refreshLastMessage(ctx, deleted.ChatID, deleted.ID)
     return nil
 }
 
-func (m *MongoStorage) GetUserChats(ctx context.Context, userID int32) ([]*Chat, error) {
-    // Ищем чаты, где пользователь является участником или создателем
-    filter := bson.M{
-        "$or": []bson.M{
-            {"member_ids": userID},
-            {"creator_id": userID},
-        },
+// HideMessage удаляет сообщение только для пользователя: остальные участники
+// продолжают его видеть
+func (m *MongoStorage) HideMessage(ctx context.Context, messageID string, userID int32) error {
+    objID, err := primitive.ObjectIDFromHex(messageID)
+    if err != nil {
+        return ErrInvalidMessageID
+    }
+    if userID == 0 {
+        return ErrInvalidUserID
+    }
+
+    update := bson.M{
+        "$addToSet": bson.M{"hidden_for": userID},
+        "$set":      bson.M{"changed_at": time.Now()},
+    }
+    res, err := m.messageColl.UpdateOne(ctx, notDeleted(bson.M{"_id": objID}), update)
+    if err != nil {
+        log.Printf("Ошибка скрытия сообщения: %v", err)
+        return err
     }
+    if res.MatchedCount == 0 {
+        return ErrMessageNotFound
+    }
+    return nil
+}
+
+func (m *MongoStorage) GetUserChats(ctx context.Context, userID int32) ([]*Chat, error) {
+    // Ищем чаты, где пользователь является участником. Создатель, покинувший
+    // чат или удалённый из него, чат больше не видит.
+    filter := bson.M{"member_ids": userID}
+
+    // Сначала чаты с самой свежей активностью
+    findOptions := options.Find().SetSort(bson.D{{Key: "last_activity_at", Value: -1}, {Key: "_id", Value: -1}})
 
-    cursor, err := m.chatColl.Find(ctx, filter)
+    cursor, err := m.chatColl.Find(ctx, filter, findOptions)
     if err != nil {
         log.Printf("Ошибка получения списка чатов: %v", err)
         return nil, errors.New("ошибка получения списка чатов")
@@ -333,6 +876,7 @@ func (m *MongoStorage) GetUserChats(ctx context.Context, userID int32) ([]*Chat,
             log.Printf("Ошибка декодирования чата: %v", err)
             return nil, err
         }
+        chat.MemberCount = len(chat.MemberIDs)
         chats = append(chats, &chat)
     }
 
@@ -344,29 +888,53 @@ func (m *MongoStorage) GetUserChats(ctx context.Context, userID int32) ([]*Chat,
     return chats, nil
 }
 
-// GetMessages возвращает сообщения чата с возможностью пагинации.
-func (m *MongoStorage) GetMessages(ctx context.Context, chatID string) ([]*Message, error) {
-    chatObjectID, err := primitive.ObjectIDFromHex(chatID)
-    if err != nil {
-        log.Printf("Некорректный идентификатор чата: %v", err)
-        return nil, errors.New("некорректный идентификатор чата")
+// CountUnread считает непрочитанные пользователем сообщения и упоминания в переданных чатах
+// одним агрегирующим запросом. Чаты, прочитанные до последнего сообщения, в запрос не попадают.
+// Собственные сообщения пользователя непрочитанными не считаются.
+func (m *MongoStorage) CountUnread(ctx context.Context, userID int32, chats []*Chat) (map[primitive.ObjectID]UnreadCount, error) {
+    counts := make(map[primitive.ObjectID]UnreadCount, len(chats))
+
+    // Для каждого чата выбираем сообщения после отметки прочтения по индексу (chat_id, seq)
+    var ranges []bson.M
+    for _, chat := range chats {
+        if !hasUnread(chat, userID) {
+            continue
+        }
+        ranges = append(ranges, notDeleted(bson.M{"chat_id": chat.ID, "seq": bson.M{"$gt": chat.ReadUpTo(userID)}}))
+    }
+    if len(ranges) == 0 {
+        return counts, nil
+    }
+
+    pipeline := mongo.Pipeline{
+        {{Key: "$match", Value: bson.M{"$or": ranges, "sender_id": bson.M{"$ne": userID}}}},
+        {{Key: "$group", Value: bson.M{
+            "_id":      "$chat_id",
+            "messages": bson.M{"$sum": 1},
+            "mentions": bson.M{"$sum": bson.M{"$cond": bson.A{
+                bson.M{"$in": bson.A{userID, bson.M{"$ifNull": bson.A{"$mentions", bson.A{}}}}}, 1, 0,
+            }}},
+        }}},
     }
 
-    cursor, err := m.messageColl.Find(ctx, bson.M{"chat_id": chatObjectID})
+    cursor, err := m.messageColl.Aggregate(ctx, pipeline)
     if err != nil {
-        log.Printf("Ошибка получения сообщений: %v", err)
-        return nil, errors.New("ошибка получения сообщений")
+        log.Printf("Ошибка подсчёта непрочитанных сообщений: %v", err)
+        return nil, errors.New("ошибка подсчёта непрочитанных сообщений")
     }
     defer cursor.Close(ctx)
 
-    var messages []*Message
     for cursor.Next(ctx) {
-        var msg Message
-        if err := cursor.Decode(&msg); err != nil {
-            log.Printf("Ошибка декодирования сообщения: %v", err)
+        var row struct {
+            ChatID   primitive.ObjectID `bson:"_id"`
+            Messages int64              `bson:"messages"`
+            Mentions int64              `bson:"mentions"`
+        }
+        if err := cursor.Decode(&row); err != nil {
+            log.Printf("Ошибка декодирования счётчиков: %v", err)
             return nil, err
         }
-        messages = append(messages, &msg)
+        counts[row.ChatID] = UnreadCount{Messages: row.Messages, Mentions: row.Mentions}
     }
 
     if err := cursor.Err(); err != nil {
@@ -374,26 +942,18 @@ func (m *MongoStorage) GetMessages(ctx context.Context, chatID string) ([]*Messa
         return nil, err
     }
 
-    return messages, nil
+    return counts, nil
 }
 
-// GetMessagesWithPagination возвращает сообщения чата с параметрами пагинации.
-func (m *MongoStorage) GetMessagesWithPagination(ctx context.Context, chatID string, limit int64, skip int64) ([]*Message, error) {
+// GetMessages возвращает сообщения чата с возможностью пагинации.
+func (m *MongoStorage) GetMessages(ctx context.Context, chatID string) ([]*Message, error) {
     chatObjectID, err := primitive.ObjectIDFromHex(chatID)
     if err != nil {
         log.Printf("Некорректный идентификатор чата: %v", err)
-        return nil, errors.New("некорректный идентификатор чата")
-    }
-
-    findOptions := options.Find()
-    if limit > 0 {
-        findOptions.SetLimit(limit)
-    }
-    if skip > 0 {
-        findOptions.SetSkip(skip)
+        return nil, ErrInvalidChatID
     }
 
-    cursor, err := m.messageColl.Find(ctx, bson.M{"chat_id": chatObjectID}, findOptions)
+    cursor, err := m.messageColl.Find(ctx, notDeleted(bson.M{"chat_id": chatObjectID}))
     if err != nil {
         log.Printf("Ошибка получения сообщений: %v", err)
         return nil, errors.New("ошибка получения сообщений")
@@ -418,8 +978,159 @@ func (m *MongoStorage) GetMessagesWithPagination(ctx context.Context, chatID str
     return messages, nil
 }
 
-func (m *MongoStorage) GetChatParticipants(ctx context.Context, chatID string) ([]int32, error) {
-    objID, err := primitive.ObjectIDFromHex(chatID)
+// GetMessagesPage возвращает страницу истории чата от новых сообщений к старым.
+// Выборка и сортировка идут по индексу (chat_id, _id), поэтому страницы
+// стабильны и не сдвигаются при появлении новых сообщений.
+func (m *MongoStorage) GetMessagesPage(ctx context.Context, chatID string, query MessagePageQuery) (*MessagePage, error) {
+    chatObjectID, err := primitive.ObjectIDFromHex(chatID)
+    if err != nil {
+        log.Printf("Некорректный идентификатор чата: %v", err)
+        return nil, ErrInvalidChatID
+    }
+
+    return m.messagesPage(ctx, bson.M{"chat_id": chatObjectID}, query)
+}
+
+// GetThreadMessages возвращает страницу ответов в ветке корневого сообщения
+// от новых к старым
+func (m *MongoStorage) GetThreadMessages(ctx context.Context, rootID string, query MessagePageQuery) (*MessagePage, error) {
+    rootObjectID, err := primitive.ObjectIDFromHex(rootID)
+    if err != nil {
+        return nil, ErrInvalidMessageID
+    }
+
+    return m.messagesPage(ctx, bson.M{"reply_to.thread_id": rootObjectID}, query)
+}
+
+// messagesPage выбирает страницу сообщений, подходящих под filter, по курсорам запроса.
+// Надгробия удалённых сообщений в историю не попадают.
+func (m *MongoStorage) messagesPage(ctx context.Context, baseFilter bson.M, query MessagePageQuery) (*MessagePage, error) {
+    before, after, err := query.bounds()
+    if err != nil {
+        return nil, err
+    }
+    baseFilter = notDeleted(baseFilter)
+    if query.ViewerID != 0 {
+        // Сообщения, удалённые зрителем для себя, в его историю не попадают
+        baseFilter["hidden_for"] = bson.M{"$ne": query.ViewerID}
+    }
+
+    // Курсоры по номерам сравниваются с seq (сообщения без номера в такую
+    // выборку не попадают), курсоры по ID - с _id
+    field := "_id"
+    rangeFilter := bson.M{}
+    if query.bySeq() {
+        field = "seq"
+        if query.BeforeSeq > 0 {
+            rangeFilter["$lt"] = query.BeforeSeq
+        }
+        if query.AfterSeq > 0 {
+            rangeFilter["$gt"] = query.AfterSeq
+        }
+    } else {
+        if !before.IsZero() {
+            rangeFilter["$lt"] = before
+        }
+        if !after.IsZero() {
+            rangeFilter["$gt"] = after
+        }
+    }
+
+    filter := bson.M{}
+    for key, value := range baseFilter {
+        filter[key] = value
+    }
+    if len(rangeFilter) > 0 {
+        filter[field] = rangeFilter
+    }
+
+    // От курсора after идём вверх, чтобы взять ближайшие к нему сообщения
+    direction := -1
+    if query.ascending() {
+        direction = 1
+    }
+    findOptions := options.Find().SetSort(bson.D{{Key: field, Value: direction}}).SetLimit(query.limit())
+
+    messages, err := m.findMessages(ctx, filter, findOptions)
+    if err != nil {
+        return nil, err
+    }
+    if query.ascending() {
+        reverseMessages(messages)
+    }
+    if len(messages) == 0 {
+        return newMessagePage(messages, false, false, query.bySeq()), nil
+    }
+
+    // Проверяем, есть ли сообщения за границами страницы
+    var oldest, newest interface{} = messages[len(messages)-1].ID, messages[0].ID
+    if query.bySeq() {
+        oldest, newest = messages[len(messages)-1].Seq, messages[0].Seq
+    }
+    olderFilter := bson.M{field: bson.M{"$lt": oldest}}
+    newerFilter := bson.M{field: bson.M{"$gt": newest}}
+    for key, value := range baseFilter {
+        olderFilter[key] = value
+        newerFilter[key] = value
+    }
+
+    hasOlder, err := m.messageExists(ctx, olderFilter)
+    if err != nil {
+        return nil, err
+    }
+    hasNewer, err := m.messageExists(ctx, newerFilter)
+    if err != nil {
+        return nil, err
+    }
+
+    return newMessagePage(messages, hasOlder, hasNewer, query.bySeq()), nil
+}
+
+func (m *MongoStorage) findMessages(ctx context.Context, filter bson.M, findOptions *options.FindOptions) ([]*Message, error) {
+    cursor, err := m.messageColl.Find(ctx, filter, findOptions)
+    if err != nil {
+        log.Printf("Ошибка получения сообщений: %v", err)
+        return nil, errors.New("ошибка получения сообщений")
+    }
+    defer cursor.Close(ctx)
+
+    messages := []*Message{}
+    for cursor.Next(ctx) {
+        var msg Message
+        if err := cursor.Decode(&msg); err != nil {
+            log.Printf("Ошибка декодирования сообщения: %v", err)
+            return nil, err
+        }
+        messages = append(messages, &msg)
+    }
+
+    if err := cursor.Err(); err != nil {
+        log.Printf("Ошибка работы курсора: %v", err)
+        return nil, err
+    }
+
+    return messages, nil
+}
+
+// notDeleted дополняет фильтр сообщений условием, исключающим надгробия удалённых
+// и сообщения чатов, находящихся в корзине
+func notDeleted(filter bson.M) bson.M {
+    filter["deleted_at"] = bson.M{"$exists": false}
+    filter["chat_deleted_at"] = bson.M{"$exists": false}
+    return filter
+}
+
+func (m *MongoStorage) messageExists(ctx context.Context, filter bson.M) (bool, error) {
+    count, err := m.messageColl.CountDocuments(ctx, filter, options.Count().SetLimit(1))
+    if err != nil {
+        log.Printf("Ошибка проверки наличия сообщений: %v", err)
+        return false, errors.New("ошибка получения сообщений")
+    }
+    return count > 0, nil
+}
+
+func (m *MongoStorage) GetChatParticipants(ctx context.Context, chatID string) ([]int32, error) {
+    objID, err := primitive.ObjectIDFromHex(chatID)
     if err != nil {
         return nil, ErrInvalidChatID
     }
@@ -436,6 +1147,49 @@ func (m *MongoStorage) GetChatParticipants(ctx context.Context, chatID string) (
     return chat.MemberIDs, nil
 }
 
+// GetChatParticipantsPage возвращает страницу участников чата в порядке вступления.
+// Из базы читается только нужный срез списка участников.
+func (m *MongoStorage) GetChatParticipantsPage(ctx context.Context, chatID string, query ParticipantPageQuery) (*ParticipantPage, error) {
+    objID, err := primitive.ObjectIDFromHex(chatID)
+    if err != nil {
+        return nil, ErrInvalidChatID
+    }
+
+    offset, err := query.offset()
+    if err != nil {
+        return nil, err
+    }
+
+    pipeline := mongo.Pipeline{
+        {{Key: "$match", Value: bson.M{"_id": objID}}},
+        {{Key: "$project", Value: bson.M{
+            "total":      bson.M{"$size": "$member_ids"},
+            "member_ids": bson.M{"$slice": bson.A{"$member_ids", offset, query.limit()}},
+        }}},
+    }
+    cursor, err := m.chatColl.Aggregate(ctx, pipeline)
+    if err != nil {
+        log.Printf("Ошибка получения участников чата: %v", err)
+        return nil, errors.New("ошибка получения участников чата")
+    }
+    defer cursor.Close(ctx)
+
+    var result []struct {
+        MemberIDs []int32 `bson:"member_ids"`
+        Total     int64   `bson:"total"`
+    }
+    if err := cursor.All(ctx, &result); err != nil {
+        log.Printf("Ошибка декодирования участников чата: %v", err)
+        return nil, err
+    }
+
+    if len(result) == 0 {
+        return nil, ErrChatNotFound
+    }
+
+    return newParticipantPage(result[0].MemberIDs, offset, result[0].Total), nil
+}
+
 func (m *MongoStorage) LeaveChat(ctx context.Context, chatID string, userID int32) error {
     // Преобразуем chatID в ObjectID
     objID, err := primitive.ObjectIDFromHex(chatID)
@@ -445,11 +1199,15 @@ func (m *MongoStorage) LeaveChat(ctx context.Context, chatID string, userID int3
 
     // Проверяем, что userID не равен 0
     if userID == 0 {
-        return errors.New("некорректный userID")
+        return ErrInvalidUserID
     }
 
-    // Удаляем пользователя из списка участников чата
-    update := bson.M{"$pull": bson.M{"member_ids": userID}}
+    // Удаляем пользователя из списка участников чата вместе с его ролью
+    update := bson.M{
+        "$pull":  bson.M{"member_ids": userID},
+        "$unset": bson.M{"roles." + markerKey(userID): ""},
+        "$set":   bson.M{"changed_at": time.Now()},
+    }
 
     // Выполняем обновление
     res, err := m.chatColl.UpdateOne(ctx, bson.M{"_id": objID}, update)
@@ -463,17 +1221,102 @@ func (m *MongoStorage) LeaveChat(ctx context.Context, chatID string, userID int3
         return ErrChatNotFound
     }
 
+    m.recordRemovals(ctx, objID, userID)
+    return nil
+}
+
+// SetMemberRole назначает участнику роль администратора или обычного участника.
+// Владелец меняется только через TransferOwnership.
+func (m *MongoStorage) SetMemberRole(ctx context.Context, chatID string, userID int32, role string) error {
+    objID, err := primitive.ObjectIDFromHex(chatID)
+    if err != nil {
+        return ErrInvalidChatID
+    }
+
+    if userID == 0 {
+        return ErrInvalidUserID
+    }
+
+    // Обычные участники в карте ролей не хранятся
+    key := "roles." + markerKey(userID)
+    set := bson.M{"changed_at": time.Now()}
+    update := bson.M{"$set": set}
+    switch role {
+    case RoleAdmin:
+        set[key] = role
+    case RoleMember:
+        update["$unset"] = bson.M{key: ""}
+    default:
+        return ErrInvalidRole
+    }
+
+    res, err := m.chatColl.UpdateOne(ctx, bson.M{"_id": objID, "member_ids": userID}, update)
+    if err != nil {
+        log.Printf("Ошибка изменения роли участника: %v", err)
+        return errors.New("ошибка изменения роли участника")
+    }
+
+    if res.MatchedCount == 0 {
+        return m.notMemberError(ctx, objID)
+    }
+
+    return nil
+}
+
+// TransferOwnership передаёт владение чатом от fromID к toID.
+// Прежний владелец становится администратором. Оба должны быть участниками чата.
+func (m *MongoStorage) TransferOwnership(ctx context.Context, chatID string, fromID int32, toID int32) error {
+    objID, err := primitive.ObjectIDFromHex(chatID)
+    if err != nil {
+        return ErrInvalidChatID
+    }
+
+    if fromID == 0 || toID == 0 {
+        return ErrInvalidUserID
+    }
+
+    filter := bson.M{"_id": objID, "member_ids": bson.M{"$all": bson.A{fromID, toID}}}
+    update := bson.M{"$set": bson.M{
+        "roles." + markerKey(fromID): RoleAdmin,
+        "roles." + markerKey(toID):   RoleOwner,
+        "changed_at":                 time.Now(),
+    }}
+
+    res, err := m.chatColl.UpdateOne(ctx, filter, update)
+    if err != nil {
+        log.Printf("Ошибка передачи владения чатом: %v", err)
+        return errors.New("ошибка передачи владения чатом")
+    }
+
+    if res.MatchedCount == 0 {
+        return m.notMemberError(ctx, objID)
+    }
+
     return nil
 }
 
+// notMemberError уточняет, почему обновление участника не нашло документ:
+// чата нет или пользователь в нём не состоит
+func (m *MongoStorage) notMemberError(ctx context.Context, chatID primitive.ObjectID) error {
+    count, err := m.chatColl.CountDocuments(ctx, bson.M{"_id": chatID}, options.Count().SetLimit(1))
+    if err != nil {
+        log.Printf("Ошибка проверки наличия чата: %v", err)
+        return errors.New("ошибка получения чата")
+    }
+    if count == 0 {
+        return ErrChatNotFound
+    }
+    return ErrNotMember
+}
+
 func (m *MongoStorage) AddReaction(ctx context.Context, messageID string, reaction string, userID int32) error {
     objID, err := primitive.ObjectIDFromHex(messageID)
     if err != nil {
-        return errors.New("некорректный messageID")
+        return ErrInvalidMessageID
     }
 
     if reaction == "" {
-        return errors.New("не указана реакция")
+        return ErrEmptyReaction
     }
 
     // Проверяем, что пользователь еще не добавил такую реакцию
@@ -487,22 +1330,28 @@ func (m *MongoStorage) AddReaction(ctx context.Context, messageID string, reacti
         },
     }
 
-    var exists bool
-    err = m.messageColl.FindOne(ctx, filter).Decode(&exists)
-    if err == nil {
-        return errors.New("реакция уже добавлена этим пользователем")
+    count, err := m.messageColl.CountDocuments(ctx, filter)
+    if err != nil {
+        log.Printf("Ошибка проверки реакции: %v", err)
+        return errors.New("ошибка добавления реакции")
+    }
+    if count > 0 {
+        return ErrReactionExists
     }
 
     // Добавляем реакцию
-    update := bson.M{"$push": bson.M{"reactions": bson.M{"reaction": reaction, "user_id": userID}}}
-    res, err := m.messageColl.UpdateOne(ctx, bson.M{"_id": objID}, update)
+    update := bson.M{
+        "$push": bson.M{"reactions": bson.M{"reaction": reaction, "user_id": userID}},
+        "$set":  bson.M{"changed_at": time.Now()},
+    }
+    res, err := m.messageColl.UpdateOne(ctx, notDeleted(bson.M{"_id": objID}), update)
     if err != nil {
         log.Printf("Ошибка добавления реакции: %v", err)
         return errors.New("ошибка добавления реакции")
     }
 
     if res.MatchedCount == 0 {
-        return errors.New("сообщение не найдено")
+        return ErrMessageNotFound
     }
 
     return nil
@@ -511,69 +1360,186 @@ func (m *MongoStorage) AddReaction(ctx context.Context, messageID string, reacti
 func (m *MongoStorage) RemoveReaction(ctx context.Context, messageID string, reaction string, userID int32) error {
     objID, err := primitive.ObjectIDFromHex(messageID)
     if err != nil {
-        return errors.New("некорректный messageID")
+        return ErrInvalidMessageID
     }
 
     if reaction == "" {
-        return errors.New("не указана реакция")
+        return ErrEmptyReaction
     }
 
     // Удаляем реакцию, если она была добавлена этим пользователем
-    update := bson.M{"$pull": bson.M{"reactions": bson.M{"reaction": reaction, "user_id": userID}}}
-    res, err := m.messageColl.UpdateOne(ctx, bson.M{"_id": objID}, update)
+    update := bson.M{
+        "$pull": bson.M{"reactions": bson.M{"reaction": reaction, "user_id": userID}},
+        "$set":  bson.M{"changed_at": time.Now()},
+    }
+    res, err := m.messageColl.UpdateOne(ctx, notDeleted(bson.M{"_id": objID}), update)
     if err != nil {
         log.Printf("Ошибка удаления реакции: %v", err)
         return errors.New("ошибка удаления реакции")
     }
 
     if res.MatchedCount == 0 {
-        return errors.New("сообщение не найдено")
+        return ErrMessageNotFound
     }
 
     return nil
 }
 
-func (m *MongoStorage) UpdateMessageStatus(ctx context.Context, messageID string, status string) error {
-    // Преобразуем messageID в ObjectID
-    objID, err := primitive.ObjectIDFromHex(messageID)
+// MarkDelivered отмечает сообщения чата до messageID включительно как доставленные пользователю.
+// Отметка только сдвигается вперёд: более старый messageID не отменяет доставку.
+func (m *MongoStorage) MarkDelivered(ctx context.Context, chatID string, userID int32, messageID string) error {
+    key := markerKey(userID)
+    return m.advanceMarkers(ctx, chatID, userID, messageID, "delivered_seqs."+key)
+}
+
+// MarkRead отмечает сообщения чата до messageID включительно как прочитанные пользователем.
+// Прочитанные сообщения одновременно считаются доставленными.
+func (m *MongoStorage) MarkRead(ctx context.Context, chatID string, userID int32, messageID string) error {
+    key := markerKey(userID)
+    return m.advanceMarkers(ctx, chatID, userID, messageID, "read_seqs."+key, "delivered_seqs."+key)
+}
+
+// markViewedAttempts - число попыток сдвинуть отметку прочтения при конкурентных обновлениях
+const markViewedAttempts = 5
+
+// MarkViewed сдвигает отметку прочтения пользователя в канале до messageID и
+// увеличивает счётчик просмотров у постов, которые пользователь видит впервые.
+// Собственные посты пользователя просмотрами не считаются.
+func (m *MongoStorage) MarkViewed(ctx context.Context, chatID string, userID int32, messageID string) error {
+    chatObjID, msgObjID, err := markerIDs(chatID, userID, messageID)
     if err != nil {
-        return errors.New("некорректный messageID")
+        return err
     }
 
-    // Проверяем, что status не пустой
-    if status == "" {
-        return errors.New("не указан статус")
+    seq, err := m.messageSeq(ctx, chatObjID, msgObjID)
+    if err != nil || seq == 0 {
+        return err
     }
 
-    // Обновляем статус сообщения
-    update := bson.M{"$set": bson.M{"status": status}}
+    key := markerKey(userID)
+    readField := "read_seqs." + key
+    for attempt := 0; attempt < markViewedAttempts; attempt++ {
+        var chat Chat
+        findOptions := options.FindOne().SetProjection(bson.M{readField: 1})
+        err := m.chatColl.FindOne(ctx, bson.M{"_id": chatObjID}, findOptions).Decode(&chat)
+        if err != nil {
+            if err == mongo.ErrNoDocuments {
+                return ErrChatNotFound
+            }
+            log.Printf("Ошибка получения отметки прочтения: %v", err)
+            return errors.New("ошибка получения отметки прочтения")
+        }
+
+        previous := chat.ReadUpTo(userID)
+        if previous >= seq {
+            return nil
+        }
 
-    // Выполняем обновление
-    res, err := m.messageColl.UpdateOne(ctx, bson.M{"_id": objID}, update)
+        // Сдвигаем отметку, только если её не сдвинул параллельный запрос,
+        // иначе одни и те же посты были бы засчитаны дважды
+        filter := bson.M{"_id": chatObjID, readField: previous}
+        if previous == 0 {
+            filter[readField] = bson.M{"$exists": false}
+        }
+        update := bson.M{
+            "$set": bson.M{readField: seq, "changed_at": time.Now()},
+            "$max": bson.M{"delivered_seqs." + key: seq},
+        }
+        res, err := m.chatColl.UpdateOne(ctx, filter, update)
+        if err != nil {
+            log.Printf("Ошибка обновления отметки прочтения: %v", err)
+            return errors.New("ошибка обновления отметки прочтения")
+        }
+        if res.MatchedCount == 0 {
+            continue
+        }
+
+        viewed := notDeleted(bson.M{
+            "chat_id":   chatObjID,
+            "sender_id": bson.M{"$ne": userID},
+            "seq":       bson.M{"$gt": previous, "$lte": seq},
+        })
+        if _, err := m.messageColl.UpdateMany(ctx, viewed, bson.M{"$inc": bson.M{"views": 1}}); err != nil {
+            log.Printf("Ошибка обновления счётчиков просмотров: %v", err)
+            return errors.New("ошибка обновления счётчиков просмотров")
+        }
+        return nil
+    }
+
+    return errors.New("не удалось обновить отметку прочтения: слишком много параллельных изменений")
+}
+
+// advanceMarkers сдвигает отметки чата до номера сообщения messageID, если они ещё не дальше
+func (m *MongoStorage) advanceMarkers(ctx context.Context, chatID string, userID int32, messageID string, fields ...string) error {
+    chatObjID, msgObjID, err := markerIDs(chatID, userID, messageID)
     if err != nil {
-        log.Printf("Ошибка обновления статуса сообщения: %v", err)
-        return errors.New("ошибка обновления статуса сообщения")
+        return err
+    }
+
+    seq, err := m.messageSeq(ctx, chatObjID, msgObjID)
+    if err != nil || seq == 0 {
+        return err
+    }
+
+    // $max не даёт отметке сдвинуться назад
+    markers := bson.M{}
+    for _, field := range fields {
+        markers[field] = seq
+    }
+
+    update := bson.M{"$max": markers, "$set": bson.M{"changed_at": time.Now()}}
+    res, err := m.chatColl.UpdateOne(ctx, bson.M{"_id": chatObjID}, update)
+    if err != nil {
+        log.Printf("Ошибка обновления отметок прочтения: %v", err)
+        return errors.New("ошибка обновления отметок прочтения")
     }
 
-    // Проверяем, был ли обновлён хотя бы один документ
     if res.MatchedCount == 0 {
-        return errors.New("сообщение не найдено")
+        return ErrChatNotFound
     }
 
     return nil
 }
 
+// messageSeq возвращает номер сообщения в чате. У сообщений, сохранённых до
+// появления номеров, он равен нулю, и отметки по ним не сдвигаются.
+func (m *MongoStorage) messageSeq(ctx context.Context, chatObjID primitive.ObjectID, msgObjID primitive.ObjectID) (int64, error) {
+    var message struct {
+        Seq int64 `bson:"seq"`
+    }
+    findOptions := options.FindOne().SetProjection(bson.M{"seq": 1})
+    err := m.messageColl.FindOne(ctx, bson.M{"_id": msgObjID, "chat_id": chatObjID}, findOptions).Decode(&message)
+    if err == nil {
+        return message.Seq, nil
+    }
+    if err != mongo.ErrNoDocuments {
+        log.Printf("Ошибка получения номера сообщения: %v", err)
+        return 0, errors.New("ошибка получения номера сообщения")
+    }
+
+    // Сообщения нет в чате: различаем отсутствие чата и отсутствие сообщения
+    count, err := m.chatColl.CountDocuments(ctx, bson.M{"_id": chatObjID}, options.Count().SetLimit(1))
+    if err != nil {
+        log.Printf("Ошибка проверки чата: %v", err)
+        return 0, errors.New("ошибка проверки чата")
+    }
+    if count == 0 {
+        return 0, ErrChatNotFound
+    }
+    return 0, ErrMessageNotFound
+}
+
 func (m *MongoStorage) GetChatByID(ctx context.Context, chatID string) (*Chat, error) {
     // Проверяем длину chatID
     if chatID == "" || len(chatID) != 24 {
-        return nil, errors.New("некорректный идентификатор чата")
+        return nil, ErrInvalidChatID
     }
 
     // Преобразуем chatID в ObjectID
     chatObjectID, err := primitive.ObjectIDFromHex(chatID)
     if err != nil {
         log.Printf("Некорректный идентификатор чата: %v", err)
-        return nil, errors.New("некорректный идентификатор чата")
+        return nil, ErrInvalidChatID
     }
 
     // Ищем чат по ID
@@ -581,53 +1547,877 @@ func (m *MongoStorage) GetChatByID(ctx context.Context, chatID string) (*Chat, e
     err = m.chatColl.FindOne(ctx, bson.M{"_id": chatObjectID}).Decode(&chat)
     if err != nil {
         if err == mongo.ErrNoDocuments {
-            return nil, errors.New("чат не найден")
+            return nil, ErrChatNotFound
         }
         log.Printf("Ошибка получения чата: %v", err)
         return nil, errors.New("ошибка получения чата")
     }
+    chat.MemberCount = len(chat.MemberIDs)
 
     // Возвращаем найденный чат
     return &chat, nil
 }
 
+// GetMessageByID возвращает сообщение по его ID. Удалённое сообщение считается ненайденным.
+func (m *MongoStorage) GetMessageByID(ctx context.Context, messageID string) (*Message, error) {
+    objID, err := primitive.ObjectIDFromHex(messageID)
+    if err != nil {
+        return nil, ErrInvalidMessageID
+    }
+
+    var message Message
+    err = m.messageColl.FindOne(ctx, notDeleted(bson.M{"_id": objID})).Decode(&message)
+    if err != nil {
+        if err == mongo.ErrNoDocuments {
+            return nil, ErrMessageNotFound
+        }
+        log.Printf("Ошибка получения сообщения: %v", err)
+        return nil, errors.New("ошибка получения сообщения")
+    }
+
+    return &message, nil
+}
+
+// GetMessageByClientID возвращает сообщение отправителя по ключу идемпотентности
+func (m *MongoStorage) GetMessageByClientID(ctx context.Context, senderID int32, clientMessageID string) (*Message, error) {
+    if clientMessageID == "" {
+        return nil, ErrMessageNotFound
+    }
+
+    var message Message
+    err := m.messageColl.FindOne(ctx, bson.M{"sender_id": senderID, "client_message_id": clientMessageID}).Decode(&message)
+    if err != nil {
+        if err == mongo.ErrNoDocuments {
+            return nil, ErrMessageNotFound
+        }
+        log.Printf("Ошибка получения сообщения по ключу идемпотентности: %v", err)
+        return nil, errors.New("ошибка получения сообщения")
+    }
+
+    return &message, nil
+}
+
+// GetMessageByAttachmentKey возвращает сообщение, к которому приложен файл с ключом key.
+// Удалённое сообщение считается ненайденным.
+func (m *MongoStorage) GetMessageByAttachmentKey(ctx context.Context, key string) (*Message, error) {
+    if key == "" {
+        return nil, ErrMessageNotFound
+    }
+
+    var message Message
+    err := m.messageColl.FindOne(ctx, notDeleted(bson.M{"attachment.key": key})).Decode(&message)
+    if err != nil {
+        if err == mongo.ErrNoDocuments {
+            return nil, ErrMessageNotFound
+        }
+        log.Printf("Ошибка получения сообщения по ключу файла: %v", err)
+        return nil, errors.New("ошибка получения сообщения")
+    }
+
+    return &message, nil
+}
+
+// DeleteChat переносит чат в корзину. Сообщения чата остаются в хранилище, но не
+// выдаются при чтении, пока чат не восстановлен через RestoreChat или не очищен PurgeDeleted.
+// Приглашения и заявки на вступление удаляются сразу и при восстановлении не возвращаются.
 func (m *MongoStorage) DeleteChat(ctx context.Context, chatID string) error {
     // Преобразуем chatID в ObjectID
     chatObjectID, err := primitive.ObjectIDFromHex(chatID)
     if err != nil {
         log.Printf("Некорректный идентификатор чата: %v", err)
-        return errors.New("некорректный идентификатор чата")
+        return ErrInvalidChatID
     }
 
-    // Удаляем все сообщения, связанные с этим чатом
-    _, err = m.messageColl.DeleteMany(ctx, bson.M{"chat_id": chatObjectID})
+    // Чат удаляется из списка чатов атомарно, поэтому параллельные изменения не теряются
+    var chat Chat
+    err = m.chatColl.FindOneAndDelete(ctx, bson.M{"_id": chatObjectID}).Decode(&chat)
     if err != nil {
-        log.Printf("Ошибка удаления сообщений чата: %v", err)
-        return errors.New("ошибка удаления сообщений чата")
+        if err == mongo.ErrNoDocuments {
+            return ErrChatNotFound
+        }
+        log.Printf("Ошибка удаления чата: %v", err)
+        return err
+    }
+
+    deletedAt := time.Now()
+    trashed := chat
+    trashed.DeletedAt = &deletedAt
+    trashed.ChangedAt = deletedAt
+    if _, err := m.trashColl.InsertOne(ctx, trashed); err != nil {
+        log.Printf("Ошибка переноса чата %s в корзину: %v", chatID, err)
+        // Возвращаем чат на место, чтобы не потерять его
+        if _, restoreErr := m.chatColl.InsertOne(ctx, chat); restoreErr != nil {
+            log.Printf("Ошибка возврата чата %s из корзины: %v", chatID, restoreErr)
+        }
+        return errors.New("ошибка удаления чата")
     }
 
-    // Удаляем сам чат
-    res, err := m.chatColl.DeleteOne(ctx, bson.M{"_id": chatObjectID})
+    // Сообщения удалённого чата скрываются до восстановления или очистки
+    _, err = m.messageColl.UpdateMany(ctx, bson.M{"chat_id": chatObjectID}, bson.M{"$set": bson.M{"chat_deleted_at": deletedAt}})
     if err != nil {
-        log.Printf("Ошибка удаления чата: %v", err)
-        return err
+        log.Printf("Ошибка скрытия сообщений удалённого чата %s: %v", chatID, err)
     }
 
-    // Проверяем, был ли удалён хотя бы один документ
-    if res.DeletedCount == 0 {
-        return errors.New("чат не найден")
+    // Приглашения и заявки на вступление удалённого чата больше не нужны
+    for _, coll := range []*mongo.Collection{m.inviteColl, m.joinColl} {
+        if _, err := coll.DeleteMany(ctx, bson.M{"chat_id": chatObjectID}); err != nil {
+            log.Printf("Ошибка очистки коллекции %s удалённого чата %s: %v", coll.Name(), chatID, err)
+        }
     }
 
+    m.recordRemovals(ctx, chatObjectID, chat.MemberIDs...)
     return nil
 }
 
-var (
-    ErrInvalidChatID   = errors.New("некорректный chatID")
-    ErrChatNotFound    = errors.New("чат не найден")
-    ErrInvalidMessageID = errors.New("некорректный messageID")
-    ErrMessageNotFound = errors.New("сообщение не найдено")
-    ErrForbidden = errors.New("чужое сообщение")
-)
+// RestoreChat возвращает чат из корзины вместе с его сообщениями
+func (m *MongoStorage) RestoreChat(ctx context.Context, chatID string) error {
+    chatObjectID, err := primitive.ObjectIDFromHex(chatID)
+    if err != nil {
+        return ErrInvalidChatID
+    }
+
+    var chat Chat
+    err = m.trashColl.FindOneAndDelete(ctx, bson.M{"_id": chatObjectID}).Decode(&chat)
+    if err != nil {
+        if err == mongo.ErrNoDocuments {
+            return ErrChatNotFound
+        }
+        log.Printf("Ошибка восстановления чата: %v", err)
+        return err
+    }
+
+    trashed := chat
+    chat.DeletedAt = nil
+    chat.ChangedAt = time.Now()
+    if _, err := m.chatColl.InsertOne(ctx, chat); err != nil {
+        log.Printf("Ошибка восстановления чата %s: %v", chatID, err)
+        // Чат остаётся в корзине
+        if _, trashErr := m.trashColl.InsertOne(ctx, trashed); trashErr != nil {
+            log.Printf("Ошибка возврата чата %s в корзину: %v", chatID, trashErr)
+        }
+        return errors.New("ошибка восстановления чата")
+    }
+
+    _, err = m.messageColl.UpdateMany(ctx, bson.M{"chat_id": chatObjectID}, bson.M{"$unset": bson.M{"chat_deleted_at": ""}})
+    if err != nil {
+        log.Printf("Ошибка восстановления сообщений чата %s: %v", chatID, err)
+        return errors.New("ошибка восстановления сообщений чата")
+    }
+    return nil
+}
+
+// GetDeletedChat возвращает чат из корзины
+func (m *MongoStorage) GetDeletedChat(ctx context.Context, chatID string) (*Chat, error) {
+    chatObjectID, err := primitive.ObjectIDFromHex(chatID)
+    if err != nil {
+        return nil, ErrInvalidChatID
+    }
+
+    var chat Chat
+    err = m.trashColl.FindOne(ctx, bson.M{"_id": chatObjectID}).Decode(&chat)
+    if err != nil {
+        if err == mongo.ErrNoDocuments {
+            return nil, ErrChatNotFound
+        }
+        log.Printf("Ошибка получения удалённого чата: %v", err)
+        return nil, errors.New("ошибка получения удалённого чата")
+    }
+    chat.MemberCount = len(chat.MemberIDs)
+    return &chat, nil
+}
+
+// GetDeletedChats возвращает чаты пользователя из корзины, недавно удалённые - первыми
+func (m *MongoStorage) GetDeletedChats(ctx context.Context, userID int32) ([]*Chat, error) {
+    findOptions := options.Find().SetSort(bson.D{{Key: "deleted_at", Value: -1}, {Key: "_id", Value: -1}})
+    cursor, err := m.trashColl.Find(ctx, bson.M{"member_ids": userID}, findOptions)
+    if err != nil {
+        log.Printf("Ошибка получения удалённых чатов: %v", err)
+        return nil, errors.New("ошибка получения удалённых чатов")
+    }
+    defer cursor.Close(ctx)
+
+    chats := []*Chat{}
+    if err := cursor.All(ctx, &chats); err != nil {
+        log.Printf("Ошибка декодирования удалённых чатов: %v", err)
+        return nil, err
+    }
+    for _, chat := range chats {
+        chat.MemberCount = len(chat.MemberIDs)
+    }
+    return chats, nil
+}
+
+// PurgeDeleted окончательно удаляет то, что удалено раньше момента before: чаты
+// из корзины вместе с сообщениями, надгробия сообщений и записи о выходе из чатов.
+// Возвращает очищенные чаты и файлы их сообщений, в том числе если очистка
+// прервалась ошибкой: уже удалённые записи при следующем запуске не найдутся.
+func (m *MongoStorage) PurgeDeleted(ctx context.Context, before time.Time) (*Purged, error) {
+    purged := &Purged{}
+
+    cursor, err := m.trashColl.Find(ctx, bson.M{"deleted_at": bson.M{"$lt": before}})
+    if err != nil {
+        log.Printf("Ошибка получения чатов для очистки: %v", err)
+        return purged, errors.New("ошибка очистки удалённых чатов")
+    }
+    var expired []*Chat
+    if err := cursor.All(ctx, &expired); err != nil {
+        log.Printf("Ошибка декодирования чатов для очистки: %v", err)
+        return purged, err
+    }
+
+    if len(expired) > 0 {
+        chatIDs := make([]primitive.ObjectID, 0, len(expired))
+        for _, chat := range expired {
+            chatIDs = append(chatIDs, chat.ID)
+        }
+
+        // Файлы собираются до удаления сообщений, которые на них ссылаются
+        filter := bson.M{"chat_id": bson.M{"$in": chatIDs}, "attachment": bson.M{"$exists": true}}
+        files, err := m.findMessages(ctx, filter, options.Find().SetProjection(bson.M{"attachment": 1}))
+        if err != nil {
+            return purged, errors.New("ошибка очистки удалённых чатов")
+        }
+
+        // Сначала удаляются сообщения и их ревизии и только затем сами чаты:
+        // прерванная очистка будет продолжена при следующем запуске
+        if _, err := m.messageColl.DeleteMany(ctx, bson.M{"chat_id": bson.M{"$in": chatIDs}}); err != nil {
+            log.Printf("Ошибка очистки сообщений удалённых чатов: %v", err)
+            return purged, errors.New("ошибка очистки удалённых чатов")
+        }
+        for _, message := range files {
+            purged.Attachments = append(purged.Attachments, message.Attachment)
+        }
+        if _, err := m.revisionColl.DeleteMany(ctx, bson.M{"chat_id": bson.M{"$in": chatIDs}}); err != nil {
+            log.Printf("Ошибка очистки ревизий удалённых чатов: %v", err)
+            return purged, errors.New("ошибка очистки удалённых чатов")
+        }
+        if _, err := m.trashColl.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": chatIDs}}); err != nil {
+            log.Printf("Ошибка очистки корзины чатов: %v", err)
+            return purged, errors.New("ошибка очистки удалённых чатов")
+        }
+        purged.Chats = expired
+    }
+
+    if _, err := m.messageColl.DeleteMany(ctx, bson.M{"deleted_at": bson.M{"$lt": before}}); err != nil {
+        log.Printf("Ошибка очистки удалённых сообщений: %v", err)
+        return purged, errors.New("ошибка очистки удалённых сообщений")
+    }
+    if _, err := m.removalColl.DeleteMany(ctx, bson.M{"removed_at": bson.M{"$lt": before}}); err != nil {
+        log.Printf("Ошибка очистки выходов из чатов: %v", err)
+        return purged, errors.New("ошибка очистки выходов из чатов")
+    }
+    return purged, nil
+}
+
+// recordRemovals записывает выход пользователей из чата для синхронизации клиентов.
+// Ошибка только логируется: состав чата к этому моменту уже изменён.
+func (m *MongoStorage) recordRemovals(ctx context.Context, chatID primitive.ObjectID, userIDs ...int32) {
+    if len(userIDs) == 0 {
+        return
+    }
+
+    removedAt := time.Now()
+    removals := make([]interface{}, 0, len(userIDs))
+    for _, userID := range userIDs {
+        removals = append(removals, ChatRemoval{ChatID: chatID, UserID: userID, RemovedAt: removedAt})
+    }
+    if _, err := m.removalColl.InsertMany(ctx, removals); err != nil {
+        log.Printf("Ошибка записи выхода из чата %s: %v", chatID.Hex(), err)
+    }
+}
+
+// SearchMessages ищет сообщения чатов query.ChatIDs по текстовому индексу
+// и упорядочивает их по релевантности (textScore)
+func (m *MongoStorage) SearchMessages(ctx context.Context, query SearchQuery) (*SearchPage, error) {
+    after, err := query.cursor()
+    if err != nil {
+        return nil, err
+    }
+
+    // В запрос к индексу передаются только слова: кавычки и минусы
+    // не должны менять смысл запроса по сравнению с MemoryStorage
+    var words []string
+    for _, t := range tokenize(query.Text) {
+        words = append(words, t.word)
+    }
+    if len(words) == 0 || len(query.ChatIDs) == 0 {
+        return &SearchPage{Results: []*SearchResult{}}, nil
+    }
+
+    match := notDeleted(bson.M{
+        "$text":   bson.M{"$search": strings.Join(words, " "), "$language": "russian"},
+        "chat_id": bson.M{"$in": query.ChatIDs},
+    })
+    if query.ViewerID != 0 {
+        match["hidden_for"] = bson.M{"$ne": query.ViewerID}
+    }
+    if query.SenderID != 0 {
+        match["sender_id"] = query.SenderID
+    }
+    createdAt := bson.M{}
+    if !query.Since.IsZero() {
+        createdAt["$gte"] = query.Since
+    }
+    if !query.Until.IsZero() {
+        createdAt["$lt"] = query.Until
+    }
+    if len(createdAt) > 0 {
+        match["created_at"] = createdAt
+    }
+    if query.Type == "" {
+        match["type"] = bson.M{"$ne": MessageTypeSystem}
+    } else {
+        match["type"] = query.Type
+    }
+
+    pipeline := mongo.Pipeline{
+        {{Key: "$match", Value: match}},
+        {{Key: "$addFields", Value: bson.M{"score": bson.M{"$meta": "textScore"}}}},
+    }
+    if after != nil {
+        pipeline = append(pipeline, bson.D{{Key: "$match", Value: bson.M{"$or": []bson.M{
+            {"score": bson.M{"$lt": after.score}},
+            {"score": after.score, "_id": bson.M{"$lt": after.messageID}},
+        }}}})
+    }
+
+    // Лишний результат показывает, что есть следующая страница
+    limit := query.limit()
+    pipeline = append(pipeline,
+        bson.D{{Key: "$sort", Value: bson.D{{Key: "score", Value: -1}, {Key: "_id", Value: -1}}}},
+        bson.D{{Key: "$limit", Value: limit + 1}},
+    )
+
+    cursor, err := m.messageColl.Aggregate(ctx, pipeline)
+    if err != nil {
+        log.Printf("Ошибка поиска сообщений: %v", err)
+        return nil, errors.New("ошибка поиска сообщений")
+    }
+    defer cursor.Close(ctx)
+
+    var found []struct {
+        Message `bson:",inline"`
+        Score   float64 `bson:"score"`
+    }
+    if err := cursor.All(ctx, &found); err != nil {
+        log.Printf("Ошибка декодирования результатов поиска: %v", err)
+        return nil, err
+    }
+
+    results := make([]*SearchResult, len(found))
+    for i := range found {
+        results[i] = &SearchResult{Message: &found[i].Message, Score: found[i].Score}
+    }
+
+    terms := searchTerms(query.Text)
+    page := newSearchPage(results, nil, limit)
+    for _, result := range page.Results {
+        result.withSnippet(terms)
+    }
+    return page, nil
+}
+
+// GetMessageChanges возвращает порцию сообщений переданных чатов, изменённых после
+// позиции курсора, в порядке изменения. Надгробия удалённых сообщений включаются.
+func (m *MongoStorage) GetMessageChanges(ctx context.Context, chatIDs []primitive.ObjectID, after ChangeCursor, limit int64) (*ChangePage, error) {
+    if len(chatIDs) == 0 {
+        return &ChangePage{Messages: []*Message{}}, nil
+    }
+
+    filter := bson.M{
+        "chat_id": bson.M{"$in": chatIDs},
+        "$or": []bson.M{
+            {"changed_at": bson.M{"$gt": after.ChangedAt}},
+            {"changed_at": after.ChangedAt, "_id": bson.M{"$gt": after.MessageID}},
+        },
+    }
+
+    // Лишнее сообщение показывает, что после порции есть ещё изменения
+    limit = changesLimit(limit)
+    findOptions := options.Find().
+        SetSort(bson.D{{Key: "changed_at", Value: 1}, {Key: "_id", Value: 1}}).
+        SetLimit(limit + 1)
+
+    messages, err := m.findMessages(ctx, filter, findOptions)
+    if err != nil {
+        return nil, err
+    }
+
+    page := &ChangePage{Messages: messages}
+    if int64(len(messages)) > limit {
+        page.Messages, page.HasMore = messages[:limit], true
+    }
+    return page, nil
+}
+
+// GetChatRemovals возвращает выходы пользователя из чатов начиная с момента since
+func (m *MongoStorage) GetChatRemovals(ctx context.Context, userID int32, since time.Time) ([]*ChatRemoval, error) {
+    filter := bson.M{"user_id": userID, "removed_at": bson.M{"$gte": since}}
+    cursor, err := m.removalColl.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "removed_at", Value: 1}}))
+    if err != nil {
+        log.Printf("Ошибка получения выходов из чатов: %v", err)
+        return nil, errors.New("ошибка получения выходов из чатов")
+    }
+    defer cursor.Close(ctx)
+
+    removals := []*ChatRemoval{}
+    if err := cursor.All(ctx, &removals); err != nil {
+        log.Printf("Ошибка декодирования выходов из чатов: %v", err)
+        return nil, err
+    }
+    return removals, nil
+}
+
+// CreateInvite сохраняет новое приглашение в чат
+func (m *MongoStorage) CreateInvite(ctx context.Context, invite *Invite) error {
+    if invite.Token == "" {
+        return ErrInviteNotFound
+    }
+
+    if _, err := m.inviteColl.InsertOne(ctx, invite); err != nil {
+        log.Printf("Ошибка создания приглашения: %v", err)
+        return errors.New("ошибка создания приглашения")
+    }
+
+    return nil
+}
+
+// GetInvite возвращает приглашение по токену
+func (m *MongoStorage) GetInvite(ctx context.Context, token string) (*Invite, error) {
+    var invite Invite
+    err := m.inviteColl.FindOne(ctx, bson.M{"_id": token}).Decode(&invite)
+    if err != nil {
+        if err == mongo.ErrNoDocuments {
+            return nil, ErrInviteNotFound
+        }
+        log.Printf("Ошибка получения приглашения: %v", err)
+        return nil, errors.New("ошибка получения приглашения")
+    }
+
+    return &invite, nil
+}
+
+// GetChatInvites возвращает действующие (не отозванные) приглашения чата от новых к старым
+func (m *MongoStorage) GetChatInvites(ctx context.Context, chatID string) ([]*Invite, error) {
+    chatObjectID, err := primitive.ObjectIDFromHex(chatID)
+    if err != nil {
+        return nil, ErrInvalidChatID
+    }
+
+    findOptions := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+    cursor, err := m.inviteColl.Find(ctx, bson.M{"chat_id": chatObjectID, "revoked": false}, findOptions)
+    if err != nil {
+        log.Printf("Ошибка получения приглашений: %v", err)
+        return nil, errors.New("ошибка получения приглашений")
+    }
+    defer cursor.Close(ctx)
+
+    invites := []*Invite{}
+    if err := cursor.All(ctx, &invites); err != nil {
+        log.Printf("Ошибка декодирования приглашений: %v", err)
+        return nil, err
+    }
+
+    return invites, nil
+}
+
+// RevokeInvite отзывает приглашение. Отозванное приглашение считается ненайденным.
+func (m *MongoStorage) RevokeInvite(ctx context.Context, token string) error {
+    res, err := m.inviteColl.UpdateOne(ctx, bson.M{"_id": token, "revoked": false}, bson.M{"$set": bson.M{"revoked": true}})
+    if err != nil {
+        log.Printf("Ошибка отзыва приглашения: %v", err)
+        return errors.New("ошибка отзыва приглашения")
+    }
+
+    if res.MatchedCount == 0 {
+        return ErrInviteNotFound
+    }
+
+    return nil
+}
+
+// RedeemInvite атомарно засчитывает использование приглашения, если оно
+// не отозвано, не истекло и лимит использований не исчерпан. Условия проверяются
+// тем же запросом, что увеличивает счётчик, поэтому параллельные переходы
+// по ссылке не превысят max_uses.
+func (m *MongoStorage) RedeemInvite(ctx context.Context, token string) (*Invite, error) {
+    now := time.Now()
+    filter := bson.M{
+        "_id":     token,
+        "revoked": false,
+        "$and": bson.A{
+            // Бессрочное или ещё действующее: expires_at > now
+            bson.M{"$or": bson.A{bson.M{"expires_at": nil}, bson.M{"expires_at": bson.M{"$gt": now}}}},
+            // Без ограничения или с запасом: uses < max_uses
+            bson.M{"$or": bson.A{bson.M{"max_uses": 0}, bson.M{"$expr": bson.M{"$lt": bson.A{"$uses", "$max_uses"}}}}},
+        },
+    }
+    opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+    var invite Invite
+    err := m.inviteColl.FindOneAndUpdate(ctx, filter, bson.M{"$inc": bson.M{"uses": 1}}, opts).Decode(&invite)
+    if err == nil {
+        return &invite, nil
+    }
+    if err != mongo.ErrNoDocuments {
+        log.Printf("Ошибка использования приглашения: %v", err)
+        return nil, errors.New("ошибка использования приглашения")
+    }
+
+    // Приглашение не подошло под условия - выясняем причину
+    current, err := m.GetInvite(ctx, token)
+    if err != nil {
+        return nil, err
+    }
+    if err := current.redeemError(now); err != nil {
+        return nil, err
+    }
+    return nil, ErrInviteExhausted
+}
+
+// ReleaseInvite возвращает использование приглашения, засчитанное RedeemInvite,
+// если вступить в чат по нему так и не удалось
+func (m *MongoStorage) ReleaseInvite(ctx context.Context, token string) error {
+    res, err := m.inviteColl.UpdateOne(ctx, bson.M{"_id": token, "uses": bson.M{"$gt": 0}}, bson.M{"$inc": bson.M{"uses": -1}})
+    if err != nil {
+        log.Printf("Ошибка возврата использования приглашения: %v", err)
+        return errors.New("ошибка возврата использования приглашения")
+    }
+
+    if res.MatchedCount == 0 {
+        return ErrInviteNotFound
+    }
+
+    return nil
+}
+
+// SetApprovalRequired включает или выключает одобрение заявок на вступление в чат
+func (m *MongoStorage) SetApprovalRequired(ctx context.Context, chatID string, required bool) error {
+    objID, err := primitive.ObjectIDFromHex(chatID)
+    if err != nil {
+        return ErrInvalidChatID
+    }
+
+    res, err := m.chatColl.UpdateOne(ctx, bson.M{"_id": objID}, bson.M{"$set": bson.M{"approval_required": required, "changed_at": time.Now()}})
+    if err != nil {
+        log.Printf("Ошибка изменения настройки одобрения заявок: %v", err)
+        return errors.New("ошибка изменения настройки одобрения заявок")
+    }
+
+    if res.MatchedCount == 0 {
+        return ErrChatNotFound
+    }
+
+    return nil
+}
+
+// CreateJoinRequest сохраняет заявку пользователя на вступление в чат.
+// Пока заявка не рассмотрена, повторная заявка в тот же чат отклоняется.
+func (m *MongoStorage) CreateJoinRequest(ctx context.Context, chatID string, userID int32, message string) (string, error) {
+    chatObjectID, err := primitive.ObjectIDFromHex(chatID)
+    if err != nil {
+        return "", ErrInvalidChatID
+    }
+
+    if userID == 0 {
+        return "", ErrInvalidUserID
+    }
+
+    request := JoinRequest{
+        ChatID:    chatObjectID,
+        UserID:    userID,
+        Message:   message,
+        Status:    JoinRequestPending,
+        CreatedAt: time.Now(),
+    }
+
+    res, err := m.joinColl.InsertOne(ctx, request)
+    if err != nil {
+        if mongo.IsDuplicateKeyError(err) {
+            return "", ErrJoinRequestExists
+        }
+        log.Printf("Ошибка создания заявки на вступление: %v", err)
+        return "", errors.New("ошибка создания заявки на вступление")
+    }
+
+    return res.InsertedID.(primitive.ObjectID).Hex(), nil
+}
+
+// GetJoinRequest возвращает заявку на вступление по ID
+func (m *MongoStorage) GetJoinRequest(ctx context.Context, requestID string) (*JoinRequest, error) {
+    objID, err := primitive.ObjectIDFromHex(requestID)
+    if err != nil {
+        return nil, ErrInvalidJoinRequestID
+    }
+
+    var request JoinRequest
+    err = m.joinColl.FindOne(ctx, bson.M{"_id": objID}).Decode(&request)
+    if err != nil {
+        if err == mongo.ErrNoDocuments {
+            return nil, ErrJoinRequestNotFound
+        }
+        log.Printf("Ошибка получения заявки на вступление: %v", err)
+        return nil, errors.New("ошибка получения заявки на вступление")
+    }
+
+    return &request, nil
+}
+
+// GetJoinRequests возвращает заявки чата в порядке подачи.
+// Пустой status означает заявки в любом состоянии.
+func (m *MongoStorage) GetJoinRequests(ctx context.Context, chatID string, status string) ([]*JoinRequest, error) {
+    chatObjectID, err := primitive.ObjectIDFromHex(chatID)
+    if err != nil {
+        return nil, ErrInvalidChatID
+    }
+
+    filter := bson.M{"chat_id": chatObjectID}
+    if status != "" {
+        if !IsValidJoinRequestStatus(status) {
+            return nil, ErrInvalidJoinRequestStatus
+        }
+        filter["status"] = status
+    }
+
+    findOptions := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}})
+    cursor, err := m.joinColl.Find(ctx, filter, findOptions)
+    if err != nil {
+        log.Printf("Ошибка получения заявок на вступление: %v", err)
+        return nil, errors.New("ошибка получения заявок на вступление")
+    }
+    defer cursor.Close(ctx)
+
+    requests := []*JoinRequest{}
+    if err := cursor.All(ctx, &requests); err != nil {
+        log.Printf("Ошибка декодирования заявок на вступление: %v", err)
+        return nil, err
+    }
+
+    return requests, nil
+}
+
+// DecideJoinRequest фиксирует решение по нерассмотренной заявке.
+// Решение принимается один раз: повторная попытка возвращает ErrJoinRequestDecided.
+func (m *MongoStorage) DecideJoinRequest(ctx context.Context, requestID string, status string, deciderID int32) error {
+    objID, err := primitive.ObjectIDFromHex(requestID)
+    if err != nil {
+        return ErrInvalidJoinRequestID
+    }
+
+    if status != JoinRequestApproved && status != JoinRequestRejected {
+        return ErrInvalidJoinRequestStatus
+    }
+
+    update := bson.M{"$set": bson.M{
+        "status":     status,
+        "decided_by": deciderID,
+        "decided_at": time.Now(),
+    }}
+    res, err := m.joinColl.UpdateOne(ctx, bson.M{"_id": objID, "status": JoinRequestPending}, update)
+    if err != nil {
+        log.Printf("Ошибка рассмотрения заявки на вступление: %v", err)
+        return errors.New("ошибка рассмотрения заявки на вступление")
+    }
+
+    if res.MatchedCount == 0 {
+        // Заявка либо не существует, либо уже рассмотрена
+        if _, err := m.GetJoinRequest(ctx, requestID); err != nil {
+            return err
+        }
+        return ErrJoinRequestDecided
+    }
+
+    return nil
+}
+
+// ReopenJoinRequest возвращает на рассмотрение заявку, решение по которой принял deciderID,
+// если исполнить это решение так и не удалось
+func (m *MongoStorage) ReopenJoinRequest(ctx context.Context, requestID string, deciderID int32) error {
+    objID, err := primitive.ObjectIDFromHex(requestID)
+    if err != nil {
+        return ErrInvalidJoinRequestID
+    }
+
+    filter := bson.M{"_id": objID, "status": bson.M{"$ne": JoinRequestPending}, "decided_by": deciderID}
+    update := bson.M{
+        "$set":   bson.M{"status": JoinRequestPending},
+        "$unset": bson.M{"decided_by": "", "decided_at": ""},
+    }
+    res, err := m.joinColl.UpdateOne(ctx, filter, update)
+    if err != nil {
+        // Заявитель успел подать новую заявку
+        if mongo.IsDuplicateKeyError(err) {
+            return ErrJoinRequestExists
+        }
+        log.Printf("Ошибка возврата заявки на вступление на рассмотрение: %v", err)
+        return errors.New("ошибка возврата заявки на вступление на рассмотрение")
+    }
+
+    if res.MatchedCount == 0 {
+        return ErrJoinRequestNotFound
+    }
+
+    return nil
+}
+
+// CreateUploadSession сохраняет новый сеанс загрузки. ID сеанса задаёт вызывающий.
+func (m *MongoStorage) CreateUploadSession(ctx context.Context, session *UploadSession) error {
+    if session.ID.IsZero() {
+        return ErrInvalidUploadID
+    }
+    if session.UserID == 0 {
+        return ErrInvalidUserID
+    }
+
+    doc := *session
+    if doc.Parts == nil {
+        doc.Parts = []UploadPart{}
+    }
+    if _, err := m.uploadColl.InsertOne(ctx, doc); err != nil {
+        log.Printf("Ошибка создания сеанса загрузки: %v", err)
+        return errors.New("ошибка создания сеанса загрузки")
+    }
+    return nil
+}
+
+// GetUploadSession возвращает сеанс загрузки по ID
+func (m *MongoStorage) GetUploadSession(ctx context.Context, uploadID string) (*UploadSession, error) {
+    objID, err := primitive.ObjectIDFromHex(uploadID)
+    if err != nil {
+        return nil, ErrInvalidUploadID
+    }
+
+    var session UploadSession
+    err = m.uploadColl.FindOne(ctx, bson.M{"_id": objID}).Decode(&session)
+    if err != nil {
+        if err == mongo.ErrNoDocuments {
+            return nil, ErrUploadNotFound
+        }
+        log.Printf("Ошибка получения сеанса загрузки: %v", err)
+        return nil, errors.New("ошибка получения сеанса загрузки")
+    }
+
+    return &session, nil
+}
+
+// AppendUploadPart атомарно добавляет к сеансу часть, начинающуюся с позиции offset,
+// и продлевает сеанс до expiresAt. Если к этому времени принято другое число байт,
+// возвращается ErrUploadOffsetMismatch, а если часть не помещается в размер файла -
+// ErrUploadSizeExceeded.
+func (m *MongoStorage) AppendUploadPart(ctx context.Context, uploadID string, offset int64, part UploadPart, expiresAt time.Time) (*UploadSession, error) {
+    objID, err := primitive.ObjectIDFromHex(uploadID)
+    if err != nil {
+        return nil, ErrInvalidUploadID
+    }
+
+    filter := bson.M{
+        "_id":    objID,
+        "offset": offset,
+        "size":   bson.M{"$gte": offset + part.Size},
+    }
+    update := bson.M{
+        "$push": bson.M{"parts": part},
+        "$inc":  bson.M{"offset": part.Size},
+        "$set":  bson.M{"expires_at": expiresAt},
+    }
+
+    var session UploadSession
+    err = m.uploadColl.FindOneAndUpdate(ctx, filter, update, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&session)
+    if err == nil {
+        return &session, nil
+    }
+    if err != mongo.ErrNoDocuments {
+        log.Printf("Ошибка добавления части загрузки: %v", err)
+        return nil, errors.New("ошибка добавления части загрузки")
+    }
+
+    // Объясняем, какое условие не выполнено
+    current, err := m.GetUploadSession(ctx, uploadID)
+    if err != nil {
+        return nil, err
+    }
+    if current.Offset != offset {
+        return nil, ErrUploadOffsetMismatch
+    }
+    return nil, ErrUploadSizeExceeded
+}
+
+// DeleteUploadSession удаляет сеанс загрузки и возвращает его последнее состояние,
+// чтобы вызывающий удалил части файла из хранилища файлов
+func (m *MongoStorage) DeleteUploadSession(ctx context.Context, uploadID string) (*UploadSession, error) {
+    objID, err := primitive.ObjectIDFromHex(uploadID)
+    if err != nil {
+        return nil, ErrInvalidUploadID
+    }
+
+    var session UploadSession
+    err = m.uploadColl.FindOneAndDelete(ctx, bson.M{"_id": objID}).Decode(&session)
+    if err != nil {
+        if err == mongo.ErrNoDocuments {
+            return nil, ErrUploadNotFound
+        }
+        log.Printf("Ошибка удаления сеанса загрузки: %v", err)
+        return nil, errors.New("ошибка удаления сеанса загрузки")
+    }
+
+    return &session, nil
+}
+
+// PurgeUploadSessions удаляет сеансы загрузки, срок действия которых истёк до before,
+// и возвращает их, чтобы вызывающий удалил части файлов из хранилища файлов.
+// Сеанс, продлённый во время очистки, не удаляется.
+func (m *MongoStorage) PurgeUploadSessions(ctx context.Context, before time.Time) ([]*UploadSession, error) {
+    purged := []*UploadSession{}
+    for {
+        var session UploadSession
+        err := m.uploadColl.FindOneAndDelete(ctx, bson.M{"expires_at": bson.M{"$lt": before}}).Decode(&session)
+        if err == mongo.ErrNoDocuments {
+            return purged, nil
+        }
+        if err != nil {
+            log.Printf("Ошибка очистки сеансов загрузки: %v", err)
+            return purged, errors.New("ошибка очистки сеансов загрузки")
+        }
+        purged = append(purged, &session)
+    }
+}
+
+var (
+    ErrInvalidChatID   = errors.New("некорректный chatID")
+    ErrChatNotFound    = errors.New("чат не найден")
+    ErrInvalidMessageID = errors.New("некорректный messageID")
+    ErrMessageNotFound = errors.New("сообщение не найдено")
+    ErrForbidden = errors.New("чужое сообщение")
+    ErrInvalidUserID = errors.New("некорректный userID")
+    ErrNotGroupChat = errors.New("чат не найден или не является групповым")
+    ErrReactionExists = errors.New("реакция уже добавлена этим пользователем")
+    ErrNoCreator = errors.New("не указан создатель чата")
+    ErrEmptyUpdate = errors.New("не переданы данные для обновления")
+    ErrEmptyAvatar = errors.New("не указан аватар")
+    ErrEmptyContent = errors.New("содержимое сообщения не может быть пустым")
+    ErrEmptyReaction = errors.New("не указана реакция")
+    ErrInvalidCursor = errors.New("некорректный курсор пагинации")
+    ErrInvalidReply = errors.New("исходное сообщение не найдено в этом чате")
+    ErrInvalidRole = errors.New("некорректная роль участника")
+    ErrNotMember = errors.New("пользователь не является участником чата")
+    ErrInviteNotFound = errors.New("приглашение не найдено или отозвано")
+    ErrInviteExpired = errors.New("срок действия приглашения истёк")
+    ErrInviteExhausted = errors.New("приглашение использовано максимальное число раз")
+    ErrInvalidJoinRequestID = errors.New("некорректный ID заявки на вступление")
+    ErrInvalidJoinRequestStatus = errors.New("некорректное состояние заявки на вступление")
+    ErrJoinRequestNotFound = errors.New("заявка на вступление не найдена")
+    ErrJoinRequestExists = errors.New("заявка на вступление уже подана и ожидает рассмотрения")
+    ErrJoinRequestDecided = errors.New("заявка на вступление уже рассмотрена")
+    ErrDuplicateMessage = errors.New("сообщение с этим ключом идемпотентности уже сохранено")
+    ErrRevisionConflict = errors.New("сообщение уже изменено: ревизия не совпадает с ожидаемой")
+    ErrAlreadyPinned = errors.New("сообщение уже закреплено")
+    ErrNotPinned = errors.New("сообщение не закреплено")
+    ErrPinLimitReached = errors.New("достигнут лимит закреплённых сообщений")
+    ErrInvalidUploadID = errors.New("некорректный ID загрузки")
+    ErrUploadNotFound = errors.New("загрузка не найдена")
+    ErrUploadOffsetMismatch = errors.New("позиция части не совпадает с числом принятых байт")
+    ErrUploadSizeExceeded = errors.New("часть выходит за объявленный размер файла")
+)
 
 // Типы данных
 type Reaction struct {
@@ -636,14 +2426,39 @@ type Reaction struct {
 }
 
 type Message struct {
-    ID        primitive.ObjectID `bson:"_id,omitempty"`
-    ChatID    primitive.ObjectID `bson:"chat_id"`
-    SenderID  int32              `bson:"sender_id"`
-    Content   string             `bson:"content"`
-    Type      string             `bson:"type"`
-    Reactions []Reaction         `bson:"reactions"`
-    Status    string             `bson:"status"`
-    CreatedAt time.Time          `bson:"created_at"`
+    ID         primitive.ObjectID `bson:"_id,omitempty"`
+    ChatID     primitive.ObjectID `bson:"chat_id"`
+    Seq        int64              `bson:"seq,omitempty"` // Порядковый номер сообщения в чате, начиная с 1
+    SenderID   int32              `bson:"sender_id"`
+    Content    string             `bson:"content"`
+    Type       string             `bson:"type"`
+    Reactions  []Reaction         `bson:"reactions"`
+    CreatedAt  time.Time          `bson:"created_at"`
+    ReplyTo    *ReplyTo           `bson:"reply_to,omitempty"`    // Цитата сообщения, на которое дан ответ
+    ReplyCount int32              `bson:"reply_count,omitempty"` // Количество ответов в ветке (у корневого сообщения)
+    Mentions   []int32            `bson:"mentions,omitempty"`    // Упомянутые в сообщении участники чата
+    Views      int64              `bson:"views,omitempty"`       // Число просмотров поста в канале
+    ChangedAt  time.Time          `bson:"changed_at"`            // Время последнего изменения (для синхронизации)
+    DeletedAt  *time.Time         `bson:"deleted_at,omitempty"`  // Время удаления: от сообщения осталось надгробие
+    EditedAt   *time.Time         `bson:"edited_at,omitempty"`   // Время последней правки
+    Revision   int64              `bson:"revision,omitempty"`    // Число правок: 0 у неотредактированного сообщения
+
+    // Пользователи, удалившие сообщение для себя. Другим участникам не показывается.
+    HiddenFor []int32 `bson:"hidden_for,omitempty" json:"-"`
+    // Время удаления чата: пока чат в корзине, его сообщения не выдаются
+    ChatDeletedAt *time.Time `bson:"chat_deleted_at,omitempty" json:"-"`
+
+    // Ключ идемпотентности отправителя. Другим участникам не показывается.
+    ClientMessageID string `bson:"client_message_id,omitempty" json:"-"`
+
+    Notice *Notice `bson:"notice,omitempty"` // Содержание системного уведомления
+
+    Attachment *Attachment `bson:"attachment,omitempty"` // Приложенный файл (у сообщений типа MessageTypeFile)
+
+    // Заполняются сервисом по отметкам участников чата и в базе не хранятся
+    Status      string  `bson:"-"` // общий статус доставки: sent, delivered или read
+    DeliveredTo []int32 `bson:"-"` // получатели, которым сообщение доставлено
+    ReadBy      []int32 `bson:"-"` // получатели, прочитавшие сообщение
 }
 
 type MessageReaction struct {
@@ -660,28 +2475,104 @@ type Chat struct {
     MemberIDs   []int32            `bson:"member_ids"`
     IsGroup     bool               `bson:"is_group"`
     CreatedAt   time.Time          `bson:"created_at"`
+
+    // Отметки участников: ID пользователя -> номер последнего доставленного/прочитанного сообщения
+    DeliveredMarkers map[string]int64 `bson:"delivered_seqs,omitempty"`
+    ReadMarkers      map[string]int64 `bson:"read_seqs,omitempty"`
+
+    LastMessage    *LastMessage `bson:"last_message,omitempty"` // Превью последнего сообщения
+    LastActivityAt time.Time    `bson:"last_activity_at"`       // Время последнего сообщения или создания чата
+
+    // Роли участников группового чата: ID пользователя -> роль (owner или admin)
+    Roles map[string]string `bson:"roles,omitempty"`
+
+    // Вступление в группу только через одобренную администратором заявку
+    ApprovalRequired bool `bson:"approval_required,omitempty"`
+
+    // Канал: групповой чат, в котором публикуют только администраторы
+    IsChannel bool `bson:"is_channel,omitempty"`
+
+    // Число участников (подписчиков канала), заполняется при чтении чата
+    MemberCount int `bson:"-"`
+
+    // Номер последнего сообщения чата: номера сообщений идут подряд, начиная с 1
+    LastSeq int64 `bson:"last_seq,omitempty"`
+
+    // Время последнего изменения чата, его состава или отметок (для синхронизации)
+    ChangedAt time.Time `bson:"changed_at"`
+
+    // Время удаления: задано только у чатов в корзине
+    DeletedAt *time.Time `bson:"deleted_at,omitempty"`
+
+    // Закреплённые сообщения в порядке закрепления, не больше MaxPinnedMessages
+    PinnedMessages []PinnedMessage `bson:"pinned_messages,omitempty"`
 }
 
 // Storage - интерфейс для работы с хранилищем.
+// Реализации: MongoStorage и MemoryStorage.
 type Storage interface {
     CreateChat(ctx context.Context, name string, memberIDs []int32, isGroup bool, description string, creatorID int32) (string, error)
     UpdateChatInfo(ctx context.Context, chatID string, name string, description string) error
     SetChatAvatar(ctx context.Context, chatID string, avatar string) error
     AddParticipant(ctx context.Context, chatID string, userID int32) error
     RemoveParticipant(ctx context.Context, chatID string, userID int32) error
-    SaveMessage(ctx context.Context, chatID string, senderID int32, content string, messageType string) (string, error)
-    EditMessage(ctx context.Context, messageID string, userID int32, newContent string) error
+    SaveMessage(ctx context.Context, msg NewMessage) (string, error)
+    EditMessage(ctx context.Context, messageID string, userID int32, newContent string, expectedRevision int64) error
+    GetMessageRevisions(ctx context.Context, messageID string) ([]*MessageRevision, error)
+    PinMessage(ctx context.Context, chatID string, messageID string, userID int32) error
+    UnpinMessage(ctx context.Context, chatID string, messageID string) error
+    SearchMessages(ctx context.Context, query SearchQuery) (*SearchPage, error)
     DeleteMessage(ctx context.Context, messageID string, userID int32) error
+    HideMessage(ctx context.Context, messageID string, userID int32) error
     GetUserChats(ctx context.Context, userID int32) ([]*Chat, error)
+    CountUnread(ctx context.Context, userID int32, chats []*Chat) (map[primitive.ObjectID]UnreadCount, error)
     GetMessages(ctx context.Context, chatID string) ([]*Message, error)
-    GetMessagesWithPagination(ctx context.Context, chatID string, limit int64, skip int64) ([]*Message, error)
+    GetMessagesPage(ctx context.Context, chatID string, query MessagePageQuery) (*MessagePage, error)
+    GetThreadMessages(ctx context.Context, rootID string, query MessagePageQuery) (*MessagePage, error)
     GetChatParticipants(ctx context.Context, chatID string) ([]int32, error)
+    GetChatParticipantsPage(ctx context.Context, chatID string, query ParticipantPageQuery) (*ParticipantPage, error)
+    CreateChannel(ctx context.Context, name string, memberIDs []int32, description string, creatorID int32) (string, error)
+    MarkViewed(ctx context.Context, chatID string, userID int32, messageID string) error
     LeaveChat(ctx context.Context, chatID string, userID int32) error
+    SetMemberRole(ctx context.Context, chatID string, userID int32, role string) error
+    TransferOwnership(ctx context.Context, chatID string, fromID int32, toID int32) error
+    CreateInvite(ctx context.Context, invite *Invite) error
+    GetInvite(ctx context.Context, token string) (*Invite, error)
+    GetChatInvites(ctx context.Context, chatID string) ([]*Invite, error)
+    RevokeInvite(ctx context.Context, token string) error
+    RedeemInvite(ctx context.Context, token string) (*Invite, error)
+    ReleaseInvite(ctx context.Context, token string) error
+    SetApprovalRequired(ctx context.Context, chatID string, required bool) error
+    CreateJoinRequest(ctx context.Context, chatID string, userID int32, message string) (string, error)
+    GetJoinRequest(ctx context.Context, requestID string) (*JoinRequest, error)
+    GetJoinRequests(ctx context.Context, chatID string, status string) ([]*JoinRequest, error)
+    DecideJoinRequest(ctx context.Context, requestID string, status string, deciderID int32) error
+    ReopenJoinRequest(ctx context.Context, requestID string, deciderID int32) error
     AddReaction(ctx context.Context, messageID string, reaction string, userID int32) error
     RemoveReaction(ctx context.Context, messageID string, reaction string, userID int32) error
-    UpdateMessageStatus(ctx context.Context, messageID string, status string) error
+    MarkDelivered(ctx context.Context, chatID string, userID int32, messageID string) error
+    MarkRead(ctx context.Context, chatID string, userID int32, messageID string) error
     GetChatByID(ctx context.Context, chatID string) (*Chat, error)
+    GetMessageByID(ctx context.Context, messageID string) (*Message, error)
+    GetMessageByClientID(ctx context.Context, senderID int32, clientMessageID string) (*Message, error)
+    GetMessageByAttachmentKey(ctx context.Context, key string) (*Message, error)
     DeleteChat(ctx context.Context, chatID string) error
+    RestoreChat(ctx context.Context, chatID string) error
+    GetDeletedChat(ctx context.Context, chatID string) (*Chat, error)
+    GetDeletedChats(ctx context.Context, userID int32) ([]*Chat, error)
+    PurgeDeleted(ctx context.Context, before time.Time) (*Purged, error)
+    GetMessageChanges(ctx context.Context, chatIDs []primitive.ObjectID, after ChangeCursor, limit int64) (*ChangePage, error)
+    GetChatRemovals(ctx context.Context, userID int32, since time.Time) ([]*ChatRemoval, error)
+    CreateUploadSession(ctx context.Context, session *UploadSession) error
+    GetUploadSession(ctx context.Context, uploadID string) (*UploadSession, error)
+    AppendUploadPart(ctx context.Context, uploadID string, offset int64, part UploadPart, expiresAt time.Time) (*UploadSession, error)
+    DeleteUploadSession(ctx context.Context, uploadID string) (*UploadSession, error)
+    PurgeUploadSessions(ctx context.Context, before time.Time) ([]*UploadSession, error)
     Close(ctx context.Context) error
     Ping(ctx context.Context) error
-}
\ No newline at end of file
+}
+
+var (
+    _ Storage = (*MongoStorage)(nil)
+    _ Storage = (*MemoryStorage)(nil)
+)
\ No newline at end of file
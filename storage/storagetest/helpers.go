@@ -0,0 +1,201 @@
+package storagetest
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"chat-service/storage"
+)
+
+func createChat(t *testing.T, s storage.Storage, name string, memberIDs []int32, isGroup bool, creatorID int32) string {
+	t.Helper()
+	chatID, err := s.CreateChat(context.Background(), name, memberIDs, isGroup, "описание", creatorID)
+	if err != nil {
+		t.Fatalf("CreateChat: %v", err)
+	}
+	return chatID
+}
+
+func getChat(t *testing.T, s storage.Storage, chatID string) *storage.Chat {
+	t.Helper()
+	chat, err := s.GetChatByID(context.Background(), chatID)
+	if err != nil {
+		t.Fatalf("GetChatByID(%s): %v", chatID, err)
+	}
+	return chat
+}
+
+func userChats(t *testing.T, s storage.Storage, userID int32) []*storage.Chat {
+	t.Helper()
+	chats, err := s.GetUserChats(context.Background(), userID)
+	if err != nil {
+		t.Fatalf("GetUserChats(%d): %v", userID, err)
+	}
+	return chats
+}
+
+func participants(t *testing.T, s storage.Storage, chatID string) []int32 {
+	t.Helper()
+	memberIDs, err := s.GetChatParticipants(context.Background(), chatID)
+	if err != nil {
+		t.Fatalf("GetChatParticipants(%s): %v", chatID, err)
+	}
+	return memberIDs
+}
+
+func saveMessage(t *testing.T, s storage.Storage, chatID string, senderID int32, content string) string {
+	t.Helper()
+	messageID, err := s.SaveMessage(context.Background(), storage.NewMessage{
+		ChatID:   chatID,
+		SenderID: senderID,
+		Content:  content,
+		Type:     "text",
+	})
+	if err != nil {
+		t.Fatalf("SaveMessage: %v", err)
+	}
+	return messageID
+}
+
+func saveReply(t *testing.T, s storage.Storage, chatID string, senderID int32, content string, replyToID string) string {
+	t.Helper()
+	messageID, err := s.SaveMessage(context.Background(), storage.NewMessage{
+		ChatID:    chatID,
+		SenderID:  senderID,
+		Content:   content,
+		Type:      "text",
+		ReplyToID: replyToID,
+	})
+	if err != nil {
+		t.Fatalf("SaveMessage (ответ на %s): %v", replyToID, err)
+	}
+	return messageID
+}
+
+func getMessage(t *testing.T, s storage.Storage, messageID string) *storage.Message {
+	t.Helper()
+	message, err := s.GetMessageByID(context.Background(), messageID)
+	if err != nil {
+		t.Fatalf("GetMessageByID(%s): %v", messageID, err)
+	}
+	return message
+}
+
+func messages(t *testing.T, s storage.Storage, chatID string) []*storage.Message {
+	t.Helper()
+	messages, err := s.GetMessages(context.Background(), chatID)
+	if err != nil {
+		t.Fatalf("GetMessages(%s): %v", chatID, err)
+	}
+	return messages
+}
+
+func search(t *testing.T, s storage.Storage, query storage.SearchQuery) *storage.SearchPage {
+	t.Helper()
+	page, err := s.SearchMessages(context.Background(), query)
+	if err != nil {
+		t.Fatalf("SearchMessages(%q): %v", query.Text, err)
+	}
+	return page
+}
+
+// assertMembers сравнивает составы участников без учёта порядка
+func assertMembers(t *testing.T, got []int32, want ...int32) {
+	t.Helper()
+	g := append([]int32{}, got...)
+	w := append([]int32{}, want...)
+	sort.Slice(g, func(i, j int) bool { return g[i] < g[j] })
+	sort.Slice(w, func(i, j int) bool { return w[i] < w[j] })
+
+	if len(g) != len(w) {
+		t.Errorf("участники = %v, ожидались %v", got, want)
+		return
+	}
+	for i := range g {
+		if g[i] != w[i] {
+			t.Errorf("участники = %v, ожидались %v", got, want)
+			return
+		}
+	}
+}
+
+// assertChatIDs сравнивает наборы чатов без учёта порядка
+func assertChatIDs(t *testing.T, chats []*storage.Chat, want ...string) {
+	t.Helper()
+	got := make(map[string]bool, len(chats))
+	for _, chat := range chats {
+		got[chat.ID.Hex()] = true
+	}
+
+	if len(got) != len(chats) || len(got) != len(want) {
+		t.Errorf("получено %d чатов, ожидалось %d", len(chats), len(want))
+		return
+	}
+	for _, id := range want {
+		if !got[id] {
+			t.Errorf("чат %s отсутствует в списке", id)
+		}
+	}
+}
+
+// assertMessageIDs сравнивает сообщения с ожидаемыми ID с учётом порядка
+func assertMessageIDs(t *testing.T, messages []*storage.Message, want ...string) {
+	t.Helper()
+	got := make([]string, 0, len(messages))
+	for _, message := range messages {
+		got = append(got, message.ID.Hex())
+	}
+
+	if len(got) != len(want) {
+		t.Errorf("сообщения = %v, ожидались %v", got, want)
+		return
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Errorf("сообщения = %v, ожидались %v", got, want)
+			return
+		}
+	}
+}
+
+// assertReactions сравнивает реакции без учёта порядка
+func assertReactions(t *testing.T, got []storage.Reaction, want ...storage.Reaction) {
+	t.Helper()
+	counts := make(map[storage.Reaction]int, len(want))
+	for _, r := range want {
+		counts[r]++
+	}
+	for _, r := range got {
+		counts[r]--
+	}
+
+	for _, n := range counts {
+		if n != 0 {
+			t.Errorf("реакции = %v, ожидались %v", got, want)
+			return
+		}
+	}
+}
+
+// assertFound сравнивает найденные сообщения с ожидаемыми ID без учёта порядка
+func assertFound(t *testing.T, results []*storage.SearchResult, want ...string) {
+	t.Helper()
+	got := make(map[string]bool, len(results))
+	ids := make([]string, 0, len(results))
+	for _, result := range results {
+		got[result.Message.ID.Hex()] = true
+		ids = append(ids, result.Message.ID.Hex())
+	}
+
+	if len(got) != len(results) || len(got) != len(want) {
+		t.Errorf("найдены сообщения %v, ожидались %v", ids, want)
+		return
+	}
+	for _, id := range want {
+		if !got[id] {
+			t.Errorf("найдены сообщения %v, ожидались %v", ids, want)
+			return
+		}
+	}
+}
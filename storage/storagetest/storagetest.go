@@ -0,0 +1,1952 @@
+// Package storagetest содержит набор тестов, проверяющий, что реализация
+// storage.Storage ведёт себя так же, как MongoStorage.
+//
+// Использование в тестах своей реализации:
+//
+//	func TestMyStorage(t *testing.T) {
+//		storagetest.Run(t, func(t *testing.T) storage.Storage {
+//			return mystorage.New(...)
+//		})
+//	}
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"chat-service/storage"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Factory создаёт новое пустое хранилище. Вызывается отдельно для каждого теста,
+// освобождение ресурсов можно зарегистрировать через t.Cleanup.
+type Factory func(t *testing.T) storage.Storage
+
+// Несуществующие, но корректные по формату идентификаторы
+const (
+	missingChatID    = "000000000000000000000000"
+	missingMessageID = "000000000000000000000000"
+)
+
+// Run запускает все проверки контракта storage.Storage
+func Run(t *testing.T, newStorage Factory) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, s storage.Storage)
+	}{
+		{"ChatCRUD", testChatCRUD},
+		{"InvalidIDs", testInvalidIDs},
+		{"UserChats", testUserChats},
+		{"GroupParticipants", testGroupParticipants},
+		{"DirectParticipants", testDirectParticipants},
+		{"LeaveChat", testLeaveChat},
+		{"MessageOwnership", testMessageOwnership},
+		{"Reactions", testReactions},
+		{"Receipts", testReceipts},
+		{"ChatList", testChatList},
+		{"Roles", testRoles},
+		{"Invites", testInvites},
+		{"JoinRequests", testJoinRequests},
+		{"Channels", testChannels},
+		{"IdempotentSave", testIdempotentSave},
+		{"Pagination", testPagination},
+		{"Sequence", testSequence},
+		{"Replies", testReplies},
+		{"Sync", testSync},
+		{"HideMessage", testHideMessage},
+		{"Revisions", testRevisions},
+		{"Pins", testPins},
+		{"Search", testSearch},
+		{"Attachments", testAttachments},
+		{"UploadSessions", testUploadSessions},
+		{"ChatTrash", testChatTrash},
+		{"DeleteChatCascade", testDeleteChatCascade},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newStorage(t)
+			t.Cleanup(func() {
+				if err := s.Close(context.Background()); err != nil {
+					t.Errorf("Close: %v", err)
+				}
+			})
+			tt.fn(t, s)
+		})
+	}
+}
+
+func testChatCRUD(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	if _, err := s.CreateChat(ctx, "без создателя", []int32{1, 2}, true, "", 0); !errors.Is(err, storage.ErrNoCreator) {
+		t.Errorf("CreateChat без создателя: ожидалась ErrNoCreator, получено %v", err)
+	}
+
+	chatID := createChat(t, s, "Команда", []int32{2, 3, 1}, true, 1)
+
+	chat := getChat(t, s, chatID)
+	if chat.ID.Hex() != chatID {
+		t.Errorf("ID = %s, ожидался %s", chat.ID.Hex(), chatID)
+	}
+	if chat.Name != "Команда" || chat.Description != "описание" {
+		t.Errorf("Name/Description = %q/%q", chat.Name, chat.Description)
+	}
+	if chat.CreatorID != 1 || !chat.IsGroup {
+		t.Errorf("CreatorID = %d, IsGroup = %v", chat.CreatorID, chat.IsGroup)
+	}
+	assertMembers(t, chat.MemberIDs, 1, 2, 3)
+	if chat.CreatedAt.IsZero() {
+		t.Error("CreatedAt не заполнено")
+	}
+
+	// Пустые поля при обновлении не затирают сохранённые значения
+	if err := s.UpdateChatInfo(ctx, chatID, "Новое имя", ""); err != nil {
+		t.Fatalf("UpdateChatInfo: %v", err)
+	}
+	chat = getChat(t, s, chatID)
+	if chat.Name != "Новое имя" || chat.Description != "описание" {
+		t.Errorf("после UpdateChatInfo Name/Description = %q/%q", chat.Name, chat.Description)
+	}
+	if err := s.UpdateChatInfo(ctx, chatID, "", "новое описание"); err != nil {
+		t.Fatalf("UpdateChatInfo: %v", err)
+	}
+	if chat = getChat(t, s, chatID); chat.Description != "новое описание" {
+		t.Errorf("Description = %q", chat.Description)
+	}
+	if err := s.UpdateChatInfo(ctx, chatID, "", ""); !errors.Is(err, storage.ErrEmptyUpdate) {
+		t.Errorf("UpdateChatInfo без данных: ожидалась ErrEmptyUpdate, получено %v", err)
+	}
+	if err := s.UpdateChatInfo(ctx, missingChatID, "имя", ""); !errors.Is(err, storage.ErrChatNotFound) {
+		t.Errorf("UpdateChatInfo несуществующего чата: ожидалась ErrChatNotFound, получено %v", err)
+	}
+
+	if err := s.SetChatAvatar(ctx, chatID, "/uploads/avatar.png"); err != nil {
+		t.Fatalf("SetChatAvatar: %v", err)
+	}
+	if chat = getChat(t, s, chatID); chat.Avatar != "/uploads/avatar.png" {
+		t.Errorf("Avatar = %q", chat.Avatar)
+	}
+	if err := s.SetChatAvatar(ctx, chatID, ""); !errors.Is(err, storage.ErrEmptyAvatar) {
+		t.Errorf("SetChatAvatar с пустым аватаром: ожидалась ErrEmptyAvatar, получено %v", err)
+	}
+	if err := s.SetChatAvatar(ctx, missingChatID, "/a.png"); !errors.Is(err, storage.ErrChatNotFound) {
+		t.Errorf("SetChatAvatar несуществующего чата: ожидалась ErrChatNotFound, получено %v", err)
+	}
+
+	// Изменение возвращённого значения не должно затрагивать хранилище
+	chat.MemberIDs[0] = 100
+	chat.Name = "изменено снаружи"
+	if chat = getChat(t, s, chatID); chat.Name != "Новое имя" {
+		t.Errorf("хранилище вернуло ссылку на внутренние данные: Name = %q", chat.Name)
+	}
+	assertMembers(t, chat.MemberIDs, 1, 2, 3)
+
+	if err := s.DeleteChat(ctx, chatID); err != nil {
+		t.Fatalf("DeleteChat: %v", err)
+	}
+	if _, err := s.GetChatByID(ctx, chatID); !errors.Is(err, storage.ErrChatNotFound) {
+		t.Errorf("GetChatByID удалённого чата: ожидалась ErrChatNotFound, получено %v", err)
+	}
+	if err := s.DeleteChat(ctx, chatID); !errors.Is(err, storage.ErrChatNotFound) {
+		t.Errorf("повторный DeleteChat: ожидалась ErrChatNotFound, получено %v", err)
+	}
+}
+
+func testInvalidIDs(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	for _, id := range []string{"", "123", "not-an-object-id-at-all!"} {
+		chatErrors := map[string]error{
+			"UpdateChatInfo":      s.UpdateChatInfo(ctx, id, "имя", ""),
+			"SetChatAvatar":       s.SetChatAvatar(ctx, id, "/a.png"),
+			"AddParticipant":      s.AddParticipant(ctx, id, 1),
+			"RemoveParticipant":   s.RemoveParticipant(ctx, id, 1),
+			"LeaveChat":           s.LeaveChat(ctx, id, 1),
+			"DeleteChat":          s.DeleteChat(ctx, id),
+			"MarkDelivered":       s.MarkDelivered(ctx, id, 1, missingMessageID),
+			"MarkRead":            s.MarkRead(ctx, id, 1, missingMessageID),
+			"SetApprovalRequired": s.SetApprovalRequired(ctx, id, true),
+			"PinMessage":          s.PinMessage(ctx, id, missingMessageID, 1),
+			"UnpinMessage":        s.UnpinMessage(ctx, id, missingMessageID),
+		}
+		_, chatErrors["GetChatByID"] = s.GetChatByID(ctx, id)
+		_, chatErrors["SaveMessage"] = s.SaveMessage(ctx, storage.NewMessage{ChatID: id, SenderID: 1, Content: "текст", Type: "text"})
+		_, chatErrors["GetMessages"] = s.GetMessages(ctx, id)
+		_, chatErrors["GetMessagesPage"] = s.GetMessagesPage(ctx, id, storage.MessagePageQuery{})
+		_, chatErrors["GetChatParticipants"] = s.GetChatParticipants(ctx, id)
+		_, chatErrors["CreateJoinRequest"] = s.CreateJoinRequest(ctx, id, 1, "")
+		_, chatErrors["GetJoinRequests"] = s.GetJoinRequests(ctx, id, "")
+		for method, err := range chatErrors {
+			if !errors.Is(err, storage.ErrInvalidChatID) {
+				t.Errorf("%s(%q): ожидалась ErrInvalidChatID, получено %v", method, id, err)
+			}
+		}
+
+		messageErrors := map[string]error{
+			"EditMessage":    s.EditMessage(ctx, id, 1, "текст", 0),
+			"DeleteMessage":  s.DeleteMessage(ctx, id, 1),
+			"AddReaction":    s.AddReaction(ctx, id, "👍", 1),
+			"RemoveReaction": s.RemoveReaction(ctx, id, "👍", 1),
+			"MarkDelivered":  s.MarkDelivered(ctx, missingChatID, 1, id),
+			"MarkRead":       s.MarkRead(ctx, missingChatID, 1, id),
+			"PinMessage":     s.PinMessage(ctx, missingChatID, id, 1),
+			"UnpinMessage":   s.UnpinMessage(ctx, missingChatID, id),
+		}
+		_, messageErrors["GetMessageByID"] = s.GetMessageByID(ctx, id)
+		_, messageErrors["GetMessageRevisions"] = s.GetMessageRevisions(ctx, id)
+		for method, err := range messageErrors {
+			if !errors.Is(err, storage.ErrInvalidMessageID) {
+				t.Errorf("%s(%q): ожидалась ErrInvalidMessageID, получено %v", method, id, err)
+			}
+		}
+	}
+
+	if _, err := s.GetChatByID(ctx, missingChatID); !errors.Is(err, storage.ErrChatNotFound) {
+		t.Errorf("GetChatByID: ожидалась ErrChatNotFound, получено %v", err)
+	}
+	if _, err := s.GetChatParticipants(ctx, missingChatID); !errors.Is(err, storage.ErrChatNotFound) {
+		t.Errorf("GetChatParticipants: ожидалась ErrChatNotFound, получено %v", err)
+	}
+	if _, err := s.GetMessageByID(ctx, missingMessageID); !errors.Is(err, storage.ErrMessageNotFound) {
+		t.Errorf("GetMessageByID: ожидалась ErrMessageNotFound, получено %v", err)
+	}
+}
+
+func testUserChats(t *testing.T, s storage.Storage) {
+	first := createChat(t, s, "первый", []int32{1, 2}, true, 1)
+	second := createChat(t, s, "второй", []int32{2, 3}, false, 2)
+	// Создатель, которого нет в списке участников, чат не видит
+	third := createChat(t, s, "третий", []int32{3, 4}, true, 1)
+
+	assertChatIDs(t, userChats(t, s, 1), first)
+	assertChatIDs(t, userChats(t, s, 2), first, second)
+	assertChatIDs(t, userChats(t, s, 3), second, third)
+	assertChatIDs(t, userChats(t, s, 5))
+
+	// Покинувший чат создатель тоже
+	if err := s.LeaveChat(context.Background(), second, 2); err != nil {
+		t.Fatalf("LeaveChat: %v", err)
+	}
+	assertChatIDs(t, userChats(t, s, 2), first)
+}
+
+func testGroupParticipants(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	chatID := createChat(t, s, "группа", []int32{1, 2}, true, 1)
+
+	if err := s.AddParticipant(ctx, chatID, 3); err != nil {
+		t.Fatalf("AddParticipant: %v", err)
+	}
+	// Повторное добавление не создаёт дубликат
+	if err := s.AddParticipant(ctx, chatID, 3); err != nil {
+		t.Fatalf("повторный AddParticipant: %v", err)
+	}
+	assertMembers(t, participants(t, s, chatID), 1, 2, 3)
+
+	if err := s.AddParticipant(ctx, chatID, 0); !errors.Is(err, storage.ErrInvalidUserID) {
+		t.Errorf("AddParticipant(0): ожидалась ErrInvalidUserID, получено %v", err)
+	}
+	if err := s.AddParticipant(ctx, missingChatID, 3); !errors.Is(err, storage.ErrNotGroupChat) {
+		t.Errorf("AddParticipant в несуществующий чат: ожидалась ErrNotGroupChat, получено %v", err)
+	}
+
+	if err := s.RemoveParticipant(ctx, chatID, 2); err != nil {
+		t.Fatalf("RemoveParticipant: %v", err)
+	}
+	// Удаление отсутствующего участника не является ошибкой
+	if err := s.RemoveParticipant(ctx, chatID, 42); err != nil {
+		t.Fatalf("RemoveParticipant отсутствующего участника: %v", err)
+	}
+	assertMembers(t, participants(t, s, chatID), 1, 3)
+
+	if err := s.RemoveParticipant(ctx, chatID, 0); !errors.Is(err, storage.ErrInvalidUserID) {
+		t.Errorf("RemoveParticipant(0): ожидалась ErrInvalidUserID, получено %v", err)
+	}
+	if err := s.RemoveParticipant(ctx, missingChatID, 1); !errors.Is(err, storage.ErrNotGroupChat) {
+		t.Errorf("RemoveParticipant из несуществующего чата: ожидалась ErrNotGroupChat, получено %v", err)
+	}
+}
+
+func testDirectParticipants(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	chatID := createChat(t, s, "личный", []int32{2, 1}, false, 1)
+
+	// Состав личного чата изменить нельзя
+	if err := s.AddParticipant(ctx, chatID, 3); !errors.Is(err, storage.ErrNotGroupChat) {
+		t.Errorf("AddParticipant в личный чат: ожидалась ErrNotGroupChat, получено %v", err)
+	}
+	if err := s.RemoveParticipant(ctx, chatID, 2); !errors.Is(err, storage.ErrNotGroupChat) {
+		t.Errorf("RemoveParticipant из личного чата: ожидалась ErrNotGroupChat, получено %v", err)
+	}
+	assertMembers(t, participants(t, s, chatID), 1, 2)
+}
+
+func testLeaveChat(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	group := createChat(t, s, "группа", []int32{1, 2, 3}, true, 1)
+	direct := createChat(t, s, "личный", []int32{1, 2}, false, 1)
+
+	// Покинуть можно и групповой, и личный чат
+	if err := s.LeaveChat(ctx, group, 2); err != nil {
+		t.Fatalf("LeaveChat из группы: %v", err)
+	}
+	assertMembers(t, participants(t, s, group), 1, 3)
+
+	if err := s.LeaveChat(ctx, direct, 2); err != nil {
+		t.Fatalf("LeaveChat из личного чата: %v", err)
+	}
+	assertMembers(t, participants(t, s, direct), 1)
+
+	if err := s.LeaveChat(ctx, group, 0); !errors.Is(err, storage.ErrInvalidUserID) {
+		t.Errorf("LeaveChat(0): ожидалась ErrInvalidUserID, получено %v", err)
+	}
+	if err := s.LeaveChat(ctx, missingChatID, 1); !errors.Is(err, storage.ErrChatNotFound) {
+		t.Errorf("LeaveChat из несуществующего чата: ожидалась ErrChatNotFound, получено %v", err)
+	}
+}
+
+func testMessageOwnership(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	chatID := createChat(t, s, "группа", []int32{1, 2}, true, 1)
+	messageID := saveMessage(t, s, chatID, 1, "привет")
+
+	message := getMessage(t, s, messageID)
+	if message.ChatID.Hex() != chatID || message.SenderID != 1 {
+		t.Errorf("ChatID/SenderID = %s/%d", message.ChatID.Hex(), message.SenderID)
+	}
+	if message.Content != "привет" || message.Type != "text" {
+		t.Errorf("Content/Type = %q/%q", message.Content, message.Type)
+	}
+	if message.CreatedAt.IsZero() {
+		t.Error("CreatedAt не заполнено")
+	}
+
+	// Редактировать может только автор
+	if err := s.EditMessage(ctx, messageID, 2, "чужая правка", 0); !errors.Is(err, storage.ErrForbidden) {
+		t.Errorf("EditMessage чужого сообщения: ожидалась ErrForbidden, получено %v", err)
+	}
+	if err := s.EditMessage(ctx, messageID, 1, "   ", 0); !errors.Is(err, storage.ErrEmptyContent) {
+		t.Errorf("EditMessage с пустым текстом: ожидалась ErrEmptyContent, получено %v", err)
+	}
+	if err := s.EditMessage(ctx, missingMessageID, 1, "текст", 0); !errors.Is(err, storage.ErrMessageNotFound) {
+		t.Errorf("EditMessage несуществующего сообщения: ожидалась ErrMessageNotFound, получено %v", err)
+	}
+	if err := s.EditMessage(ctx, messageID, 1, "исправлено", 0); err != nil {
+		t.Fatalf("EditMessage: %v", err)
+	}
+	if message = getMessage(t, s, messageID); message.Content != "исправлено" {
+		t.Errorf("Content после EditMessage = %q", message.Content)
+	}
+
+	// Удалить может только автор, чужое сообщение считается ненайденным
+	if err := s.DeleteMessage(ctx, messageID, 2); !errors.Is(err, storage.ErrMessageNotFound) {
+		t.Errorf("DeleteMessage чужого сообщения: ожидалась ErrMessageNotFound, получено %v", err)
+	}
+	getMessage(t, s, messageID)
+
+	if err := s.DeleteMessage(ctx, messageID, 1); err != nil {
+		t.Fatalf("DeleteMessage: %v", err)
+	}
+	if _, err := s.GetMessageByID(ctx, messageID); !errors.Is(err, storage.ErrMessageNotFound) {
+		t.Errorf("GetMessageByID удалённого сообщения: ожидалась ErrMessageNotFound, получено %v", err)
+	}
+	if err := s.DeleteMessage(ctx, messageID, 1); !errors.Is(err, storage.ErrMessageNotFound) {
+		t.Errorf("повторный DeleteMessage: ожидалась ErrMessageNotFound, получено %v", err)
+	}
+	if messages := messages(t, s, chatID); len(messages) != 0 {
+		t.Errorf("после удаления в истории осталось %d сообщений", len(messages))
+	}
+}
+
+func testReactions(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	chatID := createChat(t, s, "группа", []int32{1, 2}, true, 1)
+	messageID := saveMessage(t, s, chatID, 1, "привет")
+
+	if err := s.AddReaction(ctx, messageID, "👍", 1); err != nil {
+		t.Fatalf("AddReaction: %v", err)
+	}
+	// Одна и та же реакция одного пользователя не дублируется
+	if err := s.AddReaction(ctx, messageID, "👍", 1); !errors.Is(err, storage.ErrReactionExists) {
+		t.Errorf("повторный AddReaction: ожидалась ErrReactionExists, получено %v", err)
+	}
+	// Другие пользователи и другие реакции допускаются
+	if err := s.AddReaction(ctx, messageID, "👍", 2); err != nil {
+		t.Fatalf("AddReaction другого пользователя: %v", err)
+	}
+	if err := s.AddReaction(ctx, messageID, "🔥", 1); err != nil {
+		t.Fatalf("AddReaction другой реакции: %v", err)
+	}
+	assertReactions(t, getMessage(t, s, messageID).Reactions,
+		storage.Reaction{Reaction: "👍", UserID: 1},
+		storage.Reaction{Reaction: "👍", UserID: 2},
+		storage.Reaction{Reaction: "🔥", UserID: 1},
+	)
+
+	if err := s.AddReaction(ctx, messageID, "", 1); !errors.Is(err, storage.ErrEmptyReaction) {
+		t.Errorf("AddReaction без реакции: ожидалась ErrEmptyReaction, получено %v", err)
+	}
+	if err := s.AddReaction(ctx, missingMessageID, "👍", 1); !errors.Is(err, storage.ErrMessageNotFound) {
+		t.Errorf("AddReaction к несуществующему сообщению: ожидалась ErrMessageNotFound, получено %v", err)
+	}
+
+	// Удаляется только реакция указанного пользователя
+	if err := s.RemoveReaction(ctx, messageID, "👍", 1); err != nil {
+		t.Fatalf("RemoveReaction: %v", err)
+	}
+	assertReactions(t, getMessage(t, s, messageID).Reactions,
+		storage.Reaction{Reaction: "👍", UserID: 2},
+		storage.Reaction{Reaction: "🔥", UserID: 1},
+	)
+
+	// После удаления реакцию можно поставить снова
+	if err := s.AddReaction(ctx, messageID, "👍", 1); err != nil {
+		t.Fatalf("AddReaction после удаления: %v", err)
+	}
+
+	if err := s.RemoveReaction(ctx, messageID, "", 1); !errors.Is(err, storage.ErrEmptyReaction) {
+		t.Errorf("RemoveReaction без реакции: ожидалась ErrEmptyReaction, получено %v", err)
+	}
+	if err := s.RemoveReaction(ctx, missingMessageID, "👍", 1); !errors.Is(err, storage.ErrMessageNotFound) {
+		t.Errorf("RemoveReaction с несуществующего сообщения: ожидалась ErrMessageNotFound, получено %v", err)
+	}
+}
+
+func testReceipts(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	chatID := createChat(t, s, "группа", []int32{1, 2, 3}, true, 1)
+	first := saveMessage(t, s, chatID, 1, "первое")
+	second := saveMessage(t, s, chatID, 1, "второе")
+
+	firstSeq, secondSeq := getMessage(t, s, first).Seq, getMessage(t, s, second).Seq
+	if firstSeq <= 0 || secondSeq <= firstSeq {
+		t.Fatalf("номера сообщений должны возрастать: %d, %d", firstSeq, secondSeq)
+	}
+
+	if chat := getChat(t, s, chatID); chat.ReadUpTo(2) != 0 || chat.DeliveredUpTo(2) != 0 {
+		t.Errorf("у нового чата не должно быть отметок: read=%d, delivered=%d", chat.ReadUpTo(2), chat.DeliveredUpTo(2))
+	}
+
+	if err := s.MarkDelivered(ctx, chatID, 2, second); err != nil {
+		t.Fatalf("MarkDelivered: %v", err)
+	}
+	// Прочтение отмечает сообщения и доставленными
+	if err := s.MarkRead(ctx, chatID, 3, first); err != nil {
+		t.Fatalf("MarkRead: %v", err)
+	}
+
+	chat := getChat(t, s, chatID)
+	if got := chat.DeliveredUpTo(2); got != secondSeq {
+		t.Errorf("DeliveredUpTo(2) = %d, ожидалось %d", got, secondSeq)
+	}
+	if got := chat.ReadUpTo(2); got != 0 {
+		t.Errorf("ReadUpTo(2) = %d, ожидалась пустая отметка", got)
+	}
+	if got := chat.ReadUpTo(3); got != firstSeq {
+		t.Errorf("ReadUpTo(3) = %d, ожидалось %d", got, firstSeq)
+	}
+	if got := chat.DeliveredUpTo(3); got != firstSeq {
+		t.Errorf("DeliveredUpTo(3) = %d, ожидалось %d", got, firstSeq)
+	}
+
+	// Отметка не сдвигается назад
+	if err := s.MarkRead(ctx, chatID, 3, second); err != nil {
+		t.Fatalf("MarkRead: %v", err)
+	}
+	if err := s.MarkRead(ctx, chatID, 3, first); err != nil {
+		t.Fatalf("MarkRead: %v", err)
+	}
+	if got := getChat(t, s, chatID).ReadUpTo(3); got != secondSeq {
+		t.Errorf("ReadUpTo(3) после отметки более старого сообщения = %d, ожидалось %d", got, secondSeq)
+	}
+
+	// Сообщение другого чата отметку не сдвигает
+	otherChatID := createChat(t, s, "другая группа", []int32{1, 2}, true, 1)
+	other := saveMessage(t, s, otherChatID, 1, "чужое")
+	if err := s.MarkRead(ctx, chatID, 2, other); !errors.Is(err, storage.ErrMessageNotFound) {
+		t.Errorf("MarkRead сообщения другого чата: ожидалась ErrMessageNotFound, получено %v", err)
+	}
+
+	if err := s.MarkRead(ctx, chatID, 0, first); !errors.Is(err, storage.ErrInvalidUserID) {
+		t.Errorf("MarkRead без пользователя: ожидалась ErrInvalidUserID, получено %v", err)
+	}
+	if err := s.MarkDelivered(ctx, missingChatID, 2, first); !errors.Is(err, storage.ErrChatNotFound) {
+		t.Errorf("MarkDelivered в несуществующем чате: ожидалась ErrChatNotFound, получено %v", err)
+	}
+}
+
+func testChatList(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	older := createChat(t, s, "старый", []int32{1, 2}, true, 1)
+	newer := createChat(t, s, "новый", []int32{1, 2}, true, 1)
+
+	// Без сообщений чаты упорядочены по времени создания
+	chats := userChats(t, s, 1)
+	if len(chats) != 2 || chats[0].ID.Hex() != newer || chats[1].ID.Hex() != older {
+		t.Fatalf("порядок чатов без сообщений: ожидался [%s %s]", newer, older)
+	}
+	if chats[0].LastMessage != nil {
+		t.Errorf("LastMessage чата без сообщений = %+v", chats[0].LastMessage)
+	}
+
+	// Время активности хранится с точностью до миллисекунды
+	time.Sleep(2 * time.Millisecond)
+	first := saveMessage(t, s, older, 2, "первое")
+	if _, err := s.SaveMessage(ctx, storage.NewMessage{ChatID: older, SenderID: 2, Content: "@1 привет", Type: "text", Mentions: []int32{1}}); err != nil {
+		t.Fatalf("SaveMessage с упоминанием: %v", err)
+	}
+	last := saveMessage(t, s, older, 1, "последнее")
+
+	// Чат с новым сообщением поднимается наверх
+	chats = userChats(t, s, 1)
+	if len(chats) != 2 || chats[0].ID.Hex() != older {
+		t.Fatalf("чат %s с новым сообщением должен быть первым", older)
+	}
+	if chats[0].LastMessage == nil || chats[0].LastMessage.MessageID.Hex() != last || chats[0].LastMessage.Snippet != "последнее" {
+		t.Errorf("LastMessage = %+v, ожидалось сообщение %s", chats[0].LastMessage, last)
+	}
+	if chats[0].LastMessage != nil && chats[0].LastMessage.Seq != chats[0].LastSeq {
+		t.Errorf("LastMessage.Seq = %d, ожидалось %d", chats[0].LastMessage.Seq, chats[0].LastSeq)
+	}
+	if chats[0].LastActivityAt.Before(chats[1].LastActivityAt) {
+		t.Errorf("LastActivityAt = %v раньше, чем у чата без сообщений (%v)", chats[0].LastActivityAt, chats[1].LastActivityAt)
+	}
+
+	// Собственные сообщения не считаются непрочитанными
+	unread, err := s.CountUnread(ctx, 1, chats)
+	if err != nil {
+		t.Fatalf("CountUnread: %v", err)
+	}
+	if got := unread[chats[0].ID]; got != (storage.UnreadCount{Messages: 2, Mentions: 1}) {
+		t.Errorf("непрочитанные пользователя 1 = %+v", got)
+	}
+	if got := unread[chats[1].ID]; got != (storage.UnreadCount{}) {
+		t.Errorf("непрочитанные в пустом чате = %+v", got)
+	}
+
+	if err := s.MarkRead(ctx, older, 1, first); err != nil {
+		t.Fatalf("MarkRead: %v", err)
+	}
+	unread, err = s.CountUnread(ctx, 1, userChats(t, s, 1))
+	if err != nil {
+		t.Fatalf("CountUnread: %v", err)
+	}
+	if got := unread[chats[0].ID]; got != (storage.UnreadCount{Messages: 1, Mentions: 1}) {
+		t.Errorf("непрочитанные после отметки прочтения = %+v", got)
+	}
+
+	unread, err = s.CountUnread(ctx, 2, userChats(t, s, 2))
+	if err != nil {
+		t.Fatalf("CountUnread: %v", err)
+	}
+	if got := unread[chats[0].ID]; got != (storage.UnreadCount{Messages: 1}) {
+		t.Errorf("непрочитанные пользователя 2 = %+v", got)
+	}
+
+	// Правка и удаление последнего сообщения обновляют превью
+	if err := s.EditMessage(ctx, last, 1, "исправлено", 0); err != nil {
+		t.Fatalf("EditMessage: %v", err)
+	}
+	if chat := getChat(t, s, older); chat.LastMessage == nil || chat.LastMessage.Snippet != "исправлено" {
+		t.Errorf("LastMessage после правки = %+v", chat.LastMessage)
+	}
+	if err := s.DeleteMessage(ctx, last, 1); err != nil {
+		t.Fatalf("DeleteMessage: %v", err)
+	}
+	if chat := getChat(t, s, older); chat.LastMessage == nil || chat.LastMessage.Snippet != "@1 привет" {
+		t.Errorf("LastMessage после удаления = %+v", chat.LastMessage)
+	}
+	if message := getMessage(t, s, first); len(message.Mentions) != 0 {
+		t.Errorf("Mentions сообщения без упоминаний = %v", message.Mentions)
+	}
+
+	// При параллельной отправке превью остаётся у сообщения с наибольшим номером
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if _, err := s.SaveMessage(ctx, storage.NewMessage{ChatID: newer, SenderID: 1, Content: strconv.Itoa(i), Type: "text"}); err != nil {
+				t.Errorf("SaveMessage: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if chat := getChat(t, s, newer); chat.LastMessage == nil || chat.LastMessage.Seq != chat.LastSeq {
+		t.Errorf("LastMessage после параллельной отправки = %+v, LastSeq = %d", chat.LastMessage, chat.LastSeq)
+	}
+}
+
+func testRoles(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	chatID := createChat(t, s, "группа", []int32{1, 2, 3}, true, 1)
+	directID := createChat(t, s, "личный", []int32{1, 2}, false, 1)
+
+	chat := getChat(t, s, chatID)
+	if chat.RoleOf(1) != storage.RoleOwner || chat.RoleOf(2) != storage.RoleMember || chat.RoleOf(4) != "" {
+		t.Errorf("роли новой группы: 1=%q 2=%q 4=%q", chat.RoleOf(1), chat.RoleOf(2), chat.RoleOf(4))
+	}
+	if direct := getChat(t, s, directID); direct.RoleOf(1) != storage.RoleMember {
+		t.Errorf("роль создателя личного чата = %q", direct.RoleOf(1))
+	}
+
+	if err := s.SetMemberRole(ctx, chatID, 2, storage.RoleAdmin); err != nil {
+		t.Fatalf("SetMemberRole: %v", err)
+	}
+	if role := getChat(t, s, chatID).RoleOf(2); role != storage.RoleAdmin {
+		t.Errorf("роль после повышения = %q", role)
+	}
+	if err := s.SetMemberRole(ctx, chatID, 2, storage.RoleMember); err != nil {
+		t.Fatalf("SetMemberRole: %v", err)
+	}
+	if role := getChat(t, s, chatID).RoleOf(2); role != storage.RoleMember {
+		t.Errorf("роль после понижения = %q", role)
+	}
+
+	if err := s.SetMemberRole(ctx, chatID, 2, storage.RoleOwner); !errors.Is(err, storage.ErrInvalidRole) {
+		t.Errorf("SetMemberRole(owner): ожидалась ErrInvalidRole, получено %v", err)
+	}
+	if err := s.SetMemberRole(ctx, chatID, 4, storage.RoleAdmin); !errors.Is(err, storage.ErrNotMember) {
+		t.Errorf("SetMemberRole не участнику: ожидалась ErrNotMember, получено %v", err)
+	}
+	if err := s.SetMemberRole(ctx, missingChatID, 2, storage.RoleAdmin); !errors.Is(err, storage.ErrChatNotFound) {
+		t.Errorf("SetMemberRole в несуществующем чате: ожидалась ErrChatNotFound, получено %v", err)
+	}
+
+	if err := s.TransferOwnership(ctx, chatID, 1, 3); err != nil {
+		t.Fatalf("TransferOwnership: %v", err)
+	}
+	chat = getChat(t, s, chatID)
+	if chat.RoleOf(3) != storage.RoleOwner || chat.RoleOf(1) != storage.RoleAdmin || chat.OwnerID() != 3 {
+		t.Errorf("роли после передачи владения: 1=%q 3=%q, владелец %d", chat.RoleOf(1), chat.RoleOf(3), chat.OwnerID())
+	}
+	if err := s.TransferOwnership(ctx, chatID, 3, 4); !errors.Is(err, storage.ErrNotMember) {
+		t.Errorf("TransferOwnership не участнику: ожидалась ErrNotMember, получено %v", err)
+	}
+
+	// Выход и исключение снимают роль
+	if err := s.LeaveChat(ctx, chatID, 1); err != nil {
+		t.Fatalf("LeaveChat: %v", err)
+	}
+	if err := s.AddParticipant(ctx, chatID, 1); err != nil {
+		t.Fatalf("AddParticipant: %v", err)
+	}
+	if role := getChat(t, s, chatID).RoleOf(1); role != storage.RoleMember {
+		t.Errorf("роль вернувшегося участника = %q", role)
+	}
+}
+
+func testInvites(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	chatID := createChat(t, s, "группа", []int32{1, 2}, true, 1)
+	chatObjectID, _ := primitive.ObjectIDFromHex(chatID)
+	now := time.Now()
+	past := now.Add(-time.Minute)
+
+	invites := []*storage.Invite{
+		{Token: "limited", ChatID: chatObjectID, CreatorID: 1, CreatedAt: now, MaxUses: 2},
+		{Token: "expired", ChatID: chatObjectID, CreatorID: 1, CreatedAt: now.Add(-time.Hour), ExpiresAt: &past},
+		{Token: "revoked", ChatID: chatObjectID, CreatorID: 1, CreatedAt: now.Add(-2 * time.Hour)},
+	}
+	for _, invite := range invites {
+		if err := s.CreateInvite(ctx, invite); err != nil {
+			t.Fatalf("CreateInvite(%s): %v", invite.Token, err)
+		}
+	}
+
+	if err := s.RevokeInvite(ctx, "revoked"); err != nil {
+		t.Fatalf("RevokeInvite: %v", err)
+	}
+	if err := s.RevokeInvite(ctx, "revoked"); !errors.Is(err, storage.ErrInviteNotFound) {
+		t.Errorf("повторный RevokeInvite: ожидалась ErrInviteNotFound, получено %v", err)
+	}
+
+	list, err := s.GetChatInvites(ctx, chatID)
+	if err != nil {
+		t.Fatalf("GetChatInvites: %v", err)
+	}
+	if len(list) != 2 || list[0].Token != "limited" || list[1].Token != "expired" {
+		t.Errorf("GetChatInvites вернул %d приглашений, ожидались limited и expired от новых к старым", len(list))
+	}
+
+	for i := int32(1); i <= 2; i++ {
+		invite, err := s.RedeemInvite(ctx, "limited")
+		if err != nil {
+			t.Fatalf("RedeemInvite #%d: %v", i, err)
+		}
+		if invite.Uses != i {
+			t.Errorf("после использования #%d Uses = %d", i, invite.Uses)
+		}
+	}
+
+	// Параллельные переходы по ссылке не превышают лимит использований
+	crowd := &storage.Invite{Token: "crowd", ChatID: chatObjectID, CreatorID: 1, CreatedAt: now, MaxUses: 3}
+	if err := s.CreateInvite(ctx, crowd); err != nil {
+		t.Fatalf("CreateInvite(crowd): %v", err)
+	}
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		redeemed int
+	)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := s.RedeemInvite(ctx, "crowd")
+			if err != nil && !errors.Is(err, storage.ErrInviteExhausted) {
+				t.Errorf("параллельный RedeemInvite: %v", err)
+			}
+			if err == nil {
+				mu.Lock()
+				redeemed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if redeemed != 3 {
+		t.Errorf("параллельно засчитано %d использований, ожидалось 3", redeemed)
+	}
+
+	// Возвращённое использование можно засчитать снова
+	if err := s.ReleaseInvite(ctx, "crowd"); err != nil {
+		t.Fatalf("ReleaseInvite: %v", err)
+	}
+	if invite, err := s.RedeemInvite(ctx, "crowd"); err != nil || invite.Uses != 3 {
+		t.Errorf("RedeemInvite после возврата: %+v, %v", invite, err)
+	}
+	if err := s.ReleaseInvite(ctx, "missing"); !errors.Is(err, storage.ErrInviteNotFound) {
+		t.Errorf("ReleaseInvite(missing): ожидалась ErrInviteNotFound, получено %v", err)
+	}
+
+	redeemErrors := map[string]error{
+		"limited": storage.ErrInviteExhausted,
+		"expired": storage.ErrInviteExpired,
+		"revoked": storage.ErrInviteNotFound,
+		"missing": storage.ErrInviteNotFound,
+	}
+	for token, want := range redeemErrors {
+		if _, err := s.RedeemInvite(ctx, token); !errors.Is(err, want) {
+			t.Errorf("RedeemInvite(%s): ожидалась %v, получено %v", token, want, err)
+		}
+	}
+
+	// Удаление чата удаляет и его приглашения
+	if err := s.DeleteChat(ctx, chatID); err != nil {
+		t.Fatalf("DeleteChat: %v", err)
+	}
+	if _, err := s.GetInvite(ctx, "limited"); !errors.Is(err, storage.ErrInviteNotFound) {
+		t.Errorf("GetInvite после удаления чата: ожидалась ErrInviteNotFound, получено %v", err)
+	}
+}
+
+func testJoinRequests(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	chatID := createChat(t, s, "группа", []int32{1, 2}, true, 1)
+
+	if err := s.SetApprovalRequired(ctx, chatID, true); err != nil {
+		t.Fatalf("SetApprovalRequired: %v", err)
+	}
+	if !getChat(t, s, chatID).ApprovalRequired {
+		t.Error("ApprovalRequired не сохранился")
+	}
+	if err := s.SetApprovalRequired(ctx, missingChatID, true); !errors.Is(err, storage.ErrChatNotFound) {
+		t.Errorf("SetApprovalRequired в несуществующем чате: ожидалась ErrChatNotFound, получено %v", err)
+	}
+
+	firstID, err := s.CreateJoinRequest(ctx, chatID, 3, "примите")
+	if err != nil {
+		t.Fatalf("CreateJoinRequest: %v", err)
+	}
+	if _, err := s.CreateJoinRequest(ctx, chatID, 3, ""); !errors.Is(err, storage.ErrJoinRequestExists) {
+		t.Errorf("повторная заявка: ожидалась ErrJoinRequestExists, получено %v", err)
+	}
+	secondID, err := s.CreateJoinRequest(ctx, chatID, 4, "")
+	if err != nil {
+		t.Fatalf("CreateJoinRequest: %v", err)
+	}
+
+	pending, err := s.GetJoinRequests(ctx, chatID, storage.JoinRequestPending)
+	if err != nil {
+		t.Fatalf("GetJoinRequests: %v", err)
+	}
+	if len(pending) != 2 || pending[0].ID.Hex() != firstID || pending[1].ID.Hex() != secondID {
+		t.Errorf("GetJoinRequests вернул %d заявок, ожидались две в порядке подачи", len(pending))
+	}
+
+	if err := s.DecideJoinRequest(ctx, firstID, storage.JoinRequestApproved, 1); err != nil {
+		t.Fatalf("DecideJoinRequest: %v", err)
+	}
+	if err := s.DecideJoinRequest(ctx, firstID, storage.JoinRequestRejected, 1); !errors.Is(err, storage.ErrJoinRequestDecided) {
+		t.Errorf("повторное решение: ожидалась ErrJoinRequestDecided, получено %v", err)
+	}
+	if err := s.DecideJoinRequest(ctx, secondID, storage.JoinRequestPending, 1); !errors.Is(err, storage.ErrInvalidJoinRequestStatus) {
+		t.Errorf("решение pending: ожидалась ErrInvalidJoinRequestStatus, получено %v", err)
+	}
+	if err := s.DecideJoinRequest(ctx, missingChatID, storage.JoinRequestApproved, 1); !errors.Is(err, storage.ErrJoinRequestNotFound) {
+		t.Errorf("решение по несуществующей заявке: ожидалась ErrJoinRequestNotFound, получено %v", err)
+	}
+
+	request, err := s.GetJoinRequest(ctx, firstID)
+	if err != nil {
+		t.Fatalf("GetJoinRequest: %v", err)
+	}
+	if request.Status != storage.JoinRequestApproved || request.DecidedBy != 1 || request.DecidedAt == nil || request.Message != "примите" {
+		t.Errorf("рассмотренная заявка: %+v", request)
+	}
+
+	// Вернуть на рассмотрение можно только собственное решение
+	if err := s.ReopenJoinRequest(ctx, firstID, 2); !errors.Is(err, storage.ErrJoinRequestNotFound) {
+		t.Errorf("возврат чужого решения: ожидалась ErrJoinRequestNotFound, получено %v", err)
+	}
+	if err := s.ReopenJoinRequest(ctx, secondID, 1); !errors.Is(err, storage.ErrJoinRequestNotFound) {
+		t.Errorf("возврат нерассмотренной заявки: ожидалась ErrJoinRequestNotFound, получено %v", err)
+	}
+	if err := s.ReopenJoinRequest(ctx, firstID, 1); err != nil {
+		t.Fatalf("ReopenJoinRequest: %v", err)
+	}
+	request, err = s.GetJoinRequest(ctx, firstID)
+	if err != nil {
+		t.Fatalf("GetJoinRequest: %v", err)
+	}
+	if request.Status != storage.JoinRequestPending || request.DecidedBy != 0 || request.DecidedAt != nil {
+		t.Errorf("заявка после возврата на рассмотрение: %+v", request)
+	}
+	if err := s.DecideJoinRequest(ctx, firstID, storage.JoinRequestApproved, 1); err != nil {
+		t.Fatalf("DecideJoinRequest после возврата: %v", err)
+	}
+
+	// После решения пользователь может подать новую заявку
+	if _, err := s.CreateJoinRequest(ctx, chatID, 3, ""); err != nil {
+		t.Errorf("новая заявка после решения: %v", err)
+	}
+	if err := s.ReopenJoinRequest(ctx, firstID, 1); !errors.Is(err, storage.ErrJoinRequestExists) {
+		t.Errorf("возврат при новой заявке: ожидалась ErrJoinRequestExists, получено %v", err)
+	}
+	all, err := s.GetJoinRequests(ctx, chatID, "")
+	if err != nil {
+		t.Fatalf("GetJoinRequests: %v", err)
+	}
+	if len(all) != 3 {
+		t.Errorf("всего заявок %d, ожидалось 3", len(all))
+	}
+
+	if err := s.DeleteChat(ctx, chatID); err != nil {
+		t.Fatalf("DeleteChat: %v", err)
+	}
+	if _, err := s.GetJoinRequest(ctx, secondID); !errors.Is(err, storage.ErrJoinRequestNotFound) {
+		t.Errorf("GetJoinRequest после удаления чата: ожидалась ErrJoinRequestNotFound, получено %v", err)
+	}
+}
+
+func testChannels(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	subscribers := []int32{2, 3, 4, 5, 6}
+	chatID, err := s.CreateChannel(ctx, "канал", append(subscribers, 1), "новости", 1)
+	if err != nil {
+		t.Fatalf("CreateChannel: %v", err)
+	}
+
+	chat := getChat(t, s, chatID)
+	if !chat.IsChannel || !chat.IsGroup || chat.RoleOf(1) != storage.RoleOwner || chat.MemberCount != 6 {
+		t.Errorf("канал: IsChannel=%v IsGroup=%v владелец=%q MemberCount=%d", chat.IsChannel, chat.IsGroup, chat.RoleOf(1), chat.MemberCount)
+	}
+
+	// Страницы участников в порядке вступления
+	var paged []int32
+	query := storage.ParticipantPageQuery{Limit: 4}
+	for {
+		page, err := s.GetChatParticipantsPage(ctx, chatID, query)
+		if err != nil {
+			t.Fatalf("GetChatParticipantsPage: %v", err)
+		}
+		if page.Total != 6 {
+			t.Errorf("Total = %d, ожидалось 6", page.Total)
+		}
+		paged = append(paged, page.UserIDs...)
+		if page.NextCursor == "" {
+			break
+		}
+		query.Cursor = page.NextCursor
+	}
+	if len(paged) != 6 || paged[0] != 2 || paged[5] != 1 {
+		t.Errorf("участники по страницам: %v", paged)
+	}
+	if _, err := s.GetChatParticipantsPage(ctx, chatID, storage.ParticipantPageQuery{Cursor: "abc"}); !errors.Is(err, storage.ErrInvalidCursor) {
+		t.Errorf("некорректный курсор: ожидалась ErrInvalidCursor, получено %v", err)
+	}
+	if _, err := s.GetChatParticipantsPage(ctx, missingChatID, storage.ParticipantPageQuery{}); !errors.Is(err, storage.ErrChatNotFound) {
+		t.Errorf("несуществующий чат: ожидалась ErrChatNotFound, получено %v", err)
+	}
+
+	first := saveMessage(t, s, chatID, 1, "пост 1")
+	second := saveMessage(t, s, chatID, 1, "пост 2")
+
+	// Просмотр до второго поста засчитывает оба, повторный и более ранний - ничего
+	for _, view := range []struct {
+		userID    int32
+		messageID string
+	}{{2, second}, {2, second}, {2, first}, {3, first}, {1, second}} {
+		if err := s.MarkViewed(ctx, chatID, view.userID, view.messageID); err != nil {
+			t.Fatalf("MarkViewed(%d): %v", view.userID, err)
+		}
+	}
+
+	views := map[string]int64{first: 2, second: 1}
+	for messageID, want := range views {
+		if message := getMessage(t, s, messageID); message.Views != want {
+			t.Errorf("просмотры %s = %d, ожидалось %d", message.Content, message.Views, want)
+		}
+	}
+
+	if marker, want := getChat(t, s, chatID).ReadUpTo(2), getMessage(t, s, second).Seq; marker != want {
+		t.Errorf("отметка прочтения после просмотра = %d, ожидалась %d", marker, want)
+	}
+}
+
+func testIdempotentSave(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	chatID := createChat(t, s, "группа", []int32{1, 2}, true, 1)
+
+	msg := storage.NewMessage{ChatID: chatID, SenderID: 1, Content: "привет", Type: "text", ClientMessageID: "key-1"}
+	originalID, err := s.SaveMessage(ctx, msg)
+	if err != nil {
+		t.Fatalf("SaveMessage: %v", err)
+	}
+
+	// Повтор с тем же ключом возвращает ID исходного сообщения
+	msg.Content = "повтор"
+	if id, err := s.SaveMessage(ctx, msg); !errors.Is(err, storage.ErrDuplicateMessage) || id != originalID {
+		t.Errorf("повтор: ожидались %s и ErrDuplicateMessage, получено %s, %v", originalID, id, err)
+	}
+
+	// Ключ уникален в пределах отправителя, сообщения без ключа не ограничены
+	msg.SenderID = 2
+	if _, err := s.SaveMessage(ctx, msg); err != nil {
+		t.Errorf("тот же ключ у другого отправителя: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		saveMessage(t, s, chatID, 1, "без ключа")
+	}
+
+	message, err := s.GetMessageByClientID(ctx, 1, "key-1")
+	if err != nil {
+		t.Fatalf("GetMessageByClientID: %v", err)
+	}
+	if message.ID.Hex() != originalID || message.Content != "привет" || message.ClientMessageID != "key-1" {
+		t.Errorf("сообщение по ключу: %+v", message)
+	}
+	if _, err := s.GetMessageByClientID(ctx, 1, "key-2"); !errors.Is(err, storage.ErrMessageNotFound) {
+		t.Errorf("неизвестный ключ: ожидалась ErrMessageNotFound, получено %v", err)
+	}
+	if _, err := s.GetMessageByClientID(ctx, 1, ""); !errors.Is(err, storage.ErrMessageNotFound) {
+		t.Errorf("пустой ключ: ожидалась ErrMessageNotFound, получено %v", err)
+	}
+
+	// Надгробие удалённого сообщения сохраняет ключ: повтор не создаёт сообщение заново
+	if err := s.DeleteMessage(ctx, originalID, 1); err != nil {
+		t.Fatalf("DeleteMessage: %v", err)
+	}
+	msg.SenderID = 1
+	if id, err := s.SaveMessage(ctx, msg); !errors.Is(err, storage.ErrDuplicateMessage) || id != originalID {
+		t.Errorf("повтор после удаления: ожидались %s и ErrDuplicateMessage, получено %s, %v", originalID, id, err)
+	}
+	message, err = s.GetMessageByClientID(ctx, 1, "key-1")
+	if err != nil {
+		t.Fatalf("GetMessageByClientID после удаления: %v", err)
+	}
+	if message.ID.Hex() != originalID || message.DeletedAt == nil || message.ClientMessageID != "key-1" {
+		t.Errorf("надгробие по ключу: %+v", message)
+	}
+}
+
+func testPagination(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	chatID := createChat(t, s, "группа", []int32{1, 2}, true, 1)
+	otherID := createChat(t, s, "другая", []int32{1, 2}, true, 1)
+
+	var ids []string
+	for _, content := range []string{"1", "2", "3", "4", "5"} {
+		ids = append(ids, saveMessage(t, s, chatID, 1, content))
+	}
+	saveMessage(t, s, otherID, 1, "чужой чат")
+
+	// История возвращается в порядке отправки и только для указанного чата
+	assertMessageIDs(t, messages(t, s, chatID), ids...)
+
+	// id возвращает ID n-го (с единицы) сообщения
+	id := func(n int) string { return ids[n-1] }
+
+	cases := []struct {
+		name       string
+		query      storage.MessagePageQuery
+		want       []string
+		next, prev string
+	}{
+		{"newest", storage.MessagePageQuery{Limit: 2}, []string{id(5), id(4)}, id(4), ""},
+		{"before", storage.MessagePageQuery{Before: id(4), Limit: 2}, []string{id(3), id(2)}, id(2), id(3)},
+		{"oldest", storage.MessagePageQuery{Before: id(2), Limit: 2}, []string{id(1)}, "", id(1)},
+		{"after", storage.MessagePageQuery{After: id(1), Limit: 2}, []string{id(3), id(2)}, id(2), id(3)},
+		{"after newest", storage.MessagePageQuery{After: id(3), Limit: 10}, []string{id(5), id(4)}, id(4), ""},
+		{"between", storage.MessagePageQuery{After: id(1), Before: id(5), Limit: 10}, []string{id(4), id(3), id(2)}, id(2), id(4)},
+		{"default limit", storage.MessagePageQuery{}, []string{id(5), id(4), id(3), id(2), id(1)}, "", ""},
+		{"empty", storage.MessagePageQuery{Before: id(1)}, nil, "", ""},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			page, err := s.GetMessagesPage(ctx, chatID, c.query)
+			if err != nil {
+				t.Fatalf("GetMessagesPage: %v", err)
+			}
+			assertMessageIDs(t, page.Messages, c.want...)
+			if page.NextCursor != c.next || page.PrevCursor != c.prev {
+				t.Errorf("курсоры next/prev = %q/%q, ожидались %q/%q", page.NextCursor, page.PrevCursor, c.next, c.prev)
+			}
+		})
+	}
+
+	// Страницы не сдвигаются при появлении новых сообщений
+	saveMessage(t, s, chatID, 2, "6")
+	page, err := s.GetMessagesPage(ctx, chatID, storage.MessagePageQuery{Before: id(4), Limit: 2})
+	if err != nil {
+		t.Fatalf("GetMessagesPage: %v", err)
+	}
+	assertMessageIDs(t, page.Messages, id(3), id(2))
+
+	if _, err := s.GetMessagesPage(ctx, chatID, storage.MessagePageQuery{Before: "не-курсор"}); !errors.Is(err, storage.ErrInvalidCursor) {
+		t.Errorf("GetMessagesPage с некорректным курсором: ожидалась ErrInvalidCursor, получено %v", err)
+	}
+	if page, err := s.GetMessagesPage(ctx, missingChatID, storage.MessagePageQuery{}); err != nil {
+		t.Errorf("GetMessagesPage несуществующего чата: %v", err)
+	} else if len(page.Messages) != 0 {
+		t.Errorf("история несуществующего чата содержит %d сообщений", len(page.Messages))
+	}
+
+	if got := messages(t, s, missingChatID); len(got) != 0 {
+		t.Errorf("история несуществующего чата содержит %d сообщений", len(got))
+	}
+}
+
+func testSequence(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	chatID := createChat(t, s, "группа", []int32{1, 2}, true, 1)
+	otherID := createChat(t, s, "другая", []int32{1, 2}, true, 1)
+
+	var ids []string
+	for _, content := range []string{"1", "2", "3", "4", "5"} {
+		ids = append(ids, saveMessage(t, s, chatID, 1, content))
+	}
+	otherFirst := saveMessage(t, s, otherID, 1, "другой чат")
+
+	// Номера идут подряд с единицы, независимо в каждом чате
+	for i, id := range ids {
+		if seq := getMessage(t, s, id).Seq; seq != int64(i+1) {
+			t.Errorf("номер сообщения %d = %d", i+1, seq)
+		}
+	}
+	if seq := getMessage(t, s, otherFirst).Seq; seq != 1 {
+		t.Errorf("номер первого сообщения другого чата = %d", seq)
+	}
+	if last := getChat(t, s, chatID).LastSeq; last != 5 {
+		t.Errorf("LastSeq = %d, ожидалось 5", last)
+	}
+
+	// Повтор с использованным ключом идемпотентности номер не расходует
+	msg := storage.NewMessage{ChatID: chatID, SenderID: 2, Content: "6", Type: "text", ClientMessageID: "key"}
+	for i := 0; i < 2; i++ {
+		if _, err := s.SaveMessage(ctx, msg); err != nil && !errors.Is(err, storage.ErrDuplicateMessage) {
+			t.Fatalf("SaveMessage: %v", err)
+		}
+	}
+	if last := getChat(t, s, chatID).LastSeq; last != 6 {
+		t.Errorf("LastSeq после повтора = %d, ожидалось 6", last)
+	}
+
+	// id возвращает ID сообщения с номером n
+	id := func(n int) string { return ids[n-1] }
+
+	cases := []struct {
+		name  string
+		query storage.MessagePageQuery
+		want  []string
+	}{
+		{"gap", storage.MessagePageQuery{AfterSeq: 1, BeforeSeq: 5}, []string{id(4), id(3), id(2)}},
+		{"after", storage.MessagePageQuery{AfterSeq: 1, Limit: 2}, []string{id(3), id(2)}},
+		{"before", storage.MessagePageQuery{BeforeSeq: 3}, []string{id(2), id(1)}},
+		{"empty", storage.MessagePageQuery{AfterSeq: 6}, nil},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			page, err := s.GetMessagesPage(ctx, chatID, c.query)
+			if err != nil {
+				t.Fatalf("GetMessagesPage: %v", err)
+			}
+			assertMessageIDs(t, page.Messages, c.want...)
+		})
+	}
+
+	page, err := s.GetMessagesPage(ctx, chatID, storage.MessagePageQuery{AfterSeq: 1, Limit: 2})
+	if err != nil {
+		t.Fatalf("GetMessagesPage: %v", err)
+	}
+	// Страница по номерам возвращает курсоры-номера
+	if page.NextCursor != "2" || page.PrevCursor != "3" {
+		t.Errorf("курсоры next/prev = %q/%q, ожидались \"2\"/\"3\"", page.NextCursor, page.PrevCursor)
+	}
+
+	for _, query := range []storage.MessagePageQuery{{AfterSeq: -1}, {BeforeSeq: 3, After: id(1)}} {
+		if _, err := s.GetMessagesPage(ctx, chatID, query); !errors.Is(err, storage.ErrInvalidCursor) {
+			t.Errorf("GetMessagesPage(%+v): ожидалась ErrInvalidCursor, получено %v", query, err)
+		}
+	}
+
+	if _, err := s.SaveMessage(ctx, storage.NewMessage{ChatID: missingChatID, SenderID: 1, Content: "x", Type: "text"}); !errors.Is(err, storage.ErrChatNotFound) {
+		t.Errorf("SaveMessage в несуществующий чат: ожидалась ErrChatNotFound, получено %v", err)
+	}
+}
+
+func testReplies(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	chatID := createChat(t, s, "группа", []int32{1, 2}, true, 1)
+	otherID := createChat(t, s, "другая", []int32{1, 2}, true, 1)
+
+	rootID := saveMessage(t, s, chatID, 1, "корневое сообщение")
+	firstID := saveReply(t, s, chatID, 2, "первый ответ", rootID)
+	// Ответ на ответ попадает в ветку того же корневого сообщения
+	secondID := saveReply(t, s, chatID, 1, "ответ на ответ", firstID)
+
+	reply := getMessage(t, s, secondID)
+	if reply.ReplyTo == nil {
+		t.Fatal("ReplyTo не заполнено")
+	}
+	if reply.ReplyTo.MessageID.Hex() != firstID || reply.ReplyTo.ThreadID.Hex() != rootID {
+		t.Errorf("ReplyTo.MessageID/ThreadID = %s/%s, ожидались %s/%s",
+			reply.ReplyTo.MessageID.Hex(), reply.ReplyTo.ThreadID.Hex(), firstID, rootID)
+	}
+	if reply.ReplyTo.SenderID != 2 || reply.ReplyTo.Snippet != "первый ответ" {
+		t.Errorf("ReplyTo.SenderID/Snippet = %d/%q", reply.ReplyTo.SenderID, reply.ReplyTo.Snippet)
+	}
+	if getMessage(t, s, rootID).ReplyTo != nil {
+		t.Error("у корневого сообщения заполнено ReplyTo")
+	}
+	if count := getMessage(t, s, rootID).ReplyCount; count != 2 {
+		t.Errorf("ReplyCount = %d, ожидалось 2", count)
+	}
+
+	// Длинная цитата обрезается
+	longText := strings.Repeat("я", 500)
+	longID := saveMessage(t, s, chatID, 1, longText)
+	longReply := getMessage(t, s, saveReply(t, s, chatID, 2, "ответ", longID))
+	if snippet := longReply.ReplyTo.Snippet; len([]rune(snippet)) >= 500 || !strings.HasPrefix(longText, strings.TrimSuffix(snippet, "…")) {
+		t.Errorf("цитата длинного сообщения не обрезана: %d символов", len([]rune(snippet)))
+	}
+
+	// Ответ возможен только на сообщение того же чата
+	if _, err := s.SaveMessage(ctx, storage.NewMessage{ChatID: otherID, SenderID: 1, Content: "x", Type: "text", ReplyToID: rootID}); !errors.Is(err, storage.ErrInvalidReply) {
+		t.Errorf("ответ на сообщение другого чата: ожидалась ErrInvalidReply, получено %v", err)
+	}
+	if _, err := s.SaveMessage(ctx, storage.NewMessage{ChatID: chatID, SenderID: 1, Content: "x", Type: "text", ReplyToID: missingMessageID}); !errors.Is(err, storage.ErrInvalidReply) {
+		t.Errorf("ответ на несуществующее сообщение: ожидалась ErrInvalidReply, получено %v", err)
+	}
+	if _, err := s.SaveMessage(ctx, storage.NewMessage{ChatID: chatID, SenderID: 1, Content: "x", Type: "text", ReplyToID: "123"}); !errors.Is(err, storage.ErrInvalidMessageID) {
+		t.Errorf("ответ с некорректным ID: ожидалась ErrInvalidMessageID, получено %v", err)
+	}
+
+	// Ветка содержит только ответы, от новых к старым
+	page, err := s.GetThreadMessages(ctx, rootID, storage.MessagePageQuery{})
+	if err != nil {
+		t.Fatalf("GetThreadMessages: %v", err)
+	}
+	assertMessageIDs(t, page.Messages, secondID, firstID)
+
+	page, err = s.GetThreadMessages(ctx, rootID, storage.MessagePageQuery{Limit: 1})
+	if err != nil {
+		t.Fatalf("GetThreadMessages: %v", err)
+	}
+	assertMessageIDs(t, page.Messages, secondID)
+	if page.NextCursor != secondID || page.PrevCursor != "" {
+		t.Errorf("курсоры next/prev = %q/%q", page.NextCursor, page.PrevCursor)
+	}
+
+	if _, err := s.GetThreadMessages(ctx, "123", storage.MessagePageQuery{}); !errors.Is(err, storage.ErrInvalidMessageID) {
+		t.Errorf("GetThreadMessages с некорректным ID: ожидалась ErrInvalidMessageID, получено %v", err)
+	}
+
+	// Удаление ответа уменьшает счётчик и убирает ответ из ветки
+	if err := s.DeleteMessage(ctx, firstID, 2); err != nil {
+		t.Fatalf("DeleteMessage: %v", err)
+	}
+	if count := getMessage(t, s, rootID).ReplyCount; count != 1 {
+		t.Errorf("ReplyCount после удаления ответа = %d, ожидалось 1", count)
+	}
+	page, err = s.GetThreadMessages(ctx, rootID, storage.MessagePageQuery{})
+	if err != nil {
+		t.Fatalf("GetThreadMessages: %v", err)
+	}
+	assertMessageIDs(t, page.Messages, secondID)
+}
+
+func testSync(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	chatID := createChat(t, s, "группа", []int32{1, 2, 3}, true, 1)
+	otherID := createChat(t, s, "другая", []int32{1, 2}, true, 1)
+	chatIDs := []primitive.ObjectID{getChat(t, s, chatID).ID}
+
+	first := saveMessage(t, s, chatID, 1, "первое")
+	second := saveMessage(t, s, chatID, 2, "второе")
+	third := saveMessage(t, s, chatID, 1, "третье")
+	saveMessage(t, s, otherID, 1, "другой чат")
+
+	// changes возвращает изменения чата после курсора
+	changes := func(after storage.ChangeCursor, limit int64) *storage.ChangePage {
+		t.Helper()
+		page, err := s.GetMessageChanges(ctx, chatIDs, after, limit)
+		if err != nil {
+			t.Fatalf("GetMessageChanges: %v", err)
+		}
+		return page
+	}
+
+	page := changes(storage.ChangeCursor{}, 0)
+	assertMessageIDs(t, page.Messages, first, second, third)
+	if page.HasMore {
+		t.Error("HasMore при получении всех изменений")
+	}
+
+	// Порция ограничена, продолжение - с позиции последнего сообщения
+	page = changes(storage.ChangeCursor{}, 2)
+	assertMessageIDs(t, page.Messages, first, second)
+	if !page.HasMore {
+		t.Error("HasMore не установлен при неполной порции")
+	}
+	last := page.Messages[len(page.Messages)-1]
+	cursor := storage.ChangeCursor{ChangedAt: last.ChangedAt, MessageID: last.ID}
+	assertMessageIDs(t, changes(cursor, 2).Messages, third)
+
+	// Правка, реакция и удаление перемещают сообщение в конец потока изменений
+	cursor = storage.ChangeCursor{ChangedAt: getMessage(t, s, third).ChangedAt, MessageID: getMessage(t, s, third).ID}
+	time.Sleep(2 * time.Millisecond)
+	if err := s.EditMessage(ctx, first, 1, "исправлено", 0); err != nil {
+		t.Fatalf("EditMessage: %v", err)
+	}
+	time.Sleep(2 * time.Millisecond)
+	if err := s.AddReaction(ctx, third, "👍", 2); err != nil {
+		t.Fatalf("AddReaction: %v", err)
+	}
+	time.Sleep(2 * time.Millisecond)
+	if err := s.DeleteMessage(ctx, second, 2); err != nil {
+		t.Fatalf("DeleteMessage: %v", err)
+	}
+	page = changes(cursor, 0)
+	assertMessageIDs(t, page.Messages, first, third, second)
+
+	// От удалённого сообщения остаётся надгробие, в обычном чтении его нет
+	if tombstone := page.Messages[len(page.Messages)-1]; !tombstone.Deleted() || tombstone.Content != "" {
+		t.Errorf("надгробие: DeletedAt = %v, Content = %q", tombstone.DeletedAt, tombstone.Content)
+	}
+	if _, err := s.GetMessageByID(ctx, second); !errors.Is(err, storage.ErrMessageNotFound) {
+		t.Errorf("GetMessageByID удалённого сообщения: ожидалась ErrMessageNotFound, получено %v", err)
+	}
+	assertMessageIDs(t, messages(t, s, chatID), first, third)
+
+	// Изменение чата сдвигает его время изменения
+	before := getChat(t, s, chatID).ChangedAt
+	time.Sleep(2 * time.Millisecond)
+	if err := s.UpdateChatInfo(ctx, chatID, "новое имя", ""); err != nil {
+		t.Fatalf("UpdateChatInfo: %v", err)
+	}
+	if changed := getChat(t, s, chatID).ChangedAt; !changed.After(before) {
+		t.Errorf("ChangedAt после UpdateChatInfo = %v, до = %v", changed, before)
+	}
+
+	// Исключение, выход и удаление чата записываются как выходы из чата
+	since := time.Now().Add(-time.Second)
+	if err := s.RemoveParticipant(ctx, chatID, 3); err != nil {
+		t.Fatalf("RemoveParticipant: %v", err)
+	}
+	if err := s.LeaveChat(ctx, otherID, 2); err != nil {
+		t.Fatalf("LeaveChat: %v", err)
+	}
+	if err := s.DeleteChat(ctx, chatID); err != nil {
+		t.Fatalf("DeleteChat: %v", err)
+	}
+
+	for _, c := range []struct {
+		userID int32
+		want   []string
+	}{
+		{1, []string{chatID}},
+		{2, []string{otherID, chatID}},
+		{3, []string{chatID}},
+	} {
+		removals, err := s.GetChatRemovals(ctx, c.userID, since)
+		if err != nil {
+			t.Fatalf("GetChatRemovals(%d): %v", c.userID, err)
+		}
+		got := make(map[string]bool)
+		for _, removal := range removals {
+			if removal.UserID != c.userID {
+				t.Errorf("GetChatRemovals(%d) вернул запись пользователя %d", c.userID, removal.UserID)
+			}
+			got[removal.ChatID.Hex()] = true
+		}
+		for _, id := range c.want {
+			if !got[id] {
+				t.Errorf("GetChatRemovals(%d): нет записи о чате %s", c.userID, id)
+			}
+		}
+	}
+
+	removals, err := s.GetChatRemovals(ctx, 1, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("GetChatRemovals: %v", err)
+	}
+	if len(removals) != 0 {
+		t.Errorf("GetChatRemovals после since вернул %d записей", len(removals))
+	}
+}
+
+func testHideMessage(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	chatID := createChat(t, s, "группа", []int32{1, 2}, true, 1)
+	first := saveMessage(t, s, chatID, 1, "первое")
+	second := saveMessage(t, s, chatID, 2, "второе")
+	last := getMessage(t, s, second)
+
+	time.Sleep(2 * time.Millisecond)
+	// Повторное удаление для себя не считается ошибкой
+	for i := 0; i < 2; i++ {
+		if err := s.HideMessage(ctx, first, 2); err != nil {
+			t.Fatalf("HideMessage: %v", err)
+		}
+	}
+
+	// Сообщение пропадает только из истории удалившего его пользователя
+	for _, c := range []struct {
+		viewerID int32
+		want     []string
+	}{
+		{0, []string{second, first}},
+		{1, []string{second, first}},
+		{2, []string{second}},
+	} {
+		page, err := s.GetMessagesPage(ctx, chatID, storage.MessagePageQuery{ViewerID: c.viewerID})
+		if err != nil {
+			t.Fatalf("GetMessagesPage(ViewerID=%d): %v", c.viewerID, err)
+		}
+		assertMessageIDs(t, page.Messages, c.want...)
+	}
+
+	message := getMessage(t, s, first)
+	if !message.IsHiddenFor(2) || message.IsHiddenFor(1) || len(message.HiddenFor) != 1 {
+		t.Errorf("HiddenFor = %v, ожидалось [2]", message.HiddenFor)
+	}
+
+	// Удаление для себя попадает в поток изменений
+	chatIDs := []primitive.ObjectID{getChat(t, s, chatID).ID}
+	page, err := s.GetMessageChanges(ctx, chatIDs, storage.ChangeCursor{ChangedAt: last.ChangedAt, MessageID: last.ID}, 0)
+	if err != nil {
+		t.Fatalf("GetMessageChanges: %v", err)
+	}
+	assertMessageIDs(t, page.Messages, first)
+
+	if err := s.HideMessage(ctx, missingMessageID, 1); !errors.Is(err, storage.ErrMessageNotFound) {
+		t.Errorf("HideMessage несуществующего сообщения: ожидалась ErrMessageNotFound, получено %v", err)
+	}
+	if err := s.HideMessage(ctx, "123", 1); !errors.Is(err, storage.ErrInvalidMessageID) {
+		t.Errorf("HideMessage с некорректным ID: ожидалась ErrInvalidMessageID, получено %v", err)
+	}
+	if err := s.HideMessage(ctx, first, 0); !errors.Is(err, storage.ErrInvalidUserID) {
+		t.Errorf("HideMessage(0): ожидалась ErrInvalidUserID, получено %v", err)
+	}
+
+	// Удалённое для всех сообщение скрыть уже нельзя
+	if err := s.DeleteMessage(ctx, second, 2); err != nil {
+		t.Fatalf("DeleteMessage: %v", err)
+	}
+	if err := s.HideMessage(ctx, second, 1); !errors.Is(err, storage.ErrMessageNotFound) {
+		t.Errorf("HideMessage удалённого сообщения: ожидалась ErrMessageNotFound, получено %v", err)
+	}
+}
+
+func testRevisions(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	chatID := createChat(t, s, "группа", []int32{1, 2}, true, 1)
+	messageID := saveMessage(t, s, chatID, 1, "первая версия")
+
+	message := getMessage(t, s, messageID)
+	if message.Revision != 0 || message.EditedAt != nil {
+		t.Errorf("Revision/EditedAt нового сообщения = %d/%v", message.Revision, message.EditedAt)
+	}
+	created := message.CreatedAt
+
+	time.Sleep(2 * time.Millisecond)
+	if err := s.EditMessage(ctx, messageID, 1, "вторая версия", 0); err != nil {
+		t.Fatalf("EditMessage: %v", err)
+	}
+	// Правка по устаревшей ревизии отклоняется и ничего не меняет
+	if err := s.EditMessage(ctx, messageID, 1, "параллельная правка", 0); !errors.Is(err, storage.ErrRevisionConflict) {
+		t.Errorf("EditMessage устаревшей ревизии: ожидалась ErrRevisionConflict, получено %v", err)
+	}
+	if err := s.EditMessage(ctx, messageID, 1, "из будущего", 5); !errors.Is(err, storage.ErrRevisionConflict) {
+		t.Errorf("EditMessage будущей ревизии: ожидалась ErrRevisionConflict, получено %v", err)
+	}
+	// Чужую правку отличаем от конфликта ревизий
+	if err := s.EditMessage(ctx, messageID, 2, "чужая правка", 1); !errors.Is(err, storage.ErrForbidden) {
+		t.Errorf("EditMessage чужого сообщения: ожидалась ErrForbidden, получено %v", err)
+	}
+
+	message = getMessage(t, s, messageID)
+	if message.Content != "вторая версия" || message.Revision != 1 {
+		t.Errorf("Content/Revision = %q/%d, ожидалось \"вторая версия\"/1", message.Content, message.Revision)
+	}
+	if message.EditedAt == nil || !message.EditedAt.After(created) {
+		t.Fatalf("EditedAt = %v, ожидалось время после создания %v", message.EditedAt, created)
+	}
+	firstEdit := *message.EditedAt
+
+	time.Sleep(2 * time.Millisecond)
+	if err := s.EditMessage(ctx, messageID, 1, "третья версия", 1); err != nil {
+		t.Fatalf("EditMessage(1): %v", err)
+	}
+
+	revisions, err := s.GetMessageRevisions(ctx, messageID)
+	if err != nil {
+		t.Fatalf("GetMessageRevisions: %v", err)
+	}
+	if len(revisions) != 2 {
+		t.Fatalf("GetMessageRevisions вернул %d ревизий, ожидалось 2", len(revisions))
+	}
+	for i, want := range []struct {
+		content   string
+		createdAt time.Time
+	}{
+		{"первая версия", created},
+		{"вторая версия", firstEdit},
+	} {
+		r := revisions[i]
+		if r.Revision != int64(i) || r.Content != want.content || r.MessageID != message.ID || r.ChatID != message.ChatID {
+			t.Errorf("ревизия %d = %d/%q, ожидалось %d/%q", i, r.Revision, r.Content, i, want.content)
+		}
+		if !r.CreatedAt.Equal(want.createdAt) {
+			t.Errorf("CreatedAt ревизии %d = %v, ожидалось %v", i, r.CreatedAt, want.createdAt)
+		}
+	}
+	if !revisions[0].ReplacedAt.Equal(firstEdit) {
+		t.Errorf("ReplacedAt первой ревизии = %v, ожидалось %v", revisions[0].ReplacedAt, firstEdit)
+	}
+
+	// Удалённое сообщение теряет и журнал правок
+	if err := s.DeleteMessage(ctx, messageID, 1); err != nil {
+		t.Fatalf("DeleteMessage: %v", err)
+	}
+	if revisions, err := s.GetMessageRevisions(ctx, messageID); err != nil || len(revisions) != 0 {
+		t.Errorf("GetMessageRevisions удалённого сообщения = %d ревизий, %v", len(revisions), err)
+	}
+	if err := s.EditMessage(ctx, messageID, 1, "после удаления", 2); !errors.Is(err, storage.ErrMessageNotFound) {
+		t.Errorf("EditMessage удалённого сообщения: ожидалась ErrMessageNotFound, получено %v", err)
+	}
+}
+
+func testPins(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	chatID := createChat(t, s, "группа", []int32{1, 2}, true, 1)
+	otherID := createChat(t, s, "другая", []int32{1, 2}, true, 1)
+	first := saveMessage(t, s, chatID, 1, "первое")
+	second := saveMessage(t, s, chatID, 2, "второе")
+	foreign := saveMessage(t, s, otherID, 1, "из другого чата")
+
+	before := getChat(t, s, chatID).ChangedAt
+	time.Sleep(2 * time.Millisecond)
+	for _, id := range []string{second, first} {
+		if err := s.PinMessage(ctx, chatID, id, 2); err != nil {
+			t.Fatalf("PinMessage(%s): %v", id, err)
+		}
+	}
+
+	// Закреплённые сообщения приходят вместе с чатом в порядке закрепления
+	chat := getChat(t, s, chatID)
+	if len(chat.PinnedMessages) != 2 {
+		t.Fatalf("PinnedMessages = %d, ожидалось 2", len(chat.PinnedMessages))
+	}
+	if pin := chat.PinnedMessages[0]; pin.MessageID.Hex() != second || pin.PinnedBy != 2 || pin.PinnedAt.IsZero() {
+		t.Errorf("первое закрепление = %+v, ожидалось сообщение %s от пользователя 2", pin, second)
+	}
+	if chat.PinnedMessages[1].MessageID.Hex() != first {
+		t.Errorf("второе закрепление = %s, ожидалось %s", chat.PinnedMessages[1].MessageID.Hex(), first)
+	}
+	if !chat.ChangedAt.After(before) {
+		t.Error("ChangedAt чата не изменился после закрепления")
+	}
+
+	if err := s.PinMessage(ctx, chatID, first, 1); !errors.Is(err, storage.ErrAlreadyPinned) {
+		t.Errorf("повторный PinMessage: ожидалась ErrAlreadyPinned, получено %v", err)
+	}
+	if err := s.PinMessage(ctx, chatID, foreign, 1); !errors.Is(err, storage.ErrMessageNotFound) {
+		t.Errorf("PinMessage сообщения другого чата: ожидалась ErrMessageNotFound, получено %v", err)
+	}
+	if err := s.PinMessage(ctx, chatID, missingMessageID, 1); !errors.Is(err, storage.ErrMessageNotFound) {
+		t.Errorf("PinMessage несуществующего сообщения: ожидалась ErrMessageNotFound, получено %v", err)
+	}
+	if err := s.PinMessage(ctx, chatID, first, 0); !errors.Is(err, storage.ErrInvalidUserID) {
+		t.Errorf("PinMessage(0): ожидалась ErrInvalidUserID, получено %v", err)
+	}
+
+	if err := s.UnpinMessage(ctx, chatID, second); err != nil {
+		t.Fatalf("UnpinMessage: %v", err)
+	}
+	if chat := getChat(t, s, chatID); len(chat.PinnedMessages) != 1 || chat.IsPinned(getMessage(t, s, second).ID) {
+		t.Errorf("после UnpinMessage закреплено %d сообщений", len(chat.PinnedMessages))
+	}
+	if err := s.UnpinMessage(ctx, chatID, second); !errors.Is(err, storage.ErrNotPinned) {
+		t.Errorf("повторный UnpinMessage: ожидалась ErrNotPinned, получено %v", err)
+	}
+	if err := s.UnpinMessage(ctx, missingChatID, first); !errors.Is(err, storage.ErrChatNotFound) {
+		t.Errorf("UnpinMessage в несуществующем чате: ожидалась ErrChatNotFound, получено %v", err)
+	}
+
+	// Удалённое для всех сообщение открепляется
+	if err := s.DeleteMessage(ctx, first, 1); err != nil {
+		t.Fatalf("DeleteMessage: %v", err)
+	}
+	if chat := getChat(t, s, chatID); len(chat.PinnedMessages) != 0 {
+		t.Errorf("после удаления сообщения закреплено %d сообщений", len(chat.PinnedMessages))
+	}
+	if err := s.PinMessage(ctx, chatID, first, 1); !errors.Is(err, storage.ErrMessageNotFound) {
+		t.Errorf("PinMessage удалённого сообщения: ожидалась ErrMessageNotFound, получено %v", err)
+	}
+
+	// Число закреплённых сообщений ограничено
+	for i := 0; i < storage.MaxPinnedMessages; i++ {
+		id := saveMessage(t, s, otherID, 1, "сообщение")
+		if err := s.PinMessage(ctx, otherID, id, 1); err != nil {
+			t.Fatalf("PinMessage #%d: %v", i+1, err)
+		}
+	}
+	extra := saveMessage(t, s, otherID, 1, "лишнее")
+	if err := s.PinMessage(ctx, otherID, extra, 1); !errors.Is(err, storage.ErrPinLimitReached) {
+		t.Errorf("PinMessage сверх лимита: ожидалась ErrPinLimitReached, получено %v", err)
+	}
+
+	// Содержание системного уведомления сохраняется вместе с сообщением
+	noticeID, err := s.SaveMessage(ctx, storage.NewMessage{
+		ChatID:   chatID,
+		SenderID: 2,
+		Content:  "Сообщение закреплено",
+		Type:     storage.MessageTypeSystem,
+		Notice:   &storage.Notice{Action: storage.NoticeMessagePinned, MessageID: getMessage(t, s, second).ID},
+	})
+	if err != nil {
+		t.Fatalf("SaveMessage уведомления: %v", err)
+	}
+	notice := getMessage(t, s, noticeID).Notice
+	if notice == nil || notice.Action != storage.NoticeMessagePinned || notice.MessageID.Hex() != second {
+		t.Errorf("Notice = %+v, ожидалось закрепление сообщения %s", notice, second)
+	}
+}
+
+func testSearch(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	chatID := createChat(t, s, "команда", []int32{1, 2}, true, 1)
+	otherID := createChat(t, s, "соседи", []int32{1, 3}, true, 1)
+	strangerID := createChat(t, s, "чужой", []int32{3, 4}, true, 3)
+
+	meeting := saveMessage(t, s, chatID, 1, "Встреча завтра в офисе")
+	moved := saveMessage(t, s, chatID, 2, "Перенесли встречу на пятницу, встречи важны")
+	saveMessage(t, s, chatID, 1, "Купить молоко")
+	time.Sleep(2 * time.Millisecond)
+	cancelled := saveMessage(t, s, otherID, 3, "Встреча отменена")
+	saveMessage(t, s, strangerID, 3, "Встреча в чужом чате")
+	if _, err := s.SaveMessage(ctx, storage.NewMessage{ChatID: chatID, SenderID: 1, Content: "Встреча закреплена", Type: storage.MessageTypeSystem}); err != nil {
+		t.Fatalf("SaveMessage уведомления: %v", err)
+	}
+
+	chats := []primitive.ObjectID{getChat(t, s, chatID).ID, getChat(t, s, otherID).ID}
+	query := func(text string) storage.SearchQuery {
+		return storage.SearchQuery{Text: text, ChatIDs: chats}
+	}
+
+	// Слова находятся в любой форме и только в переданных чатах; системные уведомления не ищутся
+	page := search(t, s, query("встречи"))
+	assertFound(t, page.Results, meeting, moved, cancelled)
+	if page.NextCursor != "" {
+		t.Errorf("NextCursor = %q на последней странице", page.NextCursor)
+	}
+
+	// Сообщение со всеми словами запроса релевантнее сообщений с одним из них
+	page = search(t, s, query("встреча пятница"))
+	if len(page.Results) != 3 || page.Results[0].Message.ID.Hex() != moved {
+		t.Errorf("первый результат по запросу из двух слов - не %s", moved)
+	}
+	for i := 1; i < len(page.Results); i++ {
+		if page.Results[i].Score > page.Results[i-1].Score {
+			t.Errorf("результаты не упорядочены по релевантности: %v > %v", page.Results[i].Score, page.Results[i-1].Score)
+		}
+	}
+
+	// Найденные слова подсвечиваются во фрагменте сообщения
+	page = search(t, s, storage.SearchQuery{Text: "встреча", ChatIDs: chats, SenderID: 2})
+	if len(page.Results) != 1 {
+		t.Fatalf("найдено %d сообщений пользователя 2, ожидалось 1", len(page.Results))
+	}
+	result := page.Results[0]
+	if result.Snippet != "Перенесли встречу на пятницу, встречи важны" {
+		t.Errorf("Snippet = %q", result.Snippet)
+	}
+	var highlighted []string
+	for _, h := range result.Highlights {
+		highlighted = append(highlighted, string([]rune(result.Snippet)[h.Start:h.End]))
+	}
+	if strings.Join(highlighted, ",") != "встречу,встречи" {
+		t.Errorf("подсвечены %v, ожидались [встречу встречи]", highlighted)
+	}
+
+	// Фильтры по чату, типу и времени отправки
+	assertFound(t, search(t, s, storage.SearchQuery{Text: "встреча", ChatIDs: chats[1:]}).Results, cancelled)
+	assertFound(t, search(t, s, storage.SearchQuery{Text: "встреча", ChatIDs: chats, Type: "file"}).Results)
+	assertFound(t, search(t, s, storage.SearchQuery{Text: "встреча", ChatIDs: chats, Type: storage.MessageTypeSystem}).Results, messages(t, s, chatID)[3].ID.Hex())
+	created := getMessage(t, s, cancelled).CreatedAt
+	assertFound(t, search(t, s, storage.SearchQuery{Text: "встреча", ChatIDs: chats, Since: created}).Results, cancelled)
+	assertFound(t, search(t, s, storage.SearchQuery{Text: "встреча", ChatIDs: chats, Until: created}).Results, meeting, moved)
+
+	// Служебные слова не ищутся
+	assertFound(t, search(t, s, query("и в на")).Results)
+
+	// Постраничная выдача возвращает каждый результат ровно один раз
+	var all []*storage.SearchResult
+	cursor := ""
+	for i := 0; i < 4; i++ {
+		page := search(t, s, storage.SearchQuery{Text: "встреча", ChatIDs: chats, Cursor: cursor, Limit: 1})
+		all = append(all, page.Results...)
+		if cursor = page.NextCursor; cursor == "" {
+			break
+		}
+	}
+	assertFound(t, all, meeting, moved, cancelled)
+
+	if _, err := s.SearchMessages(ctx, storage.SearchQuery{Text: "встреча", ChatIDs: chats, Cursor: "не курсор"}); !errors.Is(err, storage.ErrInvalidCursor) {
+		t.Errorf("SearchMessages с некорректным курсором: ожидалась ErrInvalidCursor, получено %v", err)
+	}
+
+	// Удалённые для себя и для всех сообщения не находятся
+	if err := s.HideMessage(ctx, meeting, 2); err != nil {
+		t.Fatalf("HideMessage: %v", err)
+	}
+	if err := s.DeleteMessage(ctx, cancelled, 3); err != nil {
+		t.Fatalf("DeleteMessage: %v", err)
+	}
+	assertFound(t, search(t, s, storage.SearchQuery{Text: "встреча", ChatIDs: chats, ViewerID: 2}).Results, moved)
+	assertFound(t, search(t, s, storage.SearchQuery{Text: "встреча", ChatIDs: chats, ViewerID: 1}).Results, meeting, moved)
+}
+
+func testAttachments(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	chatID := createChat(t, s, "группа", []int32{1, 2}, true, 1)
+	saveMessage(t, s, chatID, 1, "без файла")
+
+	attachment := &storage.Attachment{Key: "files/report.pdf", Filename: "Отчёт.pdf", ContentType: "application/pdf", Size: 1024}
+	messageID, err := s.SaveMessage(ctx, storage.NewMessage{
+		ChatID:     chatID,
+		SenderID:   1,
+		Content:    "/uploads/files/report.pdf",
+		Type:       storage.MessageTypeFile,
+		Attachment: attachment,
+	})
+	if err != nil {
+		t.Fatalf("SaveMessage: %v", err)
+	}
+
+	// Сведения о файле сохраняются вместе с сообщением и не зависят от переданной структуры
+	attachment.Filename = "изменено.pdf"
+	message, err := s.GetMessageByAttachmentKey(ctx, "files/report.pdf")
+	if err != nil {
+		t.Fatalf("GetMessageByAttachmentKey: %v", err)
+	}
+	want := storage.Attachment{Key: "files/report.pdf", Filename: "Отчёт.pdf", ContentType: "application/pdf", Size: 1024}
+	if message.ID.Hex() != messageID || message.Attachment == nil || *message.Attachment != want {
+		t.Fatalf("GetMessageByAttachmentKey = %s %+v, ожидалось %s %+v", message.ID.Hex(), message.Attachment, messageID, want)
+	}
+	if got := getMessage(t, s, messageID).Attachment; got == nil || *got != want {
+		t.Fatalf("GetMessageByID: Attachment = %+v, ожидалось %+v", got, want)
+	}
+
+	for _, key := range []string{"", "files/missing.pdf", "files/report"} {
+		if _, err := s.GetMessageByAttachmentKey(ctx, key); !errors.Is(err, storage.ErrMessageNotFound) {
+			t.Fatalf("GetMessageByAttachmentKey(%q): ожидалась ErrMessageNotFound, получено %v", key, err)
+		}
+	}
+
+	// Файл удалённого сообщения больше не принадлежит ни одному сообщению
+	if err := s.DeleteMessage(ctx, messageID, 1); err != nil {
+		t.Fatalf("DeleteMessage: %v", err)
+	}
+	if _, err := s.GetMessageByAttachmentKey(ctx, "files/report.pdf"); !errors.Is(err, storage.ErrMessageNotFound) {
+		t.Fatalf("GetMessageByAttachmentKey после удаления: ожидалась ErrMessageNotFound, получено %v", err)
+	}
+}
+
+func testUploadSessions(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	chatID := createChat(t, s, "группа", []int32{1, 2}, true, 1)
+	chatObjectID, _ := primitive.ObjectIDFromHex(chatID)
+	createdAt := time.Now().Truncate(time.Millisecond)
+
+	session := &storage.UploadSession{
+		ID:        primitive.NewObjectID(),
+		UserID:    1,
+		ChatID:    chatObjectID,
+		Filename:  "видео.mp4",
+		Size:      10,
+		CreatedAt: createdAt,
+		ExpiresAt: createdAt.Add(time.Hour),
+	}
+	if err := s.CreateUploadSession(ctx, session); err != nil {
+		t.Fatalf("CreateUploadSession: %v", err)
+	}
+	uploadID := session.ID.Hex()
+
+	got, err := s.GetUploadSession(ctx, uploadID)
+	if err != nil {
+		t.Fatalf("GetUploadSession: %v", err)
+	}
+	if got.UserID != 1 || got.ChatID != chatObjectID || got.Filename != "видео.mp4" || got.Size != 10 || got.Offset != 0 || len(got.Parts) != 0 || got.Complete() {
+		t.Fatalf("GetUploadSession = %+v", got)
+	}
+
+	// Части принимаются только с текущей позиции и в пределах размера файла
+	expiresAt := createdAt.Add(2 * time.Hour)
+	got, err = s.AppendUploadPart(ctx, uploadID, 0, storage.UploadPart{Key: "parts/1", Size: 6}, expiresAt)
+	if err != nil {
+		t.Fatalf("AppendUploadPart: %v", err)
+	}
+	if got.Offset != 6 || len(got.Parts) != 1 || !got.ExpiresAt.Equal(expiresAt) {
+		t.Fatalf("AppendUploadPart = %+v, ожидалась позиция 6 и срок %v", got, expiresAt)
+	}
+	if _, err := s.AppendUploadPart(ctx, uploadID, 0, storage.UploadPart{Key: "parts/2", Size: 4}, expiresAt); !errors.Is(err, storage.ErrUploadOffsetMismatch) {
+		t.Errorf("AppendUploadPart с устаревшей позицией: ожидалась ErrUploadOffsetMismatch, получено %v", err)
+	}
+	if _, err := s.AppendUploadPart(ctx, uploadID, 6, storage.UploadPart{Key: "parts/2", Size: 5}, expiresAt); !errors.Is(err, storage.ErrUploadSizeExceeded) {
+		t.Errorf("AppendUploadPart сверх размера: ожидалась ErrUploadSizeExceeded, получено %v", err)
+	}
+	got, err = s.AppendUploadPart(ctx, uploadID, 6, storage.UploadPart{Key: "parts/2", Size: 4}, expiresAt)
+	if err != nil {
+		t.Fatalf("AppendUploadPart: %v", err)
+	}
+	wantParts := []storage.UploadPart{{Key: "parts/1", Size: 6}, {Key: "parts/2", Size: 4}}
+	if got.Offset != 10 || !got.Complete() || len(got.Parts) != 2 || got.Parts[0] != wantParts[0] || got.Parts[1] != wantParts[1] {
+		t.Fatalf("AppendUploadPart = %+v, ожидались части %+v", got, wantParts)
+	}
+
+	// Брошенный сеанс удаляется очисткой вместе со сведениями о частях
+	abandoned := &storage.UploadSession{
+		ID:        primitive.NewObjectID(),
+		UserID:    2,
+		ChatID:    chatObjectID,
+		Size:      100,
+		CreatedAt: createdAt,
+		ExpiresAt: createdAt.Add(-time.Minute),
+	}
+	if err := s.CreateUploadSession(ctx, abandoned); err != nil {
+		t.Fatalf("CreateUploadSession: %v", err)
+	}
+	if _, err := s.AppendUploadPart(ctx, abandoned.ID.Hex(), 0, storage.UploadPart{Key: "parts/3", Size: 1}, abandoned.ExpiresAt); err != nil {
+		t.Fatalf("AppendUploadPart: %v", err)
+	}
+	purged, err := s.PurgeUploadSessions(ctx, createdAt)
+	if err != nil {
+		t.Fatalf("PurgeUploadSessions: %v", err)
+	}
+	if len(purged) != 1 || purged[0].ID != abandoned.ID || len(purged[0].Parts) != 1 || purged[0].Parts[0].Key != "parts/3" {
+		t.Fatalf("PurgeUploadSessions = %+v, ожидался только брошенный сеанс", purged)
+	}
+	if _, err := s.GetUploadSession(ctx, abandoned.ID.Hex()); !errors.Is(err, storage.ErrUploadNotFound) {
+		t.Errorf("GetUploadSession после очистки: ожидалась ErrUploadNotFound, получено %v", err)
+	}
+
+	deleted, err := s.DeleteUploadSession(ctx, uploadID)
+	if err != nil {
+		t.Fatalf("DeleteUploadSession: %v", err)
+	}
+	if len(deleted.Parts) != 2 {
+		t.Errorf("DeleteUploadSession вернул части %+v, ожидались %+v", deleted.Parts, wantParts)
+	}
+	if _, err := s.DeleteUploadSession(ctx, uploadID); !errors.Is(err, storage.ErrUploadNotFound) {
+		t.Errorf("повторный DeleteUploadSession: ожидалась ErrUploadNotFound, получено %v", err)
+	}
+	if _, err := s.AppendUploadPart(ctx, uploadID, 10, storage.UploadPart{Key: "parts/4", Size: 0}, expiresAt); !errors.Is(err, storage.ErrUploadNotFound) {
+		t.Errorf("AppendUploadPart удалённого сеанса: ожидалась ErrUploadNotFound, получено %v", err)
+	}
+
+	for _, id := range []string{"", "плохой"} {
+		if _, err := s.GetUploadSession(ctx, id); !errors.Is(err, storage.ErrInvalidUploadID) {
+			t.Errorf("GetUploadSession(%q): ожидалась ErrInvalidUploadID, получено %v", id, err)
+		}
+	}
+	if _, err := s.GetUploadSession(ctx, missingMessageID); !errors.Is(err, storage.ErrUploadNotFound) {
+		t.Errorf("GetUploadSession несуществующего сеанса: ожидалась ErrUploadNotFound, получено %v", err)
+	}
+}
+
+func testChatTrash(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	chatID := createChat(t, s, "удаляемый", []int32{1, 2}, true, 1)
+	otherID := createChat(t, s, "остающийся", []int32{1, 2}, true, 1)
+
+	rootID := saveMessage(t, s, chatID, 1, "корневое")
+	replyID := saveReply(t, s, chatID, 2, "ответ", rootID)
+	tombstoneID := saveMessage(t, s, otherID, 1, "будет удалено")
+
+	if err := s.DeleteChat(ctx, chatID); err != nil {
+		t.Fatalf("DeleteChat: %v", err)
+	}
+	if err := s.DeleteChat(ctx, chatID); !errors.Is(err, storage.ErrChatNotFound) {
+		t.Errorf("повторный DeleteChat: ожидалась ErrChatNotFound, получено %v", err)
+	}
+
+	// Удалённый чат виден только в корзине
+	if _, err := s.GetChatByID(ctx, chatID); !errors.Is(err, storage.ErrChatNotFound) {
+		t.Errorf("GetChatByID удалённого чата: ожидалась ErrChatNotFound, получено %v", err)
+	}
+	deleted, err := s.GetDeletedChat(ctx, chatID)
+	if err != nil {
+		t.Fatalf("GetDeletedChat: %v", err)
+	}
+	if deleted.DeletedAt == nil || deleted.Name != "удаляемый" {
+		t.Errorf("удалённый чат: DeletedAt = %v, Name = %q", deleted.DeletedAt, deleted.Name)
+	}
+	if _, err := s.GetDeletedChat(ctx, otherID); !errors.Is(err, storage.ErrChatNotFound) {
+		t.Errorf("GetDeletedChat существующего чата: ожидалась ErrChatNotFound, получено %v", err)
+	}
+	for _, c := range []struct {
+		userID int32
+		want   []string
+	}{
+		{1, []string{chatID}},
+		{3, nil},
+	} {
+		chats, err := s.GetDeletedChats(ctx, c.userID)
+		if err != nil {
+			t.Fatalf("GetDeletedChats(%d): %v", c.userID, err)
+		}
+		assertChatIDs(t, chats, c.want...)
+	}
+
+	// Восстановленный чат возвращается вместе с историей и ветками
+	if err := s.RestoreChat(ctx, chatID); err != nil {
+		t.Fatalf("RestoreChat: %v", err)
+	}
+	if err := s.RestoreChat(ctx, chatID); !errors.Is(err, storage.ErrChatNotFound) {
+		t.Errorf("повторный RestoreChat: ожидалась ErrChatNotFound, получено %v", err)
+	}
+	if chat := getChat(t, s, chatID); chat.DeletedAt != nil {
+		t.Errorf("DeletedAt восстановленного чата = %v", chat.DeletedAt)
+	}
+	assertMessageIDs(t, messages(t, s, chatID), rootID, replyID)
+	thread, err := s.GetThreadMessages(ctx, rootID, storage.MessagePageQuery{})
+	if err != nil {
+		t.Fatalf("GetThreadMessages: %v", err)
+	}
+	assertMessageIDs(t, thread.Messages, replyID)
+	if chats, err := s.GetDeletedChats(ctx, 1); err != nil || len(chats) != 0 {
+		t.Errorf("GetDeletedChats после восстановления: %d чатов, ошибка %v", len(chats), err)
+	}
+
+	// Аватар и файлы сообщений хранятся вне базы, очистка возвращает их вызывающему
+	if err := s.SetChatAvatar(ctx, chatID, "/avatar.png"); err != nil {
+		t.Fatalf("SetChatAvatar: %v", err)
+	}
+	if _, err := s.SaveMessage(ctx, storage.NewMessage{
+		ChatID:     chatID,
+		SenderID:   1,
+		Type:       storage.MessageTypeFile,
+		Attachment: &storage.Attachment{Key: "files/purged.txt", Filename: "purged.txt", ContentType: "text/plain", Size: 1},
+	}); err != nil {
+		t.Fatalf("SaveMessage с файлом: %v", err)
+	}
+
+	// Очистка не трогает то, что удалено позже заданного момента
+	if err := s.DeleteChat(ctx, chatID); err != nil {
+		t.Fatalf("DeleteChat: %v", err)
+	}
+	if err := s.DeleteMessage(ctx, tombstoneID, 1); err != nil {
+		t.Fatalf("DeleteMessage: %v", err)
+	}
+	if purged, err := s.PurgeDeleted(ctx, time.Now().Add(-time.Hour)); err != nil || len(purged.Chats) != 0 || len(purged.Attachments) != 0 {
+		t.Fatalf("PurgeDeleted до истечения срока = %+v, %v", purged, err)
+	}
+	getDeleted := func() error {
+		_, err := s.GetDeletedChat(ctx, chatID)
+		return err
+	}
+	if err := getDeleted(); err != nil {
+		t.Errorf("GetDeletedChat до истечения срока: %v", err)
+	}
+
+	purged, err := s.PurgeDeleted(ctx, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("PurgeDeleted: %v", err)
+	}
+	if len(purged.Chats) != 1 || purged.Chats[0].ID.Hex() != chatID || purged.Chats[0].Avatar != "/avatar.png" {
+		t.Errorf("очищенные чаты = %+v, ожидался %s с аватаром", purged.Chats, chatID)
+	}
+	if len(purged.Attachments) != 1 || purged.Attachments[0].Key != "files/purged.txt" {
+		t.Errorf("файлы очищенных чатов = %+v, ожидался files/purged.txt", purged.Attachments)
+	}
+	if err := getDeleted(); !errors.Is(err, storage.ErrChatNotFound) {
+		t.Errorf("GetDeletedChat после очистки: ожидалась ErrChatNotFound, получено %v", err)
+	}
+	if err := s.RestoreChat(ctx, chatID); !errors.Is(err, storage.ErrChatNotFound) {
+		t.Errorf("RestoreChat после очистки: ожидалась ErrChatNotFound, получено %v", err)
+	}
+
+	// Вместе с надгробиями очищаются записи о выходе из чатов
+	page, err := s.GetMessageChanges(ctx, []primitive.ObjectID{getChat(t, s, otherID).ID}, storage.ChangeCursor{}, 0)
+	if err != nil {
+		t.Fatalf("GetMessageChanges: %v", err)
+	}
+	assertMessageIDs(t, page.Messages)
+	removals, err := s.GetChatRemovals(ctx, 1, time.Time{})
+	if err != nil {
+		t.Fatalf("GetChatRemovals: %v", err)
+	}
+	if len(removals) != 0 {
+		t.Errorf("после очистки осталось %d записей о выходе из чатов", len(removals))
+	}
+}
+
+func testDeleteChatCascade(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	chatID := createChat(t, s, "удаляемый", []int32{1, 2}, true, 1)
+	otherID := createChat(t, s, "остающийся", []int32{1, 2}, true, 1)
+
+	first := saveMessage(t, s, chatID, 1, "первое")
+	second := saveMessage(t, s, chatID, 2, "второе")
+	kept := saveMessage(t, s, otherID, 1, "остаётся")
+
+	if err := s.DeleteChat(ctx, chatID); err != nil {
+		t.Fatalf("DeleteChat: %v", err)
+	}
+
+	// Сообщения удалённого чата не выдаются, пока он в корзине
+	for _, messageID := range []string{first, second} {
+		if _, err := s.GetMessageByID(ctx, messageID); !errors.Is(err, storage.ErrMessageNotFound) {
+			t.Errorf("GetMessageByID(%s) после DeleteChat: ожидалась ErrMessageNotFound, получено %v", messageID, err)
+		}
+	}
+	if got := messages(t, s, chatID); len(got) != 0 {
+		t.Errorf("после DeleteChat в истории осталось %d сообщений", len(got))
+	}
+	assertChatIDs(t, userChats(t, s, 1), otherID)
+
+	// Сообщения других чатов не затрагиваются
+	getMessage(t, s, kept)
+	assertMessageIDs(t, messages(t, s, otherID), kept)
+}
@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"bytes"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Синхронизация офлайн-клиентов строится на времени изменения документов:
+// каждое изменение сообщения или чата проставляет ему ChangedAt. Удалённые
+// сообщения остаются в хранилище надгробиями (DeletedAt), а выход пользователя
+// из чата и удаление чата записываются как ChatRemoval - иначе клиент не узнал бы
+// об удалениях. Рост счётчика просмотров изменением сообщения не считается.
+
+// Размер порции изменённых сообщений по умолчанию и максимальный
+const (
+	DefaultChangesLimit int64 = 500
+	MaxChangesLimit     int64 = 1000
+)
+
+// ChangeCursor - позиция в потоке изменений сообщений: время изменения и ID
+// последнего полученного сообщения. Нулевой курсор - начало потока.
+type ChangeCursor struct {
+	ChangedAt time.Time
+	MessageID primitive.ObjectID
+}
+
+// ChangePage - порция изменённых сообщений от давних изменений к недавним,
+// включая надгробия удалённых. HasMore означает, что изменения есть и после порции.
+type ChangePage struct {
+	Messages []*Message
+	HasMore  bool
+}
+
+// ChatRemoval - запись о том, что пользователь перестал быть участником чата:
+// вышел, был исключён или чат был удалён
+type ChatRemoval struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	ChatID    primitive.ObjectID `bson:"chat_id"`
+	UserID    int32              `bson:"user_id"`
+	RemovedAt time.Time          `bson:"removed_at"`
+}
+
+// Deleted сообщает, что сообщение удалено и от него осталось только надгробие
+func (m *Message) Deleted() bool {
+	return m.DeletedAt != nil
+}
+
+// precedes сообщает, что изменение сообщения идёт в потоке после позиции курсора
+func (c ChangeCursor) precedes(message *Message) bool {
+	if !message.ChangedAt.Equal(c.ChangedAt) {
+		return message.ChangedAt.After(c.ChangedAt)
+	}
+	return bytes.Compare(message.ID[:], c.MessageID[:]) > 0
+}
+
+// changesLimit возвращает размер порции с учётом значения по умолчанию и ограничения
+func changesLimit(limit int64) int64 {
+	switch {
+	case limit <= 0:
+		return DefaultChangesLimit
+	case limit > MaxChangesLimit:
+		return MaxChangesLimit
+	}
+	return limit
+}
@@ -0,0 +1,38 @@
+package storage
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Большой файл загружается частями в рамках сеанса загрузки: каждая часть
+// сохраняется в хранилище файлов отдельным объектом, а сеанс учитывает принятые
+// части по порядку. Прерванную загрузку клиент продолжает с Offset. Части
+// дописываются только с текущей позиции, поэтому параллельные запросы одного
+// клиента не перемешивают содержимое файла.
+
+// UploadSession - сеанс докачиваемой загрузки файла в чат
+type UploadSession struct {
+	ID              primitive.ObjectID `bson:"_id"`
+	UserID          int32              `bson:"user_id"`
+	ChatID          primitive.ObjectID `bson:"chat_id"`
+	Filename        string             `bson:"filename"`
+	Size            int64              `bson:"size"`   // Полный размер файла в байтах
+	Offset          int64              `bson:"offset"` // Сколько байт уже принято
+	Parts           []UploadPart       `bson:"parts"`
+	ClientMessageID string             `bson:"client_message_id,omitempty"` // Ключ идемпотентности итогового сообщения
+	CreatedAt       time.Time          `bson:"created_at"`
+	ExpiresAt       time.Time          `bson:"expires_at"` // После этого времени незавершённый сеанс удаляется
+}
+
+// UploadPart - принятая часть файла, сохранённая в хранилище файлов
+type UploadPart struct {
+	Key  string `bson:"key"`
+	Size int64  `bson:"size"`
+}
+
+// Complete сообщает, что файл принят целиком
+func (u *UploadSession) Complete() bool {
+	return u.Offset >= u.Size
+}